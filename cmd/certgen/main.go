@@ -0,0 +1,71 @@
+// Command certgen creates a local CA with a server certificate for the OCPP
+// port and client certificates for charge points, to try out OCPP security
+// profiles 2 and 3 without a real PKI:
+//
+//	go run ./cmd/certgen -out certs -hosts localhost,127.0.0.1 -clients CP001,CP002
+//
+// Point OCPP_TLS_CERT_FILE/OCPP_TLS_KEY_FILE at server.pem/server-key.pem and
+// OCPP_TLS_CLIENT_CA_FILE at ca.pem.
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/pki"
+)
+
+func main() {
+	out := flag.String("out", "certs", "output directory")
+	hosts := flag.String("hosts", "localhost,127.0.0.1", "comma separated host names and IPs of the OCPP server")
+	clients := flag.String("clients", "", "comma separated charge point identities to issue client certificates for")
+	validity := flag.Duration("validity", 365*24*time.Hour, "certificate validity")
+	flag.Parse()
+
+	log := logrus.New()
+	if err := os.MkdirAll(*out, 0o700); err != nil {
+		log.Fatal(err)
+	}
+
+	ca, err := pki.NewCA("gocsms local CA", *validity)
+	if err != nil {
+		log.Fatal("Failed to create CA: ", err)
+	}
+	caKey, err := ca.KeyPEM()
+	if err != nil {
+		log.Fatal(err)
+	}
+	write(log, *out, "ca", ca.CertPEM(), caKey)
+
+	cert, key, err := ca.IssueServer(strings.Split(*hosts, ","), *validity)
+	if err != nil {
+		log.Fatal("Failed to issue server certificate: ", err)
+	}
+	write(log, *out, "server", cert, key)
+
+	for _, identity := range strings.Split(*clients, ",") {
+		if identity == "" {
+			continue
+		}
+		cert, key, err := ca.IssueClient(identity, *validity)
+		if err != nil {
+			log.Fatalf("Failed to issue client certificate for %s: %v", identity, err)
+		}
+		write(log, *out, identity, cert, key)
+	}
+}
+
+func write(log *logrus.Logger, dir, name string, cert, key []byte) {
+	if err := os.WriteFile(filepath.Join(dir, name+".pem"), cert, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name+"-key.pem"), key, 0o600); err != nil {
+		log.Fatal(err)
+	}
+	log.Infof("Wrote %s.pem and %s-key.pem", name, name)
+}
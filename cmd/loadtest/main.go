@@ -0,0 +1,125 @@
+// Command loadtest ramps up a fleet of virtual OCPP 1.6 charge points against
+// the CSMS and reports how it copes: WebSocket handshake times, CALL to
+// CALLRESULT latency percentiles per action, throughput and error rates.
+//
+//	go run ./cmd/loadtest -url ws://localhost:9000/ocpp -count 1000 -ramp-up 5m -duration 15m -json report.json
+//
+// The chargers run a simulator scenario, tests/loadtest/scenario.yaml by
+// default. The CSMS must accept their boot notifications and the id tags
+// of their sessions, or the report shows mostly rejections.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/loadtest"
+	"github.com/mutoulbj/gocsms/internal/simulator"
+)
+
+func main() {
+	scenario := flag.String("scenario", "tests/loadtest/scenario.yaml", "scenario file")
+	url := flag.String("url", "", "OCPP endpoint, overrides the url of the scenario")
+	count := flag.Int("count", 0, "number of chargers, overrides the count of the scenario")
+	rampUp := flag.Duration("ramp-up", 0, "period over which the chargers connect, overrides the rampUp of the scenario")
+	duration := flag.Duration("duration", 0, "stop the test after this long, by default it runs until the scenario ends")
+	progress := flag.Duration("progress", 10*time.Second, "interval of the progress log, none when 0")
+	jsonOut := flag.String("json", "", "also write the report as JSON to this file")
+	verbose := flag.Bool("v", false, "log what every charger does")
+	flag.Parse()
+
+	log := logrus.New()
+	chargerLog := logrus.New()
+	if !*verbose {
+		chargerLog.SetLevel(logrus.WarnLevel)
+	}
+
+	sc, err := simulator.LoadScenario(*scenario)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *url != "" {
+		sc.URL = *url
+	}
+	if *count > 0 {
+		sc.Chargers.Count = *count
+	}
+	if *rampUp > 0 {
+		sc.Chargers.RampUp = *rampUp
+	}
+	recorder := loadtest.NewRecorder()
+	sc.Observer = recorder
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer time.AfterFunc(*duration, cancel).Stop()
+	}
+	if *progress > 0 {
+		go logProgress(ctx, log, recorder, *progress)
+	}
+
+	log.Infof("Ramping up %d chargers over %s against %s", sc.Chargers.Count, sc.Chargers.RampUp, sc.URL)
+	failed := failedChargers(sc.Run(ctx, sc.NewChargers(chargerLog)))
+	report := recorder.Report(sc.Chargers.Count, failed)
+
+	if err := report.WriteText(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+	if *jsonOut != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(*jsonOut, data, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
+
+// logProgress logs the open connections and the CALL rate until the test ends
+func logProgress(ctx context.Context, log *logrus.Logger, recorder *loadtest.Recorder, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var last int
+	for {
+		select {
+		case <-ticker.C:
+			connected, calls, failed := recorder.Progress()
+			log.Infof("%d connected, %d CALLs (%.1f/s), %d failed",
+				connected, calls, float64(calls-last)/interval.Seconds(), failed)
+			last = calls
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// failedChargers counts the chargers whose scenario failed, not counting the
+// ones stopped because the test ended
+func failedChargers(err error) int {
+	if err == nil {
+		return 0
+	}
+	errs := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		errs = joined.Unwrap()
+	}
+	var failed int
+	for _, err := range errs {
+		if !errors.Is(err, context.Canceled) {
+			failed++
+		}
+	}
+	return failed
+}
@@ -0,0 +1,266 @@
+// Command replay feeds the CALLs a charge point sent, as recorded in the OCPP
+// message journal, through the OCPP handler again and compares the replies
+// with the recorded ones, to reproduce and debug how a session was handled:
+//
+//	go run ./cmd/replay -identity CP001 -from 2025-07-08T10:00:00Z -to 2025-07-08T11:00:00Z
+//
+// Messages are read from the journal of the configured database, or from a
+// file holding the JSON returned by GET /api/v1/chargepoints/:id/messages.
+// The handler runs against the configured database and Redis, so replaying
+// transactions and status changes writes them again. Without -write the
+// command only lists the CALLs it would replay with their recorded replies;
+// point DB_* and REDIS_* at a scratch environment before passing -write.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/joho/godotenv"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/ocpp"
+	"github.com/mutoulbj/gocsms/internal/repository"
+	"github.com/mutoulbj/gocsms/internal/services"
+	"github.com/mutoulbj/gocsms/pkg/cache"
+	"github.com/mutoulbj/gocsms/pkg/db"
+)
+
+func main() {
+	identity := flag.String("identity", "", "OCPP identity of the charge point whose session is replayed")
+	from := flag.String("from", "", "start of the session (RFC3339)")
+	to := flag.String("to", "", "end of the session (RFC3339)")
+	file := flag.String("file", "", "read the messages from a JSON file exported from the messages API instead of the journal")
+	as := flag.String("as", "", "identity to replay the CALLs as, defaults to the recorded identity")
+	version := flag.String("version", "", "OCPP version to replay with, ocpp1.6 or ocpp2.0.1; defaults to the version of the charge point")
+	verbose := flag.Bool("v", false, "print the recorded and replayed frames of every CALL")
+	write := flag.Bool("write", false, "replay the CALLs through the handler, writing to the configured database and Redis")
+	flag.Parse()
+
+	if err := godotenv.Load(); err != nil {
+		logrus.Warn("Error loading .env file, using default env vars")
+	}
+	log := logrus.New()
+	log.SetLevel(logrus.WarnLevel)
+	cfg := config.NewConfig()
+
+	ctx := context.Background()
+	var messages []*models.OCPPMessage
+	var database *db.DB
+	var err error
+	if *file != "" {
+		messages, err = readMessages(*file)
+	} else if database, err = db.NewDB(&cfg.Database); err == nil {
+		defer database.Close()
+		messages, err = journalMessages(ctx, repository.NewOCPPMessageRepository(database.DB, log), *identity, *from, *to)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	slices.SortStableFunc(messages, func(a, b *models.OCPPMessage) int {
+		return a.Timestamp.Compare(b.Timestamp)
+	})
+	replies := recordedReplies(messages)
+
+	if !*write {
+		var calls int
+		for _, msg := range messages {
+			if msg.Direction != enums.MessageDirectionInbound || msg.MessageType != int(ocpp.Call) {
+				continue
+			}
+			calls++
+			fmt.Printf("%s %-30s %-36s %s\n", msg.Timestamp.Format(time.RFC3339Nano), msg.Action, msg.UniqueID, outcome(replies[msg]))
+			if *verbose {
+				fmt.Printf("  call:     %s\n", msg.Payload)
+				if recorded := replies[msg]; recorded != nil {
+					fmt.Printf("  recorded: %s\n", recorded.Payload)
+				}
+			}
+		}
+		fmt.Printf("Dry run: %d CALLs to replay, pass -write to replay them against the configured database and Redis\n", calls)
+		return
+	}
+
+	if database == nil {
+		if database, err = db.NewDB(&cfg.Database); err != nil {
+			log.Fatal(err)
+		}
+		defer database.Close()
+	}
+	redisCache, err := cache.NewRedisCache(&cfg.Redis)
+	if err != nil {
+		log.Fatal(err)
+	}
+	bunDB, rdb := database.DB, redisCache.Client()
+
+	cpSvc := services.NewChargePointService(
+		repository.NewChargePointRepository(bunDB, rdb, log),
+		repository.NewChargePointConnectionRepository(bunDB, log),
+		repository.NewConnectorRepository(bunDB, log),
+		log,
+	)
+	mvSvc := services.NewMeterValueService(repository.NewMeterValueRepository(bunDB, log), &cfg.OCPP, log)
+	mvSvc.Start()
+	defer mvSvc.Stop()
+	validator, err := ocpp.NewSchemaValidator()
+	if err != nil {
+		log.Fatal(err)
+	}
+	handler := ocpp.GocsmsOCPPHandler(
+		&cfg.OCPP,
+		validator,
+		cpSvc,
+		services.NewTransactionService(repository.NewTransactionRepository(bunDB, log), log),
+		mvSvc,
+		services.NewChargePointConfigurationService(repository.NewChargePointConfigurationRepository(bunDB, log), log),
+		services.NewSecurityEventService(repository.NewSecurityEventRepository(bunDB, log), &cfg.OCPP, log),
+		services.NewReservationService(repository.NewReservationRepository(bunDB, log), log),
+		log,
+	)
+
+	var replayed, differing int
+	for _, msg := range messages {
+		if msg.Direction != enums.MessageDirectionInbound || msg.MessageType != int(ocpp.Call) {
+			continue
+		}
+		replayAs := *as
+		if replayAs == "" {
+			replayAs = msg.Identity
+		}
+		protocol := protocolVersion(ctx, cpSvc, replayAs, *version)
+
+		resp, err := handler.HandleMessage(ctx, protocol, replayAs, []byte(msg.Payload))
+		if err != nil {
+			log.Fatalf("Failed to replay %s %s: %v", msg.Action, msg.UniqueID, err)
+		}
+		replayed++
+
+		recorded := replies[msg]
+		result := compare(recorded, resp)
+		if result != "same" {
+			differing++
+		}
+		fmt.Printf("%s %-30s %-36s %s\n", msg.Timestamp.Format(time.RFC3339Nano), msg.Action, msg.UniqueID, result)
+		if *verbose || result != "same" {
+			fmt.Printf("  call:     %s\n", msg.Payload)
+			if recorded != nil {
+				fmt.Printf("  recorded: %s\n", recorded.Payload)
+			}
+			fmt.Printf("  replayed: %s\n", resp)
+		}
+	}
+	fmt.Printf("Replayed %d CALLs, %d with a different outcome\n", replayed, differing)
+}
+
+// recordedReplies pairs every CALL of a charge point with the reply recorded for
+// it. uniqueIds are only unique within a connection and charge points reuse them
+// after a reboot, so a reply answers the last CALL with its uniqueId that is
+// still waiting for one, and a BootNotification starts a new session.
+func recordedReplies(messages []*models.OCPPMessage) map[*models.OCPPMessage]*models.OCPPMessage {
+	type callKey struct{ identity, uniqueID string }
+	replies := make(map[*models.OCPPMessage]*models.OCPPMessage)
+	pending := make(map[callKey]*models.OCPPMessage)
+	for _, msg := range messages {
+		key := callKey{msg.Identity, msg.UniqueID}
+		switch {
+		case msg.Direction == enums.MessageDirectionInbound && msg.MessageType == int(ocpp.Call):
+			if msg.Action == "BootNotification" {
+				maps.DeleteFunc(pending, func(k callKey, _ *models.OCPPMessage) bool { return k.identity == msg.Identity })
+			}
+			pending[key] = msg
+		case msg.Direction == enums.MessageDirectionOutbound && msg.MessageType != int(ocpp.Call):
+			if call, ok := pending[key]; ok {
+				replies[call] = msg
+				delete(pending, key)
+			}
+		}
+	}
+	return replies
+}
+
+// journalMessages loads the messages exchanged with a charge point during a session from the journal
+func journalMessages(ctx context.Context, repo *repository.OCPPMessageRepository, identity, from, to string) ([]*models.OCPPMessage, error) {
+	if identity == "" {
+		return nil, fmt.Errorf("-identity or -file is required")
+	}
+	filter := repository.OCPPMessageFilter{Identity: identity, Ascending: true}
+	var err error
+	if from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return nil, fmt.Errorf("invalid -from: %w", err)
+		}
+	}
+	if to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return nil, fmt.Errorf("invalid -to: %w", err)
+		}
+	}
+	return repo.List(ctx, filter)
+}
+
+// readMessages loads messages exported from the messages API
+func readMessages(name string) ([]*models.OCPPMessage, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	var messages []*models.OCPPMessage
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return nil, fmt.Errorf("invalid messages file: %w", err)
+	}
+	return messages, nil
+}
+
+// protocolVersion returns the version forced on the command line, or else the one the charge point uses
+func protocolVersion(ctx context.Context, svc *services.ChargePointService, identity, forced string) ocpp.ProtocolVersion {
+	if forced != "" {
+		return ocpp.ProtocolVersion(forced)
+	}
+	if cp, err := svc.GetByCode(ctx, identity); err == nil && cp != nil && cp.OcppVersion == ocpp.OCPP201.Version() {
+		return ocpp.OCPP201
+	}
+	return ocpp.OCPP16
+}
+
+// outcome describes a recorded reply
+func outcome(reply *models.OCPPMessage) string {
+	if reply == nil {
+		return "no recorded reply"
+	}
+	var frame ocpp.OCPPMessage
+	if err := json.Unmarshal([]byte(reply.Payload), &frame); err != nil {
+		return "invalid recorded reply"
+	}
+	if frame.MessageTypeID == ocpp.CallError {
+		return "recorded " + frame.ErrorCode
+	}
+	return "recorded CALLRESULT"
+}
+
+// compare tells whether the replayed reply has the outcome of the recorded one:
+// both CALLRESULTs, or CALLERRORs with the same error code
+func compare(recorded *models.OCPPMessage, replayed []byte) string {
+	if recorded == nil {
+		return "no recorded reply"
+	}
+	var before, after ocpp.OCPPMessage
+	if err := json.Unmarshal([]byte(recorded.Payload), &before); err != nil {
+		return "invalid recorded reply"
+	}
+	if err := json.Unmarshal(replayed, &after); err != nil {
+		return "invalid replayed reply"
+	}
+	if before.MessageTypeID != after.MessageTypeID || before.ErrorCode != after.ErrorCode {
+		return "different"
+	}
+	return "same"
+}
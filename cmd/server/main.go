@@ -37,6 +37,7 @@ func main() {
 			config.ProvideServerConfig,
 			config.ProvideRedisConfig,
 			config.ProvideJWTConfig,
+			config.ProvideOCPPConfig,
 			// provide fiber app
 			gocsmsLogger,
 			gocsmsFiberApp,
@@ -50,8 +51,19 @@ func main() {
 			response.NewAPIResponse,
 			// charge point related providers
 			repository.NewChargePointRepository,
+			repository.NewChargePointConnectionRepository,
+			repository.NewConnectorRepository,
 			services.NewChargePointService,
 			handlers.NewChargePointHandler,
+			// transaction related providers
+			repository.NewTransactionRepository,
+			services.NewTransactionService,
+			// meter value related providers
+			repository.NewMeterValueRepository,
+			services.NewMeterValueService,
+			// ocpp message journal providers
+			repository.NewOCPPMessageRepository,
+			services.NewMessageJournalService,
 			// auth related providers
 			repository.NewUserRepository,
 			services.NewAuthService,
@@ -63,8 +75,47 @@ func main() {
 			// user related providers
 			services.NewUserService,
 			handlers.NewUserHandler,
+			// charge point configuration related providers
+			repository.NewChargePointConfigurationRepository,
+			services.NewChargePointConfigurationService,
+			repository.NewConfigurationTemplateRepository,
+			repository.NewConfigurationDriftRepository,
+			services.NewConfigurationTemplateService,
+			handlers.NewConfigurationTemplateHandler,
+			// security event related providers
+			repository.NewSecurityEventRepository,
+			services.NewSecurityEventService,
+			handlers.NewSecurityEventHandler,
+			// firmware related providers
+			repository.NewFirmwareArtifactRepository,
+			repository.NewFirmwareCampaignRepository,
+			services.NewFirmwareService,
+			handlers.NewFirmwareHandler,
+			// diagnostics related providers
+			repository.NewDiagnosticsRepository,
+			services.NewDiagnosticsService,
+			handlers.NewDiagnosticsHandler,
+			// maintenance window related providers
+			repository.NewMaintenanceWindowRepository,
+			services.NewMaintenanceService,
+			handlers.NewMaintenanceHandler,
+			// reservation related providers
+			repository.NewReservationRepository,
+			services.NewReservationService,
+			handlers.NewReservationHandler,
 			// ocpp server for charge point
+			ocpp.NewSchemaValidator,
+			ocpp.NewRegistry,
 			ocpp.NewOCPPServer,
+			ocpp.NewConfigurationManager,
+			ocpp.NewCertificateSigner,
+			ocpp.NewCertificateManager,
+			ocpp.NewOfflineWatchdog,
+			ocpp.NewFirmwareManager,
+			ocpp.NewDiagnosticsManager,
+			ocpp.NewStateRefresher,
+			ocpp.NewMaintenanceScheduler,
+			ocpp.NewReservationManager,
 		),
 		fx.Invoke(setupApplication),
 	)
@@ -78,8 +129,11 @@ func gocsmsLogger() *logrus.Logger {
 	return logger
 }
 
-func gocsmsFiberApp() *fiber.App {
+func gocsmsFiberApp(cfg *config.ServerConfig) *fiber.App {
 	app := fiber.New(fiber.Config{
+		// bodies are limited by middleware.BodyLimit, per route
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			// Log the detailed error for debugging
 			fields := logrus.Fields{
@@ -118,12 +172,28 @@ func setupApplication(
 	chargePointHandler *handlers.ChargePointHandler,
 	organizationHandler *handlers.OrganizationHandler,
 	userHandler *handlers.UserHandler,
+	configurationTemplateHandler *handlers.ConfigurationTemplateHandler,
+	securityEventHandler *handlers.SecurityEventHandler,
+	firmwareHandler *handlers.FirmwareHandler,
+	diagnosticsHandler *handlers.DiagnosticsHandler,
+	maintenanceHandler *handlers.MaintenanceHandler,
+	reservationHandler *handlers.ReservationHandler,
 	authSvc *services.AuthService,
 	redis *redis.Client,
+	meterValueSvc *services.MeterValueService,
+	messageJournalSvc *services.MessageJournalService,
 	ocppServer *ocpp.Server,
+	configurationMgr *ocpp.ConfigurationManager,
+	offlineWatchdog *ocpp.OfflineWatchdog,
+	firmwareMgr *ocpp.FirmwareManager,
+	diagnosticsMgr *ocpp.DiagnosticsManager,
+	stateRefresher *ocpp.StateRefresher,
+	maintenanceScheduler *ocpp.MaintenanceScheduler,
+	reservationMgr *ocpp.ReservationManager,
 ) {
 	// setup middleware
 	app.Use(middleware.Logger(logger))
+	app.Use(middleware.BodyLimit(cfg.BodyLimit, "/api/v1/firmware/artifacts", "/api/v1/uploads/"))
 	app.Use(middleware.Cache())
 
 	// setup routes
@@ -131,6 +201,12 @@ func setupApplication(
 	chargePointHandler.RegisterRoutes(v1)
 	organizationHandler.RegisterRoutes(v1)
 	userHandler.RegisterRoutes(v1)
+	configurationTemplateHandler.RegisterRoutes(v1)
+	securityEventHandler.RegisterRoutes(v1)
+	firmwareHandler.RegisterRoutes(v1)
+	diagnosticsHandler.RegisterRoutes(v1)
+	maintenanceHandler.RegisterRoutes(v1)
+	reservationHandler.RegisterRoutes(v1)
 
 	// start fiber server
 	lc.Append(fx.Hook{
@@ -147,6 +223,30 @@ func setupApplication(
 		},
 	})
 
+	// start meter value batch writer
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			meterValueSvc.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			meterValueSvc.Stop()
+			return nil
+		},
+	})
+
+	// start ocpp message journal writer
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			messageJournalSvc.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			messageJournalSvc.Stop()
+			return nil
+		},
+	})
+
 	// start ocpp server
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
@@ -159,6 +259,86 @@ func setupApplication(
 		},
 	})
 
+	// start configuration drift detection
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			configurationMgr.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			configurationMgr.Stop()
+			return nil
+		},
+	})
+
+	// start offline detection
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			offlineWatchdog.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			offlineWatchdog.Stop()
+			return nil
+		},
+	})
+
+	// start firmware campaigns
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			firmwareMgr.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			firmwareMgr.Stop()
+			return nil
+		},
+	})
+
+	// start diagnostics upload tracking
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			diagnosticsMgr.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			diagnosticsMgr.Stop()
+			return nil
+		},
+	})
+
+	// cancel pending status refreshes of reconnected charge points
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			stateRefresher.Stop()
+			return nil
+		},
+	})
+
+	// start the maintenance window scheduler
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			maintenanceScheduler.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			maintenanceScheduler.Stop()
+			return nil
+		},
+	})
+
+	// start releasing expired reservations
+	lc.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			reservationMgr.Start()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			reservationMgr.Stop()
+			return nil
+		},
+	})
+
 	// handle graceful shutdown
 	lc.Append(fx.Hook{
 		OnStart: func(ctx context.Context) error {
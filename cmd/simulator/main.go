@@ -0,0 +1,54 @@
+// Command simulator runs a fleet of virtual OCPP 1.6 charge points against the
+// CSMS as described by a YAML scenario, to exercise the OCPP server locally:
+//
+//	go run ./cmd/simulator -scenario tests/simulator/scenario.yaml
+//
+// The chargers connect, run the steps of the scenario and answer the calls of
+// the CSMS (remote start and stop, reset, unlock, configuration) until the
+// scenario ends or the command is interrupted. See simulator.Scenario for the
+// scenario format.
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/simulator"
+)
+
+func main() {
+	scenario := flag.String("scenario", "tests/simulator/scenario.yaml", "scenario file")
+	url := flag.String("url", "", "OCPP endpoint, overrides the url of the scenario")
+	count := flag.Int("count", 0, "number of chargers, overrides the count of the scenario")
+	quiet := flag.Bool("q", false, "only log warnings and errors")
+	flag.Parse()
+
+	log := logrus.New()
+	if *quiet {
+		log.SetLevel(logrus.WarnLevel)
+	}
+
+	sc, err := simulator.LoadScenario(*scenario)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *url != "" {
+		sc.URL = *url
+	}
+	if *count > 0 {
+		sc.Chargers.Count = *count
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Infof("Running %d chargers against %s", sc.Chargers.Count, sc.URL)
+	if err := sc.Run(ctx, sc.NewChargers(log)); err != nil && ctx.Err() == nil {
+		log.Fatal("Scenario failed: ", err)
+	}
+}
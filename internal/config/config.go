@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
@@ -14,6 +15,7 @@ type Config struct {
 	Database DatabaseConfig
 	Redis    RedisConfig
 	JWT      JWTConfig
+	OCPP     OCPPConfig
 }
 
 type ServerConfig struct {
@@ -21,6 +23,10 @@ type ServerConfig struct {
 	OCPPPort     string
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
+	// BodyLimit applies to the request bodies of all routes but the firmware
+	// and diagnostics uploads, which are allowed UploadBodyLimit (bytes)
+	BodyLimit       int
+	UploadBodyLimit int
 }
 
 type DatabaseConfig struct {
@@ -49,6 +55,111 @@ type JWTConfig struct {
 	Issuer          string
 }
 
+type OCPPConfig struct {
+	HeartbeatInterval time.Duration
+	// BootRetryInterval is sent to charge points whose BootNotification is
+	// not accepted and tells them when to try again
+	BootRetryInterval time.Duration
+	// RegistrationPolicy decides the BootNotification answer to charge points
+	// that are not provisioned: "accept" registers them, "pending" and "reject"
+	// answer without storing them
+	RegistrationPolicy string
+	// OfflineHeartbeatMultiple is the number of heartbeat intervals without a
+	// heartbeat after which a charge point is marked offline, checked every
+	// OfflineCheckInterval
+	OfflineHeartbeatMultiple   int
+	OfflineCheckInterval       time.Duration
+	CallTimeout                time.Duration
+	ConfigurationDriftInterval time.Duration
+	MeterValueQueueSize        int
+	MeterValueBatchSize        int
+	MeterValueFlushInterval    time.Duration
+	// The message journal records every OCPP frame through its own batch
+	// writer and keeps it for MessageRetention, forever when zero
+	MessageQueueSize     int
+	MessageBatchSize     int
+	MessageFlushInterval time.Duration
+	MessageRetention     time.Duration
+	// TLS serves the OCPP port over TLS (security profiles 2 and 3) when a
+	// certificate is configured; InsecurePort optionally keeps a plain
+	// WebSocket listener for charge points on profiles 0 and 1
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+	InsecurePort    string
+	// DefaultSecurityProfile applies to charge points that are not provisioned
+	DefaultSecurityProfile int
+	// CA signs the certificates charge points request with SignCertificate;
+	// without CACertFile a throwaway CA is created at startup
+	CACertFile          string
+	CAKeyFile           string
+	CertificateValidity time.Duration
+	// SecurityAlertSeverity is the severity from which security events raise an
+	// alert; SecurityEventSeverities overrides the severity of event types
+	SecurityAlertSeverity   string
+	SecurityEventSeverities map[string]string
+	// SchemaValidation is the default handling of payloads that violate the
+	// OCPP JSON schemas, "strict" or "lenient"; SchemaValidationVendors
+	// overrides it per charge point vendor
+	SchemaValidation        string
+	SchemaValidationVendors map[string]string
+	// NodeID names this server instance in the Redis connection registry that
+	// routes calls to charge points connected to other instances; registry
+	// entries expire after RegistryTTL, at least 3s, unless refreshed by their node
+	NodeID      string
+	RegistryTTL time.Duration
+	// The CSMS pings every charge point each WSPingInterval and drops it when
+	// no frame arrives within WSPongTimeout after a ping; 0 disables pings.
+	// Frames to a charge point wait in a queue of WSWriteQueueSize, and a
+	// charge point that does not take a frame within WSWriteTimeout is dropped.
+	WSPingInterval   time.Duration
+	WSPongTimeout    time.Duration
+	WSWriteTimeout   time.Duration
+	WSWriteQueueSize int
+	WSMaxMessageSize int64 // bytes, larger frames close the connection
+	WSBufferSize     int
+	// Firmware artifacts are stored in FirmwareDir and downloaded by charge
+	// points from FirmwareBaseURL, the public URL of the REST API. Campaigns
+	// advance every FirmwareCheckInterval, and an update that has not
+	// completed within FirmwareUpdateTimeout counts as failed.
+	FirmwareDir           string
+	FirmwareBaseURL       string
+	FirmwareCheckInterval time.Duration
+	FirmwareUpdateTimeout time.Duration
+	// StatusRefreshDelay after a charge point connected, the CSMS triggers a
+	// StatusNotification for each connector that has not reported its status
+	// since; 0 disables the refresh
+	StatusRefreshDelay time.Duration
+	// Diagnostics and logs requested from charge points are uploaded to the
+	// REST API at DiagnosticsBaseURL and stored in DiagnosticsDir for
+	// DiagnosticsRetention, forever when zero. An upload that has not arrived
+	// within DiagnosticsUploadTimeout counts as failed.
+	DiagnosticsDir           string
+	DiagnosticsBaseURL       string
+	DiagnosticsUploadTimeout time.Duration
+	DiagnosticsRetention     time.Duration
+}
+
+const (
+	SchemaValidationStrict  = "strict"
+	SchemaValidationLenient = "lenient"
+
+	RegistrationPolicyAccept  = "accept"
+	RegistrationPolicyPending = "pending"
+	RegistrationPolicyReject  = "reject"
+)
+
+// SchemaValidationFor returns the schema validation mode for a charge point vendor
+func (c *OCPPConfig) SchemaValidationFor(vendor string) string {
+	if mode, ok := c.SchemaValidationVendors[vendor]; ok {
+		return mode
+	}
+	return c.SchemaValidation
+}
+
+// minRegistryTTL is the shortest registry TTL; entries are refreshed every third of it
+const minRegistryTTL = 3 * time.Second
+
 func NewConfig() *Config {
 	envPaths := []string{".env", "../.env", "../../.env"}
 	envLoaded := false
@@ -65,8 +176,10 @@ func NewConfig() *Config {
 
 	return &Config{
 		Server: ServerConfig{
-			ServerPort: getEnv("SERVER_PORT", "8001"),
-			OCPPPort:   getEnv("OCPP_PORT", "8003"),
+			ServerPort:      getEnv("SERVER_PORT", "8001"),
+			OCPPPort:        getEnv("OCPP_PORT", "8003"),
+			BodyLimit:       getEnvAsInt("SERVER_BODY_LIMIT", 4<<20),
+			UploadBodyLimit: getEnvAsInt("SERVER_UPLOAD_BODY_LIMIT", 256<<20),
 		},
 		Database: DatabaseConfig{
 			Host:         getEnv("DB_HOST", "localhost"),
@@ -91,6 +204,51 @@ func NewConfig() *Config {
 			RefreshTokenTTL: getEnvDuration("JWT_REFRESH_TOKEN_TTL", 7*24*time.Hour),
 			Issuer:          getEnv("JWT_ISSUER", "gocsms"),
 		},
+		OCPP: OCPPConfig{
+			HeartbeatInterval:          getEnvDuration("OCPP_HEARTBEAT_INTERVAL", time.Minute),
+			BootRetryInterval:          getEnvDuration("OCPP_BOOT_RETRY_INTERVAL", 5*time.Minute),
+			RegistrationPolicy:         getEnv("OCPP_REGISTRATION_POLICY", RegistrationPolicyPending),
+			OfflineHeartbeatMultiple:   getEnvAsInt("OCPP_OFFLINE_HEARTBEAT_MULTIPLE", 3),
+			OfflineCheckInterval:       getEnvDuration("OCPP_OFFLINE_CHECK_INTERVAL", 30*time.Second),
+			CallTimeout:                getEnvDuration("OCPP_CALL_TIMEOUT", 30*time.Second),
+			ConfigurationDriftInterval: getEnvDuration("OCPP_CONFIGURATION_DRIFT_INTERVAL", time.Hour),
+			MeterValueQueueSize:        getEnvAsInt("OCPP_METER_VALUE_QUEUE_SIZE", 50000),
+			MeterValueBatchSize:        getEnvAsInt("OCPP_METER_VALUE_BATCH_SIZE", 1000),
+			MeterValueFlushInterval:    getEnvDuration("OCPP_METER_VALUE_FLUSH_INTERVAL", time.Second),
+			MessageQueueSize:           getEnvAsInt("OCPP_MESSAGE_QUEUE_SIZE", 50000),
+			MessageBatchSize:           getEnvAsInt("OCPP_MESSAGE_BATCH_SIZE", 1000),
+			MessageFlushInterval:       getEnvDuration("OCPP_MESSAGE_FLUSH_INTERVAL", time.Second),
+			MessageRetention:           getEnvDuration("OCPP_MESSAGE_RETENTION", 30*24*time.Hour),
+			TLSCertFile:                getEnv("OCPP_TLS_CERT_FILE", ""),
+			TLSKeyFile:                 getEnv("OCPP_TLS_KEY_FILE", ""),
+			TLSClientCAFile:            getEnv("OCPP_TLS_CLIENT_CA_FILE", ""),
+			InsecurePort:               getEnv("OCPP_INSECURE_PORT", ""),
+			DefaultSecurityProfile:     getEnvAsInt("OCPP_DEFAULT_SECURITY_PROFILE", 0),
+			CACertFile:                 getEnv("OCPP_CA_CERT_FILE", ""),
+			CAKeyFile:                  getEnv("OCPP_CA_KEY_FILE", ""),
+			CertificateValidity:        getEnvDuration("OCPP_CERTIFICATE_VALIDITY", 365*24*time.Hour),
+			SecurityAlertSeverity:      getEnv("OCPP_SECURITY_ALERT_SEVERITY", "HIGH"),
+			SecurityEventSeverities:    getEnvAsMap("OCPP_SECURITY_EVENT_SEVERITIES"),
+			SchemaValidation:           getEnv("OCPP_SCHEMA_VALIDATION", SchemaValidationStrict),
+			SchemaValidationVendors:    getEnvAsMap("OCPP_SCHEMA_VALIDATION_VENDORS"),
+			NodeID:                     getEnv("OCPP_NODE_ID", hostname()),
+			RegistryTTL:                getEnvDurationAtLeast("OCPP_REGISTRY_TTL", time.Minute, minRegistryTTL),
+			WSPingInterval:             getEnvDuration("OCPP_WS_PING_INTERVAL", 30*time.Second),
+			WSPongTimeout:              getEnvDuration("OCPP_WS_PONG_TIMEOUT", 10*time.Second),
+			WSWriteTimeout:             getEnvDuration("OCPP_WS_WRITE_TIMEOUT", 10*time.Second),
+			WSWriteQueueSize:           getEnvAsInt("OCPP_WS_WRITE_QUEUE_SIZE", 64),
+			WSMaxMessageSize:           int64(getEnvAsInt("OCPP_WS_MAX_MESSAGE_SIZE", 1<<20)),
+			WSBufferSize:               getEnvAsInt("OCPP_WS_BUFFER_SIZE", 4096),
+			FirmwareDir:                getEnv("OCPP_FIRMWARE_DIR", "data/firmware"),
+			FirmwareBaseURL:            getEnv("OCPP_FIRMWARE_BASE_URL", "http://localhost:8001/api/v1"),
+			FirmwareCheckInterval:      getEnvDuration("OCPP_FIRMWARE_CHECK_INTERVAL", 30*time.Second),
+			FirmwareUpdateTimeout:      getEnvDuration("OCPP_FIRMWARE_UPDATE_TIMEOUT", time.Hour),
+			StatusRefreshDelay:         getEnvDuration("OCPP_STATUS_REFRESH_DELAY", 30*time.Second),
+			DiagnosticsDir:             getEnv("OCPP_DIAGNOSTICS_DIR", "data/diagnostics"),
+			DiagnosticsBaseURL:         getEnv("OCPP_DIAGNOSTICS_BASE_URL", "http://localhost:8001/api/v1"),
+			DiagnosticsUploadTimeout:   getEnvDuration("OCPP_DIAGNOSTICS_UPLOAD_TIMEOUT", time.Hour),
+			DiagnosticsRetention:       getEnvDuration("OCPP_DIAGNOSTICS_RETENTION", 30*24*time.Hour),
+		},
 	}
 }
 
@@ -110,6 +268,10 @@ func ProvideJWTConfig(cfg *Config) *JWTConfig {
 	return &cfg.JWT
 }
 
+func ProvideOCPPConfig(cfg *Config) *OCPPConfig {
+	return &cfg.OCPP
+}
+
 func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	if valueStr := os.Getenv(key); valueStr != "" {
 		if d, err := time.ParseDuration(valueStr); err == nil {
@@ -119,6 +281,26 @@ func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
+// getEnvDurationAtLeast is getEnvDuration for settings that cannot go below a
+// minimum; smaller values are rejected in favour of the default
+func getEnvDurationAtLeast(key string, defaultValue, minimum time.Duration) time.Duration {
+	d := getEnvDuration(key, defaultValue)
+	if d < minimum {
+		log.Printf("Ignoring %s=%s: must be at least %s, using %s", key, d, minimum, defaultValue)
+		return defaultValue
+	}
+	return d
+}
+
+// hostname is the default node ID, unique per container or host
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "gocsms"
+	}
+	return name
+}
+
 func getEnv(key, def string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -136,3 +318,14 @@ func getEnvAsInt(key string, fallback int) int {
 	}
 	return fallback
 }
+
+// getEnvAsMap parses a comma separated list of key=value pairs
+func getEnvAsMap(key string) map[string]string {
+	m := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv(key), ",") {
+		if k, v, ok := strings.Cut(pair, "="); ok {
+			m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return m
+}
@@ -11,3 +11,84 @@ type CreateChargePointRequest struct {
 	ChargeStationId    string `json:"charge_station_id" validate:"required,uuid"`
 	RegistrationStatus string `json:"registration_status" validate:"required,oneof=accepted rejected pending"`
 }
+
+type UpdateRegistrationStatusRequest struct {
+	RegistrationStatus string `json:"registration_status" validate:"required,oneof=ACCEPTED REJECTED PENDING"`
+}
+
+type UpdateSecurityProfileRequest struct {
+	SecurityProfile *int   `json:"security_profile" validate:"required,min=0,max=3"`
+	Password        string `json:"password" validate:"omitempty,min=16,max=40"`
+}
+
+type RemoteStartRequest struct {
+	ConnectorID *int   `json:"connector_id" validate:"omitempty,gt=0"` // the EVSE for OCPP 2.0.1
+	IdTag       string `json:"id_tag" validate:"required,max=36"`
+	IdTokenType string `json:"id_token_type" validate:"omitempty,oneof=Central eMAID ISO14443 ISO15693 KeyCode Local MacAddress"` // OCPP 2.0.1 only, ISO14443 when omitted
+}
+
+type RemoteStopRequest struct {
+	TransactionID int `json:"transaction_id" validate:"required"`
+}
+
+type ResetRequest struct {
+	Type string `json:"type" validate:"required,oneof=Soft Hard"`
+}
+
+type UnlockConnectorRequest struct {
+	ConnectorID int `json:"connector_id" validate:"required,gt=0"` // the EVSE for OCPP 2.0.1
+}
+
+type TriggerMessageRequest struct {
+	RequestedMessage string `json:"requested_message" validate:"required,oneof=BootNotification Heartbeat StatusNotification MeterValues DiagnosticsStatusNotification FirmwareStatusNotification"`
+	ConnectorID      *int   `json:"connector_id" validate:"omitempty,min=0"` // the EVSE for OCPP 2.0.1, all connectors when omitted
+}
+
+type ChangeAvailabilityRequest struct {
+	ConnectorID int    `json:"connector_id" validate:"min=0"` // the EVSE for OCPP 2.0.1, 0 for the whole charge point
+	Type        string `json:"type" validate:"required,oneof=Operative Inoperative"`
+}
+
+type CommandResponse struct {
+	Status string `json:"status"`
+}
+
+type InstallCertificateRequest struct {
+	CertificateType string `json:"certificate_type" validate:"required,max=50"`
+	Certificate     string `json:"certificate" validate:"required,max=5500"`
+}
+
+// CertificateHashData identifies a certificate installed on a charge point
+type CertificateHashData struct {
+	HashAlgorithm  string `json:"hash_algorithm" validate:"required,oneof=SHA256 SHA384 SHA512"`
+	IssuerNameHash string `json:"issuer_name_hash" validate:"required,max=128"`
+	IssuerKeyHash  string `json:"issuer_key_hash" validate:"required,max=128"`
+	SerialNumber   string `json:"serial_number" validate:"required,max=40"`
+}
+
+type InstalledCertificate struct {
+	CertificateType string                `json:"certificate_type"`
+	HashData        CertificateHashData   `json:"hash_data"`
+	Children        []CertificateHashData `json:"children,omitempty"` // sub-CA certificates of a V2G chain
+}
+
+type InstalledCertificatesResponse struct {
+	Status       string                 `json:"status"`
+	Certificates []InstalledCertificate `json:"certificates"`
+}
+
+type RefreshConfigurationRequest struct {
+	Keys []string `json:"keys" validate:"omitempty,dive,required,max=50"`
+}
+
+type ChangeConfigurationRequest struct {
+	Key   string `json:"key" validate:"required,max=50"`
+	Value string `json:"value" validate:"max=500"`
+}
+
+type ConfigurationTemplateRequest struct {
+	Name   string            `json:"name" validate:"required,max=100"`
+	Vendor string            `json:"vendor" validate:"required,max=20"`
+	Model  string            `json:"model" validate:"omitempty,max=20"`
+	Keys   map[string]string `json:"keys" validate:"required,min=1,dive,keys,required,max=50,endkeys,max=500"`
+}
@@ -0,0 +1,12 @@
+package dto
+
+import "time"
+
+type DiagnosticsRequest struct {
+	ChargePointID string     `json:"charge_point_id" validate:"required,uuid"`
+	LogType       string     `json:"log_type" validate:"omitempty,oneof=DiagnosticsLog SecurityLog"` // DiagnosticsLog when omitted, SecurityLog needs OCPP 2.0.1
+	StartTime     *time.Time `json:"start_time"`                                                     // oldest information to include
+	StopTime      *time.Time `json:"stop_time"`                                                      // latest information to include
+	Retries       *int       `json:"retries" validate:"omitempty,min=0"`
+	RetryInterval *int       `json:"retry_interval" validate:"omitempty,min=0"`
+}
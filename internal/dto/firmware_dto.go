@@ -0,0 +1,35 @@
+package dto
+
+import (
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+// FirmwareArtifactRequest holds the form fields uploaded with a firmware file
+type FirmwareArtifactRequest struct {
+	Version            string `form:"version" validate:"required,max=50"`
+	Vendor             string `form:"vendor" validate:"required,max=20"`
+	Models             string `form:"models" validate:"max=500"`                                       // comma separated, all models of the vendor when empty
+	Checksum           string `form:"checksum" validate:"omitempty,len=64,hexadecimal"`                // hex SHA-256 the upload is verified against
+	SigningCertificate string `form:"signing_certificate" validate:"required_with=Signature,max=5500"` // PEM
+	Signature          string `form:"signature" validate:"required_with=SigningCertificate,max=800"`   // base64
+}
+
+type FirmwareCampaignRequest struct {
+	Name       string `json:"name" validate:"required,max=100"`
+	ArtifactID string `json:"artifact_id" validate:"required,uuid"`
+	// the campaign targets the listed charge points, else the charge points of the
+	// listed charge stations, else every charge point compatible with the artifact
+	ChargePointIDs   []string `json:"charge_point_ids" validate:"omitempty,dive,uuid"`
+	ChargeStationIDs []string `json:"charge_station_ids" validate:"omitempty,dive,uuid"`
+	WaveSize         int      `json:"wave_size" validate:"required,gt=0"`
+	SuccessThreshold *float64 `json:"success_threshold" validate:"omitempty,min=0,max=1"` // 0.9 when omitted
+	Retries          *int     `json:"retries" validate:"omitempty,min=0"`
+	RetryInterval    *int     `json:"retry_interval" validate:"omitempty,min=0"`
+}
+
+// FirmwareCampaignDetails is a campaign with the number of its targets by status
+type FirmwareCampaignDetails struct {
+	*models.FirmwareCampaign
+	Targets map[enums.FirmwareUpdateStatus]int `json:"targets"`
+}
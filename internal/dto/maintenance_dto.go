@@ -0,0 +1,25 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+type MaintenanceWindowRequest struct {
+	Name     string    `json:"name" validate:"required,max=100"`
+	Reason   string    `json:"reason" validate:"max=255"`
+	StartsAt time.Time `json:"starts_at" validate:"required"`
+	EndsAt   time.Time `json:"ends_at" validate:"required,gtfield=StartsAt"`
+	// the window takes the listed charge points out of service, else the
+	// charge points of the listed charge stations
+	ChargePointIDs   []string `json:"charge_point_ids" validate:"required_without=ChargeStationIDs,omitempty,dive,uuid"`
+	ChargeStationIDs []string `json:"charge_station_ids" validate:"required_without=ChargePointIDs,omitempty,dive,uuid"`
+}
+
+// MaintenanceWindowDetails is a maintenance window with the number of its targets by status
+type MaintenanceWindowDetails struct {
+	*models.MaintenanceWindow
+	Targets map[enums.MaintenanceTargetStatus]int `json:"targets"`
+}
@@ -0,0 +1,12 @@
+package dto
+
+import "time"
+
+type ReservationRequest struct {
+	ChargePointID string    `json:"charge_point_id" validate:"required,uuid"`
+	ConnectorID   int       `json:"connector_id" validate:"min=0"` // 0 for any connector, the EVSE id for OCPP 2.0.1
+	IdTag         string    `json:"id_tag" validate:"required,max=36"`
+	ParentIdTag   string    `json:"parent_id_tag" validate:"omitempty,max=36"`
+	IdTokenType   string    `json:"id_token_type" validate:"omitempty,oneof=Central eMAID ISO14443 ISO15693 KeyCode Local MacAddress"` // OCPP 2.0.1 only, ISO14443 when omitted
+	ExpiresAt     time.Time `json:"expires_at" validate:"required"`
+}
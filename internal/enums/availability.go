@@ -0,0 +1,21 @@
+package enums
+
+// Availability is the availability set by the CSMS for a charge point or a connector
+type Availability string
+
+const (
+	AvailabilityOperative   Availability = "OPERATIVE"
+	AvailabilityInoperative Availability = "INOPERATIVE"
+)
+
+func (a Availability) IsValid() bool {
+	return a == AvailabilityOperative || a == AvailabilityInoperative
+}
+
+// OCPP returns the availability type of ChangeAvailability, Operative or Inoperative
+func (a Availability) OCPP() string {
+	if a == AvailabilityInoperative {
+		return "Inoperative"
+	}
+	return "Operative"
+}
@@ -10,6 +10,8 @@ const (
 	ChargePointStatusFinishing     ChargePointStatus = "FINISHING"
 	ChargePointStatusSuspendedEVSE ChargePointStatus = "SUSPENDED_EVSE"
 	ChargePointStatusSuspendedEV   ChargePointStatus = "SUSPENDED_EV"
+	ChargePointStatusReserved      ChargePointStatus = "RESERVED"
+	ChargePointStatusOccupied      ChargePointStatus = "OCCUPIED" // OCPP 2.0.1 connector in use
 	ChargePointStatusUnavailable   ChargePointStatus = "UNAVAILABLE"
 	ChargePointStatusFaulted       ChargePointStatus = "FAULTED"
 	ChargePointStatusOffline       ChargePointStatus = "OFFLINE"
@@ -19,7 +21,8 @@ func (s ChargePointStatus) IsValid() bool {
 	switch s {
 	case ChargePointStatusUnknown, ChargePointStatusAvailable, ChargePointStatusPreparing, ChargePointStatusCharging,
 		ChargePointStatusFinishing, ChargePointStatusSuspendedEVSE, ChargePointStatusSuspendedEV,
-		ChargePointStatusUnavailable, ChargePointStatusFaulted, ChargePointStatusOffline:
+		ChargePointStatusReserved, ChargePointStatusOccupied, ChargePointStatusUnavailable,
+		ChargePointStatusFaulted, ChargePointStatusOffline:
 		return true
 	default:
 		return false
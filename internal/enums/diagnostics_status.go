@@ -0,0 +1,26 @@
+package enums
+
+// DiagnosticsStatus is the progress of a diagnostics or log upload requested from a charge point
+type DiagnosticsStatus string
+
+const (
+	DiagnosticsStatusRequested DiagnosticsStatus = "REQUESTED" // the charge point accepted the request
+	DiagnosticsStatusUploading DiagnosticsStatus = "UPLOADING"
+	DiagnosticsStatusUploaded  DiagnosticsStatus = "UPLOADED" // the file was received and stored
+	DiagnosticsStatusFailed    DiagnosticsStatus = "FAILED"
+)
+
+func (s DiagnosticsStatus) IsValid() bool {
+	switch s {
+	case DiagnosticsStatusRequested, DiagnosticsStatusUploading,
+		DiagnosticsStatusUploaded, DiagnosticsStatusFailed:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsFinal tells whether the upload has ended, successfully or not
+func (s DiagnosticsStatus) IsFinal() bool {
+	return s == DiagnosticsStatusUploaded || s == DiagnosticsStatusFailed
+}
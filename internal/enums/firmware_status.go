@@ -0,0 +1,56 @@
+package enums
+
+// FirmwareCampaignStatus is the state of a firmware rollout campaign
+type FirmwareCampaignStatus string
+
+const (
+	FirmwareCampaignStatusRunning   FirmwareCampaignStatus = "RUNNING"
+	FirmwareCampaignStatusPaused    FirmwareCampaignStatus = "PAUSED"
+	FirmwareCampaignStatusCompleted FirmwareCampaignStatus = "COMPLETED"
+	FirmwareCampaignStatusCancelled FirmwareCampaignStatus = "CANCELLED"
+)
+
+func (s FirmwareCampaignStatus) IsValid() bool {
+	switch s {
+	case FirmwareCampaignStatusRunning, FirmwareCampaignStatusPaused,
+		FirmwareCampaignStatusCompleted, FirmwareCampaignStatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// FirmwareUpdateStatus is the progress of a firmware update on one charge point of a campaign
+type FirmwareUpdateStatus string
+
+const (
+	FirmwareUpdateStatusPending     FirmwareUpdateStatus = "PENDING" // waiting for its wave or for the charge point to connect
+	FirmwareUpdateStatusSent        FirmwareUpdateStatus = "SENT"    // the charge point accepted the update request
+	FirmwareUpdateStatusDownloading FirmwareUpdateStatus = "DOWNLOADING"
+	FirmwareUpdateStatusDownloaded  FirmwareUpdateStatus = "DOWNLOADED"
+	FirmwareUpdateStatusInstalling  FirmwareUpdateStatus = "INSTALLING"
+	FirmwareUpdateStatusInstalled   FirmwareUpdateStatus = "INSTALLED"
+	FirmwareUpdateStatusFailed      FirmwareUpdateStatus = "FAILED"
+	FirmwareUpdateStatusCancelled   FirmwareUpdateStatus = "CANCELLED"
+)
+
+func (s FirmwareUpdateStatus) IsValid() bool {
+	switch s {
+	case FirmwareUpdateStatusPending, FirmwareUpdateStatusSent, FirmwareUpdateStatusDownloading,
+		FirmwareUpdateStatusDownloaded, FirmwareUpdateStatusInstalling, FirmwareUpdateStatusInstalled,
+		FirmwareUpdateStatusFailed, FirmwareUpdateStatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsFinal tells whether the update has ended, successfully or not
+func (s FirmwareUpdateStatus) IsFinal() bool {
+	switch s {
+	case FirmwareUpdateStatusInstalled, FirmwareUpdateStatusFailed, FirmwareUpdateStatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,54 @@
+package enums
+
+// MaintenanceWindowStatus is the state of a maintenance window
+type MaintenanceWindowStatus string
+
+const (
+	MaintenanceWindowStatusScheduled MaintenanceWindowStatus = "SCHEDULED" // waiting for its start
+	MaintenanceWindowStatusActive    MaintenanceWindowStatus = "ACTIVE"    // its charge points are taken out of service
+	MaintenanceWindowStatusRestoring MaintenanceWindowStatus = "RESTORING" // ended, some charge points are not back in service yet
+	MaintenanceWindowStatusCompleted MaintenanceWindowStatus = "COMPLETED"
+	MaintenanceWindowStatusCancelled MaintenanceWindowStatus = "CANCELLED" // cancelled before its start
+)
+
+func (s MaintenanceWindowStatus) IsValid() bool {
+	switch s {
+	case MaintenanceWindowStatusScheduled, MaintenanceWindowStatusActive, MaintenanceWindowStatusRestoring,
+		MaintenanceWindowStatusCompleted, MaintenanceWindowStatusCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+// MaintenanceTargetStatus is the availability of one charge point of a maintenance window
+type MaintenanceTargetStatus string
+
+const (
+	MaintenanceTargetStatusPending     MaintenanceTargetStatus = "PENDING"     // waiting for the window or for the charge point to connect
+	MaintenanceTargetStatusScheduled   MaintenanceTargetStatus = "SCHEDULED"   // the charge point goes out of service once its transactions end
+	MaintenanceTargetStatusUnavailable MaintenanceTargetStatus = "UNAVAILABLE" // out of service
+	MaintenanceTargetStatusRestored    MaintenanceTargetStatus = "RESTORED"    // back in service
+	MaintenanceTargetStatusSkipped     MaintenanceTargetStatus = "SKIPPED"     // the window ended before the charge point could be taken out of service
+	MaintenanceTargetStatusFailed      MaintenanceTargetStatus = "FAILED"
+)
+
+func (s MaintenanceTargetStatus) IsValid() bool {
+	switch s {
+	case MaintenanceTargetStatusPending, MaintenanceTargetStatusScheduled, MaintenanceTargetStatusUnavailable,
+		MaintenanceTargetStatusRestored, MaintenanceTargetStatusSkipped, MaintenanceTargetStatusFailed:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsFinal tells whether the charge point is done with the window
+func (s MaintenanceTargetStatus) IsFinal() bool {
+	switch s {
+	case MaintenanceTargetStatusRestored, MaintenanceTargetStatusSkipped, MaintenanceTargetStatusFailed:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,18 @@
+package enums
+
+// MessageDirection tells whether an OCPP frame was received from or sent to a charge point
+type MessageDirection string
+
+const (
+	MessageDirectionInbound  MessageDirection = "INBOUND"
+	MessageDirectionOutbound MessageDirection = "OUTBOUND"
+)
+
+func (d MessageDirection) IsValid() bool {
+	switch d {
+	case MessageDirectionInbound, MessageDirectionOutbound:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,28 @@
+package enums
+
+// ReservationStatus is the state of a connector reservation
+type ReservationStatus string
+
+const (
+	ReservationStatusRequested ReservationStatus = "REQUESTED" // sent to the charge point, waiting for its answer
+	ReservationStatusActive    ReservationStatus = "ACTIVE"    // the charge point holds the connector for the id tag
+	ReservationStatusUsed      ReservationStatus = "USED"      // a transaction was started on the reservation
+	ReservationStatusCancelled ReservationStatus = "CANCELLED"
+	ReservationStatusExpired   ReservationStatus = "EXPIRED"
+	ReservationStatusRejected  ReservationStatus = "REJECTED" // the charge point refused the reservation or could not be reached
+)
+
+func (s ReservationStatus) IsValid() bool {
+	switch s {
+	case ReservationStatusRequested, ReservationStatusActive, ReservationStatusUsed,
+		ReservationStatusCancelled, ReservationStatusExpired, ReservationStatusRejected:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsFinal tells whether the reservation no longer holds a connector
+func (s ReservationStatus) IsFinal() bool {
+	return s != ReservationStatusRequested && s != ReservationStatusActive
+}
@@ -0,0 +1,34 @@
+package enums
+
+import "slices"
+
+type SecurityEventSeverity string
+
+const (
+	SecurityEventSeverityLow      SecurityEventSeverity = "LOW"
+	SecurityEventSeverityMedium   SecurityEventSeverity = "MEDIUM"
+	SecurityEventSeverityHigh     SecurityEventSeverity = "HIGH"
+	SecurityEventSeverityCritical SecurityEventSeverity = "CRITICAL"
+)
+
+// securityEventSeverities lists the severities from lowest to highest
+var securityEventSeverities = []SecurityEventSeverity{
+	SecurityEventSeverityLow, SecurityEventSeverityMedium, SecurityEventSeverityHigh, SecurityEventSeverityCritical,
+}
+
+func (s SecurityEventSeverity) IsValid() bool {
+	return slices.Contains(securityEventSeverities, s)
+}
+
+// AtLeast reports whether s is as severe as or more severe than threshold
+func (s SecurityEventSeverity) AtLeast(threshold SecurityEventSeverity) bool {
+	return slices.Index(securityEventSeverities, s) >= slices.Index(securityEventSeverities, threshold)
+}
+
+// SecurityEventSeveritiesFrom returns threshold and the severities above it
+func SecurityEventSeveritiesFrom(threshold SecurityEventSeverity) []SecurityEventSeverity {
+	if i := slices.Index(securityEventSeverities, threshold); i >= 0 {
+		return securityEventSeverities[i:]
+	}
+	return nil
+}
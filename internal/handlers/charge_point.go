@@ -1,6 +1,11 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
@@ -9,6 +14,8 @@ import (
 	"github.com/mutoulbj/gocsms/internal/enums"
 	"github.com/mutoulbj/gocsms/internal/middleware"
 	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/ocpp"
+	"github.com/mutoulbj/gocsms/internal/repository"
 	"github.com/mutoulbj/gocsms/internal/services"
 	"github.com/mutoulbj/gocsms/internal/utils"
 )
@@ -19,22 +26,71 @@ import (
 // @BasePath /api/v1
 
 type ChargePointHandler struct {
-	svc     *services.ChargePointService
-	authSvc *services.AuthService
-	redis   *redis.Client
-	log     *logrus.Logger
+	svc      *services.ChargePointService
+	mvSvc    *services.MeterValueService
+	messages *services.MessageJournalService
+	authSvc  *services.AuthService
+	ocpp     *ocpp.Server
+	cfgMgr   *ocpp.ConfigurationManager
+	certMgr  *ocpp.CertificateManager
+	redis    *redis.Client
+	log      *logrus.Logger
 }
 
-func NewChargePointHandler(svc *services.ChargePointService, authSvc *services.AuthService, redis *redis.Client, log *logrus.Logger) *ChargePointHandler {
-	return &ChargePointHandler{svc: svc, authSvc: authSvc, redis: redis, log: log}
+func NewChargePointHandler(
+	svc *services.ChargePointService,
+	mvSvc *services.MeterValueService,
+	messages *services.MessageJournalService,
+	authSvc *services.AuthService,
+	ocppServer *ocpp.Server,
+	cfgMgr *ocpp.ConfigurationManager,
+	certMgr *ocpp.CertificateManager,
+	redis *redis.Client,
+	log *logrus.Logger,
+) *ChargePointHandler {
+	return &ChargePointHandler{
+		svc:      svc,
+		mvSvc:    mvSvc,
+		messages: messages,
+		authSvc:  authSvc,
+		ocpp:     ocppServer,
+		cfgMgr:   cfgMgr,
+		certMgr:  certMgr,
+		redis:    redis,
+		log:      log,
+	}
 }
 
 func (h *ChargePointHandler) RegisterRoutes(app fiber.Router) {
 	cp := app.Group("/chargepoints", middleware.Auth(h.authSvc, h.redis, h.log))
 
-	cp.Post("/", h.Create)                // @Summary Register a new charge point
-	cp.Get("/:id", h.GetByID)             // @Summary Get charge point by ID
-	cp.Put("/:id/status", h.UpdateStatus) // @Summary Update charge point status
+	cp.Post("/", h.Create)                                         // @Summary Register a new charge point
+	cp.Get("/:id", h.GetByID)                                      // @Summary Get charge point by ID
+	cp.Put("/:id/status", h.UpdateStatus)                          // @Summary Update charge point status
+	cp.Put("/:id/registration-status", h.UpdateRegistrationStatus) // @Summary Accept or reject a charge point
+	cp.Put("/:id/security-profile", h.UpdateSecurityProfile)       // @Summary Configure the OCPP security profile
+	cp.Get("/:id/meter-values", h.ListMeterValues)                 // @Summary List meter values of a charge point
+	cp.Get("/:id/connections", h.ListConnections)                  // @Summary List connections of a charge point
+	cp.Get("/:id/status-history", h.ListStatusHistory)             // @Summary List connector status history
+	cp.Get("/:id/messages", h.ListMessages)                        // @Summary List OCPP messages of a charge point
+
+	// commands sent to the connected charge point
+	cp.Post("/:id/commands/remote-start", h.RemoteStart)               // @Summary Remote start a transaction
+	cp.Post("/:id/commands/remote-stop", h.RemoteStop)                 // @Summary Remote stop a transaction
+	cp.Post("/:id/commands/reset", h.Reset)                            // @Summary Reset a charge point
+	cp.Post("/:id/commands/unlock-connector", h.UnlockConnector)       // @Summary Unlock a connector
+	cp.Post("/:id/commands/trigger-message", h.TriggerMessage)         // @Summary Trigger a message
+	cp.Post("/:id/commands/change-availability", h.ChangeAvailability) // @Summary Change availability
+
+	// ocpp configuration keys
+	cp.Get("/:id/configuration", h.GetConfiguration)              // @Summary Get charge point configuration
+	cp.Put("/:id/configuration", h.ChangeConfiguration)           // @Summary Change a configuration key
+	cp.Post("/:id/configuration/refresh", h.RefreshConfiguration) // @Summary Refresh charge point configuration
+
+	// certificates installed on the charge point
+	cp.Get("/:id/certificates", h.GetInstalledCertificates) // @Summary List installed certificates
+	cp.Post("/:id/certificates", h.InstallCertificate)      // @Summary Install a certificate
+	cp.Delete("/:id/certificates", h.DeleteCertificate)     // @Summary Delete an installed certificate
 }
 
 // @Summary Create(Register) a new charge point
@@ -91,6 +147,9 @@ func (h *ChargePointHandler) GetByID(c *fiber.Ctx) error {
 	if err != nil {
 		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Charge point not found"})
 	}
+	if cp.Connectors, err = h.svc.ListConnectors(c.Context(), cp.ID); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
 	return c.JSON(cp)
 }
 
@@ -121,3 +180,292 @@ func (h *ChargePointHandler) UpdateStatus(c *fiber.Ctx) error {
 	}
 	return c.JSON(fiber.Map{"message": "Status updated"})
 }
+
+// @Summary Accept or reject a charge point
+// @Description Set the registration status a charge point gets on its next BootNotification
+// @Tags ChargePoints
+// @Accept json
+// @Produce json
+// @Param id path string true "Charge Point ID"
+// @Param status body dto.UpdateRegistrationStatusRequest true "Registration status"
+// @Success 200 {object} fiber.Map
+// @Failure 400 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /chargepoints/{id}/registration-status [put]
+func (h *ChargePointHandler) UpdateRegistrationStatus(c *fiber.Ctx) error {
+	uuidID, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid UUID"})
+	}
+	var req dto.UpdateRegistrationStatusRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := utils.ValidateStruct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	status := enums.ChargePointRegistrationStatus(req.RegistrationStatus)
+	if err := h.svc.UpdateRegistrationStatus(c.Context(), uuidID, status); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "Registration status updated"})
+}
+
+// @Summary Configure the OCPP security profile
+// @Description Set the security profile a charge point must connect with and its Basic Auth password for profiles 1 and 2
+// @Tags ChargePoints
+// @Accept json
+// @Produce json
+// @Param id path string true "Charge Point ID"
+// @Param security body dto.UpdateSecurityProfileRequest true "Security profile and password"
+// @Success 200 {object} fiber.Map
+// @Failure 400 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /chargepoints/{id}/security-profile [put]
+func (h *ChargePointHandler) UpdateSecurityProfile(c *fiber.Ctx) error {
+	uuidID, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid UUID"})
+	}
+	var req dto.UpdateSecurityProfileRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := utils.ValidateStruct(req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	err = h.svc.SetSecurityProfile(c.Context(), uuidID, *req.SecurityProfile, req.Password)
+	if errors.Is(err, services.ErrPasswordRequired) {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"message": "Security profile updated"})
+}
+
+// @Summary List meter values
+// @Description Retrieve the meter value series of a charge point, optionally narrowed to a connector or transaction
+// @Tags ChargePoints
+// @Accept json
+// @Produce json
+// @Param id path string true "Charge Point ID"
+// @Param connector_id query int false "OCPP connector ID"
+// @Param transaction_id query int false "OCPP transaction ID"
+// @Param measurand query string false "Measurand, e.g. Energy.Active.Import.Register"
+// @Param from query string false "Start time (RFC3339)"
+// @Param to query string false "End time (RFC3339)"
+// @Param limit query int false "Maximum number of values" default(1000)
+// @Success 200 {array} models.MeterValue
+// @Failure 400 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /chargepoints/{id}/meter-values [get]
+func (h *ChargePointHandler) ListMeterValues(c *fiber.Ctx) error {
+	uuidID, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid UUID"})
+	}
+
+	limit, err := parseLimitQuery(c, 1000, 10000)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	filter := repository.MeterValueFilter{
+		ChargePointID: uuidID,
+		Measurand:     c.Query("measurand"),
+		Limit:         limit,
+	}
+	transactionID, err := parseIntQuery(c, "transaction_id")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid transaction_id"})
+	}
+	if transactionID != nil {
+		filter.TransactionID = *transactionID
+	}
+	if filter.ConnectorID, err = parseIntQuery(c, "connector_id"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid connector_id"})
+	}
+	if filter.From, err = parseTimeQuery(c, "from"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from time"})
+	}
+	if filter.To, err = parseTimeQuery(c, "to"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to time"})
+	}
+
+	values, err := h.mvSvc.List(c.Context(), filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(values)
+}
+
+// @Summary List connections
+// @Description Retrieve the most recent WebSocket connections of a charge point
+// @Tags ChargePoints
+// @Accept json
+// @Produce json
+// @Param id path string true "Charge Point ID"
+// @Param limit query int false "Maximum number of connections" default(100)
+// @Success 200 {array} models.ChargePointConnection
+// @Failure 400 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /chargepoints/{id}/connections [get]
+func (h *ChargePointHandler) ListConnections(c *fiber.Ctx) error {
+	uuidID, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid UUID"})
+	}
+
+	limit, err := parseLimitQuery(c, 100, 1000)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	conns, err := h.svc.ListConnections(c.Context(), uuidID, limit)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(conns)
+}
+
+// @Summary List connector status history
+// @Description Retrieve the statuses reported by a charge point for its connectors, most recent first
+// @Tags ChargePoints
+// @Accept json
+// @Produce json
+// @Param id path string true "Charge Point ID"
+// @Param connector_id query int false "OCPP connector ID, 0 for the charge point itself"
+// @Param from query string false "Start time (RFC3339)"
+// @Param to query string false "End time (RFC3339)"
+// @Param limit query int false "Maximum number of statuses" default(100)
+// @Success 200 {array} models.ConnectorStatusHistory
+// @Failure 400 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /chargepoints/{id}/status-history [get]
+func (h *ChargePointHandler) ListStatusHistory(c *fiber.Ctx) error {
+	uuidID, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid UUID"})
+	}
+
+	limit, err := parseLimitQuery(c, 100, 1000)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	filter := repository.ConnectorStatusFilter{
+		ChargePointID: uuidID,
+		Limit:         limit,
+	}
+	if filter.ConnectorID, err = parseIntQuery(c, "connector_id"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid connector_id"})
+	}
+	if filter.From, err = parseTimeQuery(c, "from"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from time"})
+	}
+	if filter.To, err = parseTimeQuery(c, "to"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to time"})
+	}
+
+	history, err := h.svc.ListConnectorStatusHistory(c.Context(), filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(history)
+}
+
+// @Summary List OCPP messages
+// @Description Retrieve the OCPP frames exchanged with a charge point from the message journal, most recent first
+// @Tags ChargePoints
+// @Accept json
+// @Produce json
+// @Param id path string true "Charge Point ID"
+// @Param direction query string false "INBOUND or OUTBOUND"
+// @Param message_type query int false "OCPP-J message type: 2 CALL, 3 CALLRESULT, 4 CALLERROR"
+// @Param action query string false "OCPP action, e.g. BootNotification"
+// @Param unique_id query string false "OCPP-J unique id, to find a CALL and its reply"
+// @Param errors query bool false "Only CALLERRORs and frames that could not be handled"
+// @Param from query string false "Start time (RFC3339)"
+// @Param to query string false "End time (RFC3339)"
+// @Param limit query int false "Maximum number of messages" default(100)
+// @Success 200 {array} models.OCPPMessage
+// @Failure 400 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /chargepoints/{id}/messages [get]
+func (h *ChargePointHandler) ListMessages(c *fiber.Ctx) error {
+	if _, err := utils.ParseUUID(c.Params("id")); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid UUID"})
+	}
+	identity, err := h.identity(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Charge point not found"})
+	}
+
+	limit, err := parseLimitQuery(c, 100, 1000)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	filter := repository.OCPPMessageFilter{
+		Identity:   identity,
+		Direction:  enums.MessageDirection(c.Query("direction")),
+		Action:     c.Query("action"),
+		UniqueID:   c.Query("unique_id"),
+		ErrorsOnly: c.QueryBool("errors"),
+		Limit:      limit,
+	}
+	if filter.Direction != "" && !filter.Direction.IsValid() {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid direction"})
+	}
+	messageType, err := parseIntQuery(c, "message_type")
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid message_type"})
+	}
+	if messageType != nil {
+		filter.MessageType = *messageType
+	}
+	if filter.From, err = parseTimeQuery(c, "from"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid from time"})
+	}
+	if filter.To, err = parseTimeQuery(c, "to"); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid to time"})
+	}
+
+	messages, err := h.messages.List(c.Context(), filter)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(messages)
+}
+
+// parseLimitQuery parses the optional limit query parameter, which must be
+// between 1 and maximum
+func parseLimitQuery(c *fiber.Ctx, defaultValue, maximum int) (int, error) {
+	if c.Query("limit") == "" {
+		return defaultValue, nil
+	}
+	limit, err := strconv.Atoi(c.Query("limit"))
+	if err != nil || limit < 1 || limit > maximum {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maximum)
+	}
+	return limit, nil
+}
+
+// parseIntQuery parses an optional non-negative integer query parameter, nil when it is absent
+func parseIntQuery(c *fiber.Ctx, key string) (*int, error) {
+	if c.Query(key) == "" {
+		return nil, nil
+	}
+	value, err := strconv.Atoi(c.Query(key))
+	if err != nil || value < 0 {
+		return nil, fmt.Errorf("%s must be a non-negative integer", key)
+	}
+	return &value, nil
+}
+
+// parseTimeQuery parses an optional RFC3339 query parameter
+func parseTimeQuery(c *fiber.Ctx, key string) (time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
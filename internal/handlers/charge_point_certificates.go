@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/mutoulbj/gocsms/internal/dto"
+	"github.com/mutoulbj/gocsms/internal/utils"
+)
+
+// @Summary List installed certificates
+// @Description Send GetInstalledCertificateIds to the connected charge point
+// @Tags ChargePoints
+// @Accept json
+// @Produce json
+// @Param id path string true "Charge Point ID"
+// @Param type query string false "Comma separated certificate types, all types when empty"
+// @Success 200 {object} dto.InstalledCertificatesResponse
+// @Failure 400 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 504 {object} fiber.Map
+// @Router /chargepoints/{id}/certificates [get]
+func (h *ChargePointHandler) GetInstalledCertificates(c *fiber.Ctx) error {
+	uuidID, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid UUID"})
+	}
+	var certificateTypes []string
+	for _, t := range strings.Split(c.Query("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			certificateTypes = append(certificateTypes, t)
+		}
+	}
+
+	resp, err := h.certMgr.Installed(c.Context(), uuidID, certificateTypes)
+	if err != nil {
+		return h.commandError(c, err)
+	}
+	return c.JSON(resp)
+}
+
+// @Summary Install a certificate
+// @Description Send InstallCertificate with a PEM encoded root certificate to the connected charge point
+// @Tags ChargePoints
+// @Accept json
+// @Produce json
+// @Param id path string true "Charge Point ID"
+// @Param certificate body dto.InstallCertificateRequest true "Certificate type and PEM certificate"
+// @Success 200 {object} dto.CommandResponse
+// @Failure 400 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 504 {object} fiber.Map
+// @Router /chargepoints/{id}/certificates [post]
+func (h *ChargePointHandler) InstallCertificate(c *fiber.Ctx) error {
+	var req dto.InstallCertificateRequest
+	if err := parseCommand(c, &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	uuidID, _ := utils.ParseUUID(c.Params("id"))
+
+	status, err := h.certMgr.Install(c.Context(), uuidID, req.CertificateType, req.Certificate)
+	if err != nil {
+		return h.commandError(c, err)
+	}
+	return c.JSON(dto.CommandResponse{Status: status})
+}
+
+// @Summary Delete an installed certificate
+// @Description Send DeleteCertificate to the connected charge point
+// @Tags ChargePoints
+// @Accept json
+// @Produce json
+// @Param id path string true "Charge Point ID"
+// @Param certificate body dto.CertificateHashData true "Hash data of the certificate"
+// @Success 200 {object} dto.CommandResponse
+// @Failure 400 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 504 {object} fiber.Map
+// @Router /chargepoints/{id}/certificates [delete]
+func (h *ChargePointHandler) DeleteCertificate(c *fiber.Ctx) error {
+	var req dto.CertificateHashData
+	if err := parseCommand(c, &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	uuidID, _ := utils.ParseUUID(c.Params("id"))
+
+	status, err := h.certMgr.Delete(c.Context(), uuidID, req)
+	if err != nil {
+		return h.commandError(c, err)
+	}
+	return c.JSON(dto.CommandResponse{Status: status})
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/mutoulbj/gocsms/internal/dto"
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/ocpp"
+	"github.com/mutoulbj/gocsms/internal/services"
+	"github.com/mutoulbj/gocsms/internal/utils"
+)
+
+// @Summary Remote start a transaction
+// @Description Send RemoteStartTransaction, or RequestStartTransaction for OCPP 2.0.1, to the connected charge point
+// @Tags ChargePoints
+// @Accept json
+// @Produce json
+// @Param id path string true "Charge Point ID"
+// @Param command body dto.RemoteStartRequest true "Remote start parameters"
+// @Success 200 {object} dto.CommandResponse
+// @Failure 400 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 504 {object} fiber.Map
+// @Router /chargepoints/{id}/commands/remote-start [post]
+func (h *ChargePointHandler) RemoteStart(c *fiber.Ctx) error {
+	var req dto.RemoteStartRequest
+	if err := parseCommand(c, &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	identity, err := h.identity(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Charge point not found"})
+	}
+	status, err := h.ocpp.RemoteStart(c.Context(), identity, req.ConnectorID, req.IdTag, req.IdTokenType)
+	if err != nil {
+		return h.commandError(c, err)
+	}
+	return c.JSON(dto.CommandResponse{Status: status})
+}
+
+// @Summary Remote stop a transaction
+// @Description Send RemoteStopTransaction, or RequestStopTransaction for OCPP 2.0.1, to the connected charge point
+// @Tags ChargePoints
+// @Accept json
+// @Produce json
+// @Param id path string true "Charge Point ID"
+// @Param command body dto.RemoteStopRequest true "Remote stop parameters"
+// @Success 200 {object} dto.CommandResponse
+// @Failure 400 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 504 {object} fiber.Map
+// @Router /chargepoints/{id}/commands/remote-stop [post]
+func (h *ChargePointHandler) RemoteStop(c *fiber.Ctx) error {
+	var req dto.RemoteStopRequest
+	if err := parseCommand(c, &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	cp, err := h.svc.GetByID(c.Context(), c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Charge point not found"})
+	}
+	status, err := h.ocpp.RemoteStop(c.Context(), cp, req.TransactionID)
+	if err != nil {
+		return h.commandError(c, err)
+	}
+	return c.JSON(dto.CommandResponse{Status: status})
+}
+
+// @Summary Reset a charge point
+// @Description Send a Soft or Hard Reset to the connected charge point, an OnIdle or Immediate one for OCPP 2.0.1
+// @Tags ChargePoints
+// @Accept json
+// @Produce json
+// @Param id path string true "Charge Point ID"
+// @Param command body dto.ResetRequest true "Reset type"
+// @Success 200 {object} dto.CommandResponse
+// @Failure 400 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 504 {object} fiber.Map
+// @Router /chargepoints/{id}/commands/reset [post]
+func (h *ChargePointHandler) Reset(c *fiber.Ctx) error {
+	var req dto.ResetRequest
+	if err := parseCommand(c, &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	identity, err := h.identity(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Charge point not found"})
+	}
+	status, err := h.ocpp.ResetChargePoint(c.Context(), identity, req.Type)
+	if err != nil {
+		return h.commandError(c, err)
+	}
+	return c.JSON(dto.CommandResponse{Status: status})
+}
+
+// @Summary Unlock a connector
+// @Description Send UnlockConnector to the connected charge point
+// @Tags ChargePoints
+// @Accept json
+// @Produce json
+// @Param id path string true "Charge Point ID"
+// @Param command body dto.UnlockConnectorRequest true "Connector to unlock"
+// @Success 200 {object} dto.CommandResponse
+// @Failure 400 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 504 {object} fiber.Map
+// @Router /chargepoints/{id}/commands/unlock-connector [post]
+func (h *ChargePointHandler) UnlockConnector(c *fiber.Ctx) error {
+	var req dto.UnlockConnectorRequest
+	if err := parseCommand(c, &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	identity, err := h.identity(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Charge point not found"})
+	}
+	status, err := h.ocpp.Unlock(c.Context(), identity, req.ConnectorID)
+	if err != nil {
+		return h.commandError(c, err)
+	}
+	return c.JSON(dto.CommandResponse{Status: status})
+}
+
+// @Summary Trigger a message
+// @Description Send TriggerMessage to the connected charge point to make it send one of its messages
+// @Tags ChargePoints
+// @Accept json
+// @Produce json
+// @Param id path string true "Charge Point ID"
+// @Param command body dto.TriggerMessageRequest true "Requested message and connector"
+// @Success 200 {object} dto.CommandResponse
+// @Failure 400 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 504 {object} fiber.Map
+// @Router /chargepoints/{id}/commands/trigger-message [post]
+func (h *ChargePointHandler) TriggerMessage(c *fiber.Ctx) error {
+	var req dto.TriggerMessageRequest
+	if err := parseCommand(c, &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	identity, err := h.identity(c)
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Charge point not found"})
+	}
+	status, err := h.ocpp.Trigger(c.Context(), identity, req.RequestedMessage, req.ConnectorID)
+	if err != nil {
+		return h.commandError(c, err)
+	}
+	return c.JSON(dto.CommandResponse{Status: status})
+}
+
+// @Summary Change availability
+// @Description Send ChangeAvailability to the connected charge point to make a connector, or the whole charge point with connector 0, operative or inoperative
+// @Tags ChargePoints
+// @Accept json
+// @Produce json
+// @Param id path string true "Charge Point ID"
+// @Param command body dto.ChangeAvailabilityRequest true "Connector and availability"
+// @Success 200 {object} dto.CommandResponse
+// @Failure 400 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 504 {object} fiber.Map
+// @Router /chargepoints/{id}/commands/change-availability [post]
+func (h *ChargePointHandler) ChangeAvailability(c *fiber.Ctx) error {
+	var req dto.ChangeAvailabilityRequest
+	if err := parseCommand(c, &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	cp, err := h.svc.GetByID(c.Context(), c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": "Charge point not found"})
+	}
+	availability := enums.AvailabilityOperative
+	if req.Type == "Inoperative" {
+		availability = enums.AvailabilityInoperative
+	}
+	status, err := h.ocpp.SetAvailability(c.Context(), cp, req.ConnectorID, availability)
+	if err != nil {
+		return h.commandError(c, err)
+	}
+	return c.JSON(dto.CommandResponse{Status: status})
+}
+
+// parseCommand validates the charge point ID and binds and validates the command body
+func parseCommand(c *fiber.Ctx, req any) error {
+	if _, err := utils.ParseUUID(c.Params("id")); err != nil {
+		return errors.New("invalid UUID")
+	}
+	if err := c.BodyParser(req); err != nil {
+		return err
+	}
+	return utils.ValidateStruct(req)
+}
+
+// identity returns the OCPP identity of the charge point addressed by the :id param
+func (h *ChargePointHandler) identity(c *fiber.Ctx) (string, error) {
+	cp, err := h.svc.GetByID(c.Context(), c.Params("id"))
+	if err != nil {
+		return "", err
+	}
+	return cp.Code, nil
+}
+
+// commandError maps the failure of a CSMS-initiated call to an HTTP response
+func (h *ChargePointHandler) commandError(c *fiber.Ctx, err error) error {
+	h.log.WithError(err).Warnf("Command to charge point %s failed", c.Params("id"))
+
+	var callErr *ocpp.CallErrorResponse
+	switch {
+	case errors.Is(err, ocpp.ErrChargePointNotConnected), errors.Is(err, services.ErrTransactionNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ocpp.ErrVersionNotSupported):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ocpp.ErrCallTimeout):
+		return c.Status(fiber.StatusGatewayTimeout).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ocpp.ErrInvalidCallRequest):
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	case errors.Is(err, ocpp.ErrInvalidCallReply):
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": err.Error()})
+	case errors.As(err, &callErr):
+		return c.Status(fiber.StatusBadGateway).JSON(fiber.Map{"error": err.Error(), "code": callErr.Code})
+	default:
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+}
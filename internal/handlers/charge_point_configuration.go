@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/mutoulbj/gocsms/internal/dto"
+	"github.com/mutoulbj/gocsms/internal/utils"
+)
+
+// @Summary Get charge point configuration
+// @Description Retrieve the last-known OCPP configuration keys of a charge point
+// @Tags ChargePoints
+// @Accept json
+// @Produce json
+// @Param id path string true "Charge Point ID"
+// @Success 200 {array} models.ChargePointConfiguration
+// @Failure 400 {object} fiber.Map
+// @Failure 500 {object} fiber.Map
+// @Router /chargepoints/{id}/configuration [get]
+func (h *ChargePointHandler) GetConfiguration(c *fiber.Ctx) error {
+	uuidID, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid UUID"})
+	}
+	items, err := h.cfgMgr.List(c.Context(), uuidID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(items)
+}
+
+// @Summary Refresh charge point configuration
+// @Description Send GetConfiguration to the connected OCPP 1.6 charge point and store the reported keys
+// @Tags ChargePoints
+// @Accept json
+// @Produce json
+// @Param id path string true "Charge Point ID"
+// @Param keys body dto.RefreshConfigurationRequest false "Keys to read, all keys when empty"
+// @Success 200 {object} fiber.Map
+// @Failure 400 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 409 {object} fiber.Map
+// @Failure 504 {object} fiber.Map
+// @Router /chargepoints/{id}/configuration/refresh [post]
+func (h *ChargePointHandler) RefreshConfiguration(c *fiber.Ctx) error {
+	uuidID, err := utils.ParseUUID(c.Params("id"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid UUID"})
+	}
+	var req dto.RefreshConfigurationRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		if err := utils.ValidateStruct(req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+	}
+
+	unknownKeys, err := h.cfgMgr.Refresh(c.Context(), uuidID, req.Keys)
+	if err != nil {
+		return h.commandError(c, err)
+	}
+	items, err := h.cfgMgr.List(c.Context(), uuidID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+	return c.JSON(fiber.Map{"configuration": items, "unknown_keys": unknownKeys})
+}
+
+// @Summary Change a configuration key
+// @Description Send ChangeConfiguration to the connected OCPP 1.6 charge point and record its answer
+// @Tags ChargePoints
+// @Accept json
+// @Produce json
+// @Param id path string true "Charge Point ID"
+// @Param change body dto.ChangeConfigurationRequest true "Key and value"
+// @Success 200 {object} dto.CommandResponse
+// @Failure 400 {object} fiber.Map
+// @Failure 404 {object} fiber.Map
+// @Failure 409 {object} fiber.Map
+// @Failure 504 {object} fiber.Map
+// @Router /chargepoints/{id}/configuration [put]
+func (h *ChargePointHandler) ChangeConfiguration(c *fiber.Ctx) error {
+	var req dto.ChangeConfigurationRequest
+	if err := parseCommand(c, &req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	uuidID, _ := utils.ParseUUID(c.Params("id"))
+
+	status, err := h.cfgMgr.Change(c.Context(), uuidID, req.Key, req.Value)
+	if err != nil {
+		return h.commandError(c, err)
+	}
+	return c.JSON(dto.CommandResponse{Status: status})
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/dto"
+	"github.com/mutoulbj/gocsms/internal/middleware"
+	"github.com/mutoulbj/gocsms/internal/services"
+	"github.com/mutoulbj/gocsms/internal/utils"
+	"github.com/mutoulbj/gocsms/pkg/response"
+)
+
+// ConfigurationTemplateHandler manages configuration templates per vendor/model
+type ConfigurationTemplateHandler struct {
+	svc     *services.ConfigurationTemplateService
+	authSvc *services.AuthService
+	redis   *redis.Client
+	log     *logrus.Logger
+	res     response.APIResponseInterface
+}
+
+// NewConfigurationTemplateHandler creates a new ConfigurationTemplateHandler
+func NewConfigurationTemplateHandler(
+	svc *services.ConfigurationTemplateService,
+	authSvc *services.AuthService,
+	redis *redis.Client,
+	log *logrus.Logger,
+	res response.APIResponseInterface,
+) *ConfigurationTemplateHandler {
+	return &ConfigurationTemplateHandler{
+		svc:     svc,
+		authSvc: authSvc,
+		redis:   redis,
+		log:     log,
+		res:     res,
+	}
+}
+
+// RegisterRoutes registers the configuration template routes with the provided router
+func (h *ConfigurationTemplateHandler) RegisterRoutes(router fiber.Router) {
+	tpl := router.Group("/configuration-templates", middleware.Auth(h.authSvc, h.redis, h.log))
+
+	tpl.Post("/", h.Create)      // Create configuration template
+	tpl.Get("/", h.List)         // List configuration templates
+	tpl.Get("/drift", h.Drift)   // Report charge points drifting from their template
+	tpl.Get("/:id", h.Get)       // Get configuration template by ID
+	tpl.Put("/:id", h.Update)    // Update configuration template by ID
+	tpl.Delete("/:id", h.Delete) // Delete configuration template by ID
+}
+
+// Create creates a new configuration template
+func (h *ConfigurationTemplateHandler) Create(c *fiber.Ctx) error {
+	var req dto.ConfigurationTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		h.log.WithError(err).Error("Failed to parse configuration template")
+		return h.res.Error(c, http.StatusBadRequest, "invalid configuration template", "params error", err.Error())
+	}
+	if err := utils.ValidateStruct(req); err != nil {
+		return h.res.ValidationError(c, utils.GetValidationErrors(err))
+	}
+
+	tpl, err := h.svc.Create(c.Context(), &req)
+	if err != nil {
+		h.log.WithError(err).Error("Failed to create configuration template")
+		return h.res.ErrorHandler(c, err)
+	}
+	return h.res.Created(c, "Configuration template created", tpl)
+}
+
+// List retrieves all configuration templates
+func (h *ConfigurationTemplateHandler) List(c *fiber.Ctx) error {
+	tpls, err := h.svc.List(c.Context())
+	if err != nil {
+		h.log.WithError(err).Error("Failed to list configuration templates")
+		return h.res.ErrorHandler(c, err)
+	}
+	return h.res.Success(c, "Configuration templates retrieved", tpls)
+}
+
+// Get retrieves a configuration template by ID
+func (h *ConfigurationTemplateHandler) Get(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid configuration template ID", "params error", err.Error())
+	}
+	tpl, err := h.svc.GetByID(c.Context(), id)
+	if err != nil {
+		return h.res.NotFound(c, "configuration template not found")
+	}
+	return h.res.Success(c, "Configuration template retrieved", tpl)
+}
+
+// Update replaces a configuration template
+func (h *ConfigurationTemplateHandler) Update(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid configuration template ID", "params error", err.Error())
+	}
+	var req dto.ConfigurationTemplateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid configuration template", "params error", err.Error())
+	}
+	if err := utils.ValidateStruct(req); err != nil {
+		return h.res.ValidationError(c, utils.GetValidationErrors(err))
+	}
+
+	tpl, err := h.svc.Update(c.Context(), id, &req)
+	if err != nil {
+		h.log.WithError(err).Error("Failed to update configuration template")
+		return h.res.ErrorHandler(c, err)
+	}
+	return h.res.Success(c, "Configuration template updated", tpl)
+}
+
+// Delete deletes a configuration template
+func (h *ConfigurationTemplateHandler) Delete(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid configuration template ID", "params error", err.Error())
+	}
+	if err := h.svc.Delete(c.Context(), id); err != nil {
+		h.log.WithError(err).Error("Failed to delete configuration template")
+		return h.res.ErrorHandler(c, err)
+	}
+	return h.res.Success(c, "Configuration template deleted", nil)
+}
+
+// Drift reports the template keys that differed on the charge points when the
+// periodic drift detection last checked them
+func (h *ConfigurationTemplateHandler) Drift(c *fiber.Ctx) error {
+	drifts, err := h.svc.DriftReport(c.Context())
+	if err != nil {
+		h.log.WithError(err).Error("Failed to list configuration drift")
+		return h.res.ErrorHandler(c, err)
+	}
+	return h.res.Success(c, "Configuration drift retrieved", drifts)
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/dto"
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/middleware"
+	"github.com/mutoulbj/gocsms/internal/ocpp"
+	"github.com/mutoulbj/gocsms/internal/services"
+	"github.com/mutoulbj/gocsms/internal/utils"
+	"github.com/mutoulbj/gocsms/pkg/response"
+)
+
+// DiagnosticsHandler requests diagnostics and logs from charge points, receives
+// their uploads and serves the stored files
+type DiagnosticsHandler struct {
+	cfg     *config.ServerConfig
+	svc     *services.DiagnosticsService
+	mgr     *ocpp.DiagnosticsManager
+	authSvc *services.AuthService
+	redis   *redis.Client
+	log     *logrus.Logger
+	res     response.APIResponseInterface
+}
+
+// NewDiagnosticsHandler creates a new DiagnosticsHandler
+func NewDiagnosticsHandler(
+	cfg *config.ServerConfig,
+	svc *services.DiagnosticsService,
+	mgr *ocpp.DiagnosticsManager,
+	authSvc *services.AuthService,
+	redis *redis.Client,
+	log *logrus.Logger,
+	res response.APIResponseInterface,
+) *DiagnosticsHandler {
+	return &DiagnosticsHandler{
+		cfg:     cfg,
+		svc:     svc,
+		mgr:     mgr,
+		authSvc: authSvc,
+		redis:   redis,
+		log:     log,
+		res:     res,
+	}
+}
+
+// RegisterRoutes registers the diagnostics routes with the provided router
+func (h *DiagnosticsHandler) RegisterRoutes(router fiber.Router) {
+	diagnostics := router.Group("/diagnostics", middleware.Auth(h.authSvc, h.redis, h.log))
+
+	diagnostics.Post("/", h.Request)             // Request diagnostics from a charge point
+	diagnostics.Get("/", h.List)                 // List diagnostics uploads
+	diagnostics.Get("/:id", h.Get)               // Get diagnostics upload by ID
+	diagnostics.Get("/:id/download", h.Download) // Download the uploaded diagnostics file
+	diagnostics.Delete("/:id", h.Delete)         // Delete diagnostics upload and its file
+
+	// charge points upload without credentials, the diagnostics ID is the secret;
+	// OCPP 1.6 charge points may append the file name to the location
+	uploads := router.Group("/uploads/diagnostics", middleware.BodyLimit(h.cfg.UploadBodyLimit))
+	uploads.Put("/:id", h.Upload)        // Receive diagnostics file
+	uploads.Post("/:id", h.Upload)       // Receive diagnostics file as multipart form
+	uploads.Put("/:id/:name", h.Upload)  // Receive named diagnostics file
+	uploads.Post("/:id/:name", h.Upload) // Receive named diagnostics file as multipart form
+}
+
+// Request asks a charge point to upload its diagnostics (OCPP 1.6) or a log (OCPP 2.0.1)
+func (h *DiagnosticsHandler) Request(c *fiber.Ctx) error {
+	var req dto.DiagnosticsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid diagnostics request", "params error", err.Error())
+	}
+	if err := utils.ValidateStruct(req); err != nil {
+		return h.res.ValidationError(c, utils.GetValidationErrors(err))
+	}
+	if req.StartTime != nil && req.StopTime != nil && req.StopTime.Before(*req.StartTime) {
+		return h.res.Error(c, http.StatusBadRequest, "invalid diagnostics request", "params error", "stop_time is before start_time")
+	}
+
+	diagnostics, err := h.mgr.Request(c.Context(), uuid.MustParse(req.ChargePointID), &req)
+	if err != nil {
+		return h.diagnosticsError(c, err)
+	}
+	return h.res.Created(c, "Diagnostics requested", diagnostics)
+}
+
+// List retrieves the diagnostics uploads, of one charge point with the
+// charge_point_id query and with one status with the status query
+func (h *DiagnosticsHandler) List(c *fiber.Ctx) error {
+	var chargePointID uuid.UUID
+	if id := c.Query("charge_point_id"); id != "" {
+		var err error
+		if chargePointID, err = uuid.Parse(id); err != nil {
+			return h.res.Error(c, http.StatusBadRequest, "invalid charge point ID", "params error", err.Error())
+		}
+	}
+	status := enums.DiagnosticsStatus(strings.ToUpper(c.Query("status")))
+	if status != "" && !status.IsValid() {
+		return h.res.Error(c, http.StatusBadRequest, "invalid diagnostics status", "params error", c.Query("status"))
+	}
+
+	diagnostics, err := h.svc.List(c.Context(), chargePointID, status)
+	if err != nil {
+		h.log.WithError(err).Error("Failed to list diagnostics")
+		return h.res.ErrorHandler(c, err)
+	}
+	return h.res.Success(c, "Diagnostics retrieved", diagnostics)
+}
+
+// Get retrieves a diagnostics upload by ID
+func (h *DiagnosticsHandler) Get(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid diagnostics ID", "params error", err.Error())
+	}
+	diagnostics, err := h.svc.Get(c.Context(), id)
+	if err != nil {
+		return h.res.NotFound(c, "diagnostics not found")
+	}
+	return h.res.Success(c, "Diagnostics retrieved", diagnostics)
+}
+
+// Download serves the file uploaded by the charge point
+func (h *DiagnosticsHandler) Download(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid diagnostics ID", "params error", err.Error())
+	}
+	diagnostics, err := h.svc.File(c.Context(), id)
+	if err != nil {
+		return h.diagnosticsError(c, err)
+	}
+	c.Attachment(diagnostics.FileName)
+	return c.SendFile(h.svc.FilePath(diagnostics))
+}
+
+// Delete deletes a diagnostics upload and its file
+func (h *DiagnosticsHandler) Delete(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid diagnostics ID", "params error", err.Error())
+	}
+	if err := h.svc.Delete(c.Context(), id); err != nil {
+		h.log.WithError(err).Error("Failed to delete diagnostics")
+		return h.diagnosticsError(c, err)
+	}
+	return h.res.Success(c, "Diagnostics deleted", nil)
+}
+
+// Upload receives a diagnostics file from a charge point, either as the body
+// of the request or as the first file of a multipart form
+func (h *DiagnosticsHandler) Upload(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.res.NotFound(c, "diagnostics not found")
+	}
+	fileName := c.Params("name")
+	var file io.Reader = bytes.NewReader(c.Body())
+	if form, err := c.MultipartForm(); err == nil {
+		header := uploadedFile(form)
+		if header == nil {
+			return h.res.Error(c, http.StatusBadRequest, "missing diagnostics file", "params error", nil)
+		}
+		f, err := header.Open()
+		if err != nil {
+			return h.res.ErrorHandler(c, err)
+		}
+		defer f.Close()
+		file = f
+		if fileName == "" {
+			fileName = header.Filename
+		}
+	}
+
+	if _, err := h.svc.StoreUpload(c.Context(), id, fileName, file); err != nil {
+		h.log.WithError(err).Errorf("Failed to store diagnostics upload from %s", c.IP())
+		return h.diagnosticsError(c, err)
+	}
+	return h.res.Success(c, "Diagnostics uploaded", nil)
+}
+
+// uploadedFile returns the file of a multipart form, the one of the "file"
+// field when there are several
+func uploadedFile(form *multipart.Form) *multipart.FileHeader {
+	if headers := form.File["file"]; len(headers) > 0 {
+		return headers[0]
+	}
+	for _, headers := range form.File {
+		if len(headers) > 0 {
+			return headers[0]
+		}
+	}
+	return nil
+}
+
+// diagnosticsError maps the failure of a diagnostics operation to an HTTP response
+func (h *DiagnosticsHandler) diagnosticsError(c *fiber.Ctx, err error) error {
+	var callErr *ocpp.CallErrorResponse
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return h.res.NotFound(c, "diagnostics or charge point not found")
+	case errors.Is(err, ocpp.ErrChargePointNotConnected):
+		return h.res.NotFound(c, err.Error())
+	case errors.Is(err, services.ErrDiagnosticsLogType),
+		errors.Is(err, ocpp.ErrInvalidCallRequest):
+		return h.res.Error(c, http.StatusBadRequest, "invalid diagnostics request", err.Error(), nil)
+	case errors.Is(err, services.ErrDiagnosticsUploaded),
+		errors.Is(err, services.ErrDiagnosticsNotUploaded):
+		return h.res.Error(c, http.StatusConflict, "diagnostics conflict", err.Error(), nil)
+	case errors.Is(err, ocpp.ErrCallTimeout):
+		return h.res.Error(c, http.StatusGatewayTimeout, "charge point did not answer", err.Error(), nil)
+	case errors.Is(err, ocpp.ErrInvalidCallReply), errors.As(err, &callErr):
+		return h.res.Error(c, http.StatusBadGateway, "charge point failed the request", err.Error(), nil)
+	default:
+		return h.res.ErrorHandler(c, err)
+	}
+}
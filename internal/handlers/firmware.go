@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/dto"
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/middleware"
+	"github.com/mutoulbj/gocsms/internal/repository"
+	"github.com/mutoulbj/gocsms/internal/services"
+	"github.com/mutoulbj/gocsms/internal/utils"
+	"github.com/mutoulbj/gocsms/pkg/response"
+)
+
+// FirmwareHandler manages firmware artifacts and rollout campaigns, and serves
+// the artifacts to the charge points
+type FirmwareHandler struct {
+	cfg     *config.ServerConfig
+	svc     *services.FirmwareService
+	authSvc *services.AuthService
+	redis   *redis.Client
+	log     *logrus.Logger
+	res     response.APIResponseInterface
+}
+
+// NewFirmwareHandler creates a new FirmwareHandler
+func NewFirmwareHandler(
+	cfg *config.ServerConfig,
+	svc *services.FirmwareService,
+	authSvc *services.AuthService,
+	redis *redis.Client,
+	log *logrus.Logger,
+	res response.APIResponseInterface,
+) *FirmwareHandler {
+	return &FirmwareHandler{
+		cfg:     cfg,
+		svc:     svc,
+		authSvc: authSvc,
+		redis:   redis,
+		log:     log,
+		res:     res,
+	}
+}
+
+// RegisterRoutes registers the firmware routes with the provided router
+func (h *FirmwareHandler) RegisterRoutes(router fiber.Router) {
+	firmware := router.Group("/firmware", middleware.Auth(h.authSvc, h.redis, h.log))
+
+	uploadLimit := middleware.BodyLimit(h.cfg.UploadBodyLimit)
+
+	firmware.Post("/artifacts", uploadLimit, h.CreateArtifact) // Upload firmware artifact
+	firmware.Get("/artifacts", h.ListArtifacts)                // List firmware artifacts
+	firmware.Get("/artifacts/:id", h.GetArtifact)              // Get firmware artifact by ID
+	firmware.Delete("/artifacts/:id", h.DeleteArtifact)        // Delete firmware artifact by ID
+	firmware.Post("/campaigns", h.CreateCampaign)              // Start firmware campaign
+	firmware.Get("/campaigns", h.ListCampaigns)                // List firmware campaigns
+	firmware.Get("/campaigns/:id", h.GetCampaign)              // Get firmware campaign with its progress
+	firmware.Get("/campaigns/:id/targets", h.ListTargets)      // List charge points of a campaign with their update status
+	firmware.Post("/campaigns/:id/pause", h.PauseCampaign)     // Pause firmware campaign
+	firmware.Post("/campaigns/:id/resume", h.ResumeCampaign)   // Resume firmware campaign
+	firmware.Post("/campaigns/:id/cancel", h.CancelCampaign)   // Cancel firmware campaign
+
+	// charge points download without credentials, the artifact ID is the secret
+	router.Get("/downloads/firmware/:id/:name", h.Download) // Download firmware artifact
+}
+
+// CreateArtifact uploads a firmware file with its details as multipart form
+func (h *FirmwareHandler) CreateArtifact(c *fiber.Ctx) error {
+	var req dto.FirmwareArtifactRequest
+	if err := c.BodyParser(&req); err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid firmware artifact", "params error", err.Error())
+	}
+	if err := utils.ValidateStruct(req); err != nil {
+		return h.res.ValidationError(c, utils.GetValidationErrors(err))
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "missing firmware file", "params error", err.Error())
+	}
+	file, err := header.Open()
+	if err != nil {
+		return h.res.ErrorHandler(c, err)
+	}
+	defer file.Close()
+
+	artifact, err := h.svc.CreateArtifact(c.Context(), &req, header.Filename, file)
+	if err != nil {
+		h.log.WithError(err).Error("Failed to create firmware artifact")
+		return h.firmwareError(c, err)
+	}
+	return h.res.Created(c, "Firmware artifact created", artifact)
+}
+
+// ListArtifacts retrieves the firmware artifacts, of one vendor with the vendor query
+func (h *FirmwareHandler) ListArtifacts(c *fiber.Ctx) error {
+	artifacts, err := h.svc.ListArtifacts(c.Context(), c.Query("vendor"))
+	if err != nil {
+		h.log.WithError(err).Error("Failed to list firmware artifacts")
+		return h.res.ErrorHandler(c, err)
+	}
+	return h.res.Success(c, "Firmware artifacts retrieved", artifacts)
+}
+
+// GetArtifact retrieves a firmware artifact by ID
+func (h *FirmwareHandler) GetArtifact(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid firmware artifact ID", "params error", err.Error())
+	}
+	artifact, err := h.svc.GetArtifact(c.Context(), id)
+	if err != nil {
+		return h.res.NotFound(c, "firmware artifact not found")
+	}
+	return h.res.Success(c, "Firmware artifact retrieved", artifact)
+}
+
+// DeleteArtifact deletes a firmware artifact that no campaign uses
+func (h *FirmwareHandler) DeleteArtifact(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid firmware artifact ID", "params error", err.Error())
+	}
+	if err := h.svc.DeleteArtifact(c.Context(), id); err != nil {
+		h.log.WithError(err).Error("Failed to delete firmware artifact")
+		return h.firmwareError(c, err)
+	}
+	return h.res.Success(c, "Firmware artifact deleted", nil)
+}
+
+// Download serves the file of a firmware artifact to a charge point
+func (h *FirmwareHandler) Download(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.res.NotFound(c, "firmware artifact not found")
+	}
+	artifact, err := h.svc.GetArtifact(c.Context(), id)
+	if err != nil {
+		return h.res.NotFound(c, "firmware artifact not found")
+	}
+	h.log.Infof("Serving firmware %s %s to %s", artifact.Vendor, artifact.Version, c.IP())
+	c.Attachment(artifact.FileName)
+	return c.SendFile(h.svc.ArtifactPath(artifact))
+}
+
+// CreateCampaign starts rolling a firmware artifact out to charge points
+func (h *FirmwareHandler) CreateCampaign(c *fiber.Ctx) error {
+	var req dto.FirmwareCampaignRequest
+	if err := c.BodyParser(&req); err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid firmware campaign", "params error", err.Error())
+	}
+	if err := utils.ValidateStruct(req); err != nil {
+		return h.res.ValidationError(c, utils.GetValidationErrors(err))
+	}
+
+	campaign, err := h.svc.CreateCampaign(c.Context(), &req)
+	if err != nil {
+		h.log.WithError(err).Error("Failed to create firmware campaign")
+		return h.firmwareError(c, err)
+	}
+	return h.res.Created(c, "Firmware campaign created", campaign)
+}
+
+// ListCampaigns retrieves the firmware campaigns, with the given status with the status query
+func (h *FirmwareHandler) ListCampaigns(c *fiber.Ctx) error {
+	status := enums.FirmwareCampaignStatus(strings.ToUpper(c.Query("status")))
+	if status != "" && !status.IsValid() {
+		return h.res.Error(c, http.StatusBadRequest, "invalid campaign status", "params error", c.Query("status"))
+	}
+	campaigns, err := h.svc.ListCampaigns(c.Context(), status)
+	if err != nil {
+		h.log.WithError(err).Error("Failed to list firmware campaigns")
+		return h.res.ErrorHandler(c, err)
+	}
+	return h.res.Success(c, "Firmware campaigns retrieved", campaigns)
+}
+
+// GetCampaign retrieves a firmware campaign with the number of its charge points by update status
+func (h *FirmwareHandler) GetCampaign(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid firmware campaign ID", "params error", err.Error())
+	}
+	campaign, err := h.svc.GetCampaign(c.Context(), id)
+	if err != nil {
+		return h.firmwareError(c, err)
+	}
+	return h.res.Success(c, "Firmware campaign retrieved", campaign)
+}
+
+// ListTargets retrieves the charge points of a campaign with the status of their
+// update, narrowed down with the wave and status queries
+func (h *FirmwareHandler) ListTargets(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid firmware campaign ID", "params error", err.Error())
+	}
+	filter := repository.FirmwareTargetFilter{
+		Wave:   c.QueryInt("wave"),
+		Status: enums.FirmwareUpdateStatus(strings.ToUpper(c.Query("status"))),
+	}
+	if filter.Status != "" && !filter.Status.IsValid() {
+		return h.res.Error(c, http.StatusBadRequest, "invalid update status", "params error", c.Query("status"))
+	}
+	targets, err := h.svc.ListTargets(c.Context(), id, filter)
+	if err != nil {
+		h.log.WithError(err).Error("Failed to list firmware campaign targets")
+		return h.res.ErrorHandler(c, err)
+	}
+	return h.res.Success(c, "Firmware campaign targets retrieved", targets)
+}
+
+// PauseCampaign stops a campaign from sending further updates
+func (h *FirmwareHandler) PauseCampaign(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid firmware campaign ID", "params error", err.Error())
+	}
+	campaign, err := h.svc.PauseCampaign(c.Context(), id, "Paused by operator")
+	if err != nil {
+		return h.firmwareError(c, err)
+	}
+	return h.res.Success(c, "Firmware campaign paused", campaign)
+}
+
+// ResumeCampaign resumes a paused campaign, past the success gate of a failed wave
+func (h *FirmwareHandler) ResumeCampaign(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid firmware campaign ID", "params error", err.Error())
+	}
+	campaign, err := h.svc.ResumeCampaign(c.Context(), id)
+	if err != nil {
+		return h.firmwareError(c, err)
+	}
+	return h.res.Success(c, "Firmware campaign resumed", campaign)
+}
+
+// CancelCampaign ends a campaign; updates already sent are still tracked
+func (h *FirmwareHandler) CancelCampaign(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid firmware campaign ID", "params error", err.Error())
+	}
+	campaign, err := h.svc.CancelCampaign(c.Context(), id)
+	if err != nil {
+		return h.firmwareError(c, err)
+	}
+	return h.res.Success(c, "Firmware campaign cancelled", campaign)
+}
+
+// firmwareError maps the failure of a firmware operation to an HTTP response
+func (h *FirmwareHandler) firmwareError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return h.res.NotFound(c, "firmware artifact or campaign not found")
+	case errors.Is(err, services.ErrFirmwareChecksumMismatch),
+		errors.Is(err, services.ErrFirmwareIncompatible),
+		errors.Is(err, services.ErrFirmwareNoTargets):
+		return h.res.Error(c, http.StatusBadRequest, "invalid firmware request", err.Error(), nil)
+	case errors.Is(err, services.ErrFirmwareArtifactInUse),
+		errors.Is(err, services.ErrFirmwareCampaignState):
+		return h.res.Error(c, http.StatusConflict, "firmware conflict", err.Error(), nil)
+	default:
+		return h.res.ErrorHandler(c, err)
+	}
+}
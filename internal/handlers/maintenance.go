@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/dto"
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/middleware"
+	"github.com/mutoulbj/gocsms/internal/services"
+	"github.com/mutoulbj/gocsms/internal/utils"
+	"github.com/mutoulbj/gocsms/pkg/response"
+)
+
+// MaintenanceHandler manages the maintenance windows taking charge points out of service
+type MaintenanceHandler struct {
+	svc     *services.MaintenanceService
+	authSvc *services.AuthService
+	redis   *redis.Client
+	log     *logrus.Logger
+	res     response.APIResponseInterface
+}
+
+// NewMaintenanceHandler creates a new MaintenanceHandler
+func NewMaintenanceHandler(
+	svc *services.MaintenanceService,
+	authSvc *services.AuthService,
+	redis *redis.Client,
+	log *logrus.Logger,
+	res response.APIResponseInterface,
+) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		svc:     svc,
+		authSvc: authSvc,
+		redis:   redis,
+		log:     log,
+		res:     res,
+	}
+}
+
+// RegisterRoutes registers the maintenance window routes with the provided router
+func (h *MaintenanceHandler) RegisterRoutes(router fiber.Router) {
+	windows := router.Group("/maintenance-windows", middleware.Auth(h.authSvc, h.redis, h.log))
+
+	windows.Post("/", h.Create)                // Schedule maintenance window
+	windows.Get("/", h.List)                   // List maintenance windows
+	windows.Get("/:id", h.Get)                 // Get maintenance window with its progress
+	windows.Get("/:id/targets", h.ListTargets) // List charge points of a maintenance window with their availability
+	windows.Post("/:id/cancel", h.Cancel)      // Cancel maintenance window, or end it now when active
+}
+
+// Create schedules a maintenance window for charge points or charge stations
+func (h *MaintenanceHandler) Create(c *fiber.Ctx) error {
+	var req dto.MaintenanceWindowRequest
+	if err := c.BodyParser(&req); err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid maintenance window", "params error", err.Error())
+	}
+	if err := utils.ValidateStruct(req); err != nil {
+		return h.res.ValidationError(c, utils.GetValidationErrors(err))
+	}
+
+	window, err := h.svc.Create(c.Context(), &req)
+	if err != nil {
+		h.log.WithError(err).Error("Failed to create maintenance window")
+		return h.maintenanceError(c, err)
+	}
+	return h.res.Created(c, "Maintenance window created", window)
+}
+
+// List retrieves the maintenance windows, with the given status with the status query
+func (h *MaintenanceHandler) List(c *fiber.Ctx) error {
+	status := enums.MaintenanceWindowStatus(strings.ToUpper(c.Query("status")))
+	if status != "" && !status.IsValid() {
+		return h.res.Error(c, http.StatusBadRequest, "invalid maintenance window status", "params error", c.Query("status"))
+	}
+	windows, err := h.svc.List(c.Context(), status)
+	if err != nil {
+		h.log.WithError(err).Error("Failed to list maintenance windows")
+		return h.res.ErrorHandler(c, err)
+	}
+	return h.res.Success(c, "Maintenance windows retrieved", windows)
+}
+
+// Get retrieves a maintenance window with the number of its charge points by status
+func (h *MaintenanceHandler) Get(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid maintenance window ID", "params error", err.Error())
+	}
+	window, err := h.svc.Get(c.Context(), id)
+	if err != nil {
+		return h.maintenanceError(c, err)
+	}
+	return h.res.Success(c, "Maintenance window retrieved", window)
+}
+
+// ListTargets retrieves the charge points of a maintenance window with their
+// availability, with the given status with the status query
+func (h *MaintenanceHandler) ListTargets(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid maintenance window ID", "params error", err.Error())
+	}
+	status := enums.MaintenanceTargetStatus(strings.ToUpper(c.Query("status")))
+	if status != "" && !status.IsValid() {
+		return h.res.Error(c, http.StatusBadRequest, "invalid maintenance target status", "params error", c.Query("status"))
+	}
+	targets, err := h.svc.ListTargets(c.Context(), id, status)
+	if err != nil {
+		h.log.WithError(err).Error("Failed to list maintenance window targets")
+		return h.res.ErrorHandler(c, err)
+	}
+	return h.res.Success(c, "Maintenance window targets retrieved", targets)
+}
+
+// Cancel cancels a scheduled maintenance window, or ends an active one now
+func (h *MaintenanceHandler) Cancel(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid maintenance window ID", "params error", err.Error())
+	}
+	window, err := h.svc.Cancel(c.Context(), id)
+	if err != nil {
+		return h.maintenanceError(c, err)
+	}
+	return h.res.Success(c, "Maintenance window cancelled", window)
+}
+
+// maintenanceError maps the failure of a maintenance window operation to an HTTP response
+func (h *MaintenanceHandler) maintenanceError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return h.res.NotFound(c, "maintenance window not found")
+	case errors.Is(err, services.ErrMaintenanceNoTargets),
+		errors.Is(err, services.ErrMaintenancePeriod):
+		return h.res.Error(c, http.StatusBadRequest, "invalid maintenance window", err.Error(), nil)
+	case errors.Is(err, services.ErrMaintenanceOverlap),
+		errors.Is(err, services.ErrMaintenanceWindowState):
+		return h.res.Error(c, http.StatusConflict, "maintenance window conflict", err.Error(), nil)
+	default:
+		return h.res.ErrorHandler(c, err)
+	}
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/dto"
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/middleware"
+	"github.com/mutoulbj/gocsms/internal/ocpp"
+	"github.com/mutoulbj/gocsms/internal/services"
+	"github.com/mutoulbj/gocsms/internal/utils"
+	"github.com/mutoulbj/gocsms/pkg/response"
+)
+
+// ReservationHandler reserves connectors of charge points for id tags
+type ReservationHandler struct {
+	svc     *services.ReservationService
+	mgr     *ocpp.ReservationManager
+	authSvc *services.AuthService
+	redis   *redis.Client
+	log     *logrus.Logger
+	res     response.APIResponseInterface
+}
+
+// NewReservationHandler creates a new ReservationHandler
+func NewReservationHandler(
+	svc *services.ReservationService,
+	mgr *ocpp.ReservationManager,
+	authSvc *services.AuthService,
+	redis *redis.Client,
+	log *logrus.Logger,
+	res response.APIResponseInterface,
+) *ReservationHandler {
+	return &ReservationHandler{
+		svc:     svc,
+		mgr:     mgr,
+		authSvc: authSvc,
+		redis:   redis,
+		log:     log,
+		res:     res,
+	}
+}
+
+// RegisterRoutes registers the reservation routes with the provided router
+func (h *ReservationHandler) RegisterRoutes(router fiber.Router) {
+	reservations := router.Group("/reservations", middleware.Auth(h.authSvc, h.redis, h.log))
+
+	reservations.Post("/", h.Reserve)          // Reserve a connector of a charge point
+	reservations.Get("/", h.List)              // List reservations
+	reservations.Get("/:id", h.Get)            // Get reservation by ID
+	reservations.Post("/:id/cancel", h.Cancel) // Cancel reservation on its charge point
+}
+
+// Reserve reserves a connector of a charge point for an id tag until the expiry date
+func (h *ReservationHandler) Reserve(c *fiber.Ctx) error {
+	var req dto.ReservationRequest
+	if err := c.BodyParser(&req); err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid reservation", "params error", err.Error())
+	}
+	if err := utils.ValidateStruct(req); err != nil {
+		return h.res.ValidationError(c, utils.GetValidationErrors(err))
+	}
+
+	reservation, err := h.mgr.Reserve(c.Context(), uuid.MustParse(req.ChargePointID), &req)
+	if err != nil {
+		return h.reservationError(c, err)
+	}
+	return h.res.Created(c, "Reservation requested", reservation)
+}
+
+// List retrieves the reservations, of one charge point with the charge_point_id
+// query and with one status with the status query
+func (h *ReservationHandler) List(c *fiber.Ctx) error {
+	var chargePointID uuid.UUID
+	if id := c.Query("charge_point_id"); id != "" {
+		var err error
+		if chargePointID, err = uuid.Parse(id); err != nil {
+			return h.res.Error(c, http.StatusBadRequest, "invalid charge point ID", "params error", err.Error())
+		}
+	}
+	status := enums.ReservationStatus(strings.ToUpper(c.Query("status")))
+	if status != "" && !status.IsValid() {
+		return h.res.Error(c, http.StatusBadRequest, "invalid reservation status", "params error", c.Query("status"))
+	}
+
+	reservations, err := h.svc.List(c.Context(), chargePointID, status)
+	if err != nil {
+		h.log.WithError(err).Error("Failed to list reservations")
+		return h.res.ErrorHandler(c, err)
+	}
+	return h.res.Success(c, "Reservations retrieved", reservations)
+}
+
+// Get retrieves a reservation by ID
+func (h *ReservationHandler) Get(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid reservation ID", "params error", err.Error())
+	}
+	reservation, err := h.svc.Get(c.Context(), id)
+	if err != nil {
+		return h.reservationError(c, err)
+	}
+	return h.res.Success(c, "Reservation retrieved", reservation)
+}
+
+// Cancel cancels a reservation on its charge point
+func (h *ReservationHandler) Cancel(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid reservation ID", "params error", err.Error())
+	}
+	reservation, err := h.mgr.Cancel(c.Context(), id)
+	if err != nil {
+		return h.reservationError(c, err)
+	}
+	return h.res.Success(c, "Reservation cancelled", reservation)
+}
+
+// reservationError maps the failure of a reservation operation to an HTTP response
+func (h *ReservationHandler) reservationError(c *fiber.Ctx, err error) error {
+	var callErr *ocpp.CallErrorResponse
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return h.res.NotFound(c, "reservation or charge point not found")
+	case errors.Is(err, ocpp.ErrChargePointNotConnected):
+		return h.res.NotFound(c, err.Error())
+	case errors.Is(err, services.ErrReservationExpiry),
+		errors.Is(err, ocpp.ErrInvalidCallRequest):
+		return h.res.Error(c, http.StatusBadRequest, "invalid reservation", err.Error(), nil)
+	case errors.Is(err, services.ErrReservationConflict),
+		errors.Is(err, services.ErrReservationState):
+		return h.res.Error(c, http.StatusConflict, "reservation conflict", err.Error(), nil)
+	case errors.Is(err, ocpp.ErrCallTimeout):
+		return h.res.Error(c, http.StatusGatewayTimeout, "charge point did not answer", err.Error(), nil)
+	case errors.Is(err, ocpp.ErrInvalidCallReply), errors.As(err, &callErr):
+		return h.res.Error(c, http.StatusBadGateway, "charge point failed the request", err.Error(), nil)
+	default:
+		return h.res.ErrorHandler(c, err)
+	}
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/middleware"
+	"github.com/mutoulbj/gocsms/internal/repository"
+	"github.com/mutoulbj/gocsms/internal/services"
+	"github.com/mutoulbj/gocsms/pkg/response"
+)
+
+// SecurityEventHandler exposes the security events reported by charge points
+type SecurityEventHandler struct {
+	svc     *services.SecurityEventService
+	authSvc *services.AuthService
+	redis   *redis.Client
+	log     *logrus.Logger
+	res     response.APIResponseInterface
+}
+
+// NewSecurityEventHandler creates a new SecurityEventHandler
+func NewSecurityEventHandler(
+	svc *services.SecurityEventService,
+	authSvc *services.AuthService,
+	redis *redis.Client,
+	log *logrus.Logger,
+	res response.APIResponseInterface,
+) *SecurityEventHandler {
+	return &SecurityEventHandler{
+		svc:     svc,
+		authSvc: authSvc,
+		redis:   redis,
+		log:     log,
+		res:     res,
+	}
+}
+
+// RegisterRoutes registers the security event routes with the provided router
+func (h *SecurityEventHandler) RegisterRoutes(router fiber.Router) {
+	events := router.Group("/security-events", middleware.Auth(h.authSvc, h.redis, h.log))
+
+	events.Get("/", h.List) // List security events
+}
+
+// List retrieves the security events matching the query filters, most recent first.
+// severity is the minimum severity; from and to are RFC3339 times.
+func (h *SecurityEventHandler) List(c *fiber.Ctx) error {
+	limit, err := parseLimitQuery(c, 100, 1000)
+	if err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid limit", "params error", err.Error())
+	}
+	filter := repository.SecurityEventFilter{
+		Type:  c.Query("type"),
+		Limit: limit,
+	}
+
+	if id := c.Query("charge_point_id"); id != "" {
+		if filter.ChargePointID, err = uuid.Parse(id); err != nil {
+			return h.res.Error(c, http.StatusBadRequest, "invalid charge point ID", "params error", err.Error())
+		}
+	}
+	if severity := c.Query("severity"); severity != "" {
+		threshold := enums.SecurityEventSeverity(strings.ToUpper(severity))
+		if !threshold.IsValid() {
+			return h.res.Error(c, http.StatusBadRequest, "invalid severity", "params error", severity)
+		}
+		filter.Severities = enums.SecurityEventSeveritiesFrom(threshold)
+	}
+	if alert := c.Query("alert"); alert != "" {
+		value, err := strconv.ParseBool(alert)
+		if err != nil {
+			return h.res.Error(c, http.StatusBadRequest, "invalid alert flag", "params error", err.Error())
+		}
+		filter.Alert = &value
+	}
+	if filter.From, err = parseTimeQuery(c, "from"); err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid from time", "params error", err.Error())
+	}
+	if filter.To, err = parseTimeQuery(c, "to"); err != nil {
+		return h.res.Error(c, http.StatusBadRequest, "invalid to time", "params error", err.Error())
+	}
+
+	events, err := h.svc.List(c.Context(), filter)
+	if err != nil {
+		h.log.WithError(err).Error("Failed to list security events")
+		return h.res.ErrorHandler(c, err)
+	}
+	return h.res.Success(c, "Security events retrieved", events)
+}
@@ -0,0 +1,135 @@
+// Package loadtest measures how the CSMS copes with a fleet of virtual
+// chargers: handshake times, CALL to CALLRESULT latencies and error rates,
+// summarised in a Report.
+package loadtest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/mutoulbj/gocsms/internal/ocpp"
+	"github.com/mutoulbj/gocsms/internal/simulator"
+)
+
+// Recorder collects the measurements of a load test. It is a
+// simulator.Observer to be shared by all chargers of the test.
+type Recorder struct {
+	mu         sync.Mutex
+	started    time.Time
+	handshakes *samples
+	calls      map[string]*samples // by action
+	connected  int
+	peak       int
+	dropped    int
+}
+
+var _ simulator.Observer = (*Recorder)(nil)
+
+// samples are the latencies of one kind of exchange and its errors by kind
+type samples struct {
+	latencies []time.Duration
+	errors    map[string]int
+}
+
+func newSamples() *samples {
+	return &samples{errors: make(map[string]int)}
+}
+
+func (s *samples) add(latency time.Duration, err error) {
+	if err != nil {
+		s.errors[errorKind(err)]++
+		return
+	}
+	s.latencies = append(s.latencies, latency)
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		started:    time.Now(),
+		handshakes: newSamples(),
+		calls:      make(map[string]*samples),
+	}
+}
+
+// Connected implements simulator.Observer
+func (r *Recorder) Connected(identity string, handshake time.Duration, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handshakes.add(handshake, err)
+	if err == nil {
+		r.connected++
+		r.peak = max(r.peak, r.connected)
+	}
+}
+
+// Disconnected implements simulator.Observer
+func (r *Recorder) Disconnected(identity string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.connected--
+	if err != nil {
+		r.dropped++
+	}
+}
+
+// CallCompleted implements simulator.Observer. CALLs cut short because the
+// test ended are not counted.
+func (r *Recorder) CallCompleted(identity, action string, latency time.Duration, err error) {
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := r.calls[action]
+	if !ok {
+		s = newSamples()
+		r.calls[action] = s
+	}
+	s.add(latency, err)
+}
+
+// Progress returns the number of open connections and of CALLs and failed
+// CALLs so far, for reporting while the test runs
+func (r *Recorder) Progress() (connected, calls, failed int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.calls {
+		calls += len(s.latencies)
+		for _, n := range s.errors {
+			calls += n
+			failed += n
+		}
+	}
+	return r.connected, calls, failed
+}
+
+// errorKind groups errors for the report: CALLERRORs by error code, timeouts,
+// lost connections and rejected or failed handshakes
+func errorKind(err error) string {
+	var callErr *ocpp.CallErrorResponse
+	var netErr net.Error
+	switch {
+	case errors.As(err, &callErr):
+		return "CALLERROR " + callErr.Code
+	case errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, simulator.ErrNotConnected):
+		return "not connected"
+	case errors.Is(err, websocket.ErrBadHandshake):
+		return "handshake rejected"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &netErr):
+		return "network error"
+	default:
+		return "other"
+	}
+}
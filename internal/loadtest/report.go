@@ -0,0 +1,155 @@
+package loadtest
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"text/tabwriter"
+	"time"
+)
+
+// Report summarises a load test
+type Report struct {
+	Started         time.Time      `json:"started"`
+	DurationSeconds float64        `json:"durationSeconds"`
+	Chargers        int            `json:"chargers"`
+	FailedChargers  int            `json:"failedChargers"` // chargers that could not complete their scenario
+	PeakConnections int            `json:"peakConnections"`
+	Dropped         int            `json:"dropped"` // connections lost rather than closed by the charger
+	Handshakes      Stats          `json:"handshakes"`
+	Calls           Stats          `json:"calls"`
+	CallsPerSecond  float64        `json:"callsPerSecond"`
+	Actions         []ActionStats  `json:"actions"`
+	Errors          map[string]int `json:"errors"` // failed handshakes and CALLs by kind
+}
+
+// Stats are the latency percentiles and error rate of a kind of exchange
+type Stats struct {
+	Count     int            `json:"count"`
+	Failed    int            `json:"failed"`
+	ErrorRate float64        `json:"errorRate"`
+	MinMs     float64        `json:"minMs"`
+	MeanMs    float64        `json:"meanMs"`
+	P50Ms     float64        `json:"p50Ms"`
+	P90Ms     float64        `json:"p90Ms"`
+	P95Ms     float64        `json:"p95Ms"`
+	P99Ms     float64        `json:"p99Ms"`
+	MaxMs     float64        `json:"maxMs"`
+	Errors    map[string]int `json:"errors,omitempty"`
+}
+
+// ActionStats are the Stats of the CALLs of one action
+type ActionStats struct {
+	Action string `json:"action"`
+	Stats
+}
+
+// Report summarises the measurements recorded so far, for a fleet of chargers
+// of which failed did not complete their scenario
+func (r *Recorder) Report(chargers, failed int) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	elapsed := time.Since(r.started)
+	report := &Report{
+		Started:         r.started,
+		DurationSeconds: elapsed.Seconds(),
+		Chargers:        chargers,
+		FailedChargers:  failed,
+		PeakConnections: r.peak,
+		Dropped:         r.dropped,
+		Handshakes:      r.handshakes.stats(),
+		Errors:          make(map[string]int),
+	}
+	for kind, n := range r.handshakes.errors {
+		report.Errors[kind] += n
+	}
+
+	all := newSamples()
+	for _, action := range slices.Sorted(maps.Keys(r.calls)) {
+		s := r.calls[action]
+		report.Actions = append(report.Actions, ActionStats{Action: action, Stats: s.stats()})
+		all.latencies = append(all.latencies, s.latencies...)
+		for kind, n := range s.errors {
+			all.errors[kind] += n
+			report.Errors[kind] += n
+		}
+	}
+	report.Calls = all.stats()
+	if elapsed > 0 {
+		report.CallsPerSecond = float64(report.Calls.Count) / elapsed.Seconds()
+	}
+	return report
+}
+
+func (s *samples) stats() Stats {
+	st := Stats{Count: len(s.latencies)}
+	for _, n := range s.errors {
+		st.Failed += n
+	}
+	st.Count += st.Failed
+	if st.Count > 0 {
+		st.ErrorRate = float64(st.Failed) / float64(st.Count)
+	}
+	if st.Failed > 0 {
+		st.Errors = maps.Clone(s.errors)
+	}
+	if len(s.latencies) == 0 {
+		return st
+	}
+
+	sorted := slices.Clone(s.latencies)
+	slices.Sort(sorted)
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	st.MinMs = ms(sorted[0])
+	st.MeanMs = ms(total / time.Duration(len(sorted)))
+	st.P50Ms = ms(percentile(sorted, 50))
+	st.P90Ms = ms(percentile(sorted, 90))
+	st.P95Ms = ms(percentile(sorted, 95))
+	st.P99Ms = ms(percentile(sorted, 99))
+	st.MaxMs = ms(sorted[len(sorted)-1])
+	return st
+}
+
+// percentile returns the nearest-rank percentile p of sorted latencies
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// WriteText writes the report as tables for humans
+func (rep *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "Load test started %s, ran %s\n", rep.Started.Format(time.RFC3339), time.Duration(rep.DurationSeconds*float64(time.Second)).Round(time.Second))
+	fmt.Fprintf(tw, "Chargers: %d, failed %d\n", rep.Chargers, rep.FailedChargers)
+	fmt.Fprintf(tw, "Connections: peak %d, dropped %d\n", rep.PeakConnections, rep.Dropped)
+	fmt.Fprintf(tw, "Throughput: %.1f CALLs/s\n\n", rep.CallsPerSecond)
+
+	fmt.Fprintln(tw, "\tcount\terrors\terror rate\tmin\tmean\tp50\tp90\tp95\tp99\tmax\t")
+	row := func(name string, st Stats) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.2f%%\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t\n",
+			name, st.Count, st.Failed, 100*st.ErrorRate, st.MinMs, st.MeanMs, st.P50Ms, st.P90Ms, st.P95Ms, st.P99Ms, st.MaxMs)
+	}
+	row("handshake", rep.Handshakes)
+	for _, a := range rep.Actions {
+		row(a.Action, a.Stats)
+	}
+	row("all CALLs", rep.Calls)
+	fmt.Fprintln(tw, "(latencies in ms)")
+
+	if len(rep.Errors) > 0 {
+		fmt.Fprintln(tw, "\nErrors:")
+		for _, kind := range slices.Sorted(maps.Keys(rep.Errors)) {
+			fmt.Fprintf(tw, "%s\t%d\t\n", kind, rep.Errors[kind])
+		}
+	}
+	return tw.Flush()
+}
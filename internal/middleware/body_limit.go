@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"io"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BodyLimit rejects request bodies larger than limit bytes. The app streams
+// request bodies so that uploads are not bound by the global limit, which
+// leaves it to this middleware to read the body of every other route before
+// its handlers run. Paths starting with one of the except prefixes are left
+// to a BodyLimit on the route itself.
+func BodyLimit(limit int, except ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		for _, prefix := range except {
+			if strings.HasPrefix(c.Path(), prefix) {
+				return c.Next()
+			}
+		}
+
+		req := c.Request()
+		if req.Header.ContentLength() > limit {
+			return bodyTooLarge(c)
+		}
+		if stream := req.BodyStream(); stream != nil {
+			body, err := io.ReadAll(io.LimitReader(stream, int64(limit)+1))
+			if err != nil {
+				c.Response().SetConnectionClose()
+				return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Failed to read request body"})
+			}
+			if len(body) > limit {
+				return bodyTooLarge(c)
+			}
+			req.SetBody(body)
+		}
+		return c.Next()
+	}
+}
+
+// bodyTooLarge refuses a request whose body has not been read to its end, so
+// the connection cannot serve further requests
+func bodyTooLarge(c *fiber.Ctx) error {
+	c.Response().SetConnectionClose()
+	return c.Status(fiber.StatusRequestEntityTooLarge).JSON(fiber.Map{"error": "Request body is too large"})
+}
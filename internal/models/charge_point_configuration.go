@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// ChargePointConfiguration is the last-known value of an OCPP configuration key on a
+// charge point, together with the last value the CSMS asked it to change to.
+type ChargePointConfiguration struct {
+	bun.BaseModel `bun:"table:charge_point_configurations,alias:cpc"`
+	ID            uuid.UUID `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	ChargePointID uuid.UUID `bun:"charge_point_id,type:uuid,notnull" json:"charge_point_id"`
+	Key           string    `bun:"key,notnull" json:"key"`
+	Value         string    `bun:"value,nullzero" json:"value"`
+	Readonly      bool      `bun:"readonly,notnull,default:false" json:"readonly"`
+	DesiredValue  string    `bun:"desired_value,nullzero" json:"desired_value,omitempty"`
+	ChangeStatus  string    `bun:"change_status,nullzero" json:"change_status,omitempty"` // Accepted, Rejected, RebootRequired, NotSupported
+	ChangedAt     time.Time `bun:"changed_at,nullzero" json:"changed_at,omitempty"`
+	RefreshedAt   time.Time `bun:"refreshed_at,nullzero" json:"refreshed_at,omitempty"`
+	CreatedAt     time.Time `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt     time.Time `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// ChargePointConnection is one WebSocket session of a charge point with the CSMS
+type ChargePointConnection struct {
+	bun.BaseModel `bun:"table:charge_point_connections,alias:cpcn"`
+
+	ID             uuid.UUID `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	ChargePointID  uuid.UUID `bun:"charge_point_id,type:uuid,notnull" json:"charge_point_id"`
+	ConnectedAt    time.Time `bun:"connected_at,notnull" json:"connected_at"`
+	DisconnectedAt time.Time `bun:"disconnected_at,nullzero" json:"disconnected_at,omitempty"`
+	RemoteAddress  string    `bun:"remote_address,notnull" json:"remote_address"`
+	CloseCode      int       `bun:"close_code,nullzero" json:"close_code,omitempty"`     // WebSocket close code, 1006 when the connection dropped
+	CloseReason    string    `bun:"close_reason,nullzero" json:"close_reason,omitempty"` // Reason sent with the close frame
+}
@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// ConfigurationDrift is a template key whose value on a charge point differed
+// from the template when the charge point was last checked
+type ConfigurationDrift struct {
+	bun.BaseModel `bun:"table:configuration_drifts,alias:cd"`
+	ID            uuid.UUID `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"-"`
+	ChargePointID uuid.UUID `bun:"charge_point_id,type:uuid,notnull" json:"charge_point_id"`
+	TemplateID    uuid.UUID `bun:"template_id,type:uuid,notnull" json:"template_id"`
+	Key           string    `bun:"key,notnull" json:"key"`
+	Expected      string    `bun:"expected,notnull" json:"expected"`
+	Actual        string    `bun:"actual,nullzero" json:"actual"`
+	Missing       bool      `bun:"missing,notnull" json:"missing"` // the key was never reported by the charge point
+	DetectedAt    time.Time `bun:"detected_at,notnull,default:current_timestamp" json:"detected_at"`
+}
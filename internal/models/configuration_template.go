@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// ConfigurationTemplate is the standard set of OCPP configuration keys for the charge
+// points of a vendor, optionally narrowed down to one model. A template with an empty
+// Model applies to every model of the vendor that has no model-specific template.
+type ConfigurationTemplate struct {
+	bun.BaseModel `bun:"table:configuration_templates,alias:ct"`
+	ID            uuid.UUID         `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	Name          string            `bun:"name,notnull" json:"name"`
+	Vendor        string            `bun:"vendor,notnull" json:"vendor"`
+	Model         string            `bun:"model,notnull,default:''" json:"model"`
+	Keys          map[string]string `bun:"keys,type:jsonb,notnull" json:"keys"`
+	CreatedAt     time.Time         `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt     time.Time         `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+func (t *ConfigurationTemplate) BeforeInsert() error {
+	t.ID = uuid.New()
+	t.CreatedAt = time.Now()
+	t.UpdatedAt = time.Now()
+	return nil
+}
+
+func (t *ConfigurationTemplate) BeforeUpdate() error {
+	t.UpdatedAt = time.Now()
+	return nil
+}
@@ -5,22 +5,32 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
 )
 
 type Connector struct {
-	bun.BaseModel `bun:"table:connectors,alias:c"`
-	ID            uuid.UUID    `bun:",pk,type:uuid,default:gen_random_uuid()" json:"id"`
-	ChargePointID uuid.UUID    `bun:"charge_point_id,type:uuid,notnull" json:"charge_point_id"`
-	ConnectorID   string       `bun:"connector_id,notnull" json:"connector_id"`
-	Standard      string       `bun:"standard,notnull" json:"standard"`
-	Format        string       `bun:"format,notnull" json:"format"`
-	PowerType     string       `bun:"power_type,notnull" json:"power_type"`
-	MaxVoltage    int          `bun:"max_voltage,notnull" json:"max_voltage"`
-	MaxAmperage   int          `bun:"max_amperage,notnull" json:"max_amperage"`
-	MaxPower      int          `bun:"max_power,notnull" json:"max_power"`
-	CreatedAt     time.Time    `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
-	UpdatedAt     time.Time    `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
-	ChargePoint   *ChargePoint `bun:"rel:belongs-to,join:charge_point_id=id" json:"charge_point,omitempty"`
+	bun.BaseModel         `bun:"table:connectors,alias:c"`
+	ID                    uuid.UUID               `bun:",pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	ChargePointID         uuid.UUID               `bun:"charge_point_id,type:uuid,notnull" json:"charge_point_id"`
+	ConnectorID           string                  `bun:"connector_id,notnull" json:"connector_id"` // OCPP connector id, "0" is the charge point itself
+	Standard              string                  `bun:"standard,nullzero" json:"standard"`
+	Format                string                  `bun:"format,nullzero" json:"format"`
+	PowerType             string                  `bun:"power_type,nullzero" json:"power_type"`
+	MaxVoltage            int                     `bun:"max_voltage,nullzero" json:"max_voltage"`
+	MaxAmperage           int                     `bun:"max_amperage,nullzero" json:"max_amperage"`
+	MaxPower              int                     `bun:"max_power,nullzero" json:"max_power"`
+	Status                enums.ChargePointStatus `bun:"status,type:VARCHAR(20),default:UNKNOWN,notnull" json:"status"`
+	ErrorCode             string                  `bun:"error_code,nullzero" json:"error_code,omitempty"`                            // e.g. "NoError", "GroundFailure"
+	Info                  string                  `bun:"info,nullzero" json:"info,omitempty"`                                        // Free text from the charge point
+	VendorID              string                  `bun:"vendor_id,nullzero" json:"vendor_id,omitempty"`                              // Vendor of the vendor error code
+	VendorErrorCode       string                  `bun:"vendor_error_code,nullzero" json:"vendor_error_code,omitempty"`              // Vendor specific error code
+	StatusUpdatedAt       time.Time               `bun:"status_updated_at,nullzero" json:"status_updated_at,omitempty"`              // Time the charge point reported the status
+	Availability          enums.Availability      `bun:"availability,notnull,default:OPERATIVE" json:"availability"`                 // as set by ChangeAvailability
+	AvailabilityScheduled bool                    `bun:"availability_scheduled,notnull,default:false" json:"availability_scheduled"` // the change waits for the running transaction to end
+	CreatedAt             time.Time               `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt             time.Time               `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
+	ChargePoint           *ChargePoint            `bun:"rel:belongs-to,join:charge_point_id=id" json:"charge_point,omitempty"`
 }
 
 func (c *Connector) BeforeInsert() error {
@@ -34,3 +44,19 @@ func (c *Connector) BeforeUpdate() error {
 	c.UpdatedAt = time.Now()
 	return nil
 }
+
+// ConnectorStatusHistory is a status reported by a charge point for one of its connectors
+type ConnectorStatusHistory struct {
+	bun.BaseModel `bun:"table:connector_status_history,alias:csh"`
+
+	ID              uuid.UUID               `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	ChargePointID   uuid.UUID               `bun:"charge_point_id,type:uuid,notnull" json:"charge_point_id"`
+	ConnectorID     int                     `bun:"connector_id,notnull" json:"connector_id"` // OCPP connector id, 0 for the charge point itself
+	Status          enums.ChargePointStatus `bun:"status,notnull" json:"status"`
+	ErrorCode       string                  `bun:"error_code,nullzero" json:"error_code,omitempty"`
+	Info            string                  `bun:"info,nullzero" json:"info,omitempty"`
+	VendorID        string                  `bun:"vendor_id,nullzero" json:"vendor_id,omitempty"`
+	VendorErrorCode string                  `bun:"vendor_error_code,nullzero" json:"vendor_error_code,omitempty"`
+	Timestamp       time.Time               `bun:"timestamp,notnull" json:"timestamp"` // Time the charge point reported the status
+	CreatedAt       time.Time               `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+}
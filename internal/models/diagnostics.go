@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
+)
+
+// Diagnostics is a diagnostics (OCPP 1.6) or log (OCPP 2.0.1) upload requested
+// from a charge point. The charge point uploads the file to the diagnostics
+// upload endpoint, which stores it on disk under the charge point.
+type Diagnostics struct {
+	bun.BaseModel `bun:"table:diagnostics,alias:dg"`
+
+	ID            uuid.UUID               `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	ChargePointID uuid.UUID               `bun:"charge_point_id,type:uuid,notnull" json:"charge_point_id"`
+	LogType       string                  `bun:"log_type,notnull" json:"log_type"`                // DiagnosticsLog or SecurityLog, always DiagnosticsLog for OCPP 1.6
+	RequestID     int                     `bun:"request_id,nullzero" json:"request_id,omitempty"` // requestId of GetLog, none for GetDiagnostics
+	Status        enums.DiagnosticsStatus `bun:"status,notnull" json:"status"`
+	UploadStatus  string                  `bun:"upload_status,nullzero" json:"upload_status,omitempty"` // last status reported by the charge point, e.g. "Uploading"
+	StartTime     *time.Time              `bun:"start_time" json:"start_time,omitempty"`                // oldest information to include
+	StopTime      *time.Time              `bun:"stop_time" json:"stop_time,omitempty"`                  // latest information to include
+	FileName      string                  `bun:"file_name,nullzero" json:"file_name,omitempty"`
+	Size          int64                   `bun:"size,nullzero" json:"size,omitempty"`
+	Error         string                  `bun:"error,nullzero" json:"error,omitempty"`
+	UploadedAt    time.Time               `bun:"uploaded_at,nullzero" json:"uploaded_at,omitempty"`
+	CreatedAt     time.Time               `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt     time.Time               `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+func (d *Diagnostics) BeforeInsert() error {
+	if d.ID == uuid.Nil {
+		d.ID = uuid.New()
+	}
+	d.CreatedAt = time.Now()
+	d.UpdatedAt = time.Now()
+	return nil
+}
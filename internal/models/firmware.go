@@ -0,0 +1,98 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
+)
+
+// FirmwareArtifact is a firmware image uploaded to the CSMS for the charge points
+// of a vendor, optionally restricted to some models. The file is stored on disk
+// and served to the charge points by the firmware download endpoint.
+type FirmwareArtifact struct {
+	bun.BaseModel `bun:"table:firmware_artifacts,alias:fa"`
+
+	ID                 uuid.UUID `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	Version            string    `bun:"version,notnull" json:"version"` // firmware version reported by BootNotification once installed
+	Vendor             string    `bun:"vendor,notnull" json:"vendor"`
+	Models             []string  `bun:"models,type:jsonb,notnull" json:"models"` // compatible models, all models of the vendor when empty
+	FileName           string    `bun:"file_name,notnull" json:"file_name"`
+	Size               int64     `bun:"size,notnull" json:"size"`
+	Checksum           string    `bun:"checksum,notnull" json:"checksum"`                                  // hex SHA-256 of the file
+	SigningCertificate string    `bun:"signing_certificate,nullzero" json:"signing_certificate,omitempty"` // PEM certificate of the firmware signer
+	Signature          string    `bun:"signature,nullzero" json:"signature,omitempty"`                     // base64 signature of the file
+	CreatedAt          time.Time `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt          time.Time `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+func (a *FirmwareArtifact) BeforeInsert() error {
+	if a.ID == uuid.Nil {
+		a.ID = uuid.New()
+	}
+	a.CreatedAt = time.Now()
+	a.UpdatedAt = time.Now()
+	return nil
+}
+
+// Signed tells whether the artifact can be installed with a signed firmware update
+func (a *FirmwareArtifact) Signed() bool {
+	return a.SigningCertificate != "" && a.Signature != ""
+}
+
+// FirmwareCampaign rolls a firmware artifact out to a group of charge points in
+// waves. A wave starts once the previous one has ended and its success rate
+// reached the threshold; otherwise the campaign is paused.
+type FirmwareCampaign struct {
+	bun.BaseModel `bun:"table:firmware_campaigns,alias:fc"`
+
+	ID               uuid.UUID                    `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	Name             string                       `bun:"name,notnull" json:"name"`
+	ArtifactID       uuid.UUID                    `bun:"artifact_id,type:uuid,notnull" json:"artifact_id"`
+	Status           enums.FirmwareCampaignStatus `bun:"status,notnull" json:"status"`
+	WaveSize         int                          `bun:"wave_size,notnull" json:"wave_size"`
+	SuccessThreshold float64                      `bun:"success_threshold,notnull" json:"success_threshold"` // minimum share of a wave that must install, 0 to 1
+	CurrentWave      int                          `bun:"current_wave,notnull" json:"current_wave"`
+	Waves            int                          `bun:"waves,notnull" json:"waves"`
+	Retries          *int                         `bun:"retries" json:"retries,omitempty"`               // download retries of the charge point
+	RetryInterval    *int                         `bun:"retry_interval" json:"retry_interval,omitempty"` // seconds between download retries
+	StatusReason     string                       `bun:"status_reason,nullzero" json:"status_reason,omitempty"`
+	WaveStartedAt    time.Time                    `bun:"wave_started_at,nullzero" json:"wave_started_at,omitempty"`
+	CompletedAt      time.Time                    `bun:"completed_at,nullzero" json:"completed_at,omitempty"`
+	CreatedAt        time.Time                    `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt        time.Time                    `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
+	Artifact         *FirmwareArtifact            `bun:"rel:belongs-to,join:artifact_id=id" json:"artifact,omitempty"`
+}
+
+func (c *FirmwareCampaign) BeforeInsert() error {
+	c.ID = uuid.New()
+	c.CreatedAt = time.Now()
+	c.UpdatedAt = time.Now()
+	return nil
+}
+
+// FirmwareCampaignTarget is a charge point of a campaign and the progress of its update
+type FirmwareCampaignTarget struct {
+	bun.BaseModel `bun:"table:firmware_campaign_targets,alias:fct"`
+
+	ID             uuid.UUID                  `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	CampaignID     uuid.UUID                  `bun:"campaign_id,type:uuid,notnull" json:"campaign_id"`
+	ChargePointID  uuid.UUID                  `bun:"charge_point_id,type:uuid,notnull" json:"charge_point_id"`
+	Wave           int                        `bun:"wave,notnull" json:"wave"`
+	Status         enums.FirmwareUpdateStatus `bun:"status,notnull" json:"status"`
+	FirmwareStatus string                     `bun:"firmware_status,nullzero" json:"firmware_status,omitempty"` // last status reported by the charge point, e.g. "Downloading"
+	RequestID      int                        `bun:"request_id,nullzero" json:"request_id,omitempty"`           // requestId of the update request
+	Error          string                     `bun:"error,nullzero" json:"error,omitempty"`
+	SentAt         time.Time                  `bun:"sent_at,nullzero" json:"sent_at,omitempty"`
+	CompletedAt    time.Time                  `bun:"completed_at,nullzero" json:"completed_at,omitempty"`
+	UpdatedAt      time.Time                  `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
+	ChargePoint    *ChargePoint               `bun:"rel:belongs-to,join:charge_point_id=id" json:"charge_point,omitempty"`
+}
+
+func (t *FirmwareCampaignTarget) BeforeInsert() error {
+	t.ID = uuid.New()
+	t.UpdatedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
+)
+
+// MaintenanceWindow takes a group of charge points out of service from its
+// start to its end with ChangeAvailability, and puts them back afterwards
+type MaintenanceWindow struct {
+	bun.BaseModel `bun:"table:maintenance_windows,alias:mw"`
+
+	ID          uuid.UUID                     `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	Name        string                        `bun:"name,notnull" json:"name"`
+	Reason      string                        `bun:"reason,nullzero" json:"reason,omitempty"`
+	Status      enums.MaintenanceWindowStatus `bun:"status,notnull" json:"status"`
+	StartsAt    time.Time                     `bun:"starts_at,notnull" json:"starts_at"`
+	EndsAt      time.Time                     `bun:"ends_at,notnull" json:"ends_at"`
+	CompletedAt time.Time                     `bun:"completed_at,nullzero" json:"completed_at,omitempty"`
+	CreatedAt   time.Time                     `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt   time.Time                     `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+func (w *MaintenanceWindow) BeforeInsert() error {
+	w.ID = uuid.New()
+	w.CreatedAt = time.Now()
+	w.UpdatedAt = time.Now()
+	return nil
+}
+
+// MaintenanceWindowTarget is a charge point of a maintenance window and its availability
+type MaintenanceWindowTarget struct {
+	bun.BaseModel `bun:"table:maintenance_window_targets,alias:mwt"`
+
+	ID            uuid.UUID                     `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	WindowID      uuid.UUID                     `bun:"window_id,type:uuid,notnull" json:"window_id"`
+	ChargePointID uuid.UUID                     `bun:"charge_point_id,type:uuid,notnull" json:"charge_point_id"`
+	Status        enums.MaintenanceTargetStatus `bun:"status,notnull" json:"status"`
+	Error         string                        `bun:"error,nullzero" json:"error,omitempty"`
+	UpdatedAt     time.Time                     `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
+	ChargePoint   *ChargePoint                  `bun:"rel:belongs-to,join:charge_point_id=id" json:"charge_point,omitempty"`
+}
+
+func (t *MaintenanceWindowTarget) BeforeInsert() error {
+	t.ID = uuid.New()
+	t.UpdatedAt = time.Now()
+	return nil
+}
@@ -12,11 +12,16 @@ type MeterValue struct {
 
 	ID            uuid.UUID `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
 	ChargePointID uuid.UUID `bun:"charge_point_id,type:uuid,notnull" json:"charge_point_id"`
-	ConnectorID   uuid.UUID `bun:"connector_id,type:uuid,notnull" json:"connector_id"`
-	Timestamp     time.Time `bun:"timestamp,notnull" json:"timestamp"`    // Unix timestamp in seconds
-	Measurand     string    `bun:"measurand,notnull" json:"measurand"`    // e.g., "Energy.Active.Import.Register"
-	Value         float64   `bun:"value,notnull" json:"value"`            // Value in kWh
-	Unit          string    `bun:"unit,notnull,default:'Wh'" json:"unit"` // e.g., "kWh", "Wh"
-	Context       string    `bun:"context,nullzero" json:"context"`       // Optional context for the meter value
+	ConnectorID   int       `bun:"connector_id,notnull" json:"connector_id"`      // OCPP connector id, 0 for the main meter
+	TransactionID int       `bun:"transaction_id,nullzero" json:"transaction_id"` // OCPP transactionId, if sampled during a transaction
+	Timestamp     time.Time `bun:"timestamp,notnull" json:"timestamp"`            // Time the value was sampled
+	Measurand     string    `bun:"measurand,notnull" json:"measurand"`            // e.g., "Energy.Active.Import.Register"
+	Value         float64   `bun:"value,notnull" json:"value"`                    // Value in Unit
+	RawValue      string    `bun:"raw_value,nullzero" json:"raw_value,omitempty"` // Original value when it is not numeric, e.g. SignedData
+	Unit          string    `bun:"unit,notnull,default:'Wh'" json:"unit"`         // e.g., "kWh", "Wh"
+	Context       string    `bun:"context,nullzero" json:"context"`               // e.g., "Sample.Periodic", "Transaction.Begin"
+	Format        string    `bun:"format,nullzero" json:"format"`                 // "Raw" or "SignedData"
+	Phase         string    `bun:"phase,nullzero" json:"phase,omitempty"`         // e.g., "L1", "L1-N"
+	Location      string    `bun:"location,nullzero" json:"location,omitempty"`   // e.g., "Outlet", "Inlet", "EV"
 	CreatedAt     time.Time `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
 }
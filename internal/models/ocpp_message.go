@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
+)
+
+// OCPPMessage is an OCPP-J frame exchanged with a charge point, as recorded in the message journal
+type OCPPMessage struct {
+	bun.BaseModel `bun:"table:ocpp_messages,alias:om"`
+
+	ID          uuid.UUID              `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	Identity    string                 `bun:"identity,notnull" json:"identity"` // OCPP identity of the charge point
+	Direction   enums.MessageDirection `bun:"direction,notnull" json:"direction"`
+	MessageType int                    `bun:"message_type,notnull" json:"message_type"` // 2 CALL, 3 CALLRESULT, 4 CALLERROR, 0 when the frame is malformed
+	UniqueID    string                 `bun:"unique_id,nullzero" json:"unique_id,omitempty"`
+	Action      string                 `bun:"action,nullzero" json:"action,omitempty"` // for replies, the action of the CALL they answer
+	Payload     string                 `bun:"payload,notnull" json:"payload"`          // the frame as sent on the wire
+	LatencyMs   *int64                 `bun:"latency_ms" json:"latency_ms,omitempty"`  // for replies, the time since the CALL they answer
+	Error       string                 `bun:"error,nullzero" json:"error,omitempty"`   // CALLERROR code and description, or why the frame could not be handled
+	Timestamp   time.Time              `bun:"timestamp,notnull" json:"timestamp"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
+)
+
+// Reservation holds a connector of a charge point for an id tag until it
+// expires, with ReserveNow on the charge point. It is used up by the
+// transaction the charge point starts on it.
+type Reservation struct {
+	bun.BaseModel `bun:"table:reservations,alias:rsv"`
+
+	ID            uuid.UUID               `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	ReservationID int                     `bun:"reservation_id,nullzero,notnull" json:"reservation_id"` // OCPP reservationId, assigned by the database sequence
+	ChargePointID uuid.UUID               `bun:"charge_point_id,type:uuid,notnull" json:"charge_point_id"`
+	ConnectorID   int                     `bun:"connector_id,notnull" json:"connector_id"` // 0 for any connector, the EVSE id for OCPP 2.0.1
+	IdTag         string                  `bun:"id_tag,notnull" json:"id_tag"`
+	ParentIdTag   string                  `bun:"parent_id_tag,nullzero" json:"parent_id_tag,omitempty"`
+	Status        enums.ReservationStatus `bun:"status,notnull" json:"status"`
+	ExpiresAt     time.Time               `bun:"expires_at,notnull" json:"expires_at"`
+	TransactionID int                     `bun:"transaction_id,nullzero" json:"transaction_id,omitempty"` // OCPP transactionId of the transaction started on the reservation
+	Error         string                  `bun:"error,nullzero" json:"error,omitempty"`
+	CreatedAt     time.Time               `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt     time.Time               `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+func (r *Reservation) BeforeInsert() error {
+	r.ID = uuid.New()
+	r.CreatedAt = time.Now()
+	r.UpdatedAt = time.Now()
+	return nil
+}
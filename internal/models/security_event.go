@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
+)
+
+// SecurityEvent is a security event reported by a charge point with SecurityEventNotification
+type SecurityEvent struct {
+	bun.BaseModel `bun:"table:security_events,alias:se"`
+
+	ID            uuid.UUID                   `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	ChargePointID uuid.UUID                   `bun:"charge_point_id,type:uuid,notnull" json:"charge_point_id"`
+	Type          string                      `bun:"type,notnull" json:"type"`                      // e.g. "TamperDetectionActivated", "InvalidFirmwareSignature"
+	Severity      enums.SecurityEventSeverity `bun:"severity,notnull" json:"severity"`              // assigned by the CSMS from the event type
+	Timestamp     time.Time                   `bun:"timestamp,notnull" json:"timestamp"`            // Time the charge point observed the event
+	TechInfo      string                      `bun:"tech_info,nullzero" json:"tech_info,omitempty"` // Additional information from the charge point
+	Alert         bool                        `bun:"alert,notnull,default:false" json:"alert"`      // the severity reached the alert threshold
+	CreatedAt     time.Time                   `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
+}
@@ -11,15 +11,34 @@ type Transaction struct {
 	bun.BaseModel  `bun:"table:transactions,alias:tx"`
 	ID             uuid.UUID `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
 	ChargePointID  uuid.UUID `bun:"charge_point_id,type:uuid,notnull" json:"charge_point_id"`
-	ConnectorID    uuid.UUID `bun:"connector_id,type:uuid,notnull" json:"connector_id"`
-	TransactionID  int       `bun:"transaction_id,notnull" json:"transaction_id"`
+	ConnectorID    int       `bun:"connector_id,notnull" json:"connector_id"`                                // OCPP connector id on the charge point
+	TransactionID  int       `bun:"transaction_id,nullzero,notnull" json:"transaction_id"`                   // OCPP transactionId, assigned by the database sequence
+	ChargerTxID    string    `bun:"charger_transaction_id,nullzero" json:"charger_transaction_id,omitempty"` // OCPP 2.0.1 transactionId, assigned by the charging station
+	IdTag          string    `bun:"id_tag,notnull" json:"id_tag"`
 	UserID         uuid.UUID `bun:"user_id,type:uuid,nullzero" json:"user_id"`
 	StartTime      time.Time `bun:"start_time,notnull" json:"start_time"`
 	StopTime       time.Time `bun:"stop_time,nullzero" json:"stop_time"`
-	MeterStart     float64   `bun:"meter_start,notnull" json:"meter_start"`
-	MeterStop      float64   `bun:"meter_stop,nullzero" json:"meter_stop"`
+	MeterStart     float64   `bun:"meter_start,notnull" json:"meter_start"` // Wh
+	MeterStop      float64   `bun:"meter_stop,nullzero" json:"meter_stop"`  // Wh
 	TotalEnergyKwh float64   `bun:"total_energy_kwh,notnull" json:"total_energy_kwh"`
 	StopReason     string    `bun:"stop_reason,nullzero" json:"stop_reason"`
 	CreatedAt      time.Time `bun:"created_at,notnull,default:current_timestamp" json:"created_at"`
 	UpdatedAt      time.Time `bun:"updated_at,notnull,default:current_timestamp" json:"updated_at"`
 }
+
+func (t *Transaction) BeforeInsert() error {
+	t.ID = uuid.New()
+	t.CreatedAt = time.Now()
+	t.UpdatedAt = time.Now()
+	return nil
+}
+
+func (t *Transaction) BeforeUpdate() error {
+	t.UpdatedAt = time.Now()
+	return nil
+}
+
+// IsActive reports whether the transaction has not been stopped yet
+func (t *Transaction) IsActive() bool {
+	return t.StopTime.IsZero()
+}
@@ -0,0 +1,22 @@
+package ocpp
+
+import "context"
+
+type afterReplyKey struct{}
+
+// withAfterReply returns a context that collects work to run once the reply to
+// the CALL being handled has been written, e.g. CSMS-initiated calls that the
+// charge point must only receive after the CALLRESULT.
+func withAfterReply(ctx context.Context) (context.Context, *[]func()) {
+	fns := &[]func(){}
+	return context.WithValue(ctx, afterReplyKey{}, fns), fns
+}
+
+// afterReply schedules fn to run in its own goroutine after the reply is written
+func afterReply(ctx context.Context, fn func()) {
+	if fns, ok := ctx.Value(afterReplyKey{}).(*[]func()); ok {
+		*fns = append(*fns, fn)
+		return
+	}
+	go fn()
+}
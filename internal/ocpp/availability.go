@@ -0,0 +1,49 @@
+package ocpp
+
+import (
+	"context"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/ocpp/v201"
+)
+
+// SetAvailability makes a connector of a charge point, or the charge point as a
+// whole when connectorID is 0, operative or inoperative with the
+// ChangeAvailability of the OCPP version it is connected with, and records the
+// availability it accepted. The connector is the EVSE for OCPP 2.0.1 charging
+// stations. A Scheduled answer means the change waits for the running
+// transactions to end.
+func (s *Server) SetAvailability(ctx context.Context, cp *models.ChargePoint, connectorID int, availability enums.Availability) (string, error) {
+	version, ok := s.ConnectedVersion(ctx, cp.Code)
+	if !ok {
+		return "", ErrChargePointNotConnected
+	}
+	var status string
+	if version == OCPP201 {
+		req := v201.ChangeAvailabilityRequest{OperationalStatus: availability.OCPP()}
+		if connectorID > 0 {
+			req.EVSE = &v201.EVSE{ID: connectorID}
+		}
+		resp, err := s.ChangeAvailabilityV201(ctx, cp.Code, req)
+		if err != nil {
+			return "", err
+		}
+		status = resp.Status
+	} else {
+		resp, err := s.ChangeAvailability(ctx, cp.Code, ChangeAvailabilityRequest{
+			ConnectorID: connectorID,
+			Type:        availability.OCPP(),
+		})
+		if err != nil {
+			return "", err
+		}
+		status = resp.Status
+	}
+
+	if status != "Accepted" && status != "Scheduled" {
+		return status, nil
+	}
+	s.log.Infof("Charge point %s set connector %d %s (%s)", cp.Code, connectorID, availability, status)
+	return status, s.svc.RecordAvailability(ctx, cp.ID, connectorID, availability, status == "Scheduled")
+}
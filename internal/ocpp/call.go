@@ -0,0 +1,172 @@
+package ocpp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
+)
+
+var (
+	ErrChargePointNotConnected = errors.New("charge point is not connected")
+	ErrCallTimeout             = errors.New("charge point did not respond in time")
+	ErrConnectionClosed        = errors.New("charge point connection closed")
+	ErrOutboxFull              = errors.New("charge point is not reading its messages")
+	ErrInvalidCallRequest      = errors.New("request does not conform to the OCPP schema")
+	ErrInvalidCallReply        = errors.New("charge point reply does not conform to the OCPP schema")
+	ErrVersionNotSupported     = errors.New("command is not supported for the OCPP version of the charge point")
+)
+
+// CallErrorResponse is returned by Server.Call when the charge point answers with a CALLERROR
+type CallErrorResponse struct {
+	Code        string
+	Description string
+	Details     json.RawMessage
+}
+
+func (e *CallErrorResponse) Error() string {
+	return fmt.Sprintf("charge point returned %s: %s", e.Code, e.Description)
+}
+
+// Call sends a CALL to a connected charge point and waits for its CALLRESULT.
+// Calls to the same charge point are queued so only one is in flight at a
+// time; ctx bounds both the wait for the slot and the wait for the reply,
+// and the configured call timeout applies when ctx has no deadline. Both the
+// request and the reply are checked against the OCPP schemas. Calls to charge
+// points connected to another node are relayed to that node.
+func (s *Server) Call(ctx context.Context, identity, action string, req any) (json.RawMessage, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.cfg.CallTimeout)
+		defer cancel()
+	}
+
+	s.mu.RLock()
+	conn, ok := s.clients[identity]
+	s.mu.RUnlock()
+	if ok {
+		return s.callConnection(ctx, conn, action, req)
+	}
+
+	node, _, err := s.registry.Lookup(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	if node == s.registry.Node() {
+		// stale entry of a connection this node has lost
+		return nil, ErrChargePointNotConnected
+	}
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	s.log.Infof("Relaying %s to %s on node %s", action, identity, node)
+	return s.registry.Forward(ctx, node, identity, action, payload)
+}
+
+// callRelayed performs a call relayed by another node to a charge point connected to this node
+func (s *Server) callRelayed(ctx context.Context, identity, action string, payload json.RawMessage) (json.RawMessage, error) {
+	s.mu.RLock()
+	conn, ok := s.clients[identity]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrChargePointNotConnected
+	}
+	return s.callConnection(ctx, conn, action, payload)
+}
+
+// callConnection sends a CALL on the connection of a charge point and waits for its reply
+func (s *Server) callConnection(ctx context.Context, conn *connection, action string, req any) (json.RawMessage, error) {
+	identity := conn.id
+	select {
+	case conn.callSlot <- struct{}{}:
+		defer func() { <-conn.callSlot }()
+	case <-conn.closed:
+		return nil, ErrConnectionClosed
+	case <-ctx.Done():
+		return nil, ctxErr(ctx)
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	cp, err := s.svc.GetByCode(ctx, identity)
+	if err != nil {
+		return nil, err
+	}
+	mode := s.handler.schemaMode(cp, OCPPMessage{Action: action})
+	if err := s.handler.checkPayload(conn.version, mode, identity, action, false, payload); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCallRequest, err)
+	}
+	msg := OCPPMessage{
+		MessageTypeID: Call,
+		UniqueID:      uuid.NewString(),
+		Action:        action,
+		Payload:       payload,
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	replyCh := conn.register(msg.UniqueID, action)
+	defer conn.unregister(msg.UniqueID)
+
+	s.log.Infof("Sending %s to %s", action, identity)
+	if err := conn.write(data); err != nil {
+		return nil, fmt.Errorf("failed to send %s: %w", action, err)
+	}
+	s.journal(identity, enums.MessageDirectionOutbound, data, action, time.Time{})
+
+	select {
+	case reply := <-replyCh:
+		if reply.MessageTypeID == CallError {
+			return nil, &CallErrorResponse{Code: reply.ErrorCode, Description: reply.ErrorMessage, Details: reply.ErrorDetails}
+		}
+		if err := s.handler.checkPayload(conn.version, mode, identity, action, true, reply.Payload); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCallReply, err)
+		}
+		return reply.Payload, nil
+	case <-conn.closed:
+		return nil, ErrConnectionClosed
+	case <-ctx.Done():
+		return nil, ctxErr(ctx)
+	}
+}
+
+// IsConnected reports whether the charge point holds a connection to this server
+func (s *Server) IsConnected(identity string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.clients[identity]
+	return ok
+}
+
+// ConnectedVersion returns the OCPP version the charge point is connected with,
+// to this node or another one
+func (s *Server) ConnectedVersion(ctx context.Context, identity string) (ProtocolVersion, bool) {
+	s.mu.RLock()
+	conn, ok := s.clients[identity]
+	s.mu.RUnlock()
+	if ok {
+		return conn.version, true
+	}
+	node, version, err := s.registry.Lookup(ctx, identity)
+	if err != nil || node == s.registry.Node() {
+		return "", false
+	}
+	return version, true
+}
+
+func ctxErr(ctx context.Context) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrCallTimeout
+	}
+	return ctx.Err()
+}
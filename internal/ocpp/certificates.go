@@ -0,0 +1,201 @@
+package ocpp
+
+import (
+	"context"
+	"crypto/x509"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/dto"
+	"github.com/mutoulbj/gocsms/internal/ocpp/v201"
+	"github.com/mutoulbj/gocsms/internal/pki"
+	"github.com/mutoulbj/gocsms/internal/services"
+)
+
+// rootCertificateTypes are the certificate types of GetInstalledCertificateIds in the OCPP 1.6 security extension
+var rootCertificateTypes = []string{"CentralSystemRootCertificate", "ManufacturerRootCertificate"}
+
+// NewCertificateSigner returns the local CA signer configured by OCPP_CA_CERT_FILE
+// and OCPP_CA_KEY_FILE, or one backed by a throwaway CA when none is configured.
+// Replace the pki.Signer provider to sign with an external CA.
+func NewCertificateSigner(cfg *config.OCPPConfig, log *logrus.Logger) (pki.Signer, error) {
+	if cfg.CACertFile != "" {
+		ca, err := pki.LoadCA(cfg.CACertFile, cfg.CAKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return pki.NewLocalSigner(ca, cfg.CertificateValidity), nil
+	}
+	log.Warn("No OCPP CA configured, charge point certificates are signed by a throwaway CA")
+	ca, err := pki.NewCA("gocsms local CA", cfg.CertificateValidity)
+	if err != nil {
+		return nil, err
+	}
+	return pki.NewLocalSigner(ca, cfg.CertificateValidity), nil
+}
+
+// CertificateManager signs the certificate signing requests of charge points
+// and manages the certificates installed on them, using the messages of the
+// OCPP 1.6 security extension or of OCPP 2.0.1 depending on the connection.
+type CertificateManager struct {
+	server *Server
+	signer pki.Signer
+	cpSvc  *services.ChargePointService
+	log    *logrus.Logger
+}
+
+func NewCertificateManager(
+	server *Server,
+	signer pki.Signer,
+	cpSvc *services.ChargePointService,
+	log *logrus.Logger,
+) *CertificateManager {
+	m := &CertificateManager{
+		server: server,
+		signer: signer,
+		cpSvc:  cpSvc,
+		log:    log,
+	}
+	server.OnSignCertificate(m.sign)
+	return m
+}
+
+// Install runs InstallCertificate on the charge point and returns its answer
+func (m *CertificateManager) Install(ctx context.Context, chargePointID uuid.UUID, certificateType, certificate string) (string, error) {
+	identity, version, err := m.connection(ctx, chargePointID)
+	if err != nil {
+		return "", err
+	}
+	if version == OCPP201 {
+		resp, err := m.server.InstallCertificateV201(ctx, identity, v201.InstallCertificateRequest{CertificateType: certificateType, Certificate: certificate})
+		if err != nil {
+			return "", err
+		}
+		return resp.Status, nil
+	}
+	resp, err := m.server.InstallCertificate(ctx, identity, InstallCertificateRequest{CertificateType: certificateType, Certificate: certificate})
+	if err != nil {
+		return "", err
+	}
+	return resp.Status, nil
+}
+
+// Installed runs GetInstalledCertificateIds on the charge point for the given
+// certificate types, all types when none are given. OCPP 1.6 asks for one type
+// at a time, so the types are queried one by one there.
+func (m *CertificateManager) Installed(ctx context.Context, chargePointID uuid.UUID, certificateTypes []string) (*dto.InstalledCertificatesResponse, error) {
+	identity, version, err := m.connection(ctx, chargePointID)
+	if err != nil {
+		return nil, err
+	}
+	result := &dto.InstalledCertificatesResponse{Status: "NotFound", Certificates: []dto.InstalledCertificate{}}
+
+	if version == OCPP201 {
+		resp, err := m.server.GetInstalledCertificateIdsV201(ctx, identity, v201.GetInstalledCertificateIdsRequest{CertificateType: certificateTypes})
+		if err != nil {
+			return nil, err
+		}
+		result.Status = resp.Status
+		for _, chain := range resp.CertificateHashDataChain {
+			installed := dto.InstalledCertificate{
+				CertificateType: chain.CertificateType,
+				HashData:        dto.CertificateHashData(chain.CertificateHashData),
+			}
+			for _, child := range chain.ChildCertificateHashData {
+				installed.Children = append(installed.Children, dto.CertificateHashData(child))
+			}
+			result.Certificates = append(result.Certificates, installed)
+		}
+		return result, nil
+	}
+
+	if len(certificateTypes) == 0 {
+		certificateTypes = rootCertificateTypes
+	}
+	for _, certificateType := range certificateTypes {
+		resp, err := m.server.GetInstalledCertificateIds(ctx, identity, GetInstalledCertificateIdsRequest{CertificateType: certificateType})
+		if err != nil {
+			return nil, err
+		}
+		if resp.Status == "Accepted" {
+			result.Status = resp.Status
+		}
+		for _, hash := range resp.CertificateHashData {
+			result.Certificates = append(result.Certificates, dto.InstalledCertificate{
+				CertificateType: certificateType,
+				HashData:        dto.CertificateHashData(hash),
+			})
+		}
+	}
+	return result, nil
+}
+
+// Delete runs DeleteCertificate on the charge point and returns its answer
+func (m *CertificateManager) Delete(ctx context.Context, chargePointID uuid.UUID, hash dto.CertificateHashData) (string, error) {
+	identity, version, err := m.connection(ctx, chargePointID)
+	if err != nil {
+		return "", err
+	}
+	if version == OCPP201 {
+		resp, err := m.server.DeleteCertificateV201(ctx, identity, v201.DeleteCertificateRequest{CertificateHashData: v201.CertificateHashData(hash)})
+		if err != nil {
+			return "", err
+		}
+		return resp.Status, nil
+	}
+	resp, err := m.server.DeleteCertificate(ctx, identity, DeleteCertificateRequest{CertificateHashData: CertificateHashData(hash)})
+	if err != nil {
+		return "", err
+	}
+	return resp.Status, nil
+}
+
+// sign signs an accepted certificate signing request and sends the chain to the charge point
+func (m *CertificateManager) sign(ctx context.Context, identity string, version ProtocolVersion, csr *x509.CertificateRequest, certificateType string) {
+	chain, err := m.signer.Sign(ctx, csr, certificateType)
+	if err != nil {
+		m.log.WithError(err).Errorf("Failed to sign %s of charge point %s", certificateType, identity)
+		return
+	}
+
+	var status string
+	if version == OCPP201 {
+		resp, err := m.server.CertificateSignedV201(ctx, identity, v201.CertificateSignedRequest{
+			CertificateChain: string(chain),
+			CertificateType:  certificateType,
+		})
+		if err != nil {
+			m.log.WithError(err).Errorf("Failed to send signed %s to charge point %s", certificateType, identity)
+			return
+		}
+		status = resp.Status
+	} else {
+		resp, err := m.server.CertificateSigned(ctx, identity, CertificateSignedRequest{CertificateChain: string(chain)})
+		if err != nil {
+			m.log.WithError(err).Errorf("Failed to send signed %s to charge point %s", certificateType, identity)
+			return
+		}
+		status = resp.Status
+	}
+
+	if status != "Accepted" {
+		m.log.Warnf("Charge point %s answered %s to its signed %s", identity, status, certificateType)
+		return
+	}
+	m.log.Infof("Charge point %s installed its signed %s", identity, certificateType)
+}
+
+// connection returns the OCPP identity of the charge point and the version it is connected with
+func (m *CertificateManager) connection(ctx context.Context, chargePointID uuid.UUID) (string, ProtocolVersion, error) {
+	cp, err := m.cpSvc.GetByID(ctx, chargePointID.String())
+	if err != nil {
+		return "", "", err
+	}
+	version, ok := m.server.ConnectedVersion(ctx, cp.Code)
+	if !ok {
+		return "", "", ErrChargePointNotConnected
+	}
+	return cp.Code, version, nil
+}
@@ -0,0 +1,105 @@
+package ocpp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// RemoteStartTransaction asks the charge point to start a transaction for an id tag
+func (s *Server) RemoteStartTransaction(ctx context.Context, identity string, req RemoteStartTransactionRequest) (*RemoteStartTransactionResponse, error) {
+	return call[RemoteStartTransactionResponse](ctx, s, identity, "RemoteStartTransaction", req)
+}
+
+// RemoteStopTransaction asks the charge point to stop an ongoing transaction
+func (s *Server) RemoteStopTransaction(ctx context.Context, identity string, req RemoteStopTransactionRequest) (*RemoteStopTransactionResponse, error) {
+	return call[RemoteStopTransactionResponse](ctx, s, identity, "RemoteStopTransaction", req)
+}
+
+// Reset asks the charge point to perform a soft or hard reset
+func (s *Server) Reset(ctx context.Context, identity string, req ResetRequest) (*ResetResponse, error) {
+	return call[ResetResponse](ctx, s, identity, "Reset", req)
+}
+
+// UnlockConnector asks the charge point to unlock a connector
+func (s *Server) UnlockConnector(ctx context.Context, identity string, req UnlockConnectorRequest) (*UnlockConnectorResponse, error) {
+	return call[UnlockConnectorResponse](ctx, s, identity, "UnlockConnector", req)
+}
+
+// GetConfiguration asks the charge point for the values of configuration keys, all keys when none are given
+func (s *Server) GetConfiguration(ctx context.Context, identity string, req GetConfigurationRequest) (*GetConfigurationResponse, error) {
+	return call[GetConfigurationResponse](ctx, s, identity, "GetConfiguration", req)
+}
+
+// ChangeConfiguration asks the charge point to change the value of a configuration key
+func (s *Server) ChangeConfiguration(ctx context.Context, identity string, req ChangeConfigurationRequest) (*ChangeConfigurationResponse, error) {
+	return call[ChangeConfigurationResponse](ctx, s, identity, "ChangeConfiguration", req)
+}
+
+// CertificateSigned delivers a certificate chain signed for the charge point
+func (s *Server) CertificateSigned(ctx context.Context, identity string, req CertificateSignedRequest) (*CertificateSignedResponse, error) {
+	return call[CertificateSignedResponse](ctx, s, identity, "CertificateSigned", req)
+}
+
+// InstallCertificate asks the charge point to install a root certificate
+func (s *Server) InstallCertificate(ctx context.Context, identity string, req InstallCertificateRequest) (*InstallCertificateResponse, error) {
+	return call[InstallCertificateResponse](ctx, s, identity, "InstallCertificate", req)
+}
+
+// GetInstalledCertificateIds asks the charge point for the root certificates it has installed
+func (s *Server) GetInstalledCertificateIds(ctx context.Context, identity string, req GetInstalledCertificateIdsRequest) (*GetInstalledCertificateIdsResponse, error) {
+	return call[GetInstalledCertificateIdsResponse](ctx, s, identity, "GetInstalledCertificateIds", req)
+}
+
+// DeleteCertificate asks the charge point to delete an installed certificate
+func (s *Server) DeleteCertificate(ctx context.Context, identity string, req DeleteCertificateRequest) (*DeleteCertificateResponse, error) {
+	return call[DeleteCertificateResponse](ctx, s, identity, "DeleteCertificate", req)
+}
+
+// UpdateFirmware asks the charge point to download and install firmware from a location
+func (s *Server) UpdateFirmware(ctx context.Context, identity string, req UpdateFirmwareRequest) (*UpdateFirmwareResponse, error) {
+	return call[UpdateFirmwareResponse](ctx, s, identity, "UpdateFirmware", req)
+}
+
+// SignedUpdateFirmware asks the charge point to download, verify and install signed firmware
+func (s *Server) SignedUpdateFirmware(ctx context.Context, identity string, req SignedUpdateFirmwareRequest) (*SignedUpdateFirmwareResponse, error) {
+	return call[SignedUpdateFirmwareResponse](ctx, s, identity, "SignedUpdateFirmware", req)
+}
+
+// GetDiagnostics asks the charge point to upload its diagnostics information to a location
+func (s *Server) GetDiagnostics(ctx context.Context, identity string, req GetDiagnosticsRequest) (*GetDiagnosticsResponse, error) {
+	return call[GetDiagnosticsResponse](ctx, s, identity, "GetDiagnostics", req)
+}
+
+// TriggerMessage asks the charge point to send one of its messages
+func (s *Server) TriggerMessage(ctx context.Context, identity string, req TriggerMessageRequest) (*TriggerMessageResponse, error) {
+	return call[TriggerMessageResponse](ctx, s, identity, "TriggerMessage", req)
+}
+
+// ChangeAvailability makes a connector or the whole charge point operative or inoperative
+func (s *Server) ChangeAvailability(ctx context.Context, identity string, req ChangeAvailabilityRequest) (*ChangeAvailabilityResponse, error) {
+	return call[ChangeAvailabilityResponse](ctx, s, identity, "ChangeAvailability", req)
+}
+
+// ReserveNow reserves a connector of the charge point for an id tag until the expiry date
+func (s *Server) ReserveNow(ctx context.Context, identity string, req ReserveNowRequest) (*ReserveNowResponse, error) {
+	return call[ReserveNowResponse](ctx, s, identity, "ReserveNow", req)
+}
+
+// CancelReservation cancels a reservation on the charge point
+func (s *Server) CancelReservation(ctx context.Context, identity string, req CancelReservationRequest) (*CancelReservationResponse, error) {
+	return call[CancelReservationResponse](ctx, s, identity, "CancelReservation", req)
+}
+
+// call performs a CSMS-initiated call and decodes the CALLRESULT payload into Resp
+func call[Resp any](ctx context.Context, s *Server, identity, action string, req any) (*Resp, error) {
+	payload, err := s.Call(ctx, identity, action, req)
+	if err != nil {
+		return nil, err
+	}
+	var resp Resp
+	if err := json.Unmarshal(payload, &resp); err != nil {
+		return nil, fmt.Errorf("invalid %s response: %w", action, err)
+	}
+	return &resp, nil
+}
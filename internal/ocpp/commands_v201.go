@@ -0,0 +1,77 @@
+package ocpp
+
+import (
+	"context"
+
+	"github.com/mutoulbj/gocsms/internal/ocpp/v201"
+)
+
+// CertificateSignedV201 delivers a certificate chain signed for the charging station
+func (s *Server) CertificateSignedV201(ctx context.Context, identity string, req v201.CertificateSignedRequest) (*v201.CertificateSignedResponse, error) {
+	return call[v201.CertificateSignedResponse](ctx, s, identity, "CertificateSigned", req)
+}
+
+// InstallCertificateV201 asks the charging station to install a root certificate
+func (s *Server) InstallCertificateV201(ctx context.Context, identity string, req v201.InstallCertificateRequest) (*v201.InstallCertificateResponse, error) {
+	return call[v201.InstallCertificateResponse](ctx, s, identity, "InstallCertificate", req)
+}
+
+// GetInstalledCertificateIdsV201 asks the charging station for the certificates it has installed
+func (s *Server) GetInstalledCertificateIdsV201(ctx context.Context, identity string, req v201.GetInstalledCertificateIdsRequest) (*v201.GetInstalledCertificateIdsResponse, error) {
+	return call[v201.GetInstalledCertificateIdsResponse](ctx, s, identity, "GetInstalledCertificateIds", req)
+}
+
+// DeleteCertificateV201 asks the charging station to delete an installed certificate
+func (s *Server) DeleteCertificateV201(ctx context.Context, identity string, req v201.DeleteCertificateRequest) (*v201.DeleteCertificateResponse, error) {
+	return call[v201.DeleteCertificateResponse](ctx, s, identity, "DeleteCertificate", req)
+}
+
+// UpdateFirmwareV201 asks the charging station to download and install firmware from a location
+func (s *Server) UpdateFirmwareV201(ctx context.Context, identity string, req v201.UpdateFirmwareRequest) (*v201.UpdateFirmwareResponse, error) {
+	return call[v201.UpdateFirmwareResponse](ctx, s, identity, "UpdateFirmware", req)
+}
+
+// GetLogV201 asks the charging station to upload a log to a location
+func (s *Server) GetLogV201(ctx context.Context, identity string, req v201.GetLogRequest) (*v201.GetLogResponse, error) {
+	return call[v201.GetLogResponse](ctx, s, identity, "GetLog", req)
+}
+
+// TriggerMessageV201 asks the charging station to send one of its messages
+func (s *Server) TriggerMessageV201(ctx context.Context, identity string, req v201.TriggerMessageRequest) (*v201.TriggerMessageResponse, error) {
+	return call[v201.TriggerMessageResponse](ctx, s, identity, "TriggerMessage", req)
+}
+
+// ChangeAvailabilityV201 makes an EVSE or the whole charging station operative or inoperative
+func (s *Server) ChangeAvailabilityV201(ctx context.Context, identity string, req v201.ChangeAvailabilityRequest) (*v201.ChangeAvailabilityResponse, error) {
+	return call[v201.ChangeAvailabilityResponse](ctx, s, identity, "ChangeAvailability", req)
+}
+
+// ReserveNowV201 reserves an EVSE, or any EVSE of the charging station, for an id token until the expiry date
+func (s *Server) ReserveNowV201(ctx context.Context, identity string, req v201.ReserveNowRequest) (*v201.ReserveNowResponse, error) {
+	return call[v201.ReserveNowResponse](ctx, s, identity, "ReserveNow", req)
+}
+
+// CancelReservationV201 cancels a reservation on the charging station
+func (s *Server) CancelReservationV201(ctx context.Context, identity string, req v201.CancelReservationRequest) (*v201.CancelReservationResponse, error) {
+	return call[v201.CancelReservationResponse](ctx, s, identity, "CancelReservation", req)
+}
+
+// RequestStartTransactionV201 asks the charging station to start a transaction for an id token
+func (s *Server) RequestStartTransactionV201(ctx context.Context, identity string, req v201.RequestStartTransactionRequest) (*v201.RequestStartTransactionResponse, error) {
+	return call[v201.RequestStartTransactionResponse](ctx, s, identity, "RequestStartTransaction", req)
+}
+
+// RequestStopTransactionV201 asks the charging station to stop an ongoing transaction
+func (s *Server) RequestStopTransactionV201(ctx context.Context, identity string, req v201.RequestStopTransactionRequest) (*v201.RequestStopTransactionResponse, error) {
+	return call[v201.RequestStopTransactionResponse](ctx, s, identity, "RequestStopTransaction", req)
+}
+
+// ResetV201 asks the charging station, or one of its EVSEs, to reset immediately or once idle
+func (s *Server) ResetV201(ctx context.Context, identity string, req v201.ResetRequest) (*v201.ResetResponse, error) {
+	return call[v201.ResetResponse](ctx, s, identity, "Reset", req)
+}
+
+// UnlockConnectorV201 asks the charging station to unlock a connector of an EVSE
+func (s *Server) UnlockConnectorV201(ctx context.Context, identity string, req v201.UnlockConnectorRequest) (*v201.UnlockConnectorResponse, error) {
+	return call[v201.UnlockConnectorResponse](ctx, s, identity, "UnlockConnector", req)
+}
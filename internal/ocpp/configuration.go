@@ -0,0 +1,254 @@
+package ocpp
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/services"
+)
+
+// driftWorkers is how many charge points are checked for drift at the same
+// time, each check runs GetConfiguration on the charge point
+const driftWorkers = 10
+
+// ConfigurationManager reads and changes OCPP configuration keys on connected
+// charge points and keeps the last-known key values in the database. It applies
+// configuration templates when a charge point boots and periodically checks
+// connected charge points for drift from their template, storing the drift it
+// finds for the drift report. Configuration keys and
+// templates are OCPP 1.6 only, OCPP 2.0.1 charging stations are left alone.
+type ConfigurationManager struct {
+	server *Server
+	cfg    *config.OCPPConfig
+	svc    *services.ChargePointConfigurationService
+	cpSvc  *services.ChargePointService
+	tplSvc *services.ConfigurationTemplateService
+	log    *logrus.Logger
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewConfigurationManager(
+	server *Server,
+	cfg *config.OCPPConfig,
+	svc *services.ChargePointConfigurationService,
+	cpSvc *services.ChargePointService,
+	tplSvc *services.ConfigurationTemplateService,
+	log *logrus.Logger,
+) *ConfigurationManager {
+	m := &ConfigurationManager{
+		server: server,
+		cfg:    cfg,
+		svc:    svc,
+		cpSvc:  cpSvc,
+		tplSvc: tplSvc,
+		log:    log,
+		done:   make(chan struct{}),
+	}
+	server.OnBootAccepted(m.applyTemplate)
+	return m
+}
+
+// Start launches the periodic drift detection
+func (m *ConfigurationManager) Start() {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.cfg.ConfigurationDriftInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.detectDrift()
+			case <-m.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic drift detection
+func (m *ConfigurationManager) Stop() {
+	close(m.done)
+	m.wg.Wait()
+}
+
+// Refresh runs GetConfiguration on the charge point and stores the reported keys.
+// It returns the keys the charge point did not recognize.
+func (m *ConfigurationManager) Refresh(ctx context.Context, chargePointID uuid.UUID, keys []string) ([]string, error) {
+	identity, err := m.identity(ctx, chargePointID)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.server.GetConfiguration(ctx, identity, GetConfigurationRequest{Key: keys})
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*models.ChargePointConfiguration, 0, len(resp.ConfigurationKey))
+	for _, kv := range resp.ConfigurationKey {
+		item := &models.ChargePointConfiguration{Key: kv.Key, Readonly: kv.Readonly}
+		if kv.Value != nil {
+			item.Value = *kv.Value
+		}
+		items = append(items, item)
+	}
+	if err := m.svc.SaveReported(ctx, chargePointID, items); err != nil {
+		return nil, err
+	}
+	return resp.UnknownKey, nil
+}
+
+// Change runs ChangeConfiguration on the charge point and records its answer
+func (m *ConfigurationManager) Change(ctx context.Context, chargePointID uuid.UUID, key, value string) (string, error) {
+	identity, err := m.identity(ctx, chargePointID)
+	if err != nil {
+		return "", err
+	}
+	resp, err := m.server.ChangeConfiguration(ctx, identity, ChangeConfigurationRequest{Key: key, Value: value})
+	if err != nil {
+		return "", err
+	}
+	if err := m.svc.RecordChange(ctx, chargePointID, key, value, resp.Status); err != nil {
+		return "", err
+	}
+	return resp.Status, nil
+}
+
+// List returns the stored configuration of a charge point
+func (m *ConfigurationManager) List(ctx context.Context, chargePointID uuid.UUID) ([]*models.ChargePointConfiguration, error) {
+	return m.svc.List(ctx, chargePointID)
+}
+
+// identity returns the OCPP identity the charge point connects with. The keys
+// are OCPP 1.6 configuration keys; OCPP 2.0.1 charging stations have a device
+// model of components and variables instead, so ErrVersionNotSupported is
+// returned for them.
+func (m *ConfigurationManager) identity(ctx context.Context, chargePointID uuid.UUID) (string, error) {
+	cp, err := m.cpSvc.GetByID(ctx, chargePointID.String())
+	if err != nil {
+		return "", err
+	}
+	version, ok := m.server.ConnectedVersion(ctx, cp.Code)
+	if !ok {
+		return "", ErrChargePointNotConnected
+	}
+	if version != OCPP16 {
+		return "", ErrVersionNotSupported
+	}
+	return cp.Code, nil
+}
+
+// checkTemplate refreshes the template keys of a charge point and returns the
+// template together with the keys that drift from it; the template is nil when
+// none applies to the charge point.
+func (m *ConfigurationManager) checkTemplate(ctx context.Context, chargePointID uuid.UUID) (*models.ConfigurationTemplate, []*models.ConfigurationDrift, error) {
+	cp, err := m.cpSvc.GetByID(ctx, chargePointID.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	tpl, err := m.tplSvc.FindFor(ctx, cp)
+	if err != nil || tpl == nil {
+		return nil, nil, err
+	}
+	if _, err := m.Refresh(ctx, chargePointID, slices.Sorted(maps.Keys(tpl.Keys))); err != nil {
+		return nil, nil, err
+	}
+	drifts, err := m.tplSvc.Drift(ctx, chargePointID, tpl)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tpl, drifts, nil
+}
+
+// applyTemplate changes the keys of a freshly booted charge point that differ from its template
+func (m *ConfigurationManager) applyTemplate(ctx context.Context, chargePointID uuid.UUID) {
+	tpl, drifts, err := m.checkTemplate(ctx, chargePointID)
+	if errors.Is(err, ErrVersionNotSupported) {
+		m.log.Infof("Not applying the configuration template of charge point %s, templates are OCPP 1.6 only", chargePointID)
+		return
+	}
+	if err != nil {
+		m.log.WithError(err).Errorf("Failed to check configuration template of charge point %s", chargePointID)
+		return
+	}
+	if tpl == nil {
+		return
+	}
+	for _, drift := range drifts {
+		status, err := m.Change(ctx, chargePointID, drift.Key, drift.Expected)
+		if err != nil {
+			m.log.WithError(err).Errorf("Failed to apply %s of template %s to charge point %s", drift.Key, tpl.Name, chargePointID)
+			continue
+		}
+		if status != "Accepted" {
+			m.log.Warnf("Charge point %s answered %s to %s=%s of template %s", chargePointID, status, drift.Key, drift.Expected, tpl.Name)
+		}
+	}
+}
+
+// detectDrift checks the connected OCPP 1.6 charge points against their
+// template, driftWorkers at a time, and stores the drift found on each
+func (m *ConfigurationManager) detectDrift() {
+	if n := len(m.server.ConnectedIDs(OCPP201)); n > 0 {
+		m.log.Debugf("Skipping drift detection of %d OCPP 2.0.1 charging stations, templates are OCPP 1.6 only", n)
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	workers := make(chan struct{}, driftWorkers)
+	for _, identity := range m.server.ConnectedIDs(OCPP16) {
+		select {
+		case workers <- struct{}{}:
+		case <-m.done:
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-workers }()
+			m.checkDrift(identity)
+		}()
+	}
+}
+
+// checkDrift checks a connected charge point against its template and stores the drift found
+func (m *ConfigurationManager) checkDrift(identity string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*m.cfg.CallTimeout)
+	defer cancel()
+	cp, err := m.cpSvc.GetByCode(ctx, identity)
+	if err != nil || cp == nil {
+		return
+	}
+	tpl, drifts, err := m.checkTemplate(ctx, cp.ID)
+	if errors.Is(err, ErrVersionNotSupported) || errors.Is(err, ErrChargePointNotConnected) {
+		// reconnected with OCPP 2.0.1 or gone meanwhile
+		return
+	}
+	if err != nil {
+		m.log.WithError(err).Warnf("Failed to check configuration drift of charge point %s", cp.ID)
+		return
+	}
+	if err := m.tplSvc.RecordDrift(ctx, cp.ID, drifts); err != nil {
+		m.log.WithError(err).Errorf("Failed to store configuration drift of charge point %s", cp.ID)
+	}
+	for _, drift := range drifts {
+		m.log.WithFields(logrus.Fields{
+			"charge_point_id": drift.ChargePointID,
+			"template":        tpl.Name,
+			"key":             drift.Key,
+			"expected":        drift.Expected,
+			"actual":          drift.Actual,
+			"missing":         drift.Missing,
+		}).Warn("Configuration drift detected")
+	}
+}
@@ -0,0 +1,192 @@
+package ocpp
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/repository"
+	"github.com/mutoulbj/gocsms/internal/services"
+)
+
+// templateDB is a database holding charge points of one vendor, a configuration
+// template for the vendor and no configuration keys; it keeps the statements
+// it is sent
+type templateDB struct {
+	template uuid.UUID
+
+	mu           sync.Mutex
+	chargePoints map[string]*models.ChargePoint
+	queries      []string
+}
+
+func (db *templateDB) add(cp *models.ChargePoint) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.chargePoints[cp.ID.String()] = cp
+}
+
+func (db *templateDB) Connect(context.Context) (driver.Conn, error) { return templateConn{db}, nil }
+
+func (db *templateDB) Driver() driver.Driver { return nil }
+
+func (db *templateDB) record(query string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.queries = append(db.queries, query)
+}
+
+// count returns how many of the statements kept start with prefix
+func (db *templateDB) count(prefix string) int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	n := 0
+	for _, query := range db.queries {
+		if strings.HasPrefix(query, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+type templateConn struct{ db *templateDB }
+
+var chargePointByID = regexp.MustCompile(`FROM "charge_points" AS "cp" WHERE \(id = '([^']+)'\)`)
+
+func (c templateConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.db.record(query)
+	if strings.Contains(query, `FROM "configuration_templates"`) {
+		return &tableRows{
+			columns: []string{"id", "name", "vendor", "model", "keys"},
+			rows:    [][]driver.Value{{c.db.template.String(), "Standard", "Vendor", "", `{"HeartbeatInterval":"60"}`}},
+		}, nil
+	}
+	if match := chargePointByID.FindStringSubmatch(query); match != nil {
+		c.db.mu.Lock()
+		defer c.db.mu.Unlock()
+		if cp, ok := c.db.chargePoints[match[1]]; ok {
+			return &tableRows{
+				columns: []string{"id", "code", "vendor", "ocpp_version"},
+				rows:    [][]driver.Value{{cp.ID.String(), cp.Code, cp.Vendor, cp.OcppVersion}},
+			}, nil
+		}
+	}
+	return noRows{}, nil
+}
+
+func (c templateConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.db.record(query)
+	return driver.RowsAffected(0), nil
+}
+
+func (templateConn) Prepare(string) (driver.Stmt, error) { return nil, errDatabaseUnavailable }
+
+func (templateConn) Close() error { return nil }
+
+func (templateConn) Begin() (driver.Tx, error) { return templateTx{}, nil }
+
+type templateTx struct{}
+
+func (templateTx) Commit() error { return nil }
+
+func (templateTx) Rollback() error { return nil }
+
+func TestDetectDriftBoundsConcurrencyAndStoresDrift(t *testing.T) {
+	mr := miniredis.RunT(t)
+	db := &templateDB{template: uuid.New(), chargePoints: make(map[string]*models.ChargePoint)}
+	s := newTestServer(t, "node-a", mr.Addr(), db)
+	srv := httptest.NewServer(http.HandlerFunc(s.handleWebSocket))
+	t.Cleanup(srv.Close)
+
+	var inFlight, maxInFlight atomic.Int32
+	var v201Calls atomic.Int32
+	connect := func(identity string, version ProtocolVersion) {
+		cp := &models.ChargePoint{Code: identity, Vendor: "Vendor", OcppVersion: version.Version()}
+		seedChargePoint(t, mr, cp)
+		db.add(cp)
+		dialer := websocket.Dialer{Subprotocols: []string{string(version)}}
+		ws, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ocpp/"+identity, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { ws.Close() })
+		go func() {
+			for {
+				_, data, err := ws.ReadMessage()
+				if err != nil {
+					return
+				}
+				var call OCPPMessage
+				if err := json.Unmarshal(data, &call); err != nil {
+					return
+				}
+				if version == OCPP201 {
+					v201Calls.Add(1)
+					continue
+				}
+				n := inFlight.Add(1)
+				for {
+					current := maxInFlight.Load()
+					if n <= current || maxInFlight.CompareAndSwap(current, n) {
+						break
+					}
+				}
+				time.Sleep(20 * time.Millisecond)
+				inFlight.Add(-1)
+				reply := `{"configurationKey":[{"key":"HeartbeatInterval","readonly":false,"value":"30"}]}`
+				ws.WriteMessage(websocket.TextMessage, []byte(`[3,"`+call.UniqueID+`",`+reply+`]`))
+			}
+		}()
+	}
+	chargePoints := 3 * driftWorkers
+	for i := range chargePoints {
+		connect(fmt.Sprintf("CP-%d", i), OCPP16)
+	}
+	connect("CS-201", OCPP201)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.ConnectedIDs(OCPP16)) < chargePoints || len(s.ConnectedIDs(OCPP201)) < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("charge points did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	bdb := bun.NewDB(sql.OpenDB(db), pgdialect.New())
+	t.Cleanup(func() { bdb.Close() })
+	cfgSvc := services.NewChargePointConfigurationService(repository.NewChargePointConfigurationRepository(bdb, s.log), s.log)
+	tplSvc := services.NewConfigurationTemplateService(
+		repository.NewConfigurationTemplateRepository(bdb, s.log),
+		repository.NewConfigurationDriftRepository(bdb, s.log),
+		cfgSvc,
+		s.log,
+	)
+	NewConfigurationManager(s, s.cfg, cfgSvc, s.svc, tplSvc, s.log).detectDrift()
+
+	if n := maxInFlight.Load(); n > driftWorkers {
+		t.Errorf("%d charge points were checked at the same time, want at most %d", n, driftWorkers)
+	}
+	if n := v201Calls.Load(); n > 0 {
+		t.Errorf("OCPP 2.0.1 charging station received %d calls", n)
+	}
+	if n := db.count(`INSERT INTO "configuration_drifts"`); n != chargePoints {
+		t.Errorf("stored the drift of %d charge points, want %d", n, chargePoints)
+	}
+}
@@ -0,0 +1,244 @@
+package ocpp
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+// closeFrameTimeout bounds the wait for sending the close frame of a connection
+const closeFrameTimeout = time.Second
+
+// connection wraps the WebSocket of a connected charge point. The reader loop
+// (answering CALLs from the charge point) and CSMS-initiated calls queue their
+// frames in the outbox, which a single writer goroutine sends along with the
+// keepalive pings. At most one CSMS-initiated CALL is in flight at a time as
+// required by OCPP-J.
+type connection struct {
+	id         string
+	version    ProtocolVersion
+	ws         *websocket.Conn
+	cfg        *config.OCPPConfig
+	remoteAddr string
+	session    *models.ChargePointConnection // connection history entry, nil until the charge point is known
+	outbox     chan []byte
+	callSlot   chan struct{}
+	closed     chan struct{}
+	once       sync.Once
+	lastSeen   atomic.Int64 // unix nanoseconds of the last frame or pong of the charge point
+
+	// set when the CSMS closes the connection, see closeWith
+	closeCode   int
+	closeReason string
+
+	pendingMu sync.Mutex
+	pending   map[string]*pendingCall
+}
+
+// pendingCall is a CSMS-initiated CALL waiting for its reply
+type pendingCall struct {
+	action string
+	sentAt time.Time
+	reply  chan *OCPPMessage
+}
+
+func newConnection(id string, version ProtocolVersion, ws *websocket.Conn, cfg *config.OCPPConfig, remoteAddr string) *connection {
+	return &connection{
+		id:         id,
+		version:    version,
+		ws:         ws,
+		cfg:        cfg,
+		remoteAddr: remoteAddr,
+		outbox:     make(chan []byte, cfg.WSWriteQueueSize),
+		callSlot:   make(chan struct{}, 1),
+		closed:     make(chan struct{}),
+		pending:    make(map[string]*pendingCall),
+	}
+}
+
+// start applies the size limit and read deadline to the WebSocket and starts
+// the writer
+func (c *connection) start() {
+	if c.cfg.WSMaxMessageSize > 0 {
+		c.ws.SetReadLimit(c.cfg.WSMaxMessageSize)
+	}
+	c.seen()
+	c.ws.SetPongHandler(func(string) error {
+		c.seen()
+		return nil
+	})
+	go c.writeLoop()
+}
+
+// read returns the next frame of the charge point. Any frame, like a pong,
+// proves the charge point alive and extends the read deadline.
+func (c *connection) read() ([]byte, error) {
+	_, data, err := c.ws.ReadMessage()
+	if err == nil {
+		c.seen()
+	}
+	return data, err
+}
+
+// seen records that the charge point is alive and extends the read deadline
+func (c *connection) seen() {
+	c.lastSeen.Store(time.Now().UnixNano())
+	c.extendReadDeadline()
+}
+
+// seenAt returns when the charge point last sent a frame or pong
+func (c *connection) seenAt() time.Time {
+	return time.Unix(0, c.lastSeen.Load())
+}
+
+func (c *connection) extendReadDeadline() {
+	if c.cfg.WSPingInterval > 0 {
+		_ = c.ws.SetReadDeadline(time.Now().Add(c.cfg.WSPingInterval + c.cfg.WSPongTimeout))
+	}
+}
+
+// write queues a frame for the writer. It waits while the outbox is full, up
+// to the write timeout after which the charge point is considered stalled and
+// the connection is closed.
+func (c *connection) write(data []byte) error {
+	select {
+	case <-c.closed:
+		return ErrConnectionClosed
+	default:
+	}
+	select {
+	case c.outbox <- data:
+		return nil
+	default:
+	}
+
+	timer := time.NewTimer(c.cfg.WSWriteTimeout)
+	defer timer.Stop()
+	select {
+	case c.outbox <- data:
+		return nil
+	case <-c.closed:
+		return ErrConnectionClosed
+	case <-timer.C:
+		c.closeWith(websocket.ClosePolicyViolation, "Outbound queue full")
+		return ErrOutboxFull
+	}
+}
+
+// writeLoop is the only writer of frames to the WebSocket: it sends the queued
+// frames and the pings until the connection closes
+func (c *connection) writeLoop() {
+	var ping <-chan time.Time
+	if c.cfg.WSPingInterval > 0 {
+		ticker := time.NewTicker(c.cfg.WSPingInterval)
+		defer ticker.Stop()
+		ping = ticker.C
+	}
+	for {
+		select {
+		case data := <-c.outbox:
+			_ = c.ws.SetWriteDeadline(time.Now().Add(c.cfg.WSWriteTimeout))
+			if err := c.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+				c.closeWith(websocket.CloseAbnormalClosure, writeFailure(err))
+				return
+			}
+		case <-ping:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.cfg.WSWriteTimeout)); err != nil {
+				c.closeWith(websocket.CloseAbnormalClosure, writeFailure(err))
+				return
+			}
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+// register creates the channel the reply to uniqueID will be delivered on
+func (c *connection) register(uniqueID, action string) chan *OCPPMessage {
+	call := &pendingCall{action: action, sentAt: time.Now(), reply: make(chan *OCPPMessage, 1)}
+	c.pendingMu.Lock()
+	c.pending[uniqueID] = call
+	c.pendingMu.Unlock()
+	return call.reply
+}
+
+func (c *connection) unregister(uniqueID string) {
+	c.pendingMu.Lock()
+	delete(c.pending, uniqueID)
+	c.pendingMu.Unlock()
+}
+
+// resolve delivers a CALLRESULT or CALLERROR to the waiting caller and
+// returns the call it answers, nil when no call with that uniqueId was pending
+func (c *connection) resolve(msg *OCPPMessage) *pendingCall {
+	c.pendingMu.Lock()
+	call, ok := c.pending[msg.UniqueID]
+	delete(c.pending, msg.UniqueID)
+	c.pendingMu.Unlock()
+	if ok {
+		call.reply <- msg
+	}
+	return call
+}
+
+// writeFailure describes why a frame could not be sent
+func writeFailure(err error) string {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "Write timeout"
+	}
+	return "Write failed: " + err.Error()
+}
+
+func (c *connection) close() {
+	c.once.Do(func() {
+		close(c.closed)
+		c.ws.Close()
+	})
+}
+
+// closeWith closes the connection, telling the charge point why with a close
+// frame unless the code is one that must not be sent. The code and reason are
+// recorded in place of the ones of the read error this causes.
+func (c *connection) closeWith(code int, reason string) {
+	c.once.Do(func() {
+		c.closeCode, c.closeReason = code, reason
+		close(c.closed)
+		if code != websocket.CloseAbnormalClosure {
+			frame := websocket.FormatCloseMessage(code, reason)
+			_ = c.ws.WriteControl(websocket.CloseMessage, frame, time.Now().Add(closeFrameTimeout))
+		}
+		c.ws.Close()
+	})
+}
+
+// closeStatus returns the close code and reason of a connection that ended
+// with the read error err
+func (c *connection) closeStatus(err error) (int, string) {
+	select {
+	case <-c.closed:
+		if c.closeCode != 0 {
+			return c.closeCode, c.closeReason
+		}
+	default:
+	}
+	var closeErr *websocket.CloseError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &closeErr):
+		return closeErr.Code, closeErr.Text
+	case errors.Is(err, websocket.ErrReadLimit):
+		return websocket.CloseMessageTooBig, "Message too big"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return websocket.CloseAbnormalClosure, "Pong timeout"
+	default:
+		return websocket.CloseAbnormalClosure, ""
+	}
+}
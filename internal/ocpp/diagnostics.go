@@ -0,0 +1,212 @@
+package ocpp
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/dto"
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/ocpp/v201"
+	"github.com/mutoulbj/gocsms/internal/services"
+)
+
+// diagnosticsCheckInterval is how often timed out uploads are failed and
+// uploads past the retention period removed
+const diagnosticsCheckInterval = time.Minute
+
+// logTypeDiagnostics is the log type of GetLog that OCPP 1.6 GetDiagnostics stands for
+const logTypeDiagnostics = "DiagnosticsLog"
+
+// DiagnosticsManager requests diagnostics from charge points, with
+// GetDiagnostics for OCPP 1.6 and GetLog for OCPP 2.0.1, and tracks the
+// uploads through the status notifications of the charge points.
+type DiagnosticsManager struct {
+	server *Server
+	cfg    *config.OCPPConfig
+	svc    *services.DiagnosticsService
+	cpSvc  *services.ChargePointService
+	log    *logrus.Logger
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewDiagnosticsManager(
+	server *Server,
+	cfg *config.OCPPConfig,
+	svc *services.DiagnosticsService,
+	cpSvc *services.ChargePointService,
+	log *logrus.Logger,
+) *DiagnosticsManager {
+	m := &DiagnosticsManager{
+		server: server,
+		cfg:    cfg,
+		svc:    svc,
+		cpSvc:  cpSvc,
+		log:    log,
+		done:   make(chan struct{}),
+	}
+	server.OnDiagnosticsStatus(m.recordStatus)
+	return m
+}
+
+// Start launches the periodic upload timeout and retention check
+func (m *DiagnosticsManager) Start() {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(diagnosticsCheckInterval)
+		defer ticker.Stop()
+		m.check()
+		for {
+			select {
+			case <-ticker.C:
+				m.check()
+			case <-m.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic check
+func (m *DiagnosticsManager) Stop() {
+	close(m.done)
+	m.wg.Wait()
+}
+
+// Request asks a charge point to upload its diagnostics and returns the
+// upload, failed when the charge point refused it. The upload is recorded as
+// well when the request fails, as the charge point may still have received it.
+func (m *DiagnosticsManager) Request(ctx context.Context, chargePointID uuid.UUID, req *dto.DiagnosticsRequest) (*models.Diagnostics, error) {
+	cp, err := m.cpSvc.GetByID(ctx, chargePointID.String())
+	if err != nil {
+		return nil, err
+	}
+	version, ok := m.server.ConnectedVersion(ctx, cp.Code)
+	if !ok {
+		return nil, ErrChargePointNotConnected
+	}
+	logType := req.LogType
+	if logType == "" {
+		logType = logTypeDiagnostics
+	}
+	if version != OCPP201 && logType != logTypeDiagnostics {
+		return nil, services.ErrDiagnosticsLogType
+	}
+
+	diagnostics := &models.Diagnostics{
+		ID:            uuid.New(),
+		ChargePointID: cp.ID,
+		LogType:       logType,
+		Status:        enums.DiagnosticsStatusRequested,
+		StartTime:     req.StartTime,
+		StopTime:      req.StopTime,
+	}
+	if version == OCPP201 {
+		diagnostics.RequestID = rand.IntN(1 << 31)
+	}
+	// recorded before it is sent, the charge point may upload before answering
+	if err := m.svc.Create(ctx, diagnostics); err != nil {
+		return nil, err
+	}
+
+	m.log.Infof("Requesting %s from charge point %s", logType, cp.Code)
+	fileName, status, err := m.send(ctx, cp.Code, version, diagnostics, req)
+
+	// the charge point may already have reported on the upload, or uploaded it
+	current, getErr := m.svc.Get(ctx, diagnostics.ID)
+	if getErr != nil {
+		return nil, getErr
+	}
+	if current.Status.IsFinal() {
+		return current, err
+	}
+	current.FileName = fileName
+	if current.Status == enums.DiagnosticsStatusRequested {
+		switch {
+		case err != nil:
+			current.Status = enums.DiagnosticsStatusFailed
+			current.Error = err.Error()
+		case status != "Accepted" && status != "AcceptedCanceled":
+			current.Status = enums.DiagnosticsStatusFailed
+			current.Error = fmt.Sprintf("Charge point answered %s", status)
+		case fileName == "":
+			// OCPP 1.6 charge points answer without a file name when they have no diagnostics
+			current.Status = enums.DiagnosticsStatusFailed
+			current.Error = "No diagnostics available"
+		}
+	}
+	if updateErr := m.svc.Update(ctx, current); updateErr != nil {
+		return nil, updateErr
+	}
+	return current, err
+}
+
+// send sends the diagnostics request with the message of the OCPP version of
+// the charge point and returns the name of the file it is going to upload
+// with the answer of the charge point
+func (m *DiagnosticsManager) send(ctx context.Context, identity string, version ProtocolVersion, diagnostics *models.Diagnostics, req *dto.DiagnosticsRequest) (string, string, error) {
+	location := m.uploadURL(diagnostics)
+	if version == OCPP201 {
+		resp, err := m.server.GetLogV201(ctx, identity, v201.GetLogRequest{
+			Log: v201.LogParameters{
+				RemoteLocation:  location,
+				OldestTimestamp: req.StartTime,
+				LatestTimestamp: req.StopTime,
+			},
+			LogType:       diagnostics.LogType,
+			RequestID:     diagnostics.RequestID,
+			Retries:       req.Retries,
+			RetryInterval: req.RetryInterval,
+		})
+		if err != nil {
+			return "", "", err
+		}
+		// the file name is optional in OCPP 2.0.1
+		return cmp.Or(resp.Filename, diagnostics.ID.String()), resp.Status, nil
+	}
+
+	resp, err := m.server.GetDiagnostics(ctx, identity, GetDiagnosticsRequest{
+		Location:      location,
+		Retries:       req.Retries,
+		RetryInterval: req.RetryInterval,
+		StartTime:     req.StartTime,
+		StopTime:      req.StopTime,
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return resp.FileName, "Accepted", nil
+}
+
+// uploadURL returns the URL a charge point uploads a diagnostics file to
+func (m *DiagnosticsManager) uploadURL(diagnostics *models.Diagnostics) string {
+	return fmt.Sprintf("%s/uploads/diagnostics/%s", m.cfg.DiagnosticsBaseURL, diagnostics.ID)
+}
+
+// recordStatus tracks the upload statuses reported by charge points
+func (m *DiagnosticsManager) recordStatus(ctx context.Context, chargePointID uuid.UUID, status string, requestID *int) {
+	if err := m.svc.RecordStatus(ctx, chargePointID, status, requestID); err != nil {
+		m.log.WithError(err).Errorf("Failed to record diagnostics status %s of charge point %s", status, chargePointID)
+	}
+}
+
+func (m *DiagnosticsManager) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), diagnosticsCheckInterval)
+	defer cancel()
+	if err := m.svc.TimeOut(ctx); err != nil {
+		m.log.WithError(err).Error("Failed to time out diagnostics uploads")
+	}
+	if err := m.svc.Expire(ctx); err != nil {
+		m.log.WithError(err).Error("Failed to remove expired diagnostics uploads")
+	}
+}
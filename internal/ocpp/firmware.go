@@ -0,0 +1,256 @@
+package ocpp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/ocpp/v201"
+	"github.com/mutoulbj/gocsms/internal/repository"
+	"github.com/mutoulbj/gocsms/internal/services"
+)
+
+// FirmwareManager drives the running firmware campaigns. Every check it sends
+// the update to the charge points of the current wave that are connected,
+// follows their progress and, once every update of the wave has ended, lets
+// the success gate decide between the next wave and pausing the campaign.
+// An update has ended when the charge point reports Installed, when it boots
+// with the new firmware version, when it reports a failure or when it times out.
+type FirmwareManager struct {
+	server *Server
+	cfg    *config.OCPPConfig
+	svc    *services.FirmwareService
+	log    *logrus.Logger
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewFirmwareManager(
+	server *Server,
+	cfg *config.OCPPConfig,
+	svc *services.FirmwareService,
+	log *logrus.Logger,
+) *FirmwareManager {
+	m := &FirmwareManager{
+		server: server,
+		cfg:    cfg,
+		svc:    svc,
+		log:    log,
+		done:   make(chan struct{}),
+	}
+	server.OnFirmwareStatus(m.recordStatus)
+	return m
+}
+
+// Start launches the periodic campaign check
+func (m *FirmwareManager) Start() {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.cfg.FirmwareCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				m.check()
+			case <-m.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic campaign check
+func (m *FirmwareManager) Stop() {
+	close(m.done)
+	m.wg.Wait()
+}
+
+// recordStatus tracks the firmware statuses reported by charge points
+func (m *FirmwareManager) recordStatus(ctx context.Context, chargePointID uuid.UUID, status string, requestID *int) {
+	if err := m.svc.RecordStatus(ctx, chargePointID, status, requestID); err != nil {
+		m.log.WithError(err).Errorf("Failed to record firmware status %s of charge point %s", status, chargePointID)
+	}
+}
+
+func (m *FirmwareManager) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), m.cfg.FirmwareCheckInterval)
+	campaigns, err := m.svc.ListCampaigns(ctx, enums.FirmwareCampaignStatusRunning)
+	cancel()
+	if err != nil {
+		m.log.WithError(err).Error("Failed to list running firmware campaigns")
+		return
+	}
+	for _, campaign := range campaigns {
+		if err := m.advance(campaign); err != nil {
+			m.log.WithError(err).Errorf("Failed to advance firmware campaign %s", campaign.Name)
+		}
+	}
+}
+
+// advance works through the current wave of a campaign and applies the
+// success gate once all of its updates have ended
+func (m *FirmwareManager) advance(campaign *models.FirmwareCampaign) error {
+	ctx, cancel := context.WithTimeout(context.Background(), m.cfg.FirmwareCheckInterval)
+	targets, err := m.svc.ListTargets(ctx, campaign.ID, repository.FirmwareTargetFilter{Wave: campaign.CurrentWave})
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	ended := true
+	for _, target := range targets {
+		if !target.Status.IsFinal() {
+			m.progress(campaign, target)
+		}
+		ended = ended && target.Status.IsFinal()
+	}
+	if !ended {
+		return nil
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), m.cfg.FirmwareCheckInterval)
+	defer cancel()
+	return m.svc.CompleteWave(ctx, campaign, targets)
+}
+
+// progress moves an update on: it is sent when still pending, and ends when the
+// charge point runs the new version or the update timed out
+func (m *FirmwareManager) progress(campaign *models.FirmwareCampaign, target *models.FirmwareCampaignTarget) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*m.cfg.CallTimeout)
+	defer cancel()
+
+	before := *target
+	cp := target.ChargePoint
+	switch {
+	case cp == nil:
+		target.Status = enums.FirmwareUpdateStatusFailed
+		target.Error = "Charge point was deleted"
+	case cp.FirmwareVersion == campaign.Artifact.Version:
+		// the version of the last boot tells for charge points that do not report Installed
+		target.Status = enums.FirmwareUpdateStatusInstalled
+	case target.Status == enums.FirmwareUpdateStatusPending:
+		err := m.send(ctx, campaign, target)
+		switch {
+		case err == nil:
+			target.Status = enums.FirmwareUpdateStatusSent
+			target.SentAt = time.Now()
+			target.Error = ""
+		case errors.Is(err, errUpdateRejected) || time.Since(campaign.WaveStartedAt) > m.cfg.FirmwareUpdateTimeout:
+			target.Status = enums.FirmwareUpdateStatusFailed
+			target.Error = err.Error()
+		case !errors.Is(err, ErrChargePointNotConnected):
+			// tried again on the next check, charge points not connected are sent the update once they connect
+			m.log.WithError(err).Warnf("Failed to send firmware update of campaign %s to charge point %s", campaign.Name, cp.Code)
+			target.Error = err.Error()
+		}
+	case time.Since(target.SentAt) > m.cfg.FirmwareUpdateTimeout:
+		target.Error = fmt.Sprintf("Timed out while %s", target.Status)
+		if target.FirmwareStatus != "" {
+			target.Error = fmt.Sprintf("Timed out after %s", target.FirmwareStatus)
+		}
+		target.Status = enums.FirmwareUpdateStatusFailed
+	}
+
+	if target.Status == before.Status && target.Error == before.Error {
+		return
+	}
+	if err := m.svc.UpdateTarget(ctx, target); err != nil {
+		m.log.WithError(err).Errorf("Failed to update firmware update of charge point %s", target.ChargePointID)
+	}
+}
+
+// errUpdateRejected is returned by send when the charge point refuses the update
+var errUpdateRejected = errors.New("charge point rejected the firmware update")
+
+// send asks a charge point to install the firmware of a campaign with the
+// message of its OCPP version. Signed firmware goes to OCPP 1.6 charge points
+// with the security extension's SignedUpdateFirmware, falling back to a plain
+// UpdateFirmware when the charge point does not implement it.
+func (m *FirmwareManager) send(ctx context.Context, campaign *models.FirmwareCampaign, target *models.FirmwareCampaignTarget) error {
+	identity := target.ChargePoint.Code
+	version, ok := m.server.ConnectedVersion(ctx, identity)
+	if !ok {
+		return ErrChargePointNotConnected
+	}
+	artifact := campaign.Artifact
+	location := m.downloadURL(artifact)
+	target.RequestID = rand.IntN(1 << 31)
+	m.log.Infof("Sending firmware %s %s of campaign %s to charge point %s", artifact.Vendor, artifact.Version, campaign.Name, identity)
+
+	if version == OCPP201 {
+		req := v201.UpdateFirmwareRequest{
+			Retries:       campaign.Retries,
+			RetryInterval: campaign.RetryInterval,
+			RequestID:     target.RequestID,
+			Firmware: v201.Firmware{
+				Location:           location,
+				RetrieveDateTime:   time.Now(),
+				SigningCertificate: artifact.SigningCertificate,
+				Signature:          artifact.Signature,
+			},
+		}
+		resp, err := m.server.UpdateFirmwareV201(ctx, identity, req)
+		if err != nil {
+			return err
+		}
+		return updateStatus(resp.Status)
+	}
+
+	if artifact.Signed() {
+		resp, err := m.server.SignedUpdateFirmware(ctx, identity, SignedUpdateFirmwareRequest{
+			Retries:       campaign.Retries,
+			RetryInterval: campaign.RetryInterval,
+			RequestID:     target.RequestID,
+			Firmware: Firmware{
+				Location:           location,
+				RetrieveDateTime:   time.Now(),
+				SigningCertificate: artifact.SigningCertificate,
+				Signature:          artifact.Signature,
+			},
+		})
+		var callErr *CallErrorResponse
+		if !errors.As(err, &callErr) || (callErr.Code != ErrorCodeNotImplemented && callErr.Code != ErrorCodeNotSupported) {
+			if err != nil {
+				return err
+			}
+			return updateStatus(resp.Status)
+		}
+		m.log.Warnf("Charge point %s does not support SignedUpdateFirmware, sending UpdateFirmware", identity)
+	}
+
+	// a plain UpdateFirmware has no request id to match the status notifications with
+	target.RequestID = 0
+	_, err := m.server.UpdateFirmware(ctx, identity, UpdateFirmwareRequest{
+		Location:      location,
+		Retries:       campaign.Retries,
+		RetrieveDate:  time.Now(),
+		RetryInterval: campaign.RetryInterval,
+	})
+	return err
+}
+
+// downloadURL returns the URL charge points download a firmware artifact from
+func (m *FirmwareManager) downloadURL(artifact *models.FirmwareArtifact) string {
+	return fmt.Sprintf("%s/downloads/firmware/%s/%s", m.cfg.FirmwareBaseURL, artifact.ID, url.PathEscape(artifact.FileName))
+}
+
+// updateStatus turns the answer to a firmware update into an error unless it was accepted
+func updateStatus(status string) error {
+	switch status {
+	case "Accepted", "AcceptedCanceled":
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", errUpdateRejected, status)
+	}
+}
@@ -1,90 +1,270 @@
 package ocpp
 
 import (
+	"cmp"
 	"context"
+	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 
+	"github.com/mutoulbj/gocsms/internal/config"
 	"github.com/mutoulbj/gocsms/internal/enums"
 	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/ocpp/v201"
+	"github.com/mutoulbj/gocsms/internal/pki"
 	"github.com/mutoulbj/gocsms/internal/services"
 )
 
+// BootHook is run after a charge point has been accepted by a BootNotification
+type BootHook func(ctx context.Context, chargePointID uuid.UUID)
+
+// SignCertificateHook is run after a certificate signing request of a charge
+// point has been accepted, to sign it and send back the certificate
+type SignCertificateHook func(ctx context.Context, identity string, version ProtocolVersion, csr *x509.CertificateRequest, certificateType string)
+
+// FirmwareStatusHook is run for every firmware status reported by a charge
+// point, with the request id of the update when the charge point gave one
+type FirmwareStatusHook func(ctx context.Context, chargePointID uuid.UUID, status string, requestID *int)
+
+// DiagnosticsStatusHook is run for every diagnostics or log upload status
+// reported by a charge point, with the request id of the upload when the
+// charge point gave one
+type DiagnosticsStatusHook func(ctx context.Context, chargePointID uuid.UUID, status string, requestID *int)
+
 type OCPPHandler struct {
-	svc *services.ChargePointService
-	log *logrus.Logger
+	cfg             *config.OCPPConfig
+	validator       *SchemaValidator
+	svc             *services.ChargePointService
+	txSvc           *services.TransactionService
+	mvSvc           *services.MeterValueService
+	cfgSvc          *services.ChargePointConfigurationService
+	secSvc          *services.SecurityEventService
+	rsvSvc          *services.ReservationService
+	bootHooks       []BootHook
+	signHook        SignCertificateHook
+	firmwareHook    FirmwareStatusHook
+	diagnosticsHook DiagnosticsStatusHook
+	log             *logrus.Logger
 }
 
-func GocsmsOCPPHandler(svc *services.ChargePointService, log *logrus.Logger) *OCPPHandler {
-	return &OCPPHandler{svc: svc, log: log}
+func GocsmsOCPPHandler(
+	cfg *config.OCPPConfig,
+	validator *SchemaValidator,
+	svc *services.ChargePointService,
+	txSvc *services.TransactionService,
+	mvSvc *services.MeterValueService,
+	cfgSvc *services.ChargePointConfigurationService,
+	secSvc *services.SecurityEventService,
+	rsvSvc *services.ReservationService,
+	log *logrus.Logger,
+) *OCPPHandler {
+	return &OCPPHandler{
+		cfg:       cfg,
+		validator: validator,
+		svc:       svc,
+		txSvc:     txSvc,
+		mvSvc:     mvSvc,
+		cfgSvc:    cfgSvc,
+		secSvc:    secSvc,
+		rsvSvc:    rsvSvc,
+		log:       log,
+	}
 }
 
-func (h *OCPPHandler) HandleMessage(ctx context.Context, chargePointID string, msg []byte) ([]byte, error) {
+// HandleMessage handles a frame from the charge point with the given OCPP
+// identity, which is the Code of a provisioned charge point
+func (h *OCPPHandler) HandleMessage(ctx context.Context, version ProtocolVersion, identity string, msg []byte) ([]byte, error) {
 	var ocppMsg OCPPMessage
 	if err := json.Unmarshal(msg, &ocppMsg); err != nil {
 		h.log.Error("Invalid OCPP message: ", err)
-		return h.createErrorResponse(ocppMsg.UniqueID, "FormationViolation", "Invalid JSON")
+		if errors.Is(err, ErrUnknownMessageType) {
+			return h.createErrorResponse(ocppMsg.UniqueID, ErrorCodeProtocolError, "Unknown message type")
+		}
+		return h.createErrorResponse(ocppMsg.UniqueID, version.errorCode(ErrorCodeFormationViolation), "Invalid OCPP-J frame")
 	}
 
 	if ocppMsg.MessageTypeID != Call {
-		return h.createErrorResponse(ocppMsg.UniqueID, "NotSupported", "Only CALL messages supported")
+		return h.createErrorResponse(ocppMsg.UniqueID, ErrorCodeNotSupported, "Only CALL messages supported")
 	}
 
-	parsedID, err := uuid.Parse(chargePointID)
+	cp, err := h.svc.GetByCode(ctx, identity)
 	if err != nil {
-		h.log.Error("Invalid chargePointID: ", err)
-		return h.createErrorResponse(ocppMsg.UniqueID, "FormationViolation", "Invalid chargePointID format")
+		h.log.Error("Failed to look up charge point: ", err)
+		return h.createErrorResponse(ocppMsg.UniqueID, ErrorCodeInternalError, err.Error())
+	}
+
+	mode := h.schemaMode(cp, ocppMsg)
+	if err := h.checkPayload(version, mode, identity, ocppMsg.Action, false, ocppMsg.Payload); err != nil {
+		var violation *SchemaViolation
+		if errors.As(err, &violation) {
+			return h.createErrorResponse(ocppMsg.UniqueID, violation.Code, violation.Description)
+		}
+		return h.createErrorResponse(ocppMsg.UniqueID, ErrorCodeInternalError, err.Error())
+	}
+
+	resp, err := h.dispatch(ctx, version, identity, cp, ocppMsg)
+	if err == nil {
+		h.checkReply(version, identity, ocppMsg.Action, resp)
+	}
+	return resp, err
+}
+
+func (h *OCPPHandler) dispatch(ctx context.Context, version ProtocolVersion, identity string, cp *models.ChargePoint, ocppMsg OCPPMessage) ([]byte, error) {
+	if ocppMsg.Action == "BootNotification" {
+		if version == OCPP201 {
+			return h.handleBootNotificationV201(ctx, identity, ocppMsg)
+		}
+		return h.handleBootNotification(ctx, identity, ocppMsg)
+	}
+
+	// until a BootNotification of it is accepted a charge point may only send
+	// BootNotification
+	if cp == nil || cp.RegistrationStatus != enums.ChargePointRegistrationStatusAccepted {
+		h.log.Warnf("Refusing %s from %s: charge point is not accepted", ocppMsg.Action, identity)
+		return h.createErrorResponse(ocppMsg.UniqueID, ErrorCodeSecurityError, "Charge point is not accepted")
+	}
+
+	// the security extension of OCPP 1.6 uses the OCPP 2.0.1 message
+	if ocppMsg.Action == "SignCertificate" {
+		return h.handleSignCertificate(ctx, version, identity, ocppMsg)
+	}
+
+	if version == OCPP201 {
+		return h.handleMessageV201(ctx, cp.ID, ocppMsg)
 	}
 
 	switch ocppMsg.Action {
-	case "BootNotification":
-		return h.handleBootNotification(ctx, parsedID, ocppMsg)
 	case "Heartbeat":
-
-		return h.handleHeartbeat(ctx, parsedID, ocppMsg)
+		return h.handleHeartbeat(ctx, cp.ID, ocppMsg)
 	case "StatusNotification":
-		return h.handleStatusNotification(ctx, parsedID, ocppMsg)
+		return h.handleStatusNotification(ctx, cp.ID, ocppMsg)
+	case "Authorize":
+		return h.handleAuthorize(ctx, cp.ID, ocppMsg)
+	case "StartTransaction":
+		return h.handleStartTransaction(ctx, cp.ID, ocppMsg)
+	case "StopTransaction":
+		return h.handleStopTransaction(ctx, cp.ID, ocppMsg)
+	case "MeterValues":
+		return h.handleMeterValues(ctx, cp.ID, ocppMsg)
+	case "SecurityEventNotification":
+		return h.handleSecurityEventNotification(ctx, cp.ID, ocppMsg)
+	case "FirmwareStatusNotification":
+		return h.handleFirmwareStatusNotification(ctx, cp.ID, ocppMsg)
+	case "SignedFirmwareStatusNotification":
+		return h.handleSignedFirmwareStatusNotification(ctx, cp.ID, ocppMsg)
+	case "DiagnosticsStatusNotification":
+		return h.handleDiagnosticsStatusNotification(ctx, cp.ID, ocppMsg)
 	default:
-		return h.createErrorResponse(ocppMsg.UniqueID, "NotSupported", fmt.Sprintf("Action %s not supported", ocppMsg.Action))
+		return h.createErrorResponse(ocppMsg.UniqueID, ErrorCodeNotSupported, fmt.Sprintf("Action %s not supported", ocppMsg.Action))
 	}
 }
 
-func (h *OCPPHandler) handleBootNotification(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
+func (h *OCPPHandler) handleBootNotification(ctx context.Context, identity string, msg OCPPMessage) ([]byte, error) {
 	var req BootNotificationRequest
 	if err := json.Unmarshal(msg.Payload, &req); err != nil {
-		return h.createErrorResponse(msg.UniqueID, "FormationViolation", "Invalid payload")
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeFormationViolation, "Invalid payload")
 	}
 
-	h.log.Infof("Received BootNotification from %s: %+v", chargePointID, req)
-	err := h.svc.Register(ctx, &models.ChargePoint{
-		ID:            chargePointID,
-		SerialNumber:  req.ChargePointSerialNumber,
-		Status:        enums.ChargePointStatusAvailable,
-		LastHeartbeat: time.Now(),
-	})
+	h.log.Infof("Received BootNotification from %s: %+v", identity, req)
+	cp, err := h.svc.Boot(ctx, identity, &models.ChargePoint{
+		SerialNumber:    req.ChargePointSerialNumber,
+		Vendor:          req.ChargePointVendor,
+		Model:           req.ChargePointModel,
+		FirmwareVersion: req.FirmwareVersion,
+		OcppVersion:     OCPP16.Version(),
+	}, h.cfg.RegistrationPolicy)
 	if err != nil {
 		h.log.Error("Failed to register charge point: ", err)
-		return h.createErrorResponse(msg.UniqueID, "InternalError", err.Error())
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeInternalError, err.Error())
+	}
+
+	status, interval := h.bootResult(cp)
+	if status == "Accepted" {
+		h.runBootHooks(ctx, cp)
 	}
 
 	resp := BootNotificationResponse{
-		Status:      "Accepted",
+		Status:      status,
 		CurrentTime: time.Now(),
-		Interval:    60,
+		Interval:    interval,
 	}
 	return h.createResponse(msg.UniqueID, resp)
 }
 
+// runBootHooks runs the boot hooks once the reply to the accepted
+// BootNotification of a charge point has been sent, for either OCPP version
+func (h *OCPPHandler) runBootHooks(ctx context.Context, cp *models.ChargePoint) {
+	for _, hook := range h.bootHooks {
+		afterReply(ctx, func() { hook(context.Background(), cp.ID) })
+	}
+}
+
+// bootResult returns the BootNotification status for the registration status of
+// a charge point, with the heartbeat interval or the interval to retry after
+func (h *OCPPHandler) bootResult(cp *models.ChargePoint) (string, int) {
+	switch cp.RegistrationStatus {
+	case enums.ChargePointRegistrationStatusAccepted:
+		return "Accepted", int(h.cfg.HeartbeatInterval.Seconds())
+	case enums.ChargePointRegistrationStatusRejected:
+		return "Rejected", int(h.cfg.BootRetryInterval.Seconds())
+	default:
+		return "Pending", int(h.cfg.BootRetryInterval.Seconds())
+	}
+}
+
+// handleSignCertificate accepts a well-formed certificate signing request and
+// leaves the signing to the registered hook, which sends CertificateSigned
+// once the reply has gone out. A charge point certificate must carry the
+// identity as common name so it can later authenticate with security profile 3.
+func (h *OCPPHandler) handleSignCertificate(ctx context.Context, version ProtocolVersion, identity string, msg OCPPMessage) ([]byte, error) {
+	var req v201.SignCertificateRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return h.createErrorResponse(msg.UniqueID, version.errorCode(ErrorCodeFormationViolation), "Invalid payload")
+	}
+
+	h.log.Infof("Received SignCertificate from %s", identity)
+	certificateType := cmp.Or(req.CertificateType, "ChargingStationCertificate")
+	reason := ""
+	csr, err := pki.ParseCSR([]byte(req.Csr))
+	switch {
+	case err != nil:
+		h.log.Warnf("Rejecting certificate signing request of %s: %v", identity, err)
+		reason = "InvalidCSR"
+	case certificateType == "ChargingStationCertificate" && csr.Subject.CommonName != identity:
+		h.log.Warnf("Rejecting certificate signing request of %s for common name %q", identity, csr.Subject.CommonName)
+		reason = "InvalidCommonName"
+	case h.signHook == nil:
+		reason = "NoSigner"
+	default:
+		afterReply(ctx, func() { h.signHook(context.Background(), identity, version, csr, certificateType) })
+	}
+
+	status := "Accepted"
+	if reason != "" {
+		status = "Rejected"
+	}
+	if version == OCPP201 {
+		resp := v201.SignCertificateResponse{Status: status}
+		if reason != "" {
+			resp.StatusInfo = &v201.StatusInfo{ReasonCode: reason}
+		}
+		return h.createResponse(msg.UniqueID, resp)
+	}
+	return h.createResponse(msg.UniqueID, SignCertificateResponse{Status: status})
+}
+
 func (h *OCPPHandler) handleHeartbeat(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
 	h.log.Infof("Received Heartbeat from %s", chargePointID)
-	err := h.svc.UpdateStatus(ctx, chargePointID, "Available")
+	err := h.svc.Heartbeat(ctx, chargePointID)
 	if err != nil {
 		h.log.Error("Failed to update heartbeat: ", err)
-		return h.createErrorResponse(msg.UniqueID, "InternalError", err.Error())
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeInternalError, err.Error())
 	}
 
 	resp := HeartbeatResponse{
@@ -96,31 +276,296 @@ func (h *OCPPHandler) handleHeartbeat(ctx context.Context, chargePointID uuid.UU
 func (h *OCPPHandler) handleStatusNotification(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
 	var req StatusNotificationRequest
 	if err := json.Unmarshal(msg.Payload, &req); err != nil {
-		return h.createErrorResponse(msg.UniqueID, "FormationViolation", "Invalid payload")
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeFormationViolation, "Invalid payload")
 	}
 
 	h.log.Infof("Received StatusNotification from %s: %+v", chargePointID, req)
-	err := h.svc.UpdateStatus(ctx, chargePointID, req.Status)
+	report := &models.ConnectorStatusHistory{
+		ChargePointID:   chargePointID,
+		ConnectorID:     req.ConnectorID,
+		Status:          connectorStatus(req.Status),
+		ErrorCode:       req.ErrorCode,
+		Info:            req.Info,
+		VendorID:        req.VendorId,
+		VendorErrorCode: req.VendorErrorCode,
+		Timestamp:       time.Now(),
+	}
+	if req.Timestamp != nil {
+		report.Timestamp = *req.Timestamp
+	}
+	err := h.svc.UpdateConnectorStatus(ctx, report)
 	if err != nil {
 		h.log.Error("Failed to update status: ", err)
-		return h.createErrorResponse(msg.UniqueID, "InternalError", err.Error())
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeInternalError, err.Error())
 	}
 
 	resp := StatusNotificationResponse{}
 	return h.createResponse(msg.UniqueID, resp)
 }
 
-func (h *OCPPHandler) createResponse(uniqueID string, payload interface{}) ([]byte, error) {
-	resp := OCPPMessage{
-		MessageTypeID: CallResult,
-		UniqueID:      uniqueID,
-		Payload:       json.RawMessage{},
+func (h *OCPPHandler) handleAuthorize(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
+	var req AuthorizeRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeFormationViolation, "Invalid payload")
+	}
+
+	h.log.Infof("Received Authorize from %s: %+v", chargePointID, req)
+	status, err := idTagStatus(h.txSvc.Authorize(ctx, req.IdTag, OCPP16.maxIdTagLength()))
+	if err != nil {
+		h.log.Error("Failed to authorize id tag: ", err)
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeInternalError, err.Error())
+	}
+
+	resp := AuthorizeResponse{
+		IdTagInfo: IdTagInfo{Status: status},
+	}
+	return h.createResponse(msg.UniqueID, resp)
+}
+
+func (h *OCPPHandler) handleStartTransaction(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
+	var req StartTransactionRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeFormationViolation, "Invalid payload")
+	}
+
+	h.log.Infof("Received StartTransaction from %s: %+v", chargePointID, req)
+	tx, err := h.txSvc.Start(ctx, &models.Transaction{
+		ChargePointID: chargePointID,
+		ConnectorID:   req.ConnectorID,
+		IdTag:         req.IdTag,
+		StartTime:     req.Timestamp,
+		MeterStart:    float64(req.MeterStart),
+	}, OCPP16.maxIdTagLength())
+	status, err := idTagStatus(err)
+	if err != nil {
+		h.log.Error("Failed to start transaction: ", err)
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeInternalError, err.Error())
+	}
+	if req.ReservationID != nil {
+		h.useReservation(ctx, chargePointID, *req.ReservationID, tx)
+	}
+
+	resp := StartTransactionResponse{
+		IdTagInfo:     IdTagInfo{Status: status},
+		TransactionID: tx.TransactionID,
+	}
+	return h.createResponse(msg.UniqueID, resp)
+}
+
+func (h *OCPPHandler) handleStopTransaction(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
+	var req StopTransactionRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeFormationViolation, "Invalid payload")
+	}
+
+	h.log.Infof("Received StopTransaction from %s: %+v", chargePointID, req)
+	tx, err := h.txSvc.Stop(ctx, chargePointID, req.TransactionID, float64(req.MeterStop), req.Timestamp, req.Reason)
+	if errors.Is(err, services.ErrTransactionNotFound) {
+		// the charge point would keep retrying an error, so the stop is acknowledged anyway
+		h.log.Warnf("StopTransaction for unknown transaction %d from %s", req.TransactionID, chargePointID)
+	} else if err != nil {
+		h.log.Error("Failed to stop transaction: ", err)
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeInternalError, err.Error())
 	}
+
+	if tx != nil && len(req.TransactionData) > 0 {
+		values := toMeterValueModels(chargePointID, tx.ConnectorID, req.TransactionID, req.TransactionData)
+		if err := h.mvSvc.Enqueue(ctx, values); err != nil {
+			h.log.Error("Failed to enqueue transaction data: ", err)
+		}
+	}
+
+	resp := StopTransactionResponse{}
+	if req.IdTag != "" {
+		// checked once the transaction is stopped, so that it does not count as a concurrent one
+		status, err := idTagStatus(h.txSvc.Authorize(ctx, req.IdTag, OCPP16.maxIdTagLength()))
+		if err != nil {
+			h.log.Error("Failed to authorize id tag: ", err)
+			return h.createErrorResponse(msg.UniqueID, ErrorCodeInternalError, err.Error())
+		}
+		resp.IdTagInfo = &IdTagInfo{Status: status}
+	}
+	return h.createResponse(msg.UniqueID, resp)
+}
+
+func (h *OCPPHandler) handleMeterValues(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
+	var req MeterValuesRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeFormationViolation, "Invalid payload")
+	}
+
+	h.log.Debugf("Received MeterValues from %s: %+v", chargePointID, req)
+	transactionID := 0
+	if req.TransactionID != nil {
+		transactionID = *req.TransactionID
+	}
+	values := toMeterValueModels(chargePointID, req.ConnectorID, transactionID, req.MeterValue)
+	if err := h.mvSvc.Enqueue(ctx, values); err != nil {
+		h.log.Error("Failed to enqueue meter values: ", err)
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeInternalError, err.Error())
+	}
+
+	resp := MeterValuesResponse{}
+	return h.createResponse(msg.UniqueID, resp)
+}
+
+func (h *OCPPHandler) handleSecurityEventNotification(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
+	var req SecurityEventNotificationRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeFormationViolation, "Invalid payload")
+	}
+
+	h.log.Infof("Received SecurityEventNotification from %s: %+v", chargePointID, req)
+	err := h.secSvc.Record(ctx, &models.SecurityEvent{
+		ChargePointID: chargePointID,
+		Type:          req.Type,
+		Timestamp:     req.Timestamp,
+		TechInfo:      req.TechInfo,
+	})
+	if err != nil {
+		h.log.Error("Failed to record security event: ", err)
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeInternalError, err.Error())
+	}
+
+	resp := SecurityEventNotificationResponse{}
+	return h.createResponse(msg.UniqueID, resp)
+}
+
+func (h *OCPPHandler) handleFirmwareStatusNotification(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
+	var req FirmwareStatusNotificationRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeFormationViolation, "Invalid payload")
+	}
+
+	h.log.Infof("Received FirmwareStatusNotification from %s: %s", chargePointID, req.Status)
+	if h.firmwareHook != nil {
+		h.firmwareHook(ctx, chargePointID, req.Status, nil)
+	}
+
+	resp := FirmwareStatusNotificationResponse{}
+	return h.createResponse(msg.UniqueID, resp)
+}
+
+func (h *OCPPHandler) handleSignedFirmwareStatusNotification(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
+	var req SignedFirmwareStatusNotificationRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeFormationViolation, "Invalid payload")
+	}
+
+	h.log.Infof("Received SignedFirmwareStatusNotification from %s: %s", chargePointID, req.Status)
+	if h.firmwareHook != nil {
+		h.firmwareHook(ctx, chargePointID, req.Status, req.RequestID)
+	}
+
+	resp := SignedFirmwareStatusNotificationResponse{}
+	return h.createResponse(msg.UniqueID, resp)
+}
+
+func (h *OCPPHandler) handleDiagnosticsStatusNotification(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
+	var req DiagnosticsStatusNotificationRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeFormationViolation, "Invalid payload")
+	}
+
+	h.log.Infof("Received DiagnosticsStatusNotification from %s: %s", chargePointID, req.Status)
+	if h.diagnosticsHook != nil {
+		h.diagnosticsHook(ctx, chargePointID, req.Status, nil)
+	}
+
+	resp := DiagnosticsStatusNotificationResponse{}
+	return h.createResponse(msg.UniqueID, resp)
+}
+
+// useReservation links a transaction to the reservation it was started on; the
+// transaction is recorded anyway, so a failure is only logged
+func (h *OCPPHandler) useReservation(ctx context.Context, chargePointID uuid.UUID, reservationID int, tx *models.Transaction) {
+	if err := h.rsvSvc.Use(ctx, chargePointID, reservationID, tx.TransactionID); err != nil {
+		h.log.WithError(err).Errorf("Failed to link transaction %d to reservation %d", tx.TransactionID, reservationID)
+	}
+}
+
+// toMeterValueModels flattens OCPP 1.6 meter values into one row per sampled
+// value, filling in the defaults the specification defines for omitted fields.
+func toMeterValueModels(chargePointID uuid.UUID, connectorID, transactionID int, meterValues []MeterValue) []*models.MeterValue {
+	var values []*models.MeterValue
+	for _, mv := range meterValues {
+		for _, sv := range mv.SampledValue {
+			value := &models.MeterValue{
+				ChargePointID: chargePointID,
+				ConnectorID:   connectorID,
+				TransactionID: transactionID,
+				Timestamp:     mv.Timestamp,
+				Measurand:     cmp.Or(sv.Measurand, "Energy.Active.Import.Register"),
+				Unit:          cmp.Or(sv.Unit, "Wh"),
+				Context:       cmp.Or(sv.Context, "Sample.Periodic"),
+				Format:        cmp.Or(sv.Format, "Raw"),
+				Phase:         sv.Phase,
+				Location:      cmp.Or(sv.Location, "Outlet"),
+			}
+			if f, err := strconv.ParseFloat(sv.Value, 64); err == nil && value.Format == "Raw" {
+				value.Value = f
+			} else {
+				value.RawValue = sv.Value
+			}
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// idTagStatus maps an authorization result to an OCPP 1.6 IdTagInfo status.
+// Errors other than authorization failures are returned unchanged.
+func idTagStatus(err error) (string, error) {
+	switch {
+	case err == nil:
+		return "Accepted", nil
+	case errors.Is(err, services.ErrIdTagInvalid):
+		return "Invalid", nil
+	case errors.Is(err, services.ErrIdTagConcurrentTx):
+		return "ConcurrentTx", nil
+	default:
+		return "", err
+	}
+}
+
+// connectorStatus maps an OCPP 1.6 or 2.0.1 connector status to the stored status
+func connectorStatus(status string) enums.ChargePointStatus {
+	switch status {
+	case "Available":
+		return enums.ChargePointStatusAvailable
+	case "Preparing":
+		return enums.ChargePointStatusPreparing
+	case "Charging":
+		return enums.ChargePointStatusCharging
+	case "SuspendedEVSE":
+		return enums.ChargePointStatusSuspendedEVSE
+	case "SuspendedEV":
+		return enums.ChargePointStatusSuspendedEV
+	case "Finishing":
+		return enums.ChargePointStatusFinishing
+	case "Reserved":
+		return enums.ChargePointStatusReserved
+	case "Occupied":
+		return enums.ChargePointStatusOccupied
+	case "Unavailable":
+		return enums.ChargePointStatusUnavailable
+	case "Faulted":
+		return enums.ChargePointStatusFaulted
+	default:
+		return enums.ChargePointStatusUnknown
+	}
+}
+
+func (h *OCPPHandler) createResponse(uniqueID string, payload interface{}) ([]byte, error) {
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return nil, err
 	}
-	resp.Payload = data
+	resp := OCPPMessage{
+		MessageTypeID: CallResult,
+		UniqueID:      uniqueID,
+		Payload:       data,
+	}
 	return json.Marshal(resp)
 }
 
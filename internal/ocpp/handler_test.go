@@ -0,0 +1,102 @@
+package ocpp
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+// emptyDB is a database without rows that keeps the statements it is sent
+type emptyDB struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+func (db *emptyDB) Connect(context.Context) (driver.Conn, error) { return emptyConn{db}, nil }
+
+func (db *emptyDB) Driver() driver.Driver { return nil }
+
+func (db *emptyDB) record(query string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.queries = append(db.queries, query)
+}
+
+type emptyConn struct{ db *emptyDB }
+
+func (c emptyConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.db.record(query)
+	return noRows{}, nil
+}
+
+func (c emptyConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.db.record(query)
+	return driver.RowsAffected(0), nil
+}
+
+func (emptyConn) Prepare(string) (driver.Stmt, error) { return nil, errDatabaseUnavailable }
+
+func (emptyConn) Close() error { return nil }
+
+func (emptyConn) Begin() (driver.Tx, error) { return nil, errDatabaseUnavailable }
+
+type noRows struct{}
+
+func (noRows) Columns() []string { return nil }
+
+func (noRows) Close() error { return nil }
+
+func (noRows) Next([]driver.Value) error { return io.EOF }
+
+func TestHandlerOnlyLetsAcceptedChargePointsThrough(t *testing.T) {
+	mr := miniredis.RunT(t)
+	seedChargePoint(t, mr, &models.ChargePoint{Code: "CP-LEGACY", OcppVersion: "1.6", RegistrationStatus: enums.ChargePointRegistrationStatusUnknown})
+	db := &emptyDB{}
+	s := newTestServer(t, "node-a", mr.Addr(), db)
+	s.cfg.RegistrationPolicy = config.RegistrationPolicyPending
+
+	boot := `[2,"1","BootNotification",{"chargePointVendor":"Vendor","chargePointModel":"Model"}]`
+	resp, err := s.handler.HandleMessage(context.Background(), OCPP16, "CP-NEW", []byte(boot))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reply OCPPMessage
+	if err := json.Unmarshal(resp, &reply); err != nil {
+		t.Fatal(err)
+	}
+	var result BootNotificationResponse
+	if err := json.Unmarshal(reply.Payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != "Pending" {
+		t.Errorf("unknown charge point got %s, want Pending", result.Status)
+	}
+	for _, query := range db.queries {
+		if !strings.HasPrefix(query, "SELECT") {
+			t.Errorf("unknown charge point was stored: %s", query)
+		}
+	}
+
+	for _, identity := range []string{"CP-NEW", "CP-LEGACY"} {
+		resp, err := s.handler.HandleMessage(context.Background(), OCPP16, identity, []byte(`[2,"2","Heartbeat",{}]`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(resp, &reply); err != nil {
+			t.Fatal(err)
+		}
+		if reply.MessageTypeID != CallError || reply.ErrorCode != ErrorCodeSecurityError {
+			t.Errorf("Heartbeat of %s got %s, want a SecurityError", identity, resp)
+		}
+	}
+}
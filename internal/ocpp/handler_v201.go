@@ -0,0 +1,402 @@
+package ocpp
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/ocpp/v201"
+	"github.com/mutoulbj/gocsms/internal/services"
+)
+
+// handleMessageV201 dispatches a CALL from an accepted OCPP 2.0.1 charging
+// station. The handlers share the services used for OCPP 1.6; an EVSE id plays
+// the role of the 1.6 connector id.
+func (h *OCPPHandler) handleMessageV201(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
+	switch msg.Action {
+	case "Heartbeat":
+		return h.handleHeartbeatV201(ctx, chargePointID, msg)
+	case "StatusNotification":
+		return h.handleStatusNotificationV201(ctx, chargePointID, msg)
+	case "Authorize":
+		return h.handleAuthorizeV201(ctx, chargePointID, msg)
+	case "TransactionEvent":
+		return h.handleTransactionEventV201(ctx, chargePointID, msg)
+	case "MeterValues":
+		return h.handleMeterValuesV201(ctx, chargePointID, msg)
+	case "NotifyReport":
+		return h.handleNotifyReportV201(ctx, chargePointID, msg)
+	case "SecurityEventNotification":
+		return h.handleSecurityEventNotificationV201(ctx, chargePointID, msg)
+	case "FirmwareStatusNotification":
+		return h.handleFirmwareStatusNotificationV201(ctx, chargePointID, msg)
+	case "LogStatusNotification":
+		return h.handleLogStatusNotificationV201(ctx, chargePointID, msg)
+	case "ReservationStatusUpdate":
+		return h.handleReservationStatusUpdateV201(ctx, chargePointID, msg)
+	default:
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeNotSupported, fmt.Sprintf("Action %s not supported", msg.Action))
+	}
+}
+
+func (h *OCPPHandler) handleBootNotificationV201(ctx context.Context, identity string, msg OCPPMessage) ([]byte, error) {
+	var req v201.BootNotificationRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeFormatViolation, "Invalid payload")
+	}
+
+	h.log.Infof("Received BootNotification (2.0.1) from %s: %+v", identity, req)
+	cp, err := h.svc.Boot(ctx, identity, &models.ChargePoint{
+		SerialNumber:    req.ChargingStation.SerialNumber,
+		Vendor:          req.ChargingStation.VendorName,
+		Model:           req.ChargingStation.Model,
+		FirmwareVersion: req.ChargingStation.FirmwareVersion,
+		OcppVersion:     OCPP201.Version(),
+	}, h.cfg.RegistrationPolicy)
+	if err != nil {
+		h.log.Error("Failed to register charge point: ", err)
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeInternalError, err.Error())
+	}
+
+	status, interval := h.bootResult(cp)
+	if status == "Accepted" {
+		h.runBootHooks(ctx, cp)
+	}
+
+	resp := v201.BootNotificationResponse{
+		CurrentTime: time.Now(),
+		Interval:    interval,
+		Status:      status,
+	}
+	return h.createResponse(msg.UniqueID, resp)
+}
+
+func (h *OCPPHandler) handleHeartbeatV201(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
+	h.log.Infof("Received Heartbeat (2.0.1) from %s", chargePointID)
+	err := h.svc.Heartbeat(ctx, chargePointID)
+	if err != nil {
+		h.log.Error("Failed to update heartbeat: ", err)
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeInternalError, err.Error())
+	}
+
+	resp := v201.HeartbeatResponse{
+		CurrentTime: time.Now(),
+	}
+	return h.createResponse(msg.UniqueID, resp)
+}
+
+func (h *OCPPHandler) handleStatusNotificationV201(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
+	var req v201.StatusNotificationRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeFormatViolation, "Invalid payload")
+	}
+
+	h.log.Infof("Received StatusNotification (2.0.1) from %s: %+v", chargePointID, req)
+	// the EVSE is stored as the connector, like the EVSE id of 2.0.1 transactions
+	err := h.svc.UpdateConnectorStatus(ctx, &models.ConnectorStatusHistory{
+		ChargePointID: chargePointID,
+		ConnectorID:   req.EvseID,
+		Status:        connectorStatus(req.ConnectorStatus),
+		Timestamp:     cmp.Or(req.Timestamp, time.Now()),
+	})
+	if err != nil {
+		h.log.Error("Failed to update status: ", err)
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeInternalError, err.Error())
+	}
+
+	resp := v201.StatusNotificationResponse{}
+	return h.createResponse(msg.UniqueID, resp)
+}
+
+func (h *OCPPHandler) handleAuthorizeV201(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
+	var req v201.AuthorizeRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeFormatViolation, "Invalid payload")
+	}
+
+	h.log.Infof("Received Authorize (2.0.1) from %s: %+v", chargePointID, req)
+	status, err := idTagStatus(h.txSvc.Authorize(ctx, req.IdToken.IdToken, OCPP201.maxIdTagLength()))
+	if err != nil {
+		h.log.Error("Failed to authorize id token: ", err)
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeInternalError, err.Error())
+	}
+
+	resp := v201.AuthorizeResponse{
+		IdTokenInfo: v201.IdTokenInfo{Status: status},
+	}
+	return h.createResponse(msg.UniqueID, resp)
+}
+
+func (h *OCPPHandler) handleTransactionEventV201(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
+	var req v201.TransactionEventRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeFormatViolation, "Invalid payload")
+	}
+
+	h.log.Infof("Received TransactionEvent (2.0.1) from %s: %s %s", chargePointID, req.EventType, req.TransactionInfo.TransactionID)
+	resp := v201.TransactionEventResponse{}
+
+	tx, err := h.txSvc.GetByChargerTxID(ctx, chargePointID, req.TransactionInfo.TransactionID)
+	var authErr error
+	if errors.Is(err, services.ErrTransactionNotFound) {
+		// the first event of a transaction may be Updated or even Ended when the station was offline
+		tx, err = h.startTransactionV201(ctx, chargePointID, req)
+		if errors.Is(err, services.ErrIdTagInvalid) || errors.Is(err, services.ErrIdTagConcurrentTx) {
+			authErr, err = err, nil
+		}
+	}
+	if err != nil {
+		h.log.Error("Failed to record transaction event: ", err)
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeInternalError, err.Error())
+	}
+	if req.ReservationID != nil {
+		// the reservation may be reported on any event of the transaction
+		h.useReservation(ctx, chargePointID, *req.ReservationID, tx)
+	}
+	if req.IdToken != nil && req.EventType != "Ended" {
+		status, _ := idTagStatus(authErr)
+		resp.IdTokenInfo = &v201.IdTokenInfo{Status: status}
+	}
+
+	if len(req.MeterValue) > 0 {
+		values := toMeterValueModelsV201(chargePointID, tx.ConnectorID, tx.TransactionID, req.MeterValue)
+		if err := h.mvSvc.Enqueue(ctx, values); err != nil {
+			h.log.Error("Failed to enqueue meter values: ", err)
+		}
+	}
+
+	if req.EventType == "Ended" {
+		meterStop := tx.MeterStart
+		if energy, ok := energyRegisterV201(req.MeterValue); ok {
+			meterStop = energy
+		}
+		if _, err := h.txSvc.Stop(ctx, chargePointID, tx.TransactionID, meterStop, req.Timestamp, req.TransactionInfo.StoppedReason); err != nil {
+			h.log.Error("Failed to stop transaction: ", err)
+			return h.createErrorResponse(msg.UniqueID, ErrorCodeInternalError, err.Error())
+		}
+	}
+
+	return h.createResponse(msg.UniqueID, resp)
+}
+
+// startTransactionV201 records the transaction a TransactionEvent refers to. Like
+// TransactionService.Start it returns the transaction together with an
+// authorization error when the id token is not accepted.
+func (h *OCPPHandler) startTransactionV201(ctx context.Context, chargePointID uuid.UUID, req v201.TransactionEventRequest) (*models.Transaction, error) {
+	tx := &models.Transaction{
+		ChargePointID: chargePointID,
+		ChargerTxID:   req.TransactionInfo.TransactionID,
+		StartTime:     req.Timestamp,
+	}
+	if req.EVSE != nil {
+		tx.ConnectorID = req.EVSE.ID
+	}
+	if req.IdToken != nil {
+		tx.IdTag = req.IdToken.IdToken
+	}
+	if energy, ok := energyRegisterV201(req.MeterValue); ok {
+		tx.MeterStart = energy
+	}
+	return h.txSvc.Start(ctx, tx, OCPP201.maxIdTagLength())
+}
+
+func (h *OCPPHandler) handleSecurityEventNotificationV201(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
+	var req v201.SecurityEventNotificationRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeFormatViolation, "Invalid payload")
+	}
+
+	h.log.Infof("Received SecurityEventNotification (2.0.1) from %s: %+v", chargePointID, req)
+	err := h.secSvc.Record(ctx, &models.SecurityEvent{
+		ChargePointID: chargePointID,
+		Type:          req.Type,
+		Timestamp:     req.Timestamp,
+		TechInfo:      req.TechInfo,
+	})
+	if err != nil {
+		h.log.Error("Failed to record security event: ", err)
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeInternalError, err.Error())
+	}
+
+	resp := v201.SecurityEventNotificationResponse{}
+	return h.createResponse(msg.UniqueID, resp)
+}
+
+func (h *OCPPHandler) handleMeterValuesV201(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
+	var req v201.MeterValuesRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeFormatViolation, "Invalid payload")
+	}
+
+	h.log.Debugf("Received MeterValues (2.0.1) from %s: %+v", chargePointID, req)
+	values := toMeterValueModelsV201(chargePointID, req.EvseID, 0, req.MeterValue)
+	if err := h.mvSvc.Enqueue(ctx, values); err != nil {
+		h.log.Error("Failed to enqueue meter values: ", err)
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeInternalError, err.Error())
+	}
+
+	resp := v201.MeterValuesResponse{}
+	return h.createResponse(msg.UniqueID, resp)
+}
+
+func (h *OCPPHandler) handleNotifyReportV201(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
+	var req v201.NotifyReportRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeFormatViolation, "Invalid payload")
+	}
+
+	h.log.Infof("Received NotifyReport (2.0.1) from %s: request %d, seqNo %d, %d variables", chargePointID, req.RequestID, req.SeqNo, len(req.ReportData))
+	var items []*models.ChargePointConfiguration
+	for _, data := range req.ReportData {
+		for _, attr := range data.VariableAttribute {
+			if attr.Type != "" && attr.Type != "Actual" {
+				continue
+			}
+			item := &models.ChargePointConfiguration{
+				Key:      deviceModelKey(data.Component, data.Variable),
+				Readonly: attr.Mutability == "ReadOnly",
+			}
+			if attr.Value != nil {
+				item.Value = *attr.Value
+			}
+			items = append(items, item)
+		}
+	}
+	if err := h.cfgSvc.SaveReported(ctx, chargePointID, items); err != nil {
+		h.log.Error("Failed to store reported variables: ", err)
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeInternalError, err.Error())
+	}
+
+	resp := v201.NotifyReportResponse{}
+	return h.createResponse(msg.UniqueID, resp)
+}
+
+func (h *OCPPHandler) handleFirmwareStatusNotificationV201(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
+	var req v201.FirmwareStatusNotificationRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeFormatViolation, "Invalid payload")
+	}
+
+	h.log.Infof("Received FirmwareStatusNotification (2.0.1) from %s: %s", chargePointID, req.Status)
+	if h.firmwareHook != nil {
+		h.firmwareHook(ctx, chargePointID, req.Status, req.RequestID)
+	}
+
+	resp := v201.FirmwareStatusNotificationResponse{}
+	return h.createResponse(msg.UniqueID, resp)
+}
+
+func (h *OCPPHandler) handleLogStatusNotificationV201(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
+	var req v201.LogStatusNotificationRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeFormatViolation, "Invalid payload")
+	}
+
+	h.log.Infof("Received LogStatusNotification (2.0.1) from %s: %s", chargePointID, req.Status)
+	if h.diagnosticsHook != nil {
+		h.diagnosticsHook(ctx, chargePointID, req.Status, req.RequestID)
+	}
+
+	resp := v201.LogStatusNotificationResponse{}
+	return h.createResponse(msg.UniqueID, resp)
+}
+
+func (h *OCPPHandler) handleReservationStatusUpdateV201(ctx context.Context, chargePointID uuid.UUID, msg OCPPMessage) ([]byte, error) {
+	var req v201.ReservationStatusUpdateRequest
+	if err := json.Unmarshal(msg.Payload, &req); err != nil {
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeFormatViolation, "Invalid payload")
+	}
+
+	h.log.Infof("Received ReservationStatusUpdate (2.0.1) from %s: %d %s", chargePointID, req.ReservationID, req.ReservationUpdateStatus)
+	if err := h.rsvSvc.RecordStatusUpdate(ctx, chargePointID, req.ReservationID, req.ReservationUpdateStatus); err != nil {
+		h.log.Error("Failed to record reservation status: ", err)
+		return h.createErrorResponse(msg.UniqueID, ErrorCodeInternalError, err.Error())
+	}
+
+	resp := v201.ReservationStatusUpdateResponse{}
+	return h.createResponse(msg.UniqueID, resp)
+}
+
+// deviceModelKey flattens an OCPP 2.0.1 component variable into a configuration key,
+// e.g. "OCPPCommCtrlr.HeartbeatInterval" or "EVSE[1].Connector[2].Available"
+func deviceModelKey(component v201.Component, variable v201.Variable) string {
+	var b strings.Builder
+	if component.EVSE != nil {
+		fmt.Fprintf(&b, "EVSE[%d].", component.EVSE.ID)
+		if component.EVSE.ConnectorID != nil {
+			fmt.Fprintf(&b, "Connector[%d].", *component.EVSE.ConnectorID)
+		}
+	}
+	b.WriteString(component.Name)
+	if component.Instance != "" {
+		fmt.Fprintf(&b, "[%s]", component.Instance)
+	}
+	b.WriteString(".")
+	b.WriteString(variable.Name)
+	if variable.Instance != "" {
+		fmt.Fprintf(&b, "[%s]", variable.Instance)
+	}
+	return b.String()
+}
+
+// toMeterValueModelsV201 flattens OCPP 2.0.1 meter values into one row per sampled value
+func toMeterValueModelsV201(chargePointID uuid.UUID, evseID, transactionID int, meterValues []v201.MeterValue) []*models.MeterValue {
+	var values []*models.MeterValue
+	for _, mv := range meterValues {
+		for _, sv := range mv.SampledValue {
+			value := &models.MeterValue{
+				ChargePointID: chargePointID,
+				ConnectorID:   evseID,
+				TransactionID: transactionID,
+				Timestamp:     mv.Timestamp,
+				Measurand:     cmp.Or(sv.Measurand, "Energy.Active.Import.Register"),
+				Value:         sv.Value,
+				Unit:          "Wh",
+				Context:       cmp.Or(sv.Context, "Sample.Periodic"),
+				Format:        "Raw",
+				Phase:         sv.Phase,
+				Location:      cmp.Or(sv.Location, "Outlet"),
+			}
+			if sv.UnitOfMeasure != nil {
+				value.Unit = cmp.Or(sv.UnitOfMeasure.Unit, "Wh")
+				value.Value = sv.Value * math.Pow10(sv.UnitOfMeasure.Multiplier)
+			}
+			if sv.SignedMeterValue != nil {
+				value.Format = "SignedData"
+				value.RawValue = sv.SignedMeterValue.SignedMeterData
+			}
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// energyRegisterV201 returns the last Energy.Active.Import.Register reading in Wh
+func energyRegisterV201(meterValues []v201.MeterValue) (float64, bool) {
+	var energy float64
+	found := false
+	for _, mv := range meterValues {
+		for _, sv := range mv.SampledValue {
+			if cmp.Or(sv.Measurand, "Energy.Active.Import.Register") != "Energy.Active.Import.Register" || sv.Phase != "" {
+				continue
+			}
+			energy = sv.Value
+			if sv.UnitOfMeasure != nil {
+				energy *= math.Pow10(sv.UnitOfMeasure.Multiplier)
+				if sv.UnitOfMeasure.Unit == "kWh" {
+					energy *= 1000
+				}
+			}
+			found = true
+		}
+	}
+	return energy, found
+}
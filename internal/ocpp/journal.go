@@ -0,0 +1,65 @@
+package ocpp
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+// Sizes of the ocpp_messages columns filled from the frames of charge points
+const (
+	journalUniqueIDSize = 64
+	journalActionSize   = 50
+	journalErrorSize    = 512
+)
+
+// journal records a frame exchanged with a charge point in the message journal.
+// Replies carry the action of the CALL they answer and, when callAt is set, the
+// time elapsed since that CALL.
+func (s *Server) journal(identity string, direction enums.MessageDirection, data []byte, action string, callAt time.Time) {
+	now := time.Now()
+	msg := &models.OCPPMessage{
+		Identity:  identity,
+		Direction: direction,
+		Action:    action,
+		Payload:   strings.ToValidUTF8(string(data), "\uFFFD"),
+		Timestamp: now,
+	}
+	if !callAt.IsZero() {
+		latency := now.Sub(callAt).Milliseconds()
+		msg.LatencyMs = &latency
+	}
+
+	var frame OCPPMessage
+	err := json.Unmarshal(data, &frame)
+	msg.MessageType = int(frame.MessageTypeID)
+	msg.UniqueID = frame.UniqueID
+	switch {
+	case err != nil:
+		msg.Error = err.Error()
+	case frame.MessageTypeID == Call:
+		msg.Action = frame.Action
+	case frame.MessageTypeID == CallError:
+		msg.Error = fmt.Sprintf("%s: %s", frame.ErrorCode, frame.ErrorMessage)
+	}
+	msg.UniqueID = journalText(msg.UniqueID, journalUniqueIDSize)
+	msg.Action = journalText(msg.Action, journalActionSize)
+	msg.Error = journalText(msg.Error, journalErrorSize)
+	s.messages.Record(msg)
+}
+
+// journalText cuts s to a column of size characters. The journal is written in
+// batches, so a single over-long or malformed field sent by a charge point would
+// otherwise fail the insert of the frames of every other charge point.
+func journalText(s string, size int) string {
+	s = strings.ToValidUTF8(s, "\uFFFD")
+	if utf8.RuneCountInString(s) <= size {
+		return s
+	}
+	return string([]rune(s)[:size])
+}
@@ -0,0 +1,180 @@
+package ocpp
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
+)
+
+// journalDB stands in for Postgres when inserting into ocpp_messages. Like
+// Postgres it fails the whole statement when a value does not fit its column,
+// and it keeps the rows of the statements that succeed.
+type journalDB struct {
+	sizes map[string]int // VARCHAR sizes of the ocpp_messages columns
+
+	mu   sync.Mutex
+	rows []map[string]string
+}
+
+func (db *journalDB) Connect(context.Context) (driver.Conn, error) { return journalConn{db}, nil }
+
+func (db *journalDB) Driver() driver.Driver { return nil }
+
+type journalConn struct{ db *journalDB }
+
+func (c journalConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if err := c.db.insert(query); err != nil {
+		return nil, err
+	}
+	return emptyRows{}, nil
+}
+
+func (c journalConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if err := c.db.insert(query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (journalConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("database unavailable")
+}
+
+func (journalConn) Close() error { return nil }
+
+func (journalConn) Begin() (driver.Tx, error) { return nil, errors.New("database unavailable") }
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string { return nil }
+
+func (emptyRows) Close() error { return nil }
+
+func (emptyRows) Next([]driver.Value) error { return errors.New("no rows") }
+
+// insert checks the rows of an INSERT into ocpp_messages and keeps them
+func (db *journalDB) insert(query string) error {
+	prefix := `INSERT INTO "ocpp_messages" (`
+	if !strings.HasPrefix(query, prefix) {
+		return errors.New("unexpected query: " + query)
+	}
+	columns, values, ok := strings.Cut(strings.TrimPrefix(query, prefix), ") VALUES ")
+	if !ok {
+		return errors.New("unexpected query: " + query)
+	}
+	names := strings.Split(strings.ReplaceAll(columns, `"`, ""), ", ")
+
+	var rows []map[string]string
+	for _, tuple := range parseTuples(values) {
+		row := make(map[string]string)
+		for i, value := range tuple {
+			size, limited := db.sizes[names[i]]
+			if limited && utf8.RuneCountInString(value) > size {
+				return fmt.Errorf("value too long for type character varying(%d)", size)
+			}
+			if !utf8.ValidString(value) {
+				return errors.New("invalid byte sequence for encoding UTF8")
+			}
+			row[names[i]] = value
+		}
+		rows = append(rows, row)
+	}
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.rows = append(db.rows, rows...)
+	return nil
+}
+
+// parseTuples returns the string literals of the VALUES tuples of an INSERT
+// as formatted by bun, with an empty string for DEFAULT and other keywords
+func parseTuples(values string) [][]string {
+	var tuples [][]string
+	var tuple []string
+	for i := 0; i < len(values); i++ {
+		switch values[i] {
+		case '(':
+			tuple = nil
+		case ')':
+			tuples = append(tuples, tuple)
+			if !strings.HasPrefix(values[i+1:], ", (") {
+				return tuples
+			}
+		case '\'':
+			var literal strings.Builder
+			for i++; i < len(values); i++ {
+				if values[i] == '\'' {
+					if i+1 < len(values) && values[i+1] == '\'' {
+						i++
+					} else {
+						break
+					}
+				}
+				literal.WriteByte(values[i])
+			}
+			tuple = append(tuple, literal.String())
+		case ',', ' ':
+		default:
+			end := strings.IndexAny(values[i:], ",)")
+			tuple = append(tuple, "")
+			i += end - 1
+		}
+	}
+	return tuples
+}
+
+// messageColumnSizes reads the VARCHAR sizes of the ocpp_messages columns from their migration
+func messageColumnSizes(t *testing.T) map[string]int {
+	t.Helper()
+	migration, err := os.ReadFile("../../migrations/20250708120000_create_ocpp_messages_table.up.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes := make(map[string]int)
+	for _, match := range regexp.MustCompile(`(\w+) VARCHAR\((\d+)\)`).FindAllStringSubmatch(string(migration), -1) {
+		sizes[match[1]], _ = strconv.Atoi(match[2])
+	}
+	return sizes
+}
+
+func TestJournalKeepsBatchWithOverlongFrame(t *testing.T) {
+	db := &journalDB{sizes: messageColumnSizes(t)}
+	s := newTestServer(t, "node-a", miniredis.RunT(t).Addr(), db)
+	s.cfg.MessageBatchSize = 10
+	s.cfg.MessageFlushInterval = time.Hour
+
+	long := strings.Repeat("é", 600)
+	frames := []string{
+		`[2,"1","Heartbeat",{}]`,
+		`[2,"` + long + `","` + long + `",{}]`,
+		`[4,"2","InternalError","` + long + `",{}]`,
+		`[3,"3",{"currentTime":"2025-07-08T12:00:00Z"}]`,
+		"[2,\"4\",\"\xff\",{}]",
+	}
+	for _, frame := range frames {
+		s.journal("CP-1", enums.MessageDirectionInbound, []byte(frame), "", time.Time{})
+	}
+	s.messages.Start()
+	s.messages.Stop()
+
+	if len(db.rows) != len(frames) {
+		t.Fatalf("journaled %d of %d frames", len(db.rows), len(frames))
+	}
+	for i, row := range db.rows {
+		if want := strings.ToValidUTF8(frames[i], "\uFFFD"); row["payload"] != want {
+			t.Errorf("frame %d: got payload %q, want %q", i, row["payload"], want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package ocpp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/services"
+)
+
+// maintenanceCheckInterval is how often maintenance windows are started, ended
+// and their charge points that could not be reached tried again
+const maintenanceCheckInterval = 30 * time.Second
+
+// MaintenanceScheduler takes the charge points of the maintenance windows out
+// of service when a window starts and puts them back once it ends, with
+// ChangeAvailability on the charge point as a whole. Charge points that are
+// not connected are tried again on every check. A window is completed once
+// all of its charge points are back in service.
+type MaintenanceScheduler struct {
+	server *Server
+	cfg    *config.OCPPConfig
+	svc    *services.MaintenanceService
+	log    *logrus.Logger
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewMaintenanceScheduler(
+	server *Server,
+	cfg *config.OCPPConfig,
+	svc *services.MaintenanceService,
+	log *logrus.Logger,
+) *MaintenanceScheduler {
+	return &MaintenanceScheduler{
+		server: server,
+		cfg:    cfg,
+		svc:    svc,
+		log:    log,
+		done:   make(chan struct{}),
+	}
+}
+
+// Start launches the periodic maintenance window check
+func (m *MaintenanceScheduler) Start() {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(maintenanceCheckInterval)
+		defer ticker.Stop()
+		m.check()
+		for {
+			select {
+			case <-ticker.C:
+				m.check()
+			case <-m.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic check
+func (m *MaintenanceScheduler) Stop() {
+	close(m.done)
+	m.wg.Wait()
+}
+
+func (m *MaintenanceScheduler) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), maintenanceCheckInterval)
+	windows, err := m.svc.ListOpen(ctx)
+	cancel()
+	if err != nil {
+		m.log.WithError(err).Error("Failed to list open maintenance windows")
+		return
+	}
+	for _, window := range windows {
+		if err := m.advance(window); err != nil {
+			m.log.WithError(err).Errorf("Failed to advance maintenance window %s", window.Name)
+		}
+	}
+}
+
+// advance starts or ends a window when it is time to, then takes its charge
+// points out of service or puts them back
+func (m *MaintenanceScheduler) advance(window *models.MaintenanceWindow) error {
+	ctx, cancel := context.WithTimeout(context.Background(), maintenanceCheckInterval)
+	defer cancel()
+
+	now := time.Now()
+	switch {
+	case window.Status == enums.MaintenanceWindowStatusScheduled && !now.Before(window.StartsAt):
+		m.log.Infof("Starting maintenance window %s", window.Name)
+		window.Status = enums.MaintenanceWindowStatusActive
+	case window.Status == enums.MaintenanceWindowStatusActive && !now.Before(window.EndsAt):
+		m.log.Infof("Ending maintenance window %s", window.Name)
+		window.Status = enums.MaintenanceWindowStatusRestoring
+	case window.Status == enums.MaintenanceWindowStatusScheduled:
+		return nil
+	default:
+		return m.progress(ctx, window)
+	}
+	if err := m.svc.UpdateState(ctx, window); err != nil {
+		return err
+	}
+	return m.progress(ctx, window)
+}
+
+// progress moves the charge points of an active or restoring window on, and
+// completes a restoring window once all of them are done
+func (m *MaintenanceScheduler) progress(ctx context.Context, window *models.MaintenanceWindow) error {
+	targets, err := m.svc.ListTargets(ctx, window.ID, "")
+	if err != nil {
+		return err
+	}
+
+	ended := true
+	for _, target := range targets {
+		if !target.Status.IsFinal() {
+			m.move(window, target)
+		}
+		ended = ended && target.Status.IsFinal()
+	}
+	if !ended || window.Status != enums.MaintenanceWindowStatusRestoring {
+		return nil
+	}
+	m.log.Infof("Maintenance window %s completed", window.Name)
+	window.Status = enums.MaintenanceWindowStatusCompleted
+	window.CompletedAt = time.Now()
+	return m.svc.UpdateState(ctx, window)
+}
+
+// move takes a charge point out of service while the window is active and puts
+// it back once the window has ended
+func (m *MaintenanceScheduler) move(window *models.MaintenanceWindow, target *models.MaintenanceWindowTarget) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*m.cfg.CallTimeout)
+	defer cancel()
+
+	before := *target
+	cp := target.ChargePoint
+	restoring := window.Status == enums.MaintenanceWindowStatusRestoring
+	switch {
+	case cp == nil:
+		target.Status = enums.MaintenanceTargetStatusFailed
+		target.Error = "Charge point was deleted"
+	case restoring && target.Status == enums.MaintenanceTargetStatusPending:
+		target.Status = enums.MaintenanceTargetStatusSkipped
+	case restoring:
+		m.change(ctx, window, target, enums.AvailabilityOperative, enums.MaintenanceTargetStatusRestored)
+	case target.Status == enums.MaintenanceTargetStatusPending:
+		m.change(ctx, window, target, enums.AvailabilityInoperative, enums.MaintenanceTargetStatusUnavailable)
+	case target.Status == enums.MaintenanceTargetStatusScheduled &&
+		cp.Availability == enums.AvailabilityInoperative && !cp.AvailabilityScheduled:
+		// the charge point reported its connectors unavailable once its transactions ended
+		target.Status = enums.MaintenanceTargetStatusUnavailable
+	}
+
+	if target.Status == before.Status && target.Error == before.Error {
+		return
+	}
+	if err := m.svc.UpdateTarget(ctx, target); err != nil {
+		m.log.WithError(err).Errorf("Failed to update maintenance of charge point %s", target.ChargePointID)
+	}
+}
+
+// change sends the availability to the charge point of a target and moves the
+// target to the given status once the charge point accepted it. Charge points
+// going out of service once their transactions end are Scheduled meanwhile.
+func (m *MaintenanceScheduler) change(ctx context.Context, window *models.MaintenanceWindow, target *models.MaintenanceWindowTarget, availability enums.Availability, accepted enums.MaintenanceTargetStatus) {
+	cp := target.ChargePoint
+	status, err := m.server.SetAvailability(ctx, cp, 0, availability)
+	switch {
+	case errors.Is(err, ErrChargePointNotConnected):
+		// tried again on the next check
+	case err != nil:
+		m.log.WithError(err).Warnf("Failed to change availability of charge point %s for maintenance window %s", cp.Code, window.Name)
+		target.Error = err.Error()
+	case status == "Scheduled" && availability == enums.AvailabilityInoperative:
+		target.Status = enums.MaintenanceTargetStatusScheduled
+		target.Error = ""
+	case status == "Accepted" || status == "Scheduled":
+		target.Status = accepted
+		target.Error = ""
+	default:
+		target.Status = enums.MaintenanceTargetStatusFailed
+		target.Error = fmt.Sprintf("Charge point answered %s to %s", status, availability.OCPP())
+	}
+}
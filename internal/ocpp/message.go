@@ -0,0 +1,157 @@
+package ocpp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// OCPP-J error codes used in CALLERROR frames
+const (
+	ErrorCodeNotImplemented               = "NotImplemented"
+	ErrorCodeNotSupported                 = "NotSupported"
+	ErrorCodeInternalError                = "InternalError"
+	ErrorCodeProtocolError                = "ProtocolError"
+	ErrorCodeSecurityError                = "SecurityError"
+	ErrorCodeFormationViolation           = "FormationViolation"
+	ErrorCodePropertyConstraintViolation  = "PropertyConstraintViolation"
+	ErrorCodeOccurenceConstraintViolation = "OccurenceConstraintViolation"
+	ErrorCodeTypeConstraintViolation      = "TypeConstraintViolation"
+	ErrorCodeGenericError                 = "GenericError"
+
+	// OCPP 2.0.1 renamed two of the codes, see ProtocolVersion.errorCode
+	ErrorCodeFormatViolation               = "FormatViolation"
+	ErrorCodeOccurrenceConstraintViolation = "OccurrenceConstraintViolation"
+)
+
+var (
+	// ErrInvalidFrame is returned when a frame is not a valid OCPP-J array
+	ErrInvalidFrame = errors.New("invalid OCPP-J frame")
+	// ErrUnknownMessageType is returned when the frame carries an unsupported message type id
+	ErrUnknownMessageType = errors.New("unknown OCPP-J message type")
+)
+
+var emptyObject = json.RawMessage("{}")
+
+// MarshalJSON encodes the message as an OCPP-J positional array:
+//
+//	CALL:       [2, "<uniqueId>", "<action>", {<payload>}]
+//	CALLRESULT: [3, "<uniqueId>", {<payload>}]
+//	CALLERROR:  [4, "<uniqueId>", "<errorCode>", "<errorDescription>", {<errorDetails>}]
+func (m OCPPMessage) MarshalJSON() ([]byte, error) {
+	switch m.MessageTypeID {
+	case Call:
+		return json.Marshal([]any{m.MessageTypeID, m.UniqueID, m.Action, orEmptyObject(m.Payload)})
+	case CallResult:
+		return json.Marshal([]any{m.MessageTypeID, m.UniqueID, orEmptyObject(m.Payload)})
+	case CallError:
+		return json.Marshal([]any{m.MessageTypeID, m.UniqueID, m.ErrorCode, m.ErrorMessage, orEmptyObject(m.ErrorDetails)})
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrUnknownMessageType, m.MessageTypeID)
+	}
+}
+
+// UnmarshalJSON decodes an OCPP-J positional array. Fields are filled in as
+// they are decoded, so the UniqueID is available to build a CALLERROR even
+// when a later element of the frame is malformed.
+func (m *OCPPMessage) UnmarshalJSON(data []byte) error {
+	var elems []json.RawMessage
+	if err := json.Unmarshal(data, &elems); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidFrame, err)
+	}
+	if len(elems) < 3 {
+		return fmt.Errorf("%w: expected at least 3 elements, got %d", ErrInvalidFrame, len(elems))
+	}
+
+	var typeID MessageType
+	if err := json.Unmarshal(elems[0], &typeID); err != nil {
+		return fmt.Errorf("%w: message type id: %v", ErrInvalidFrame, err)
+	}
+	var uniqueID string
+	if err := stringElement(elems[1], &uniqueID); err != nil {
+		return fmt.Errorf("%w: unique id: %v", ErrInvalidFrame, err)
+	}
+	*m = OCPPMessage{MessageTypeID: typeID, UniqueID: uniqueID}
+
+	switch typeID {
+	case Call:
+		if len(elems) != 4 {
+			return fmt.Errorf("%w: CALL expects 4 elements, got %d", ErrInvalidFrame, len(elems))
+		}
+		if err := stringElement(elems[2], &m.Action); err != nil {
+			return fmt.Errorf("%w: action: %v", ErrInvalidFrame, err)
+		}
+		payload, err := objectPayload(elems[3])
+		if err != nil {
+			return err
+		}
+		m.Payload = payload
+	case CallResult:
+		if len(elems) != 3 {
+			return fmt.Errorf("%w: CALLRESULT expects 3 elements, got %d", ErrInvalidFrame, len(elems))
+		}
+		payload, err := objectPayload(elems[2])
+		if err != nil {
+			return err
+		}
+		m.Payload = payload
+	case CallError:
+		if len(elems) != 5 {
+			return fmt.Errorf("%w: CALLERROR expects 5 elements, got %d", ErrInvalidFrame, len(elems))
+		}
+		if err := stringElement(elems[2], &m.ErrorCode); err != nil {
+			return fmt.Errorf("%w: error code: %v", ErrInvalidFrame, err)
+		}
+		if err := stringElement(elems[3], &m.ErrorMessage); err != nil {
+			return fmt.Errorf("%w: error description: %v", ErrInvalidFrame, err)
+		}
+		details, err := objectPayload(elems[4])
+		if err != nil {
+			return err
+		}
+		m.ErrorDetails = details
+	default:
+		return fmt.Errorf("%w: %d", ErrUnknownMessageType, typeID)
+	}
+	return nil
+}
+
+// frameType returns the message type id of a frame, even one that does not
+// decode, and false when the frame has none
+func frameType(data []byte) (MessageType, bool) {
+	var elems []json.RawMessage
+	if err := json.Unmarshal(data, &elems); err != nil || len(elems) == 0 {
+		return 0, false
+	}
+	var typeID MessageType
+	if err := json.Unmarshal(elems[0], &typeID); err != nil {
+		return 0, false
+	}
+	return typeID, true
+}
+
+// stringElement decodes a string element of a frame; unlike json.Unmarshal it
+// does not accept null
+func stringElement(raw json.RawMessage, dst *string) error {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || trimmed[0] != '"' {
+		return errors.New("must be a string")
+	}
+	return json.Unmarshal(trimmed, dst)
+}
+
+func objectPayload(raw json.RawMessage) (json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(raw)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, fmt.Errorf("%w: payload must be a JSON object", ErrInvalidFrame)
+	}
+	return append(json.RawMessage(nil), trimmed...), nil
+}
+
+func orEmptyObject(raw json.RawMessage) json.RawMessage {
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return emptyObject
+	}
+	return raw
+}
@@ -0,0 +1,209 @@
+package ocpp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// frames captured from real chargers
+var capturedFrames = []struct {
+	name  string
+	frame string
+	want  OCPPMessage
+}{
+	{
+		name:  "BootNotification CALL",
+		frame: `[2,"19223201","BootNotification",{"chargePointVendor":"ABB","chargePointModel":"Terra AC W22-T-R-0","chargePointSerialNumber":"TACW2243220G1234","firmwareVersion":"1.6.6"}]`,
+		want: OCPPMessage{
+			MessageTypeID: Call,
+			UniqueID:      "19223201",
+			Action:        "BootNotification",
+			Payload:       json.RawMessage(`{"chargePointVendor":"ABB","chargePointModel":"Terra AC W22-T-R-0","chargePointSerialNumber":"TACW2243220G1234","firmwareVersion":"1.6.6"}`),
+		},
+	},
+	{
+		name:  "Heartbeat CALL with empty payload",
+		frame: `[2, "a7c1a0ee-4c5a-4d1f-9b7e-2f0c0d1e5b3a", "Heartbeat", {}]`,
+		want: OCPPMessage{
+			MessageTypeID: Call,
+			UniqueID:      "a7c1a0ee-4c5a-4d1f-9b7e-2f0c0d1e5b3a",
+			Action:        "Heartbeat",
+			Payload:       json.RawMessage(`{}`),
+		},
+	},
+	{
+		name:  "StatusNotification CALL",
+		frame: `[2,"1000045","StatusNotification",{"connectorId":1,"errorCode":"NoError","status":"Preparing","timestamp":"2025-06-30T09:12:44Z"}]`,
+		want: OCPPMessage{
+			MessageTypeID: Call,
+			UniqueID:      "1000045",
+			Action:        "StatusNotification",
+			Payload:       json.RawMessage(`{"connectorId":1,"errorCode":"NoError","status":"Preparing","timestamp":"2025-06-30T09:12:44Z"}`),
+		},
+	},
+	{
+		name:  "GetConfiguration CALLRESULT",
+		frame: `[3,"6b0b6d0e-87c4-4f0b-bb2c-6f1c3e6e0c11",{"configurationKey":[{"key":"HeartbeatInterval","readonly":false,"value":"300"}],"unknownKey":["Foo"]}]`,
+		want: OCPPMessage{
+			MessageTypeID: CallResult,
+			UniqueID:      "6b0b6d0e-87c4-4f0b-bb2c-6f1c3e6e0c11",
+			Payload:       json.RawMessage(`{"configurationKey":[{"key":"HeartbeatInterval","readonly":false,"value":"300"}],"unknownKey":["Foo"]}`),
+		},
+	},
+	{
+		name:  "RemoteStopTransaction CALLRESULT",
+		frame: `[3,"42",{"status":"Accepted"}]`,
+		want: OCPPMessage{
+			MessageTypeID: CallResult,
+			UniqueID:      "42",
+			Payload:       json.RawMessage(`{"status":"Accepted"}`),
+		},
+	},
+	{
+		name:  "CALLERROR",
+		frame: `[4,"7e1d0b2c","NotImplemented","Requested Action is not known by receiver",{}]`,
+		want: OCPPMessage{
+			MessageTypeID: CallError,
+			UniqueID:      "7e1d0b2c",
+			ErrorCode:     "NotImplemented",
+			ErrorMessage:  "Requested Action is not known by receiver",
+			ErrorDetails:  json.RawMessage(`{}`),
+		},
+	},
+	{
+		name:  "CALLERROR with details",
+		frame: `[4,"1000046","FormationViolation","Payload for Action is syntactically incorrect",{"field":"meterValue"}]`,
+		want: OCPPMessage{
+			MessageTypeID: CallError,
+			UniqueID:      "1000046",
+			ErrorCode:     "FormationViolation",
+			ErrorMessage:  "Payload for Action is syntactically incorrect",
+			ErrorDetails:  json.RawMessage(`{"field":"meterValue"}`),
+		},
+	},
+}
+
+func TestOCPPMessageRoundTrip(t *testing.T) {
+	for _, tt := range capturedFrames {
+		t.Run(tt.name, func(t *testing.T) {
+			var msg OCPPMessage
+			if err := json.Unmarshal([]byte(tt.frame), &msg); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if msg.MessageTypeID != tt.want.MessageTypeID || msg.UniqueID != tt.want.UniqueID ||
+				msg.Action != tt.want.Action || msg.ErrorCode != tt.want.ErrorCode || msg.ErrorMessage != tt.want.ErrorMessage {
+				t.Fatalf("unmarshal: got %+v, want %+v", msg, tt.want)
+			}
+			if !bytes.Equal(msg.Payload, tt.want.Payload) {
+				t.Errorf("payload: got %s, want %s", msg.Payload, tt.want.Payload)
+			}
+			if !bytes.Equal(msg.ErrorDetails, tt.want.ErrorDetails) {
+				t.Errorf("error details: got %s, want %s", msg.ErrorDetails, tt.want.ErrorDetails)
+			}
+
+			out, err := json.Marshal(msg)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			var want bytes.Buffer
+			if err := json.Compact(&want, []byte(tt.frame)); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(out, want.Bytes()) {
+				t.Errorf("marshal: got %s, want %s", out, want.Bytes())
+			}
+		})
+	}
+}
+
+func TestOCPPMessageMarshalDefaults(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  OCPPMessage
+		want string
+	}{
+		{"CALL without payload", OCPPMessage{MessageTypeID: Call, UniqueID: "1", Action: "Heartbeat"}, `[2,"1","Heartbeat",{}]`},
+		{"CALLRESULT without payload", OCPPMessage{MessageTypeID: CallResult, UniqueID: "2"}, `[3,"2",{}]`},
+		{"CALLERROR without details", OCPPMessage{MessageTypeID: CallError, UniqueID: "3", ErrorCode: "InternalError"}, `[4,"3","InternalError","",{}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := json.Marshal(tt.msg)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if string(out) != tt.want {
+				t.Errorf("got %s, want %s", out, tt.want)
+			}
+		})
+	}
+
+	if _, err := json.Marshal(OCPPMessage{MessageTypeID: 5, UniqueID: "4"}); !errors.Is(err, ErrUnknownMessageType) {
+		t.Errorf("unknown message type: got %v, want %v", err, ErrUnknownMessageType)
+	}
+}
+
+func TestOCPPMessageUnmarshalMalformed(t *testing.T) {
+	tests := []struct {
+		name     string
+		frame    string
+		wantErr  error
+		uniqueID string // kept so that a CALLERROR can still be sent back
+	}{
+		{name: "not an array", frame: `{"messageTypeId":2}`, wantErr: ErrInvalidFrame},
+		{name: "not JSON", frame: `[2,"1","Heartbeat",{}`, wantErr: ErrInvalidFrame},
+		{name: "too few elements", frame: `[2,"1"]`, wantErr: ErrInvalidFrame},
+		{name: "CALL with 3 elements", frame: `[2,"1","Heartbeat"]`, wantErr: ErrInvalidFrame, uniqueID: "1"},
+		{name: "CALL with 5 elements", frame: `[2,"1","Heartbeat",{},{}]`, wantErr: ErrInvalidFrame, uniqueID: "1"},
+		{name: "CALLRESULT with 4 elements", frame: `[3,"1",{},{}]`, wantErr: ErrInvalidFrame, uniqueID: "1"},
+		{name: "CALLERROR with 4 elements", frame: `[4,"1","InternalError",""]`, wantErr: ErrInvalidFrame, uniqueID: "1"},
+		{name: "CALL with null payload", frame: `[2,"1","Heartbeat",null]`, wantErr: ErrInvalidFrame, uniqueID: "1"},
+		{name: "CALLRESULT with null payload", frame: `[3,"1",null]`, wantErr: ErrInvalidFrame, uniqueID: "1"},
+		{name: "CALLERROR with null details", frame: `[4,"1","InternalError","",null]`, wantErr: ErrInvalidFrame, uniqueID: "1"},
+		{name: "CALL with array payload", frame: `[2,"1","Heartbeat",[]]`, wantErr: ErrInvalidFrame, uniqueID: "1"},
+		{name: "CALL with non-string action", frame: `[2,"1",7,{}]`, wantErr: ErrInvalidFrame, uniqueID: "1"},
+		{name: "unknown message type id", frame: `[5,"1","Heartbeat",{}]`, wantErr: ErrUnknownMessageType, uniqueID: "1"},
+		{name: "string message type id", frame: `["2","1","Heartbeat",{}]`, wantErr: ErrInvalidFrame},
+		{name: "numeric unique id", frame: `[2,1,"Heartbeat",{}]`, wantErr: ErrInvalidFrame},
+		{name: "null unique id", frame: `[2,null,"Heartbeat",{}]`, wantErr: ErrInvalidFrame},
+		{name: "null action", frame: `[2,"1",null,{}]`, wantErr: ErrInvalidFrame, uniqueID: "1"},
+		{name: "null error code", frame: `[4,"1",null,"",{}]`, wantErr: ErrInvalidFrame, uniqueID: "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var msg OCPPMessage
+			err := msg.UnmarshalJSON([]byte(tt.frame))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if msg.UniqueID != tt.uniqueID {
+				t.Errorf("unique id: got %q, want %q", msg.UniqueID, tt.uniqueID)
+			}
+		})
+	}
+}
+
+func TestFrameType(t *testing.T) {
+	tests := []struct {
+		frame  string
+		want   MessageType
+		wantOK bool
+	}{
+		{`[2,"1","Heartbeat",{}]`, Call, true},
+		{`[3,"1",null]`, CallResult, true},
+		{`[4,1,"InternalError","",{}]`, CallError, true},
+		{`[5,"1"]`, 5, true},
+		{`["3","1",{}]`, 0, false},
+		{`[]`, 0, false},
+		{`{"messageTypeId":3}`, 0, false},
+		{`[3,"1",{}`, 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := frameType([]byte(tt.frame))
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("frameType(%s) = %d, %v; want %d, %v", tt.frame, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
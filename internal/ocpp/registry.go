@@ -0,0 +1,324 @@
+package ocpp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+)
+
+const (
+	registryKeyPrefix  = "ocpp:connection:" // charge point identity -> registryEntry
+	relayChannelPrefix = "ocpp:node:"       // per node channel carrying relayed calls and their results
+)
+
+// refreshScript extends the registry entry of a connection unless another node took it over
+var refreshScript = redis.NewScript(`
+local current = redis.call("GET", KEYS[1])
+if current == false or current == ARGV[1] then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[2])
+	return 1
+end
+return 0`)
+
+// unregisterScript removes the registry entry of a connection unless another node took it over
+var unregisterScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// registryEntry records which node holds the WebSocket of a charge point
+type registryEntry struct {
+	Node    string          `json:"node"`
+	Version ProtocolVersion `json:"version"`
+}
+
+// relayMessage is a CSMS-initiated call relayed to the node holding the charge
+// point connection, or the outcome of that call relayed back
+type relayMessage struct {
+	Type     string          `json:"type"` // "call" or "result"
+	ID       string          `json:"id"`
+	From     string          `json:"from"`
+	Identity string          `json:"identity,omitempty"`
+	Action   string          `json:"action,omitempty"`
+	Payload  json.RawMessage `json:"payload,omitempty"`
+	Deadline time.Time       `json:"deadline,omitzero"`
+	Error    *relayError     `json:"error,omitempty"`
+}
+
+// relayError carries a call failure across nodes so the caller gets the same error as a local call
+type relayError struct {
+	Kind        string          `json:"kind"`
+	Message     string          `json:"message,omitempty"`
+	Code        string          `json:"code,omitempty"`
+	Description string          `json:"description,omitempty"`
+	Details     json.RawMessage `json:"details,omitempty"`
+}
+
+// relayErrors are the call failures that keep their identity across nodes
+var relayErrors = map[string]error{
+	"NotConnected":     ErrChargePointNotConnected,
+	"Timeout":          ErrCallTimeout,
+	"ConnectionClosed": ErrConnectionClosed,
+	"InvalidRequest":   ErrInvalidCallRequest,
+	"InvalidReply":     ErrInvalidCallReply,
+}
+
+func newRelayError(err error) *relayError {
+	var callErr *CallErrorResponse
+	if errors.As(err, &callErr) {
+		return &relayError{Kind: "CallError", Code: callErr.Code, Description: callErr.Description, Details: callErr.Details}
+	}
+	for kind, sentinel := range relayErrors {
+		if errors.Is(err, sentinel) {
+			return &relayError{Kind: kind, Message: err.Error()}
+		}
+	}
+	return &relayError{Kind: "Internal", Message: err.Error()}
+}
+
+func (e *relayError) err() error {
+	if e.Kind == "CallError" {
+		return &CallErrorResponse{Code: e.Code, Description: e.Description, Details: e.Details}
+	}
+	if sentinel, ok := relayErrors[e.Kind]; ok {
+		if e.Message == "" || e.Message == sentinel.Error() {
+			return sentinel
+		}
+		return fmt.Errorf("%w: %s", sentinel, e.Message)
+	}
+	return errors.New(e.Message)
+}
+
+// Registry keeps track in Redis of the node every charge point is connected to
+// and relays CSMS-initiated calls between nodes over Redis pub/sub, so any
+// node can reach any charge point. Entries expire unless refreshed by their
+// node, so the charge points of a crashed node are released after the TTL.
+type Registry struct {
+	cfg   *config.OCPPConfig
+	redis *redis.Client
+	log   *logrus.Logger
+
+	pendingMu sync.Mutex
+	pending   map[string]chan *relayMessage
+
+	pubsub *redis.PubSub
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewRegistry(cfg *config.OCPPConfig, redis *redis.Client, log *logrus.Logger) *Registry {
+	return &Registry{
+		cfg:     cfg,
+		redis:   redis,
+		log:     log,
+		pending: make(map[string]chan *relayMessage),
+		done:    make(chan struct{}),
+	}
+}
+
+// Node returns the ID of this node
+func (r *Registry) Node() string {
+	return r.cfg.NodeID
+}
+
+// Register records that the charge point is connected to this node, taking
+// over the entry of a previous connection on another node
+func (r *Registry) Register(ctx context.Context, identity string, version ProtocolVersion) error {
+	value, err := r.entry(version)
+	if err != nil {
+		return err
+	}
+	return r.redis.Set(ctx, registryKeyPrefix+identity, value, r.cfg.RegistryTTL).Err()
+}
+
+// Unregister removes the entry of a charge point that disconnected from this node
+func (r *Registry) Unregister(ctx context.Context, identity string, version ProtocolVersion) error {
+	value, err := r.entry(version)
+	if err != nil {
+		return err
+	}
+	return unregisterScript.Run(ctx, r.redis, []string{registryKeyPrefix + identity}, value).Err()
+}
+
+// Lookup returns the node the charge point is connected to and the OCPP version it uses
+func (r *Registry) Lookup(ctx context.Context, identity string) (string, ProtocolVersion, error) {
+	value, err := r.redis.Get(ctx, registryKeyPrefix+identity).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return "", "", ErrChargePointNotConnected
+	}
+	if err != nil {
+		return "", "", err
+	}
+	var entry registryEntry
+	if err := json.Unmarshal(value, &entry); err != nil {
+		return "", "", err
+	}
+	return entry.Node, entry.Version, nil
+}
+
+// Serve subscribes to the calls relayed to this node and passes them to call,
+// and keeps the entries of the connections listed by connected alive
+func (r *Registry) Serve(ctx context.Context, call func(ctx context.Context, identity, action string, payload json.RawMessage) (json.RawMessage, error), connected func() map[string]ProtocolVersion) error {
+	r.pubsub = r.redis.Subscribe(ctx, relayChannelPrefix+r.cfg.NodeID)
+	if _, err := r.pubsub.Receive(ctx); err != nil {
+		r.pubsub.Close()
+		return fmt.Errorf("failed to subscribe to relayed calls: %w", err)
+	}
+
+	r.wg.Add(2)
+	go func() {
+		defer r.wg.Done()
+		for msg := range r.pubsub.Channel() {
+			var relayed relayMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &relayed); err != nil {
+				r.log.WithError(err).Warn("Dropping invalid relayed message")
+				continue
+			}
+			switch relayed.Type {
+			case "call":
+				go r.answer(&relayed, call)
+			case "result":
+				r.resolve(&relayed)
+			}
+		}
+	}()
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(r.cfg.RegistryTTL / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.refresh(connected())
+			case <-r.done:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop stops serving relayed calls
+func (r *Registry) Stop() {
+	close(r.done)
+	if r.pubsub != nil {
+		r.pubsub.Close()
+	}
+	r.wg.Wait()
+}
+
+// Forward relays a call to the node the charge point is connected to and waits for its outcome
+func (r *Registry) Forward(ctx context.Context, node, identity, action string, payload json.RawMessage) (json.RawMessage, error) {
+	msg := relayMessage{
+		Type:     "call",
+		ID:       uuid.NewString(),
+		From:     r.cfg.NodeID,
+		Identity: identity,
+		Action:   action,
+		Payload:  payload,
+	}
+	msg.Deadline, _ = ctx.Deadline()
+
+	replyCh := make(chan *relayMessage, 1)
+	r.pendingMu.Lock()
+	r.pending[msg.ID] = replyCh
+	r.pendingMu.Unlock()
+	defer func() {
+		r.pendingMu.Lock()
+		delete(r.pending, msg.ID)
+		r.pendingMu.Unlock()
+	}()
+
+	receivers, err := r.publish(ctx, node, &msg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to relay %s to node %s: %w", action, node, err)
+	}
+	if receivers == 0 {
+		// the node is gone, its registry entries are stale
+		return nil, ErrChargePointNotConnected
+	}
+
+	select {
+	case reply := <-replyCh:
+		if reply.Error != nil {
+			return nil, reply.Error.err()
+		}
+		return reply.Payload, nil
+	case <-ctx.Done():
+		return nil, ctxErr(ctx)
+	}
+}
+
+// answer performs a relayed call and publishes its outcome to the calling node
+func (r *Registry) answer(msg *relayMessage, call func(ctx context.Context, identity, action string, payload json.RawMessage) (json.RawMessage, error)) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.cfg.CallTimeout)
+	if !msg.Deadline.IsZero() {
+		cancel()
+		ctx, cancel = context.WithDeadline(context.Background(), msg.Deadline)
+	}
+	defer cancel()
+
+	result := relayMessage{Type: "result", ID: msg.ID, From: r.cfg.NodeID}
+	payload, err := call(ctx, msg.Identity, msg.Action, msg.Payload)
+	if err != nil {
+		result.Error = newRelayError(err)
+	} else {
+		result.Payload = payload
+	}
+
+	publishCtx, publishCancel := context.WithTimeout(context.Background(), r.cfg.CallTimeout)
+	defer publishCancel()
+	if _, err := r.publish(publishCtx, msg.From, &result); err != nil {
+		r.log.WithError(err).Errorf("Failed to relay the result of %s to node %s", msg.Action, msg.From)
+	}
+}
+
+// resolve delivers the outcome of a relayed call to the waiting caller
+func (r *Registry) resolve(msg *relayMessage) {
+	r.pendingMu.Lock()
+	ch, ok := r.pending[msg.ID]
+	delete(r.pending, msg.ID)
+	r.pendingMu.Unlock()
+	if ok {
+		ch <- msg
+	}
+}
+
+// refresh extends the entries of the charge points connected to this node
+func (r *Registry) refresh(connected map[string]ProtocolVersion) {
+	ctx, cancel := context.WithTimeout(context.Background(), r.cfg.RegistryTTL/3)
+	defer cancel()
+	ttl := r.cfg.RegistryTTL.Milliseconds()
+	for identity, version := range connected {
+		value, err := r.entry(version)
+		if err != nil {
+			continue
+		}
+		if err := refreshScript.Run(ctx, r.redis, []string{registryKeyPrefix + identity}, value, ttl).Err(); err != nil {
+			r.log.WithError(err).Warnf("Failed to refresh the registry entry of %s", identity)
+		}
+	}
+}
+
+func (r *Registry) publish(ctx context.Context, node string, msg *relayMessage) (int64, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return 0, err
+	}
+	return r.redis.Publish(ctx, relayChannelPrefix+node, data).Result()
+}
+
+func (r *Registry) entry(version ProtocolVersion) (string, error) {
+	data, err := json.Marshal(registryEntry{Node: r.cfg.NodeID, Version: version})
+	return string(data), err
+}
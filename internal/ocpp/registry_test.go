@@ -0,0 +1,228 @@
+package ocpp
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect/pgdialect"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/repository"
+	"github.com/mutoulbj/gocsms/internal/services"
+)
+
+// unavailableDB is a database connector that always fails, so the tests run
+// on the Redis cache alone and database writes are only logged
+type unavailableDB struct{}
+
+func (unavailableDB) Connect(context.Context) (driver.Conn, error) {
+	return nil, errors.New("database unavailable")
+}
+
+func (unavailableDB) Driver() driver.Driver { return nil }
+
+// newTestServer returns an OCPP server for the given node backed by the Redis
+// at addr and by conn, or by a database that is always unavailable when conn is nil
+func newTestServer(t *testing.T, node, addr string, conn driver.Connector) *Server {
+	t.Helper()
+	cfg := &config.OCPPConfig{
+		CallTimeout:      5 * time.Second,
+		MessageQueueSize: 100,
+		SchemaValidation: config.SchemaValidationStrict,
+		NodeID:           node,
+		RegistryTTL:      time.Minute,
+		WSWriteTimeout:   time.Second,
+		WSWriteQueueSize: 10,
+	}
+	log := logrus.New()
+	log.SetOutput(io.Discard)
+
+	if conn == nil {
+		conn = unavailableDB{}
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { rdb.Close() })
+	db := bun.NewDB(sql.OpenDB(conn), pgdialect.New())
+	t.Cleanup(func() { db.Close() })
+
+	validator, err := NewSchemaValidator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc := services.NewChargePointService(
+		repository.NewChargePointRepository(db, rdb, log),
+		repository.NewChargePointConnectionRepository(db, log),
+		repository.NewConnectorRepository(db, log),
+		log,
+	)
+	messages := services.NewMessageJournalService(repository.NewOCPPMessageRepository(db, log), cfg, log)
+	registry := NewRegistry(cfg, rdb, log)
+	return NewOCPPServer(cfg, validator, svc, nil, nil, nil, nil, nil, registry, messages, log)
+}
+
+// seedChargePoint caches a provisioned charge point in Redis the way ChargePointRepository does
+func seedChargePoint(t *testing.T, mr *miniredis.Miniredis, cp *models.ChargePoint) {
+	t.Helper()
+	if cp.ID == uuid.Nil {
+		cp.ID = uuid.New()
+	}
+	data, err := json.Marshal(cp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.Set("chargepoint:code:"+cp.Code, cp.ID.String()); err != nil {
+		t.Fatal(err)
+	}
+	if err := mr.Set("chargepoint:"+cp.ID.String(), string(data)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// serveRegistry serves the calls relayed to the node of s until the test ends
+func serveRegistry(t *testing.T, s *Server) {
+	t.Helper()
+	if err := s.registry.Serve(context.Background(), s.callRelayed, s.connectedVersions); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.registry.Stop)
+}
+
+func TestServerCallRelaysToOtherNode(t *testing.T) {
+	mr := miniredis.RunT(t)
+	seedChargePoint(t, mr, &models.ChargePoint{Code: "CP-1", OcppVersion: "1.6"})
+
+	nodeA := newTestServer(t, "node-a", mr.Addr(), nil)
+	nodeB := newTestServer(t, "node-b", mr.Addr(), nil)
+	serveRegistry(t, nodeA)
+	serveRegistry(t, nodeB)
+
+	srv := httptest.NewServer(http.HandlerFunc(nodeB.handleWebSocket))
+	t.Cleanup(srv.Close)
+	dialer := websocket.Dialer{Subprotocols: []string{string(OCPP16)}}
+	ws, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ocpp/CP-1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for {
+		node, version, err := nodeA.registry.Lookup(ctx, "CP-1")
+		if err == nil && node == "node-b" && version == OCPP16 {
+			break
+		}
+		if ctx.Err() != nil {
+			t.Fatalf("charge point was not registered on node-b: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if nodeA.IsConnected("CP-1") {
+		t.Fatal("charge point is connected to node-a")
+	}
+
+	// the charge point accepts the first call and rejects the second
+	replies := []func(uniqueID string) string{
+		func(uniqueID string) string {
+			return `[3,"` + uniqueID + `",{"configurationKey":[{"key":"HeartbeatInterval","readonly":false,"value":"300"}]}]`
+		},
+		func(uniqueID string) string {
+			return `[4,"` + uniqueID + `","NotSupported","RemoteStopTransaction is not supported",{"reason":"test"}]`
+		},
+	}
+	actions := make(chan string, len(replies))
+	go func() {
+		for _, reply := range replies {
+			_, data, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			var call OCPPMessage
+			if err := json.Unmarshal(data, &call); err != nil || call.MessageTypeID != Call {
+				return
+			}
+			actions <- call.Action
+			if err := ws.WriteMessage(websocket.TextMessage, []byte(reply(call.UniqueID))); err != nil {
+				return
+			}
+		}
+	}()
+
+	result, err := nodeA.Call(ctx, "CP-1", "GetConfiguration", map[string][]string{"key": {"HeartbeatInterval"}})
+	if err != nil {
+		t.Fatalf("GetConfiguration: %v", err)
+	}
+	var conf struct {
+		ConfigurationKey []struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		} `json:"configurationKey"`
+	}
+	if err := json.Unmarshal(result, &conf); err != nil {
+		t.Fatal(err)
+	}
+	if len(conf.ConfigurationKey) != 1 || conf.ConfigurationKey[0].Key != "HeartbeatInterval" || conf.ConfigurationKey[0].Value != "300" {
+		t.Errorf("GetConfiguration: got %s", result)
+	}
+	if action := <-actions; action != "GetConfiguration" {
+		t.Errorf("charge point received %s, want GetConfiguration", action)
+	}
+
+	_, err = nodeA.Call(ctx, "CP-1", "RemoteStopTransaction", map[string]int{"transactionId": 1})
+	var callErr *CallErrorResponse
+	if !errors.As(err, &callErr) {
+		t.Fatalf("RemoteStopTransaction: got error %v, want a CallErrorResponse", err)
+	}
+	if callErr.Code != "NotSupported" || callErr.Description != "RemoteStopTransaction is not supported" || string(callErr.Details) != `{"reason":"test"}` {
+		t.Errorf("RemoteStopTransaction: got %+v", callErr)
+	}
+	if action := <-actions; action != "RemoteStopTransaction" {
+		t.Errorf("charge point received %s, want RemoteStopTransaction", action)
+	}
+}
+
+func TestServerCallRelayErrors(t *testing.T) {
+	mr := miniredis.RunT(t)
+	nodeA := newTestServer(t, "node-a", mr.Addr(), nil)
+	nodeB := newTestServer(t, "node-b", mr.Addr(), nil)
+	serveRegistry(t, nodeA)
+	serveRegistry(t, nodeB)
+	ctx := context.Background()
+
+	if _, err := nodeA.Call(ctx, "CP-1", "Reset", map[string]string{"type": "Soft"}); !errors.Is(err, ErrChargePointNotConnected) {
+		t.Errorf("unregistered charge point: got %v, want %v", err, ErrChargePointNotConnected)
+	}
+
+	// node-b holds a registry entry for a charge point it is no longer connected to
+	if err := nodeB.registry.Register(ctx, "CP-1", OCPP16); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nodeA.Call(ctx, "CP-1", "Reset", map[string]string{"type": "Soft"}); !errors.Is(err, ErrChargePointNotConnected) {
+		t.Errorf("charge point lost by node-b: got %v, want %v", err, ErrChargePointNotConnected)
+	}
+
+	// the entry of a crashed node has no subscriber behind it
+	nodeC := newTestServer(t, "node-c", mr.Addr(), nil)
+	if err := nodeC.registry.Register(ctx, "CP-2", OCPP16); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nodeA.Call(ctx, "CP-2", "Reset", map[string]string{"type": "Soft"}); !errors.Is(err, ErrChargePointNotConnected) {
+		t.Errorf("charge point of a crashed node: got %v, want %v", err, ErrChargePointNotConnected)
+	}
+}
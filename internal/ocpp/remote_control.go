@@ -0,0 +1,122 @@
+package ocpp
+
+import (
+	"cmp"
+	"context"
+	"math/rand/v2"
+
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/ocpp/v201"
+	"github.com/mutoulbj/gocsms/internal/services"
+)
+
+// resetTypesV201 maps the OCPP 1.6 reset types to the OCPP 2.0.1 ones
+var resetTypesV201 = map[string]string{
+	"Hard": "Immediate",
+	"Soft": "OnIdle",
+}
+
+// RemoteStart asks a charge point to start a transaction for an id tag, on a
+// connector when connectorID is given, with RemoteStartTransaction for OCPP 1.6
+// and RequestStartTransaction for OCPP 2.0.1. The connector is the EVSE for
+// OCPP 2.0.1 charging stations, and idTokenType the type of the id tag.
+func (s *Server) RemoteStart(ctx context.Context, identity string, connectorID *int, idTag, idTokenType string) (string, error) {
+	version, ok := s.ConnectedVersion(ctx, identity)
+	if !ok {
+		return "", ErrChargePointNotConnected
+	}
+	if version == OCPP201 {
+		resp, err := s.RequestStartTransactionV201(ctx, identity, v201.RequestStartTransactionRequest{
+			EvseID:        connectorID,
+			RemoteStartID: rand.IntN(1 << 31),
+			IdToken:       v201.IdToken{IdToken: idTag, Type: cmp.Or(idTokenType, defaultIdTokenType)},
+		})
+		if err != nil {
+			return "", err
+		}
+		return resp.Status, nil
+	}
+	resp, err := s.RemoteStartTransaction(ctx, identity, RemoteStartTransactionRequest{
+		ConnectorID: connectorID,
+		IdTag:       idTag,
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Status, nil
+}
+
+// RemoteStop asks a charge point to stop one of its transactions with
+// RemoteStopTransaction for OCPP 1.6 and RequestStopTransaction for OCPP
+// 2.0.1, which addresses the transaction by the id the charging station
+// assigned to it. It returns services.ErrTransactionNotFound when the
+// transaction is not one of the charge point.
+func (s *Server) RemoteStop(ctx context.Context, cp *models.ChargePoint, transactionID int) (string, error) {
+	version, ok := s.ConnectedVersion(ctx, cp.Code)
+	if !ok {
+		return "", ErrChargePointNotConnected
+	}
+	if version == OCPP201 {
+		tx, err := s.handler.txSvc.GetByTransactionID(ctx, cp.ID, transactionID)
+		if err != nil {
+			return "", err
+		}
+		if tx.ChargerTxID == "" {
+			return "", services.ErrTransactionNotFound
+		}
+		resp, err := s.RequestStopTransactionV201(ctx, cp.Code, v201.RequestStopTransactionRequest{TransactionID: tx.ChargerTxID})
+		if err != nil {
+			return "", err
+		}
+		return resp.Status, nil
+	}
+	resp, err := s.RemoteStopTransaction(ctx, cp.Code, RemoteStopTransactionRequest{TransactionID: transactionID})
+	if err != nil {
+		return "", err
+	}
+	return resp.Status, nil
+}
+
+// ResetChargePoint asks a charge point to perform a Soft or Hard reset with the
+// Reset of the OCPP version it is connected with. OCPP 2.0.1 charging stations
+// reset once idle for a soft reset and immediately for a hard one.
+func (s *Server) ResetChargePoint(ctx context.Context, identity, resetType string) (string, error) {
+	version, ok := s.ConnectedVersion(ctx, identity)
+	if !ok {
+		return "", ErrChargePointNotConnected
+	}
+	if version == OCPP201 {
+		resp, err := s.ResetV201(ctx, identity, v201.ResetRequest{Type: resetTypesV201[resetType]})
+		if err != nil {
+			return "", err
+		}
+		return resp.Status, nil
+	}
+	resp, err := s.Reset(ctx, identity, ResetRequest{Type: resetType})
+	if err != nil {
+		return "", err
+	}
+	return resp.Status, nil
+}
+
+// Unlock asks a charge point to unlock a connector with the UnlockConnector of
+// the OCPP version it is connected with. The connector is the EVSE for OCPP
+// 2.0.1 charging stations, which are asked to unlock its first connector.
+func (s *Server) Unlock(ctx context.Context, identity string, connectorID int) (string, error) {
+	version, ok := s.ConnectedVersion(ctx, identity)
+	if !ok {
+		return "", ErrChargePointNotConnected
+	}
+	if version == OCPP201 {
+		resp, err := s.UnlockConnectorV201(ctx, identity, v201.UnlockConnectorRequest{EvseID: connectorID, ConnectorID: 1})
+		if err != nil {
+			return "", err
+		}
+		return resp.Status, nil
+	}
+	resp, err := s.UnlockConnector(ctx, identity, UnlockConnectorRequest{ConnectorID: connectorID})
+	if err != nil {
+		return "", err
+	}
+	return resp.Status, nil
+}
@@ -0,0 +1,154 @@
+package ocpp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gorilla/websocket"
+
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+func TestRemoteControlRoutesByVersion(t *testing.T) {
+	connectorID := 2
+	tests := []struct {
+		version ProtocolVersion
+		command func(ctx context.Context, s *Server) (string, error)
+		action  string
+		payload string // the payload the charge point receives
+		reply   string // the payload the charge point answers with
+	}{
+		{
+			version: OCPP16,
+			command: func(ctx context.Context, s *Server) (string, error) {
+				return s.RemoteStart(ctx, "CP-1", &connectorID, "TAG-1", "")
+			},
+			action:  "RemoteStartTransaction",
+			payload: `{"connectorId":2,"idTag":"TAG-1"}`,
+			reply:   `{"status":"Accepted"}`,
+		},
+		{
+			version: OCPP201,
+			command: func(ctx context.Context, s *Server) (string, error) {
+				return s.RemoteStart(ctx, "CP-1", &connectorID, "TAG-1", "")
+			},
+			action:  "RequestStartTransaction",
+			payload: `{"evseId":2,"idToken":{"idToken":"TAG-1","type":"ISO14443"}}`,
+			reply:   `{"status":"Accepted"}`,
+		},
+		{
+			version: OCPP16,
+			command: func(ctx context.Context, s *Server) (string, error) {
+				return s.ResetChargePoint(ctx, "CP-1", "Soft")
+			},
+			action:  "Reset",
+			payload: `{"type":"Soft"}`,
+			reply:   `{"status":"Accepted"}`,
+		},
+		{
+			version: OCPP201,
+			command: func(ctx context.Context, s *Server) (string, error) {
+				return s.ResetChargePoint(ctx, "CP-1", "Soft")
+			},
+			action:  "Reset",
+			payload: `{"type":"OnIdle"}`,
+			reply:   `{"status":"Scheduled"}`,
+		},
+		{
+			version: OCPP16,
+			command: func(ctx context.Context, s *Server) (string, error) {
+				return s.Unlock(ctx, "CP-1", 2)
+			},
+			action:  "UnlockConnector",
+			payload: `{"connectorId":2}`,
+			reply:   `{"status":"Unlocked"}`,
+		},
+		{
+			version: OCPP201,
+			command: func(ctx context.Context, s *Server) (string, error) {
+				return s.Unlock(ctx, "CP-1", 2)
+			},
+			action:  "UnlockConnector",
+			payload: `{"evseId":2,"connectorId":1}`,
+			reply:   `{"status":"Unlocked"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.version)+" "+tt.action, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			seedChargePoint(t, mr, &models.ChargePoint{Code: "CP-1", OcppVersion: tt.version.Version()})
+			s := newTestServer(t, "node-a", mr.Addr(), nil)
+			srv := httptest.NewServer(http.HandlerFunc(s.handleWebSocket))
+			t.Cleanup(srv.Close)
+
+			dialer := websocket.Dialer{Subprotocols: []string{string(tt.version)}}
+			ws, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ocpp/CP-1", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { ws.Close() })
+
+			received := make(chan OCPPMessage, 1)
+			go func() {
+				_, data, err := ws.ReadMessage()
+				if err != nil {
+					return
+				}
+				var call OCPPMessage
+				if err := json.Unmarshal(data, &call); err != nil {
+					return
+				}
+				received <- call
+				ws.WriteMessage(websocket.TextMessage, []byte(`[3,"`+call.UniqueID+`",`+tt.reply+`]`))
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			for !s.IsConnected("CP-1") {
+				if ctx.Err() != nil {
+					t.Fatal("charge point did not connect")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			status, err := tt.command(ctx, s)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want struct{ Status string }
+			if err := json.Unmarshal([]byte(tt.reply), &want); err != nil {
+				t.Fatal(err)
+			}
+			if status != want.Status {
+				t.Errorf("got status %s, want %s", status, want.Status)
+			}
+
+			call := <-received
+			if call.Action != tt.action {
+				t.Errorf("charge point received %s, want %s", call.Action, tt.action)
+			}
+			payload := string(call.Payload)
+			if tt.action == "RequestStartTransaction" {
+				// the remote start id is random
+				var req map[string]any
+				if err := json.Unmarshal(call.Payload, &req); err != nil {
+					t.Fatal(err)
+				}
+				if _, ok := req["remoteStartId"].(float64); !ok {
+					t.Errorf("missing remoteStartId in %s", payload)
+				}
+				delete(req, "remoteStartId")
+				data, _ := json.Marshal(req)
+				payload = string(data)
+			}
+			if payload != tt.payload {
+				t.Errorf("charge point received %s, want %s", payload, tt.payload)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package ocpp
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/dto"
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/ocpp/v201"
+	"github.com/mutoulbj/gocsms/internal/services"
+)
+
+// reservationCheckInterval is how often expired reservations are released
+const reservationCheckInterval = 30 * time.Second
+
+// defaultIdTokenType is the OCPP 2.0.1 type of the id tags reserved for, which
+// are RFID cards unless the request tells otherwise
+const defaultIdTokenType = "ISO14443"
+
+// ReservationManager reserves connectors with ReserveNow and cancels
+// reservations with CancelReservation. The charge point ends a reservation
+// itself at its expiry; expired reservations are released here as well, and
+// cancelled on charge points whose clock lags behind.
+type ReservationManager struct {
+	server *Server
+	cfg    *config.OCPPConfig
+	svc    *services.ReservationService
+	cpSvc  *services.ChargePointService
+	log    *logrus.Logger
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewReservationManager(
+	server *Server,
+	cfg *config.OCPPConfig,
+	svc *services.ReservationService,
+	cpSvc *services.ChargePointService,
+	log *logrus.Logger,
+) *ReservationManager {
+	return &ReservationManager{
+		server: server,
+		cfg:    cfg,
+		svc:    svc,
+		cpSvc:  cpSvc,
+		log:    log,
+		done:   make(chan struct{}),
+	}
+}
+
+// Start launches the periodic release of expired reservations
+func (m *ReservationManager) Start() {
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(reservationCheckInterval)
+		defer ticker.Stop()
+		m.check()
+		for {
+			select {
+			case <-ticker.C:
+				m.check()
+			case <-m.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic release
+func (m *ReservationManager) Stop() {
+	close(m.done)
+	m.wg.Wait()
+}
+
+// Reserve reserves a connector of a charge point and returns the reservation,
+// rejected when the charge point refused it. The reservation is recorded as
+// well when the request fails, as the charge point may still have received it.
+func (m *ReservationManager) Reserve(ctx context.Context, chargePointID uuid.UUID, req *dto.ReservationRequest) (*models.Reservation, error) {
+	cp, err := m.cpSvc.GetByID(ctx, chargePointID.String())
+	if err != nil {
+		return nil, err
+	}
+	version, ok := m.server.ConnectedVersion(ctx, cp.Code)
+	if !ok {
+		return nil, ErrChargePointNotConnected
+	}
+
+	reservation := &models.Reservation{
+		ChargePointID: cp.ID,
+		ConnectorID:   req.ConnectorID,
+		IdTag:         req.IdTag,
+		ParentIdTag:   req.ParentIdTag,
+		ExpiresAt:     req.ExpiresAt,
+	}
+	// recorded before it is sent, the charge point needs its reservation id
+	if err := m.svc.Create(ctx, reservation); err != nil {
+		return nil, err
+	}
+
+	m.log.Infof("Reserving connector %d of charge point %s for %s until %s",
+		reservation.ConnectorID, cp.Code, reservation.IdTag, reservation.ExpiresAt.Format(time.RFC3339))
+	status, err := m.reserve(ctx, cp.Code, version, reservation, cmp.Or(req.IdTokenType, defaultIdTokenType))
+
+	// a transaction may already have been started on the reservation
+	current, getErr := m.svc.Get(ctx, reservation.ID)
+	if getErr != nil {
+		return nil, getErr
+	}
+	if current.Status != enums.ReservationStatusRequested {
+		return current, err
+	}
+	switch {
+	case err != nil:
+		current.Status = enums.ReservationStatusRejected
+		current.Error = err.Error()
+	case status == "Accepted":
+		current.Status = enums.ReservationStatusActive
+	default:
+		current.Status = enums.ReservationStatusRejected
+		current.Error = fmt.Sprintf("Charge point answered %s", status)
+	}
+	if updateErr := m.svc.Update(ctx, current); updateErr != nil {
+		return nil, updateErr
+	}
+	return current, err
+}
+
+// Cancel cancels a reservation on its charge point. A reservation the charge
+// point answers it does not hold is cancelled as well.
+func (m *ReservationManager) Cancel(ctx context.Context, id uuid.UUID) (*models.Reservation, error) {
+	reservation, err := m.svc.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if reservation.Status.IsFinal() {
+		return nil, fmt.Errorf("%w: reservation is %s", services.ErrReservationState, reservation.Status)
+	}
+	cp, err := m.cpSvc.GetByID(ctx, reservation.ChargePointID.String())
+	if err != nil {
+		return nil, err
+	}
+	version, ok := m.server.ConnectedVersion(ctx, cp.Code)
+	if !ok {
+		return nil, ErrChargePointNotConnected
+	}
+
+	m.log.Infof("Cancelling reservation %d of charge point %s", reservation.ReservationID, cp.Code)
+	status, err := m.cancel(ctx, cp.Code, version, reservation.ReservationID)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := m.svc.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status.IsFinal() {
+		return current, nil
+	}
+	current.Status = enums.ReservationStatusCancelled
+	if status != "Accepted" {
+		current.Error = fmt.Sprintf("Charge point answered %s", status)
+	}
+	if err := m.svc.Update(ctx, current); err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+// reserve sends ReserveNow with the message of the OCPP version of the charge
+// point and returns the answer of the charge point
+func (m *ReservationManager) reserve(ctx context.Context, identity string, version ProtocolVersion, reservation *models.Reservation, idTokenType string) (string, error) {
+	if version == OCPP201 {
+		req := v201.ReserveNowRequest{
+			ID:             reservation.ReservationID,
+			ExpiryDateTime: reservation.ExpiresAt,
+			IdToken:        v201.IdToken{IdToken: reservation.IdTag, Type: idTokenType},
+		}
+		if reservation.ConnectorID > 0 {
+			req.EvseID = &reservation.ConnectorID
+		}
+		if reservation.ParentIdTag != "" {
+			req.GroupIdToken = &v201.IdToken{IdToken: reservation.ParentIdTag, Type: idTokenType}
+		}
+		resp, err := m.server.ReserveNowV201(ctx, identity, req)
+		if err != nil {
+			return "", err
+		}
+		return resp.Status, nil
+	}
+
+	resp, err := m.server.ReserveNow(ctx, identity, ReserveNowRequest{
+		ConnectorID:   reservation.ConnectorID,
+		ExpiryDate:    reservation.ExpiresAt,
+		IdTag:         reservation.IdTag,
+		ParentIdTag:   reservation.ParentIdTag,
+		ReservationID: reservation.ReservationID,
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Status, nil
+}
+
+// cancel sends CancelReservation with the message of the OCPP version of the
+// charge point and returns the answer of the charge point
+func (m *ReservationManager) cancel(ctx context.Context, identity string, version ProtocolVersion, reservationID int) (string, error) {
+	if version == OCPP201 {
+		resp, err := m.server.CancelReservationV201(ctx, identity, v201.CancelReservationRequest{ReservationID: reservationID})
+		if err != nil {
+			return "", err
+		}
+		return resp.Status, nil
+	}
+
+	resp, err := m.server.CancelReservation(ctx, identity, CancelReservationRequest{ReservationID: reservationID})
+	if err != nil {
+		return "", err
+	}
+	return resp.Status, nil
+}
+
+func (m *ReservationManager) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), reservationCheckInterval)
+	expired, err := m.svc.ListExpired(ctx)
+	cancel()
+	if err != nil {
+		m.log.WithError(err).Error("Failed to list expired reservations")
+		return
+	}
+	for _, reservation := range expired {
+		m.release(reservation)
+	}
+}
+
+// release expires a reservation, cancelling it first on its charge point when
+// it is connected in case the charge point still holds the connector
+func (m *ReservationManager) release(reservation *models.Reservation) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*m.cfg.CallTimeout)
+	defer cancel()
+
+	cp, err := m.cpSvc.GetByID(ctx, reservation.ChargePointID.String())
+	if err == nil {
+		if version, ok := m.server.ConnectedVersion(ctx, cp.Code); ok {
+			_, err = m.cancel(ctx, cp.Code, version, reservation.ReservationID)
+		}
+	}
+	if err != nil {
+		m.log.WithError(err).Debugf("Failed to cancel expired reservation %d on its charge point", reservation.ReservationID)
+	}
+
+	// a transaction may have been started on the reservation meanwhile
+	current, err := m.svc.Get(ctx, reservation.ID)
+	if err != nil || current.Status.IsFinal() {
+		return
+	}
+	m.log.Infof("Reservation %d of charge point %s expired", current.ReservationID, current.ChargePointID)
+	current.Status = enums.ReservationStatusExpired
+	if err := m.svc.Update(ctx, current); err != nil {
+		m.log.WithError(err).Errorf("Failed to expire reservation %d", current.ReservationID)
+	}
+}
@@ -0,0 +1,145 @@
+package ocpp
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/santhosh-tekuri/jsonschema/v6/kind"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// schemaFS holds the OCPP JSON schemas, one directory per protocol version.
+// OCPP 1.6 names them <Action>.json and <Action>Response.json, OCPP 2.0.1
+// <Action>Request.json and <Action>Response.json.
+//
+//go:embed schemas
+var schemaFS embed.FS
+
+var schemaPrinter = message.NewPrinter(language.English)
+
+// SchemaViolation is returned when a payload does not conform to its OCPP JSON schema
+type SchemaViolation struct {
+	Code        string
+	Description string
+}
+
+func (e *SchemaViolation) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+// SchemaValidator validates OCPP payloads against the embedded JSON schemas
+type SchemaValidator struct {
+	schemas map[string]*jsonschema.Schema // keyed by path below schemas/
+}
+
+func NewSchemaValidator() (*SchemaValidator, error) {
+	compiler := jsonschema.NewCompiler()
+	compiler.AssertFormat()
+
+	var locations []string
+	err := fs.WalkDir(schemaFS, "schemas", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := schemaFS.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to parse schema %s: %w", name, err)
+		}
+		location := "embed:///" + name
+		if err := compiler.AddResource(location, doc); err != nil {
+			return err
+		}
+		locations = append(locations, location)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	v := &SchemaValidator{schemas: make(map[string]*jsonschema.Schema, len(locations))}
+	for _, location := range locations {
+		schema, err := compiler.Compile(location)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compile schema %s: %w", location, err)
+		}
+		v.schemas[strings.TrimPrefix(location, "embed:///schemas/")] = schema
+	}
+	return v, nil
+}
+
+// Validate checks the payload of a CALL (response false) or CALLRESULT
+// (response true) for the action. Actions without a schema are not checked.
+func (v *SchemaValidator) Validate(version ProtocolVersion, action string, response bool, payload json.RawMessage) error {
+	schema, ok := v.schemas[schemaName(version, action, response)]
+	if !ok {
+		return nil
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(orEmptyObject(payload)))
+	if err != nil {
+		return &SchemaViolation{Code: version.errorCode(ErrorCodeFormationViolation), Description: "Payload is not valid JSON"}
+	}
+	err = schema.Validate(doc)
+	if err == nil {
+		return nil
+	}
+	verr, ok := err.(*jsonschema.ValidationError)
+	if !ok {
+		return err
+	}
+	leaf := firstLeaf(verr)
+	return &SchemaViolation{
+		Code:        version.errorCode(violationCode(leaf.ErrorKind)),
+		Description: fmt.Sprintf("%s: %s", instanceLocation(leaf), leaf.ErrorKind.LocalizedString(schemaPrinter)),
+	}
+}
+
+func schemaName(version ProtocolVersion, action string, response bool) string {
+	switch {
+	case response:
+		action += "Response"
+	case version == OCPP201:
+		action += "Request"
+	}
+	return path.Join(string(version), action+".json")
+}
+
+// violationCode maps a failed schema keyword onto the OCPP-J error code for it
+func violationCode(k jsonschema.ErrorKind) string {
+	switch k.(type) {
+	case *kind.Type:
+		return ErrorCodeTypeConstraintViolation
+	case *kind.Required, *kind.MinItems, *kind.MaxItems:
+		return ErrorCodeOccurenceConstraintViolation
+	case *kind.AdditionalProperties:
+		return ErrorCodeFormationViolation
+	default:
+		return ErrorCodePropertyConstraintViolation
+	}
+}
+
+// firstLeaf returns the innermost cause, which names the offending property
+func firstLeaf(err *jsonschema.ValidationError) *jsonschema.ValidationError {
+	for len(err.Causes) > 0 {
+		err = err.Causes[0]
+	}
+	return err
+}
+
+func instanceLocation(err *jsonschema.ValidationError) string {
+	if len(err.InstanceLocation) == 0 {
+		return "payload"
+	}
+	return strings.Join(err.InstanceLocation, ".")
+}
@@ -0,0 +1,80 @@
+package ocpp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// ErrUnauthorized is returned when a charge point fails the authentication of its security profile
+var ErrUnauthorized = errors.New("charge point authentication failed")
+
+// authenticate enforces the OCPP security profile of the connecting charge point:
+//
+//	0: no authentication, for charge points that predate the security profiles
+//	1: HTTP Basic Auth with the charge point identity as username
+//	2: HTTP Basic Auth over TLS
+//	3: TLS with a client certificate whose common name is the charge point identity
+//
+// Charge points that are not provisioned get the configured default profile.
+func (s *Server) authenticate(r *http.Request, identity string) error {
+	cp, err := s.svc.GetByCode(r.Context(), identity)
+	if err != nil {
+		return err
+	}
+	profile := s.cfg.DefaultSecurityProfile
+	if cp != nil {
+		profile = cp.SecurityProfile
+	}
+
+	switch profile {
+	case 0:
+		return nil
+	case 1, 2:
+		if profile == 2 && r.TLS == nil {
+			return fmt.Errorf("%w: security profile 2 requires TLS", ErrUnauthorized)
+		}
+		username, password, ok := r.BasicAuth()
+		if !ok {
+			return fmt.Errorf("%w: missing basic auth credentials", ErrUnauthorized)
+		}
+		if username != identity || cp == nil || !s.svc.VerifyPassword(r.Context(), cp.ID, password) {
+			return fmt.Errorf("%w: invalid basic auth credentials", ErrUnauthorized)
+		}
+		return nil
+	case 3:
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			return fmt.Errorf("%w: security profile 3 requires a verified client certificate", ErrUnauthorized)
+		}
+		if cn := r.TLS.VerifiedChains[0][0].Subject.CommonName; cn != identity {
+			return fmt.Errorf("%w: client certificate is issued to %q", ErrUnauthorized, cn)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: unknown security profile %d", ErrUnauthorized, profile)
+	}
+}
+
+// tlsConfig returns the TLS configuration of the OCPP port. Client certificates
+// are requested but only required for charge points on security profile 3.
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if s.cfg.TLSClientCAFile == "" {
+		return tlsConfig, nil
+	}
+
+	caPEM, err := os.ReadFile(s.cfg.TLSClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in %s", s.cfg.TLSClientCAFile)
+	}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	return tlsConfig, nil
+}
@@ -0,0 +1,217 @@
+package ocpp
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"database/sql/driver"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/pki"
+)
+
+var errDatabaseUnavailable = errors.New("database unavailable")
+
+// passwordDB stands in for Postgres. It only answers the password hash lookups
+// of charge points, every other query fails like unavailableDB.
+type passwordDB struct {
+	passwordHashes map[uuid.UUID]string
+}
+
+func (db *passwordDB) Connect(context.Context) (driver.Conn, error) { return passwordConn{db}, nil }
+
+func (db *passwordDB) Driver() driver.Driver { return nil }
+
+type passwordConn struct{ db *passwordDB }
+
+func (c passwordConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	if strings.Contains(query, `"password_hash"`) {
+		for id, hash := range c.db.passwordHashes {
+			if strings.Contains(query, id.String()) {
+				return &passwordRows{values: []driver.Value{hash}}, nil
+			}
+		}
+	}
+	return nil, errDatabaseUnavailable
+}
+
+func (passwordConn) Prepare(string) (driver.Stmt, error) { return nil, errDatabaseUnavailable }
+
+func (passwordConn) Close() error { return nil }
+
+func (passwordConn) Begin() (driver.Tx, error) { return nil, errDatabaseUnavailable }
+
+// passwordRows is a single row result with one column
+type passwordRows struct {
+	values []driver.Value
+}
+
+func (r *passwordRows) Columns() []string { return []string{"value"} }
+
+func (r *passwordRows) Close() error { return nil }
+
+func (r *passwordRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
+
+func TestSecurityProfiles(t *testing.T) {
+	const password = "0123456789abcdef"
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mr := miniredis.RunT(t)
+	db := &passwordDB{passwordHashes: make(map[uuid.UUID]string)}
+	for profile, code := range []string{"CP-0", "CP-1", "CP-2", "CP-3"} {
+		cp := &models.ChargePoint{Code: code, OcppVersion: "1.6", SecurityProfile: profile}
+		seedChargePoint(t, mr, cp)
+		if profile == 1 || profile == 2 {
+			db.passwordHashes[cp.ID] = string(hash)
+		}
+	}
+
+	ca, err := pki.NewCA("Test CSMS Root CA", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, ca.CertPEM(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	rogueCA, err := pki.NewCA("Rogue Root CA", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestServer(t, "node-a", mr.Addr(), db)
+	s.cfg.TLSClientCAFile = caFile
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverCert := issueCertificate(t, ca, func(ca *pki.CA) ([]byte, []byte, error) {
+		return ca.IssueServer([]string{"127.0.0.1"}, time.Hour)
+	})
+	tlsConfig.Certificates = []tls.Certificate{serverCert}
+
+	secure := httptest.NewUnstartedServer(http.HandlerFunc(s.handleWebSocket))
+	secure.TLS = tlsConfig
+	secure.StartTLS()
+	t.Cleanup(secure.Close)
+	insecure := httptest.NewServer(http.HandlerFunc(s.handleWebSocket))
+	t.Cleanup(insecure.Close)
+
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(ca.CertPEM())
+	clientCert := func(ca *pki.CA, commonName string) *tls.Certificate {
+		cert := issueCertificate(t, ca, func(ca *pki.CA) ([]byte, []byte, error) {
+			return ca.IssueClient(commonName, time.Hour)
+		})
+		return &cert
+	}
+
+	tests := []struct {
+		name       string
+		identity   string
+		tls        bool
+		password   string // sent as Basic Auth when not empty
+		clientCert *tls.Certificate
+		wantStatus int // HTTP status of a refused upgrade, 0 when the charge point connects
+		wantTLSErr bool
+	}{
+		{name: "profile 0 without credentials", identity: "CP-0"},
+		{name: "profile 1 with valid password", identity: "CP-1", password: password},
+		{name: "profile 1 with wrong password", identity: "CP-1", password: "fedcba9876543210", wantStatus: http.StatusUnauthorized},
+		{name: "profile 1 without credentials", identity: "CP-1", wantStatus: http.StatusUnauthorized},
+		{name: "profile 2 with valid password", identity: "CP-2", tls: true, password: password},
+		{name: "profile 2 with wrong password", identity: "CP-2", tls: true, password: "fedcba9876543210", wantStatus: http.StatusUnauthorized},
+		{name: "profile 2 without TLS", identity: "CP-2", password: password, wantStatus: http.StatusUnauthorized},
+		{name: "profile 3 with client certificate", identity: "CP-3", tls: true, clientCert: clientCert(ca, "CP-3")},
+		{name: "profile 3 with certificate of another charge point", identity: "CP-3", tls: true, clientCert: clientCert(ca, "CP-1"), wantStatus: http.StatusUnauthorized},
+		{name: "profile 3 without client certificate", identity: "CP-3", tls: true, wantStatus: http.StatusUnauthorized},
+		{name: "profile 3 with password instead of certificate", identity: "CP-3", tls: true, password: password, wantStatus: http.StatusUnauthorized},
+		{name: "profile 3 with certificate of another CA", identity: "CP-3", tls: true, clientCert: clientCert(rogueCA, "CP-3"), wantTLSErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dialer := websocket.Dialer{Subprotocols: []string{string(OCPP16)}, HandshakeTimeout: 5 * time.Second}
+			url := "ws" + strings.TrimPrefix(insecure.URL, "http") + "/ocpp/" + tt.identity
+			if tt.tls {
+				dialer.TLSClientConfig = &tls.Config{RootCAs: roots}
+				if tt.clientCert != nil {
+					// sent even when the server does not accept its CA
+					dialer.TLSClientConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+						return tt.clientCert, nil
+					}
+				}
+				url = "wss" + strings.TrimPrefix(secure.URL, "https") + "/ocpp/" + tt.identity
+			}
+			header := http.Header{}
+			if tt.password != "" {
+				header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(tt.identity+":"+tt.password)))
+			}
+
+			ws, resp, err := dialer.Dial(url, header)
+			switch {
+			case tt.wantTLSErr:
+				if err == nil {
+					ws.Close()
+					t.Fatal("connected with a certificate of an untrusted CA")
+				}
+				if resp != nil {
+					t.Fatalf("got HTTP status %d, want a TLS handshake failure", resp.StatusCode)
+				}
+			case tt.wantStatus != 0:
+				if err == nil {
+					ws.Close()
+					t.Fatalf("connected, want HTTP status %d", tt.wantStatus)
+				}
+				if !errors.Is(err, websocket.ErrBadHandshake) || resp == nil || resp.StatusCode != tt.wantStatus {
+					t.Fatalf("got %v, want HTTP status %d", err, tt.wantStatus)
+				}
+				if resp.StatusCode == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+					t.Error("missing WWW-Authenticate header")
+				}
+			default:
+				if err != nil {
+					t.Fatalf("refused: %v", err)
+				}
+				ws.Close()
+			}
+		})
+	}
+}
+
+// issueCertificate returns the key pair issued by ca
+func issueCertificate(t *testing.T, ca *pki.CA, issue func(ca *pki.CA) ([]byte, []byte, error)) tls.Certificate {
+	t.Helper()
+	certPEM, keyPEM, err := issue(ca)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
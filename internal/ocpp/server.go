@@ -1,47 +1,112 @@
 package ocpp
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/sirupsen/logrus"
 
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/enums"
 	"github.com/mutoulbj/gocsms/internal/services"
 )
 
-type Server struct {
-	addr    string
-	handler *OCPPHandler
-	log     *logrus.Logger
-	server  *http.Server
-	clients map[string]*websocket.Conn
-	mu      sync.RWMutex
-}
+// ConnectHook is run after a known charge point has connected, with the time
+// its connection was established
+type ConnectHook func(ctx context.Context, chargePointID uuid.UUID, identity string, connectedAt time.Time)
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for simplicity; adjust in production
-	},
+type Server struct {
+	addr     string
+	cfg      *config.OCPPConfig
+	svc      *services.ChargePointService
+	handler  *OCPPHandler
+	registry *Registry
+	messages *services.MessageJournalService
+	log      *logrus.Logger
+	server   *http.Server
+	insecure *http.Server // plain listener next to the TLS one, see config.OCPPConfig.InsecurePort
+	upgrader websocket.Upgrader
+	clients  map[string]*connection
+	mu       sync.RWMutex
+	// run once a connection is tracked, set up before the server starts
+	connectHooks []ConnectHook
 }
 
-func NewOCPPServer(svc *services.ChargePointService, log *logrus.Logger) *Server {
+func NewOCPPServer(
+	cfg *config.OCPPConfig,
+	validator *SchemaValidator,
+	svc *services.ChargePointService,
+	txSvc *services.TransactionService,
+	mvSvc *services.MeterValueService,
+	cfgSvc *services.ChargePointConfigurationService,
+	secSvc *services.SecurityEventService,
+	rsvSvc *services.ReservationService,
+	registry *Registry,
+	messages *services.MessageJournalService,
+	log *logrus.Logger,
+) *Server {
 	return &Server{
-		handler: GocsmsOCPPHandler(svc, log),
-		log:     log,
-		clients: make(map[string]*websocket.Conn),
+		cfg:      cfg,
+		svc:      svc,
+		handler:  GocsmsOCPPHandler(cfg, validator, svc, txSvc, mvSvc, cfgSvc, secSvc, rsvSvc, log),
+		registry: registry,
+		messages: messages,
+		log:      log,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  cfg.WSBufferSize,
+			WriteBufferSize: cfg.WSBufferSize,
+			Subprotocols:    supportedVersions,
+			CheckOrigin: func(r *http.Request) bool {
+				return true // Allow all origins for simplicity; adjust in production
+			},
+		},
+		clients: make(map[string]*connection),
 	}
 }
 
 func (s *Server) Start(port string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ocpp/", s.handleWebSocket)
+	mux.HandleFunc("/ws", s.handleWebSocket)
+
 	s.addr = ":" + port
-	s.server = &http.Server{Addr: s.addr}
-	http.HandleFunc("/ws", s.handleWebSocket)
-	s.log.Infof("Starting OCPP server on %s", s.addr)
-	if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if err := s.registry.Serve(context.Background(), s.callRelayed, s.connectedVersions); err != nil {
+		s.log.Error("Calls from other nodes will not reach this node: ", err)
+	}
+	s.server = &http.Server{Addr: s.addr, Handler: mux}
+	if s.cfg.TLSCertFile == "" {
+		s.log.Infof("Starting OCPP server on %s", s.addr)
+		s.serve(s.server.ListenAndServe)
+		return
+	}
+
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		s.log.Fatal("Failed to load OCPP TLS configuration: ", err)
+	}
+	s.server.TLSConfig = tlsConfig
+	if s.cfg.InsecurePort != "" {
+		s.insecure = &http.Server{Addr: ":" + s.cfg.InsecurePort, Handler: mux}
+		s.log.Infof("Starting insecure OCPP server on %s", s.insecure.Addr)
+		go s.serve(s.insecure.ListenAndServe)
+	}
+	s.log.Infof("Starting OCPP server with TLS on %s", s.addr)
+	s.serve(func() error {
+		return s.server.ListenAndServeTLS(s.cfg.TLSCertFile, s.cfg.TLSKeyFile)
+	})
+}
+
+func (s *Server) serve(listen func() error) {
+	if err := listen(); err != nil && err != http.ErrServerClosed {
 		s.log.Fatal("Failed to start OCPP server: ", err)
 	}
 }
@@ -51,56 +116,276 @@ func (s *Server) Stop() {
 	if err := s.server.Shutdown(context.Background()); err != nil {
 		s.log.Error("Error shutting down OCPP server: ", err)
 	}
+	if s.insecure != nil {
+		if err := s.insecure.Shutdown(context.Background()); err != nil {
+			s.log.Error("Error shutting down insecure OCPP server: ", err)
+		}
+	}
+	s.registry.Stop()
 	s.mu.Lock()
 	for id, conn := range s.clients {
-		conn.Close()
+		conn.closeWith(websocket.CloseGoingAway, "Server shutting down")
 		delete(s.clients, id)
 	}
 	s.mu.Unlock()
 }
 
+// chargePointIdentity returns the OCPP identity of the connecting charge point,
+// taken from ws://host/ocpp/<identity> or the legacy ws://host/ws?id=<identity>
+func chargePointIdentity(r *http.Request) string {
+	if identity, ok := strings.CutPrefix(r.URL.Path, "/ocpp/"); ok {
+		if strings.Contains(identity, "/") {
+			return ""
+		}
+		return identity
+	}
+	return r.URL.Query().Get("id")
+}
+
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	chargePointID := r.URL.Query().Get("id")
-	if chargePointID == "" {
-		http.Error(w, "Missing charge point ID", http.StatusBadRequest)
+	identity := chargePointIdentity(r)
+	if identity == "" {
+		http.Error(w, "Missing charge point identity", http.StatusBadRequest)
 		return
 	}
 
-	conn, err := upgrader.Upgrade(w, r, nil)
+	if err := s.authenticate(r, identity); err != nil {
+		s.log.Warnf("Refused connection of %s from %s: %v", identity, r.RemoteAddr, err)
+		if errors.Is(err, ErrUnauthorized) {
+			w.Header().Set("WWW-Authenticate", `Basic realm="OCPP"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// charge points that offer subprotocols must offer one we support;
+	// those that offer none are legacy OCPP 1.6 clients
+	if offered := websocket.Subprotocols(r); len(offered) > 0 && !slices.ContainsFunc(offered, func(p string) bool {
+		return slices.Contains(supportedVersions, p)
+	}) {
+		http.Error(w, "Unsupported OCPP version", http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		s.log.Error("Failed to upgrade to WebSocket: ", err)
 		return
 	}
+	version := ProtocolVersion(cmp.Or(ws.Subprotocol(), string(OCPP16)))
+	conn := newConnection(identity, version, ws, s.cfg, r.RemoteAddr)
+	conn.start()
 
+	if cp, err := s.svc.GetByCode(r.Context(), identity); err == nil && cp != nil && cp.OcppVersion != version.Version() {
+		if err := s.svc.UpdateOcppVersion(r.Context(), cp.ID, version.Version()); err != nil {
+			s.log.Error("Failed to record OCPP version: ", err)
+		}
+	}
+
+	// a charge point reconnecting before its old socket timed out replaces it
 	s.mu.Lock()
-	s.clients[chargePointID] = conn
+	previous := s.clients[identity]
+	s.clients[identity] = conn
 	s.mu.Unlock()
+	if previous != nil {
+		s.log.Infof("Charge point %s reconnected, closing its previous connection from %s", identity, previous.remoteAddr)
+		previous.closeWith(websocket.ClosePolicyViolation, "Replaced by a new connection")
+	}
+	if err := s.registry.Register(r.Context(), identity, version); err != nil {
+		s.log.Error("Failed to register charge point connection: ", err)
+	}
 
-	s.log.Infof("Charge point %s connected", chargePointID)
+	s.log.Infof("Charge point %s connected using %s from %s", identity, version, r.RemoteAddr)
+	s.trackConnection(r.Context(), conn)
+	closeCode, closeReason := websocket.CloseAbnormalClosure, ""
 	defer func() {
 		s.mu.Lock()
-		delete(s.clients, chargePointID)
+		current := s.clients[identity] == conn
+		if current {
+			delete(s.clients, identity)
+		}
 		s.mu.Unlock()
-		conn.Close()
-		s.log.Infof("Charge point %s disconnected", chargePointID)
+		conn.close()
+		s.untrackConnection(conn, closeCode, closeReason, !current)
+		if current {
+			s.unregisterConnection(conn)
+		}
+		s.log.Infof("Charge point %s disconnected with close code %d", identity, closeCode)
 	}()
 
 	for {
-		_, msg, err := conn.ReadMessage()
+		msg, err := conn.read()
 		if err != nil {
-			s.log.Error("WebSocket read error: ", err)
+			closeCode, closeReason = conn.closeStatus(err)
+			if closeReason != "" {
+				s.log.Warnf("Connection of %s closed: %s", identity, closeReason)
+			} else {
+				s.log.Error("WebSocket read error: ", err)
+			}
 			return
 		}
 
-		resp, err := s.handler.HandleMessage(r.Context(), chargePointID, msg)
+		receivedAt := time.Now()
+
+		// replies to CSMS-initiated calls are routed to the waiting caller; only
+		// CALLs are answered, so any other frame that cannot be routed is dropped
+		var frame OCPPMessage
+		err = json.Unmarshal(msg, &frame)
+		if err == nil && frame.MessageTypeID != Call {
+			if call := conn.resolve(&frame); call != nil {
+				s.journal(identity, enums.MessageDirectionInbound, msg, call.action, call.sentAt)
+			} else {
+				s.log.Warnf("Dropping reply %s from %s: no pending call", frame.UniqueID, identity)
+				s.journal(identity, enums.MessageDirectionInbound, msg, "", time.Time{})
+			}
+			continue
+		}
+		if typeID, ok := frameType(msg); err != nil && ok && typeID != Call {
+			s.log.Warnf("Dropping invalid frame of type %d from %s: %v", typeID, identity, err)
+			s.journal(identity, enums.MessageDirectionInbound, msg, "", time.Time{})
+			continue
+		}
+		s.journal(identity, enums.MessageDirectionInbound, msg, "", time.Time{})
+
+		ctx, deferred := withAfterReply(r.Context())
+		resp, err := s.handler.HandleMessage(ctx, version, identity, msg)
 		if err != nil {
 			s.log.Error("Failed to handle OCPP message: ", err)
 			continue
 		}
 
-		if err := conn.WriteMessage(websocket.TextMessage, resp); err != nil {
+		if err := conn.write(resp); err != nil {
 			s.log.Error("WebSocket write error: ", err)
 			return
 		}
+		s.journal(identity, enums.MessageDirectionOutbound, resp, frame.Action, receivedAt)
+		for _, fn := range *deferred {
+			go fn()
+		}
+		// a charge point that connected before it was registered is known after its BootNotification
+		if conn.session == nil {
+			s.trackConnection(r.Context(), conn)
+		}
+	}
+}
+
+// trackConnection marks the charge point connected and opens its connection
+// history entry, once the identity belongs to a known charge point
+func (s *Server) trackConnection(ctx context.Context, conn *connection) {
+	cp, err := s.svc.GetByCode(ctx, conn.id)
+	if err != nil || cp == nil {
+		return
+	}
+	session, err := s.svc.Connect(ctx, cp.ID, conn.remoteAddr)
+	if err != nil {
+		s.log.Error("Failed to record charge point connection: ", err)
+		return
+	}
+	conn.session = session
+	for _, hook := range s.connectHooks {
+		go hook(context.Background(), cp.ID, conn.id, session.ConnectedAt)
+	}
+}
+
+// untrackConnection closes the connection history entry of a closed connection
+func (s *Server) untrackConnection(conn *connection, closeCode int, closeReason string, reconnected bool) {
+	if conn.session == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.CallTimeout)
+	defer cancel()
+	if err := s.svc.Disconnect(ctx, conn.session, closeCode, closeReason, reconnected); err != nil {
+		s.log.Error("Failed to record charge point disconnection: ", err)
+	}
+}
+
+// unregisterConnection removes the registry entry of a closed connection
+func (s *Server) unregisterConnection(conn *connection) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.cfg.CallTimeout)
+	defer cancel()
+	if err := s.registry.Unregister(ctx, conn.id, conn.version); err != nil {
+		s.log.Error("Failed to unregister charge point connection: ", err)
+	}
+}
+
+// OnBootAccepted registers a hook that runs after a BootNotification has been accepted
+// and the response has been sent to the charge point. Hooks run for charge points
+// of either OCPP version and skip the ones they do not apply to.
+func (s *Server) OnBootAccepted(hook BootHook) {
+	s.handler.bootHooks = append(s.handler.bootHooks, hook)
+}
+
+// OnConnected registers a hook that runs after a known charge point has connected
+func (s *Server) OnConnected(hook ConnectHook) {
+	s.connectHooks = append(s.connectHooks, hook)
+}
+
+// OnSignCertificate registers the hook that signs the accepted certificate
+// signing requests of charge points; without one they are rejected
+func (s *Server) OnSignCertificate(hook SignCertificateHook) {
+	s.handler.signHook = hook
+}
+
+// OnFirmwareStatus registers the hook that tracks the firmware statuses
+// reported by charge points
+func (s *Server) OnFirmwareStatus(hook FirmwareStatusHook) {
+	s.handler.firmwareHook = hook
+}
+
+// OnDiagnosticsStatus registers the hook that tracks the diagnostics and log
+// upload statuses reported by charge points
+func (s *Server) OnDiagnosticsStatus(hook DiagnosticsStatusHook) {
+	s.handler.diagnosticsHook = hook
+}
+
+// ConnectedIDs returns the identities of the charge points connected to this server using the given version
+func (s *Server) ConnectedIDs(version ProtocolVersion) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, 0, len(s.clients))
+	for id, conn := range s.clients {
+		if conn.version == version {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Disconnect closes the connections of this server to the charge points with the given identities
+func (s *Server) Disconnect(identities []string, closeCode int, closeReason string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, identity := range identities {
+		if conn, ok := s.clients[identity]; ok {
+			s.log.Warnf("Closing connection of %s: %s", identity, closeReason)
+			conn.closeWith(closeCode, closeReason)
+		}
+	}
+}
+
+// SeenSince returns the identities of the charge points connected to this
+// server that sent a frame or answered a ping since the given time
+func (s *Server) SeenSince(since time.Time) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, 0, len(s.clients))
+	for id, conn := range s.clients {
+		if conn.seenAt().After(since) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// connectedVersions returns the charge points connected to this server with their OCPP version
+func (s *Server) connectedVersions() map[string]ProtocolVersion {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	versions := make(map[string]ProtocolVersion, len(s.clients))
+	for id, conn := range s.clients {
+		versions[id] = conn.version
 	}
+	return versions
 }
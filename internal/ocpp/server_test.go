@@ -0,0 +1,59 @@
+package ocpp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gorilla/websocket"
+
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+func TestServerDropsInvalidReplies(t *testing.T) {
+	mr := miniredis.RunT(t)
+	seedChargePoint(t, mr, &models.ChargePoint{Code: "CP-1", OcppVersion: "1.6"})
+	s := newTestServer(t, "node-a", mr.Addr(), nil)
+	srv := httptest.NewServer(http.HandlerFunc(s.handleWebSocket))
+	t.Cleanup(srv.Close)
+
+	dialer := websocket.Dialer{Subprotocols: []string{string(OCPP16)}}
+	ws, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ocpp/CP-1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+
+	// only the CALL at the end is answered
+	frames := []string{
+		`[3,"1",null]`,
+		`[3,1,{}]`,
+		`[4,"2","InternalError",null,{}]`,
+		`[4,"3","InternalError","",{}]`,
+		`[5,"4",{}]`,
+		`[2,"5","DataTransfer",{"vendorId":"test"}]`,
+	}
+	for _, frame := range frames {
+		if err := ws.WriteMessage(websocket.TextMessage, []byte(frame)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ws.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	_, data, err := ws.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reply OCPPMessage
+	if err := json.Unmarshal(data, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if reply.UniqueID != "5" {
+		t.Errorf("got reply %s, want the reply to the CALL", data)
+	}
+}
@@ -0,0 +1,145 @@
+package ocpp
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/ocpp/v201"
+	"github.com/mutoulbj/gocsms/internal/services"
+)
+
+// triggerMessagesV201 maps the OCPP 1.6 messages that have another name in OCPP 2.0.1
+var triggerMessagesV201 = map[string]string{
+	"DiagnosticsStatusNotification": "LogStatusNotification",
+}
+
+// Trigger asks a charge point to send one of its messages, for one connector
+// when connectorID is given, with the TriggerMessage of the OCPP version it is
+// connected with. The connector is the EVSE for OCPP 2.0.1 charging stations.
+func (s *Server) Trigger(ctx context.Context, identity, requestedMessage string, connectorID *int) (string, error) {
+	version, ok := s.ConnectedVersion(ctx, identity)
+	if !ok {
+		return "", ErrChargePointNotConnected
+	}
+	if version == OCPP201 {
+		req := v201.TriggerMessageRequest{RequestedMessage: requestedMessage}
+		if name, ok := triggerMessagesV201[requestedMessage]; ok {
+			req.RequestedMessage = name
+		}
+		if connectorID != nil {
+			req.EVSE = &v201.EVSE{ID: *connectorID}
+		}
+		resp, err := s.TriggerMessageV201(ctx, identity, req)
+		if err != nil {
+			return "", err
+		}
+		return resp.Status, nil
+	}
+	resp, err := s.TriggerMessage(ctx, identity, TriggerMessageRequest{
+		RequestedMessage: requestedMessage,
+		ConnectorID:      connectorID,
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.Status, nil
+}
+
+// StateRefresher asks charge points for the status of their connectors once
+// they have connected, as the statuses they reported before may be stale.
+// It waits StatusRefreshDelay first and skips the connectors whose status was
+// reported meanwhile, as charge points that rebooted report all of them.
+type StateRefresher struct {
+	server *Server
+	cfg    *config.OCPPConfig
+	cpSvc  *services.ChargePointService
+	log    *logrus.Logger
+	done   chan struct{}
+}
+
+func NewStateRefresher(
+	server *Server,
+	cfg *config.OCPPConfig,
+	cpSvc *services.ChargePointService,
+	log *logrus.Logger,
+) *StateRefresher {
+	r := &StateRefresher{
+		server: server,
+		cfg:    cfg,
+		cpSvc:  cpSvc,
+		log:    log,
+		done:   make(chan struct{}),
+	}
+	if cfg.StatusRefreshDelay > 0 {
+		server.OnConnected(r.refresh)
+	}
+	return r
+}
+
+// Stop cancels the pending refreshes
+func (r *StateRefresher) Stop() {
+	close(r.done)
+}
+
+// refresh triggers a StatusNotification for every connector of a charge point
+// that has not reported its status since it connected
+func (r *StateRefresher) refresh(ctx context.Context, chargePointID uuid.UUID, identity string, connectedAt time.Time) {
+	timer := time.NewTimer(r.cfg.StatusRefreshDelay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-r.done:
+		return
+	}
+
+	// each trigger gets the call timeout of its own
+	lookupCtx, cancel := context.WithTimeout(ctx, r.cfg.CallTimeout)
+	defer cancel()
+	version, ok := r.server.ConnectedVersion(lookupCtx, identity)
+	if !ok {
+		return
+	}
+	connectors, err := r.cpSvc.ListConnectors(lookupCtx, chargePointID)
+	if err != nil {
+		r.log.WithError(err).Errorf("Failed to list connectors of charge point %s", identity)
+		return
+	}
+	if len(connectors) == 0 {
+		// nothing known yet, the charge point reports all of its connectors
+		r.trigger(ctx, identity, nil)
+		return
+	}
+	for _, connector := range connectors {
+		if connector.UpdatedAt.After(connectedAt) {
+			continue
+		}
+		id, err := strconv.Atoi(connector.ConnectorID)
+		if err != nil || (id == 0 && version == OCPP201) {
+			// OCPP 2.0.1 has no status for the charging station as a whole
+			continue
+		}
+		if !r.trigger(ctx, identity, &id) {
+			return
+		}
+	}
+}
+
+// trigger triggers a StatusNotification and tells whether the charge point
+// can be asked for more
+func (r *StateRefresher) trigger(ctx context.Context, identity string, connectorID *int) bool {
+	status, err := r.server.Trigger(ctx, identity, "StatusNotification", connectorID)
+	if err != nil {
+		r.log.WithError(err).Warnf("Failed to refresh the status of charge point %s", identity)
+		return false
+	}
+	if status != "Accepted" {
+		r.log.Warnf("Charge point %s answered %s to the status refresh", identity, status)
+		return status == "Rejected"
+	}
+	return true
+}
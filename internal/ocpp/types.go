@@ -9,19 +9,21 @@ import (
 type MessageType int
 
 const (
-	Call        MessageType = 2
-	CallResult  MessageType = 3
-	CallError   MessageType = 4
+	Call       MessageType = 2
+	CallResult MessageType = 3
+	CallError  MessageType = 4
 )
 
-// OCPPMessage represents a generic OCPP message
+// OCPPMessage represents a generic OCPP message. On the wire it is encoded
+// as an OCPP-J positional array, see MarshalJSON and UnmarshalJSON.
 type OCPPMessage struct {
-	MessageTypeID MessageType     `json:"messageTypeId"`
-	UniqueID      string          `json:"uniqueId"`
-	Action        string          `json:"action,omitempty"`
-	Payload       json.RawMessage `json:"payload"`
-	ErrorCode     string          `json:"errorCode,omitempty"`
-	ErrorMessage  string          `json:"errorMessage,omitempty"`
+	MessageTypeID MessageType
+	UniqueID      string
+	Action        string          // CALL only
+	Payload       json.RawMessage // CALL and CALLRESULT
+	ErrorCode     string          // CALLERROR only
+	ErrorMessage  string          // CALLERROR only
+	ErrorDetails  json.RawMessage // CALLERROR only
 }
 
 // BootNotificationRequest for OCPP 1.6
@@ -51,13 +53,360 @@ type HeartbeatResponse struct {
 
 // StatusNotificationRequest for OCPP 1.6
 type StatusNotificationRequest struct {
-	ConnectorID int       `json:"connectorId"`
-	Status      string    `json:"status"` // Available, Preparing, Charging, etc.
-	ErrorCode   string    `json:"errorCode"`
-	Timestamp   time.Time `json:"timestamp"`
+	ConnectorID     int        `json:"connectorId"`
+	Status          string     `json:"status"`    // Available, Preparing, Charging, etc.
+	ErrorCode       string     `json:"errorCode"` // NoError, ConnectorLockFailure, GroundFailure, etc.
+	Info            string     `json:"info,omitempty"`
+	Timestamp       *time.Time `json:"timestamp,omitempty"`
+	VendorId        string     `json:"vendorId,omitempty"`
+	VendorErrorCode string     `json:"vendorErrorCode,omitempty"`
 }
 
 // StatusNotificationResponse for OCPP 1.6
 type StatusNotificationResponse struct {
 	// Empty payload as per OCPP 1.6
-}
\ No newline at end of file
+}
+
+// IdTagInfo for OCPP 1.6
+type IdTagInfo struct {
+	Status      string     `json:"status"` // Accepted, Blocked, Expired, Invalid, ConcurrentTx
+	ExpiryDate  *time.Time `json:"expiryDate,omitempty"`
+	ParentIdTag string     `json:"parentIdTag,omitempty"`
+}
+
+// AuthorizeRequest for OCPP 1.6
+type AuthorizeRequest struct {
+	IdTag string `json:"idTag"`
+}
+
+// AuthorizeResponse for OCPP 1.6
+type AuthorizeResponse struct {
+	IdTagInfo IdTagInfo `json:"idTagInfo"`
+}
+
+// StartTransactionRequest for OCPP 1.6
+type StartTransactionRequest struct {
+	ConnectorID   int       `json:"connectorId"`
+	IdTag         string    `json:"idTag"`
+	MeterStart    int       `json:"meterStart"` // Wh
+	ReservationID *int      `json:"reservationId,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+}
+
+// StartTransactionResponse for OCPP 1.6
+type StartTransactionResponse struct {
+	IdTagInfo     IdTagInfo `json:"idTagInfo"`
+	TransactionID int       `json:"transactionId"`
+}
+
+// StopTransactionRequest for OCPP 1.6
+type StopTransactionRequest struct {
+	IdTag           string       `json:"idTag,omitempty"`
+	MeterStop       int          `json:"meterStop"` // Wh
+	Timestamp       time.Time    `json:"timestamp"`
+	TransactionID   int          `json:"transactionId"`
+	Reason          string       `json:"reason,omitempty"` // EmergencyStop, EVDisconnected, HardReset, Local, Other, PowerLoss, Reboot, Remote, SoftReset, UnlockCommand, DeAuthorized
+	TransactionData []MeterValue `json:"transactionData,omitempty"`
+}
+
+// StopTransactionResponse for OCPP 1.6
+type StopTransactionResponse struct {
+	IdTagInfo *IdTagInfo `json:"idTagInfo,omitempty"`
+}
+
+// SampledValue for OCPP 1.6
+type SampledValue struct {
+	Value     string `json:"value"`
+	Context   string `json:"context,omitempty"`   // Interruption.Begin, Interruption.End, Other, Sample.Clock, Sample.Periodic, Transaction.Begin, Transaction.End, Trigger
+	Format    string `json:"format,omitempty"`    // Raw, SignedData
+	Measurand string `json:"measurand,omitempty"` // Energy.Active.Import.Register, Power.Active.Import, Current.Import, Voltage, SoC, etc.
+	Phase     string `json:"phase,omitempty"`     // L1, L2, L3, N, L1-N, L2-N, L3-N, L1-L2, L2-L3, L3-L1
+	Location  string `json:"location,omitempty"`  // Body, Cable, EV, Inlet, Outlet
+	Unit      string `json:"unit,omitempty"`      // Wh, kWh, varh, kvarh, W, kW, VA, kVA, var, kvar, A, V, Celsius, Fahrenheit, K, Percent
+}
+
+// MeterValue for OCPP 1.6
+type MeterValue struct {
+	Timestamp    time.Time      `json:"timestamp"`
+	SampledValue []SampledValue `json:"sampledValue"`
+}
+
+// MeterValuesRequest for OCPP 1.6
+type MeterValuesRequest struct {
+	ConnectorID   int          `json:"connectorId"`
+	TransactionID *int         `json:"transactionId,omitempty"`
+	MeterValue    []MeterValue `json:"meterValue"`
+}
+
+// MeterValuesResponse for OCPP 1.6
+type MeterValuesResponse struct {
+	// Empty payload as per OCPP 1.6
+}
+
+// RemoteStartTransactionRequest for OCPP 1.6
+type RemoteStartTransactionRequest struct {
+	ConnectorID *int   `json:"connectorId,omitempty"`
+	IdTag       string `json:"idTag"`
+}
+
+// RemoteStartTransactionResponse for OCPP 1.6
+type RemoteStartTransactionResponse struct {
+	Status string `json:"status"` // Accepted, Rejected
+}
+
+// RemoteStopTransactionRequest for OCPP 1.6
+type RemoteStopTransactionRequest struct {
+	TransactionID int `json:"transactionId"`
+}
+
+// RemoteStopTransactionResponse for OCPP 1.6
+type RemoteStopTransactionResponse struct {
+	Status string `json:"status"` // Accepted, Rejected
+}
+
+// ResetRequest for OCPP 1.6
+type ResetRequest struct {
+	Type string `json:"type"` // Hard, Soft
+}
+
+// ResetResponse for OCPP 1.6
+type ResetResponse struct {
+	Status string `json:"status"` // Accepted, Rejected
+}
+
+// UnlockConnectorRequest for OCPP 1.6
+type UnlockConnectorRequest struct {
+	ConnectorID int `json:"connectorId"`
+}
+
+// UnlockConnectorResponse for OCPP 1.6
+type UnlockConnectorResponse struct {
+	Status string `json:"status"` // Unlocked, UnlockFailed, NotSupported
+}
+
+// GetConfigurationRequest for OCPP 1.6
+type GetConfigurationRequest struct {
+	Key []string `json:"key,omitempty"`
+}
+
+// KeyValue for OCPP 1.6
+type KeyValue struct {
+	Key      string  `json:"key"`
+	Readonly bool    `json:"readonly"`
+	Value    *string `json:"value,omitempty"`
+}
+
+// GetConfigurationResponse for OCPP 1.6
+type GetConfigurationResponse struct {
+	ConfigurationKey []KeyValue `json:"configurationKey,omitempty"`
+	UnknownKey       []string   `json:"unknownKey,omitempty"`
+}
+
+// ChangeConfigurationRequest for OCPP 1.6
+type ChangeConfigurationRequest struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// ChangeConfigurationResponse for OCPP 1.6
+type ChangeConfigurationResponse struct {
+	Status string `json:"status"` // Accepted, Rejected, RebootRequired, NotSupported
+}
+
+// SignCertificateRequest for the OCPP 1.6 security extension
+type SignCertificateRequest struct {
+	Csr string `json:"csr"`
+}
+
+// SignCertificateResponse for the OCPP 1.6 security extension
+type SignCertificateResponse struct {
+	Status string `json:"status"` // Accepted, Rejected
+}
+
+// CertificateSignedRequest for the OCPP 1.6 security extension
+type CertificateSignedRequest struct {
+	CertificateChain string `json:"certificateChain"`
+}
+
+// CertificateSignedResponse for the OCPP 1.6 security extension
+type CertificateSignedResponse struct {
+	Status string `json:"status"` // Accepted, Rejected
+}
+
+// InstallCertificateRequest for the OCPP 1.6 security extension
+type InstallCertificateRequest struct {
+	CertificateType string `json:"certificateType"` // CentralSystemRootCertificate, ManufacturerRootCertificate
+	Certificate     string `json:"certificate"`
+}
+
+// InstallCertificateResponse for the OCPP 1.6 security extension
+type InstallCertificateResponse struct {
+	Status string `json:"status"` // Accepted, Failed, Rejected
+}
+
+// CertificateHashData for the OCPP 1.6 security extension
+type CertificateHashData struct {
+	HashAlgorithm  string `json:"hashAlgorithm"` // SHA256, SHA384, SHA512
+	IssuerNameHash string `json:"issuerNameHash"`
+	IssuerKeyHash  string `json:"issuerKeyHash"`
+	SerialNumber   string `json:"serialNumber"`
+}
+
+// GetInstalledCertificateIdsRequest for the OCPP 1.6 security extension
+type GetInstalledCertificateIdsRequest struct {
+	CertificateType string `json:"certificateType"` // CentralSystemRootCertificate, ManufacturerRootCertificate
+}
+
+// GetInstalledCertificateIdsResponse for the OCPP 1.6 security extension
+type GetInstalledCertificateIdsResponse struct {
+	Status              string                `json:"status"` // Accepted, NotFound
+	CertificateHashData []CertificateHashData `json:"certificateHashData,omitempty"`
+}
+
+// DeleteCertificateRequest for the OCPP 1.6 security extension
+type DeleteCertificateRequest struct {
+	CertificateHashData CertificateHashData `json:"certificateHashData"`
+}
+
+// DeleteCertificateResponse for the OCPP 1.6 security extension
+type DeleteCertificateResponse struct {
+	Status string `json:"status"` // Accepted, Failed, NotFound
+}
+
+// SecurityEventNotificationRequest for the OCPP 1.6 security extension
+type SecurityEventNotificationRequest struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	TechInfo  string    `json:"techInfo,omitempty"`
+}
+
+// SecurityEventNotificationResponse for the OCPP 1.6 security extension
+type SecurityEventNotificationResponse struct {
+	// Empty payload as per OCPP 1.6
+}
+
+// UpdateFirmwareRequest for OCPP 1.6
+type UpdateFirmwareRequest struct {
+	Location      string    `json:"location"`
+	Retries       *int      `json:"retries,omitempty"`
+	RetrieveDate  time.Time `json:"retrieveDate"`
+	RetryInterval *int      `json:"retryInterval,omitempty"`
+}
+
+// UpdateFirmwareResponse for OCPP 1.6
+type UpdateFirmwareResponse struct {
+	// Empty payload as per OCPP 1.6
+}
+
+// FirmwareStatusNotificationRequest for OCPP 1.6
+type FirmwareStatusNotificationRequest struct {
+	Status string `json:"status"` // Downloaded, DownloadFailed, Downloading, Idle, InstallationFailed, Installing, Installed
+}
+
+// FirmwareStatusNotificationResponse for OCPP 1.6
+type FirmwareStatusNotificationResponse struct {
+	// Empty payload as per OCPP 1.6
+}
+
+// Firmware for the OCPP 1.6 security extension
+type Firmware struct {
+	Location           string     `json:"location"`
+	RetrieveDateTime   time.Time  `json:"retrieveDateTime"`
+	InstallDateTime    *time.Time `json:"installDateTime,omitempty"`
+	SigningCertificate string     `json:"signingCertificate"`
+	Signature          string     `json:"signature"`
+}
+
+// SignedUpdateFirmwareRequest for the OCPP 1.6 security extension
+type SignedUpdateFirmwareRequest struct {
+	Retries       *int     `json:"retries,omitempty"`
+	RetryInterval *int     `json:"retryInterval,omitempty"`
+	RequestID     int      `json:"requestId"`
+	Firmware      Firmware `json:"firmware"`
+}
+
+// SignedUpdateFirmwareResponse for the OCPP 1.6 security extension
+type SignedUpdateFirmwareResponse struct {
+	Status string `json:"status"` // Accepted, Rejected, AcceptedCanceled, InvalidCertificate, RevokedCertificate
+}
+
+// SignedFirmwareStatusNotificationRequest for the OCPP 1.6 security extension
+type SignedFirmwareStatusNotificationRequest struct {
+	Status    string `json:"status"`
+	RequestID *int   `json:"requestId,omitempty"`
+}
+
+// SignedFirmwareStatusNotificationResponse for the OCPP 1.6 security extension
+type SignedFirmwareStatusNotificationResponse struct {
+	// Empty payload as per OCPP 1.6
+}
+
+// GetDiagnosticsRequest for OCPP 1.6
+type GetDiagnosticsRequest struct {
+	Location      string     `json:"location"`
+	Retries       *int       `json:"retries,omitempty"`
+	RetryInterval *int       `json:"retryInterval,omitempty"`
+	StartTime     *time.Time `json:"startTime,omitempty"`
+	StopTime      *time.Time `json:"stopTime,omitempty"`
+}
+
+// GetDiagnosticsResponse for OCPP 1.6
+type GetDiagnosticsResponse struct {
+	FileName string `json:"fileName,omitempty"` // empty when there is no diagnostics information
+}
+
+// DiagnosticsStatusNotificationRequest for OCPP 1.6
+type DiagnosticsStatusNotificationRequest struct {
+	Status string `json:"status"` // Idle, Uploaded, UploadFailed, Uploading
+}
+
+// DiagnosticsStatusNotificationResponse for OCPP 1.6
+type DiagnosticsStatusNotificationResponse struct {
+	// Empty payload as per OCPP 1.6
+}
+
+// TriggerMessageRequest for OCPP 1.6
+type TriggerMessageRequest struct {
+	RequestedMessage string `json:"requestedMessage"` // BootNotification, DiagnosticsStatusNotification, FirmwareStatusNotification, Heartbeat, MeterValues, StatusNotification
+	ConnectorID      *int   `json:"connectorId,omitempty"`
+}
+
+// TriggerMessageResponse for OCPP 1.6
+type TriggerMessageResponse struct {
+	Status string `json:"status"` // Accepted, Rejected, NotImplemented
+}
+
+// ChangeAvailabilityRequest for OCPP 1.6
+type ChangeAvailabilityRequest struct {
+	ConnectorID int    `json:"connectorId"` // 0 for the charge point as a whole
+	Type        string `json:"type"`        // Inoperative, Operative
+}
+
+// ChangeAvailabilityResponse for OCPP 1.6
+type ChangeAvailabilityResponse struct {
+	Status string `json:"status"` // Accepted, Rejected, Scheduled
+}
+
+// ReserveNowRequest for OCPP 1.6
+type ReserveNowRequest struct {
+	ConnectorID   int       `json:"connectorId"` // 0 for any connector of the charge point
+	ExpiryDate    time.Time `json:"expiryDate"`
+	IdTag         string    `json:"idTag"`
+	ParentIdTag   string    `json:"parentIdTag,omitempty"`
+	ReservationID int       `json:"reservationId"`
+}
+
+// ReserveNowResponse for OCPP 1.6
+type ReserveNowResponse struct {
+	Status string `json:"status"` // Accepted, Faulted, Occupied, Rejected, Unavailable
+}
+
+// CancelReservationRequest for OCPP 1.6
+type CancelReservationRequest struct {
+	ReservationID int `json:"reservationId"`
+}
+
+// CancelReservationResponse for OCPP 1.6
+type CancelReservationResponse struct {
+	Status string `json:"status"` // Accepted, Rejected
+}
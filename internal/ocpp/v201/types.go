@@ -0,0 +1,464 @@
+// Package v201 defines the OCPP 2.0.1 message payloads handled by the CSMS.
+package v201
+
+import "time"
+
+// ChargingStation for OCPP 2.0.1
+type ChargingStation struct {
+	SerialNumber    string `json:"serialNumber,omitempty"`
+	Model           string `json:"model"`
+	VendorName      string `json:"vendorName"`
+	FirmwareVersion string `json:"firmwareVersion,omitempty"`
+}
+
+// BootNotificationRequest for OCPP 2.0.1
+type BootNotificationRequest struct {
+	ChargingStation ChargingStation `json:"chargingStation"`
+	Reason          string          `json:"reason"` // ApplicationReset, FirmwareUpdate, LocalReset, PowerUp, RemoteReset, ScheduledReset, Triggered, Unknown, Watchdog
+}
+
+// BootNotificationResponse for OCPP 2.0.1
+type BootNotificationResponse struct {
+	CurrentTime time.Time `json:"currentTime"`
+	Interval    int       `json:"interval"`
+	Status      string    `json:"status"` // Accepted, Pending, Rejected
+}
+
+// HeartbeatRequest for OCPP 2.0.1
+type HeartbeatRequest struct {
+	// Empty payload as per OCPP 2.0.1
+}
+
+// HeartbeatResponse for OCPP 2.0.1
+type HeartbeatResponse struct {
+	CurrentTime time.Time `json:"currentTime"`
+}
+
+// StatusNotificationRequest for OCPP 2.0.1
+type StatusNotificationRequest struct {
+	Timestamp       time.Time `json:"timestamp"`
+	ConnectorStatus string    `json:"connectorStatus"` // Available, Occupied, Reserved, Unavailable, Faulted
+	EvseID          int       `json:"evseId"`
+	ConnectorID     int       `json:"connectorId"`
+}
+
+// StatusNotificationResponse for OCPP 2.0.1
+type StatusNotificationResponse struct {
+	// Empty payload as per OCPP 2.0.1
+}
+
+// IdToken for OCPP 2.0.1
+type IdToken struct {
+	IdToken string `json:"idToken"`
+	Type    string `json:"type"` // Central, eMAID, ISO14443, ISO15693, KeyCode, Local, MacAddress, NoAuthorization
+}
+
+// IdTokenInfo for OCPP 2.0.1
+type IdTokenInfo struct {
+	Status              string     `json:"status"` // Accepted, Blocked, ConcurrentTx, Expired, Invalid, NoCredit, NotAllowedTypeEVSE, NotAtThisLocation, NotAtThisTime, Unknown
+	CacheExpiryDateTime *time.Time `json:"cacheExpiryDateTime,omitempty"`
+}
+
+// AuthorizeRequest for OCPP 2.0.1
+type AuthorizeRequest struct {
+	IdToken     IdToken `json:"idToken"`
+	Certificate string  `json:"certificate,omitempty"`
+}
+
+// AuthorizeResponse for OCPP 2.0.1
+type AuthorizeResponse struct {
+	IdTokenInfo IdTokenInfo `json:"idTokenInfo"`
+}
+
+// SignedMeterValue for OCPP 2.0.1
+type SignedMeterValue struct {
+	SignedMeterData string `json:"signedMeterData"`
+	SigningMethod   string `json:"signingMethod"`
+	EncodingMethod  string `json:"encodingMethod"`
+	PublicKey       string `json:"publicKey"`
+}
+
+// UnitOfMeasure for OCPP 2.0.1
+type UnitOfMeasure struct {
+	Unit       string `json:"unit,omitempty"` // defaults to Wh
+	Multiplier int    `json:"multiplier,omitempty"`
+}
+
+// SampledValue for OCPP 2.0.1
+type SampledValue struct {
+	Value            float64           `json:"value"`
+	Context          string            `json:"context,omitempty"`   // Interruption.Begin, Interruption.End, Other, Sample.Clock, Sample.Periodic, Transaction.Begin, Transaction.End, Trigger
+	Measurand        string            `json:"measurand,omitempty"` // defaults to Energy.Active.Import.Register
+	Phase            string            `json:"phase,omitempty"`
+	Location         string            `json:"location,omitempty"` // defaults to Outlet
+	SignedMeterValue *SignedMeterValue `json:"signedMeterValue,omitempty"`
+	UnitOfMeasure    *UnitOfMeasure    `json:"unitOfMeasure,omitempty"`
+}
+
+// MeterValue for OCPP 2.0.1
+type MeterValue struct {
+	Timestamp    time.Time      `json:"timestamp"`
+	SampledValue []SampledValue `json:"sampledValue"`
+}
+
+// MeterValuesRequest for OCPP 2.0.1
+type MeterValuesRequest struct {
+	EvseID     int          `json:"evseId"`
+	MeterValue []MeterValue `json:"meterValue"`
+}
+
+// MeterValuesResponse for OCPP 2.0.1
+type MeterValuesResponse struct {
+	// Empty payload as per OCPP 2.0.1
+}
+
+// EVSE for OCPP 2.0.1
+type EVSE struct {
+	ID          int  `json:"id"`
+	ConnectorID *int `json:"connectorId,omitempty"`
+}
+
+// Transaction for OCPP 2.0.1
+type Transaction struct {
+	TransactionID     string `json:"transactionId"`
+	ChargingState     string `json:"chargingState,omitempty"` // Charging, EVConnected, SuspendedEV, SuspendedEVSE, Idle
+	TimeSpentCharging *int   `json:"timeSpentCharging,omitempty"`
+	StoppedReason     string `json:"stoppedReason,omitempty"`
+	RemoteStartID     *int   `json:"remoteStartId,omitempty"`
+}
+
+// TransactionEventRequest for OCPP 2.0.1
+type TransactionEventRequest struct {
+	EventType          string       `json:"eventType"` // Started, Updated, Ended
+	Timestamp          time.Time    `json:"timestamp"`
+	TriggerReason      string       `json:"triggerReason"`
+	SeqNo              int          `json:"seqNo"`
+	Offline            bool         `json:"offline,omitempty"`
+	NumberOfPhasesUsed *int         `json:"numberOfPhasesUsed,omitempty"`
+	CableMaxCurrent    *int         `json:"cableMaxCurrent,omitempty"`
+	ReservationID      *int         `json:"reservationId,omitempty"`
+	TransactionInfo    Transaction  `json:"transactionInfo"`
+	IdToken            *IdToken     `json:"idToken,omitempty"`
+	EVSE               *EVSE        `json:"evse,omitempty"`
+	MeterValue         []MeterValue `json:"meterValue,omitempty"`
+}
+
+// TransactionEventResponse for OCPP 2.0.1
+type TransactionEventResponse struct {
+	TotalCost   *float64     `json:"totalCost,omitempty"`
+	IdTokenInfo *IdTokenInfo `json:"idTokenInfo,omitempty"`
+}
+
+// Component for OCPP 2.0.1
+type Component struct {
+	Name     string `json:"name"`
+	Instance string `json:"instance,omitempty"`
+	EVSE     *EVSE  `json:"evse,omitempty"`
+}
+
+// Variable for OCPP 2.0.1
+type Variable struct {
+	Name     string `json:"name"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// VariableAttribute for OCPP 2.0.1
+type VariableAttribute struct {
+	Type       string  `json:"type,omitempty"` // Actual (default), Target, MinSet, MaxSet
+	Value      *string `json:"value,omitempty"`
+	Mutability string  `json:"mutability,omitempty"` // ReadOnly, WriteOnly, ReadWrite (default)
+	Persistent bool    `json:"persistent,omitempty"`
+	Constant   bool    `json:"constant,omitempty"`
+}
+
+// ReportData for OCPP 2.0.1
+type ReportData struct {
+	Component         Component           `json:"component"`
+	Variable          Variable            `json:"variable"`
+	VariableAttribute []VariableAttribute `json:"variableAttribute"`
+}
+
+// NotifyReportRequest for OCPP 2.0.1
+type NotifyReportRequest struct {
+	RequestID   int          `json:"requestId"`
+	GeneratedAt time.Time    `json:"generatedAt"`
+	Tbc         bool         `json:"tbc,omitempty"`
+	SeqNo       int          `json:"seqNo"`
+	ReportData  []ReportData `json:"reportData,omitempty"`
+}
+
+// NotifyReportResponse for OCPP 2.0.1
+type NotifyReportResponse struct {
+	// Empty payload as per OCPP 2.0.1
+}
+
+// StatusInfo for OCPP 2.0.1
+type StatusInfo struct {
+	ReasonCode     string `json:"reasonCode"`
+	AdditionalInfo string `json:"additionalInfo,omitempty"`
+}
+
+// SignCertificateRequest for OCPP 2.0.1
+type SignCertificateRequest struct {
+	Csr             string `json:"csr"`
+	CertificateType string `json:"certificateType,omitempty"` // ChargingStationCertificate, V2GCertificate
+}
+
+// SignCertificateResponse for OCPP 2.0.1
+type SignCertificateResponse struct {
+	Status     string      `json:"status"` // Accepted, Rejected
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// CertificateSignedRequest for OCPP 2.0.1
+type CertificateSignedRequest struct {
+	CertificateChain string `json:"certificateChain"`
+	CertificateType  string `json:"certificateType,omitempty"`
+}
+
+// CertificateSignedResponse for OCPP 2.0.1
+type CertificateSignedResponse struct {
+	Status     string      `json:"status"` // Accepted, Rejected
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// InstallCertificateRequest for OCPP 2.0.1
+type InstallCertificateRequest struct {
+	CertificateType string `json:"certificateType"` // V2GRootCertificate, MORootCertificate, CSMSRootCertificate, ManufacturerRootCertificate
+	Certificate     string `json:"certificate"`
+}
+
+// InstallCertificateResponse for OCPP 2.0.1
+type InstallCertificateResponse struct {
+	Status     string      `json:"status"` // Accepted, Rejected, Failed
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// CertificateHashData for OCPP 2.0.1
+type CertificateHashData struct {
+	HashAlgorithm  string `json:"hashAlgorithm"` // SHA256, SHA384, SHA512
+	IssuerNameHash string `json:"issuerNameHash"`
+	IssuerKeyHash  string `json:"issuerKeyHash"`
+	SerialNumber   string `json:"serialNumber"`
+}
+
+// CertificateHashDataChain for OCPP 2.0.1
+type CertificateHashDataChain struct {
+	CertificateType          string                `json:"certificateType"`
+	CertificateHashData      CertificateHashData   `json:"certificateHashData"`
+	ChildCertificateHashData []CertificateHashData `json:"childCertificateHashData,omitempty"`
+}
+
+// GetInstalledCertificateIdsRequest for OCPP 2.0.1
+type GetInstalledCertificateIdsRequest struct {
+	CertificateType []string `json:"certificateType,omitempty"`
+}
+
+// GetInstalledCertificateIdsResponse for OCPP 2.0.1
+type GetInstalledCertificateIdsResponse struct {
+	Status                   string                     `json:"status"` // Accepted, NotFound
+	StatusInfo               *StatusInfo                `json:"statusInfo,omitempty"`
+	CertificateHashDataChain []CertificateHashDataChain `json:"certificateHashDataChain,omitempty"`
+}
+
+// DeleteCertificateRequest for OCPP 2.0.1
+type DeleteCertificateRequest struct {
+	CertificateHashData CertificateHashData `json:"certificateHashData"`
+}
+
+// DeleteCertificateResponse for OCPP 2.0.1
+type DeleteCertificateResponse struct {
+	Status     string      `json:"status"` // Accepted, Failed, NotFound
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// SecurityEventNotificationRequest for OCPP 2.0.1
+type SecurityEventNotificationRequest struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	TechInfo  string    `json:"techInfo,omitempty"`
+}
+
+// SecurityEventNotificationResponse for OCPP 2.0.1
+type SecurityEventNotificationResponse struct {
+	// Empty payload as per OCPP 2.0.1
+}
+
+// Firmware for OCPP 2.0.1
+type Firmware struct {
+	Location           string     `json:"location"`
+	RetrieveDateTime   time.Time  `json:"retrieveDateTime"`
+	InstallDateTime    *time.Time `json:"installDateTime,omitempty"`
+	SigningCertificate string     `json:"signingCertificate,omitempty"`
+	Signature          string     `json:"signature,omitempty"`
+}
+
+// UpdateFirmwareRequest for OCPP 2.0.1
+type UpdateFirmwareRequest struct {
+	Retries       *int     `json:"retries,omitempty"`
+	RetryInterval *int     `json:"retryInterval,omitempty"`
+	RequestID     int      `json:"requestId"`
+	Firmware      Firmware `json:"firmware"`
+}
+
+// UpdateFirmwareResponse for OCPP 2.0.1
+type UpdateFirmwareResponse struct {
+	Status     string      `json:"status"` // Accepted, Rejected, AcceptedCanceled, InvalidCertificate, RevokedCertificate
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// FirmwareStatusNotificationRequest for OCPP 2.0.1
+type FirmwareStatusNotificationRequest struct {
+	Status    string `json:"status"`
+	RequestID *int   `json:"requestId,omitempty"`
+}
+
+// FirmwareStatusNotificationResponse for OCPP 2.0.1
+type FirmwareStatusNotificationResponse struct {
+	// Empty payload as per OCPP 2.0.1
+}
+
+// LogParameters for OCPP 2.0.1
+type LogParameters struct {
+	RemoteLocation  string     `json:"remoteLocation"`
+	OldestTimestamp *time.Time `json:"oldestTimestamp,omitempty"`
+	LatestTimestamp *time.Time `json:"latestTimestamp,omitempty"`
+}
+
+// GetLogRequest for OCPP 2.0.1
+type GetLogRequest struct {
+	Log           LogParameters `json:"log"`
+	LogType       string        `json:"logType"` // DiagnosticsLog, SecurityLog
+	RequestID     int           `json:"requestId"`
+	Retries       *int          `json:"retries,omitempty"`
+	RetryInterval *int          `json:"retryInterval,omitempty"`
+}
+
+// GetLogResponse for OCPP 2.0.1
+type GetLogResponse struct {
+	Status     string      `json:"status"` // Accepted, Rejected, AcceptedCanceled
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+	Filename   string      `json:"filename,omitempty"`
+}
+
+// LogStatusNotificationRequest for OCPP 2.0.1
+type LogStatusNotificationRequest struct {
+	Status    string `json:"status"` // BadMessage, Idle, NotSupportedOperation, PermissionDenied, Uploaded, UploadFailure, Uploading, AcceptedCanceled
+	RequestID *int   `json:"requestId,omitempty"`
+}
+
+// LogStatusNotificationResponse for OCPP 2.0.1
+type LogStatusNotificationResponse struct {
+	// Empty payload as per OCPP 2.0.1
+}
+
+// TriggerMessageRequest for OCPP 2.0.1
+type TriggerMessageRequest struct {
+	RequestedMessage string `json:"requestedMessage"`
+	EVSE             *EVSE  `json:"evse,omitempty"`
+}
+
+// TriggerMessageResponse for OCPP 2.0.1
+type TriggerMessageResponse struct {
+	Status     string      `json:"status"` // Accepted, Rejected, NotImplemented
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// ChangeAvailabilityRequest for OCPP 2.0.1
+type ChangeAvailabilityRequest struct {
+	OperationalStatus string `json:"operationalStatus"` // Inoperative, Operative
+	EVSE              *EVSE  `json:"evse,omitempty"`    // the charging station as a whole when omitted
+}
+
+// ChangeAvailabilityResponse for OCPP 2.0.1
+type ChangeAvailabilityResponse struct {
+	Status     string      `json:"status"` // Accepted, Rejected, Scheduled
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// ReserveNowRequest for OCPP 2.0.1
+type ReserveNowRequest struct {
+	ID             int       `json:"id"`
+	ExpiryDateTime time.Time `json:"expiryDateTime"`
+	ConnectorType  string    `json:"connectorType,omitempty"`
+	IdToken        IdToken   `json:"idToken"`
+	EvseID         *int      `json:"evseId,omitempty"` // any EVSE of the charging station when omitted
+	GroupIdToken   *IdToken  `json:"groupIdToken,omitempty"`
+}
+
+// ReserveNowResponse for OCPP 2.0.1
+type ReserveNowResponse struct {
+	Status     string      `json:"status"` // Accepted, Faulted, Occupied, Rejected, Unavailable
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// CancelReservationRequest for OCPP 2.0.1
+type CancelReservationRequest struct {
+	ReservationID int `json:"reservationId"`
+}
+
+// CancelReservationResponse for OCPP 2.0.1
+type CancelReservationResponse struct {
+	Status     string      `json:"status"` // Accepted, Rejected
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// ReservationStatusUpdateRequest for OCPP 2.0.1
+type ReservationStatusUpdateRequest struct {
+	ReservationID           int    `json:"reservationId"`
+	ReservationUpdateStatus string `json:"reservationUpdateStatus"` // Expired, Removed
+}
+
+// ReservationStatusUpdateResponse for OCPP 2.0.1
+type ReservationStatusUpdateResponse struct {
+	// Empty payload as per OCPP 2.0.1
+}
+
+// RequestStartTransactionRequest for OCPP 2.0.1
+type RequestStartTransactionRequest struct {
+	EvseID        *int    `json:"evseId,omitempty"` // chosen by the charging station when omitted
+	RemoteStartID int     `json:"remoteStartId"`    // returned in the TransactionEvent of the transaction
+	IdToken       IdToken `json:"idToken"`
+}
+
+// RequestStartTransactionResponse for OCPP 2.0.1
+type RequestStartTransactionResponse struct {
+	Status        string      `json:"status"`                  // Accepted, Rejected
+	TransactionID string      `json:"transactionId,omitempty"` // when a transaction was already started, e.g. by plugging in
+	StatusInfo    *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// RequestStopTransactionRequest for OCPP 2.0.1
+type RequestStopTransactionRequest struct {
+	TransactionID string `json:"transactionId"`
+}
+
+// RequestStopTransactionResponse for OCPP 2.0.1
+type RequestStopTransactionResponse struct {
+	Status     string      `json:"status"` // Accepted, Rejected
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// ResetRequest for OCPP 2.0.1
+type ResetRequest struct {
+	Type   string `json:"type"`             // Immediate, OnIdle
+	EvseID *int   `json:"evseId,omitempty"` // the charging station as a whole when omitted
+}
+
+// ResetResponse for OCPP 2.0.1
+type ResetResponse struct {
+	Status     string      `json:"status"` // Accepted, Rejected, Scheduled
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
+
+// UnlockConnectorRequest for OCPP 2.0.1
+type UnlockConnectorRequest struct {
+	EvseID      int `json:"evseId"`
+	ConnectorID int `json:"connectorId"`
+}
+
+// UnlockConnectorResponse for OCPP 2.0.1
+type UnlockConnectorResponse struct {
+	Status     string      `json:"status"` // Unlocked, UnlockFailed, OngoingAuthorizedTransaction, UnknownConnector
+	StatusInfo *StatusInfo `json:"statusInfo,omitempty"`
+}
@@ -0,0 +1,66 @@
+package ocpp
+
+import (
+	"encoding/json"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+// schemaMode returns the schema validation mode for the charge point's vendor.
+// A BootNotification carries the vendor itself, which matters for charge
+// points that are not registered yet.
+func (h *OCPPHandler) schemaMode(cp *models.ChargePoint, msg OCPPMessage) string {
+	if msg.Action == "BootNotification" {
+		var boot struct {
+			ChargePointVendor string `json:"chargePointVendor"`
+			ChargingStation   struct {
+				VendorName string `json:"vendorName"`
+			} `json:"chargingStation"`
+		}
+		if err := json.Unmarshal(msg.Payload, &boot); err == nil {
+			if vendor := boot.ChargePointVendor + boot.ChargingStation.VendorName; vendor != "" {
+				return h.cfg.SchemaValidationFor(vendor)
+			}
+		}
+	}
+
+	if cp == nil {
+		return h.cfg.SchemaValidation
+	}
+	return h.cfg.SchemaValidationFor(cp.Vendor)
+}
+
+// checkPayload validates a payload against its schema. Violations are
+// returned in strict mode and only logged in lenient mode.
+func (h *OCPPHandler) checkPayload(version ProtocolVersion, mode, chargePointID, action string, response bool, payload json.RawMessage) error {
+	err := h.validator.Validate(version, action, response, payload)
+	if err == nil {
+		return nil
+	}
+	if mode == config.SchemaValidationLenient {
+		h.log.Warnf("Ignoring schema violation in %s for %s: %v", messageName(action, response), chargePointID, err)
+		return nil
+	}
+	h.log.Errorf("Schema violation in %s for %s: %v", messageName(action, response), chargePointID, err)
+	return err
+}
+
+// checkReply validates the CALLRESULT the CSMS sends back. A violation here
+// is a bug in the CSMS, so it is logged but the reply is still sent.
+func (h *OCPPHandler) checkReply(version ProtocolVersion, chargePointID, action string, reply []byte) {
+	var msg OCPPMessage
+	if err := json.Unmarshal(reply, &msg); err != nil || msg.MessageTypeID != CallResult {
+		return
+	}
+	if err := h.validator.Validate(version, action, true, msg.Payload); err != nil {
+		h.log.Errorf("%s sent to %s violates the schema: %v", messageName(action, true), chargePointID, err)
+	}
+}
+
+func messageName(action string, response bool) string {
+	if response {
+		return action + "Response"
+	}
+	return action
+}
@@ -0,0 +1,46 @@
+package ocpp
+
+// ProtocolVersion is an OCPP-J WebSocket subprotocol
+type ProtocolVersion string
+
+const (
+	OCPP16  ProtocolVersion = "ocpp1.6"
+	OCPP201 ProtocolVersion = "ocpp2.0.1"
+)
+
+// supportedVersions lists the subprotocols offered to charge points, in order of preference
+var supportedVersions = []string{string(OCPP201), string(OCPP16)}
+
+// Version returns the OCPP version as stored on a charge point, e.g. "1.6"
+func (v ProtocolVersion) Version() string {
+	switch v {
+	case OCPP201:
+		return "2.0.1"
+	default:
+		return "1.6"
+	}
+}
+
+// maxIdTagLength returns the length limit of id tags: the IdToken of OCPP 1.6
+// is up to 20 characters, the idToken of 2.0.1 up to 36
+func (v ProtocolVersion) maxIdTagLength() int {
+	if v == OCPP201 {
+		return 36
+	}
+	return 20
+}
+
+// errorCode translates an OCPP 1.6 CALLERROR code into its spelling in this version
+func (v ProtocolVersion) errorCode(code string) string {
+	if v != OCPP201 {
+		return code
+	}
+	switch code {
+	case ErrorCodeFormationViolation:
+		return ErrorCodeFormatViolation
+	case ErrorCodeOccurenceConstraintViolation:
+		return ErrorCodeOccurrenceConstraintViolation
+	default:
+		return code
+	}
+}
@@ -0,0 +1,96 @@
+package ocpp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/services"
+)
+
+// OfflineWatchdog marks charge points offline when their heartbeats lapse for
+// the configured multiple of the heartbeat interval. This also catches
+// connections that died without a close, e.g. when a node of the CSMS crashed.
+// Charge points may skip heartbeats while they send other messages, so any
+// frame or pong received by this node counts as a heartbeat.
+type OfflineWatchdog struct {
+	cfg    *config.OCPPConfig
+	server *Server
+	svc    *services.ChargePointService
+	log    *logrus.Logger
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewOfflineWatchdog(cfg *config.OCPPConfig, server *Server, svc *services.ChargePointService, log *logrus.Logger) *OfflineWatchdog {
+	return &OfflineWatchdog{
+		cfg:    cfg,
+		server: server,
+		svc:    svc,
+		log:    log,
+		done:   make(chan struct{}),
+	}
+}
+
+// Start launches the periodic heartbeat check
+func (w *OfflineWatchdog) Start() {
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(w.cfg.OfflineCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.check()
+			case <-w.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops the periodic heartbeat check
+func (w *OfflineWatchdog) Stop() {
+	close(w.done)
+	w.wg.Wait()
+}
+
+func (w *OfflineWatchdog) check() {
+	ctx, cancel := context.WithTimeout(context.Background(), w.cfg.OfflineCheckInterval)
+	defer cancel()
+
+	// pongs arrive once per ping interval, so they are looked for that much further back
+	seen := w.server.SeenSince(time.Now().Add(-w.cfg.OfflineCheckInterval - w.cfg.WSPingInterval))
+	if err := w.svc.RecordActivity(ctx, seen); err != nil {
+		w.log.WithError(err).Error("Failed to record charge point activity")
+	}
+
+	lapse := time.Duration(w.cfg.OfflineHeartbeatMultiple) * w.cfg.HeartbeatInterval
+	ids, err := w.svc.MarkOffline(ctx, time.Now().Add(-lapse), websocket.CloseAbnormalClosure)
+	if err != nil {
+		w.log.WithError(err).Error("Failed to mark charge points offline")
+		return
+	}
+	if len(ids) == 0 {
+		return
+	}
+	w.log.Warnf("Marked %d charge points offline after %s without heartbeat", len(ids), lapse)
+
+	// a lapsed charge point still connected to this node has to connect anew
+	// to be marked online again
+	cps, err := w.svc.ListByIDs(ctx, ids)
+	if err != nil {
+		w.log.WithError(err).Error("Failed to look up the charge points marked offline")
+		return
+	}
+	identities := make([]string, len(cps))
+	for i, cp := range cps {
+		identities[i] = cp.Code
+	}
+	w.server.Disconnect(identities, websocket.ClosePolicyViolation, "Heartbeat lapsed")
+}
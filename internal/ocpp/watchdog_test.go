@@ -0,0 +1,141 @@
+package ocpp
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gorilla/websocket"
+
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+// lapsedDB is a database in which the one charge point it holds has let its
+// heartbeats lapse; it keeps the statements it is sent
+type lapsedDB struct {
+	cp *models.ChargePoint
+
+	mu      sync.Mutex
+	queries []string
+}
+
+func (db *lapsedDB) Connect(context.Context) (driver.Conn, error) { return lapsedConn{db}, nil }
+
+func (db *lapsedDB) Driver() driver.Driver { return nil }
+
+func (db *lapsedDB) record(query string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.queries = append(db.queries, query)
+}
+
+// closedConnections returns the statements that closed a connection history entry
+func (db *lapsedDB) closedConnections() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var closed []string
+	for _, query := range db.queries {
+		if strings.HasPrefix(query, `UPDATE "charge_point_connections"`) {
+			closed = append(closed, query)
+		}
+	}
+	return closed
+}
+
+type lapsedConn struct{ db *lapsedDB }
+
+func (c lapsedConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.db.record(query)
+	switch {
+	case strings.HasPrefix(query, `UPDATE "charge_points"`) && strings.Contains(query, "RETURNING"):
+		return &tableRows{columns: []string{"id"}, rows: [][]driver.Value{{c.db.cp.ID.String()}}}, nil
+	case strings.HasPrefix(query, "SELECT") && strings.Contains(query, "id IN"):
+		return &tableRows{columns: []string{"id", "code"}, rows: [][]driver.Value{{c.db.cp.ID.String(), c.db.cp.Code}}}, nil
+	}
+	return noRows{}, nil
+}
+
+func (c lapsedConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.db.record(query)
+	return driver.RowsAffected(0), nil
+}
+
+func (lapsedConn) Prepare(string) (driver.Stmt, error) { return nil, errDatabaseUnavailable }
+
+func (lapsedConn) Close() error { return nil }
+
+func (lapsedConn) Begin() (driver.Tx, error) { return nil, errDatabaseUnavailable }
+
+type tableRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *tableRows) Columns() []string { return r.columns }
+
+func (r *tableRows) Close() error { return nil }
+
+func (r *tableRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestWatchdogClosesLapsedConnection(t *testing.T) {
+	mr := miniredis.RunT(t)
+	cp := &models.ChargePoint{Code: "CP-1", OcppVersion: "1.6"}
+	seedChargePoint(t, mr, cp)
+	db := &lapsedDB{cp: cp}
+	s := newTestServer(t, "node-a", mr.Addr(), db)
+	s.cfg.OfflineHeartbeatMultiple = 3
+	s.cfg.HeartbeatInterval = time.Minute
+	s.cfg.OfflineCheckInterval = 5 * time.Second
+	srv := httptest.NewServer(http.HandlerFunc(s.handleWebSocket))
+	t.Cleanup(srv.Close)
+
+	dialer := websocket.Dialer{Subprotocols: []string{string(OCPP16)}}
+	ws, _, err := dialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ocpp/CP-1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !s.IsConnected("CP-1") {
+		if time.Now().After(deadline) {
+			t.Fatal("charge point did not connect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	NewOfflineWatchdog(s.cfg, s, s.svc, s.log).check()
+
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = ws.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation {
+		t.Fatalf("got %v, want the lapsed connection closed with a policy violation", err)
+	}
+
+	// the entry the watchdog closed is not overwritten when the socket closes
+	for len(db.closedConnections()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("connection history entry was not closed on disconnect")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if closed := db.closedConnections()[1]; !strings.Contains(closed, "WHERE (disconnected_at IS NULL)") {
+		t.Errorf("disconnect overwrites closed connection history entries: %s", closed)
+	}
+}
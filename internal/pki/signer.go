@@ -0,0 +1,52 @@
+package pki
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"time"
+)
+
+// Signer signs the certificate signing requests charge points send through
+// SignCertificate. Deployments that use an external CA plug in their own
+// implementation; LocalSigner is the built-in one.
+type Signer interface {
+	// Sign returns the PEM encoded certificate chain for csr, leaf first.
+	// certificateType is the OCPP certificate use, e.g. ChargingStationCertificate
+	// or V2GCertificate.
+	Sign(ctx context.Context, csr *x509.CertificateRequest, certificateType string) ([]byte, error)
+}
+
+// LocalSigner signs requests with a local CA, for development and tests
+type LocalSigner struct {
+	CA       *CA
+	Validity time.Duration
+}
+
+func NewLocalSigner(ca *CA, validity time.Duration) *LocalSigner {
+	return &LocalSigner{CA: ca, Validity: validity}
+}
+
+func (s *LocalSigner) Sign(_ context.Context, csr *x509.CertificateRequest, _ string) ([]byte, error) {
+	certPEM, err := s.CA.SignCSR(csr, s.Validity)
+	if err != nil {
+		return nil, err
+	}
+	return append(certPEM, s.CA.CertPEM()...), nil
+}
+
+// ParseCSR parses and verifies the signature of a PEM encoded certificate signing request
+func ParseCSR(csrPEM []byte) (*x509.CertificateRequest, error) {
+	block, _ := pem.Decode(csrPEM)
+	if block == nil {
+		return nil, ErrInvalidPEM
+	}
+	csr, err := x509.ParseCertificateRequest(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, err
+	}
+	return csr, nil
+}
@@ -2,7 +2,9 @@ package repository
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,6 +12,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/uptrace/bun"
 
+	"github.com/mutoulbj/gocsms/internal/enums"
 	"github.com/mutoulbj/gocsms/internal/models"
 )
 
@@ -53,6 +56,132 @@ func (r *ChargePointRepository) GetByID(ctx context.Context, id string) (*models
 	return cp, r.cacheChargePoint(ctx, cp)
 }
 
+// GetByCode returns the charge point with the given OCPP identity, nil when there is none
+func (r *ChargePointRepository) GetByCode(ctx context.Context, code string) (*models.ChargePoint, error) {
+	// the code to id mapping never changes, so the cached id can be used for the id lookup
+	if id, err := r.redis.Get(ctx, "chargepoint:code:"+code).Result(); err == nil {
+		return r.GetByID(ctx, id)
+	}
+
+	cp := &models.ChargePoint{}
+	err := r.db.NewSelect().Model(cp).Where("code = ?", code).Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		r.log.Error("failed to get charge point by code: ", err)
+		return nil, err
+	}
+	if err := r.redis.Set(ctx, "chargepoint:code:"+code, cp.ID.String(), 5*time.Minute).Err(); err != nil {
+		return nil, err
+	}
+	return cp, r.cacheChargePoint(ctx, cp)
+}
+
+// UpdateBoot stores the details and registration status reported by a BootNotification
+func (r *ChargePointRepository) UpdateBoot(ctx context.Context, cp *models.ChargePoint) error {
+	cp.UpdatedAt = time.Now()
+	_, err := r.db.NewUpdate().
+		Model(cp).
+		Column("serial_number", "vendor", "model", "firmware_version", "ocpp_version", "registration_status", "registered_at", "last_heartbeat", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		r.log.Error("failed to update charge point boot details: ", err)
+		return err
+	}
+	return r.invalidateCache(ctx, cp.ID.String())
+}
+
+func (r *ChargePointRepository) UpdateRegistrationStatus(ctx context.Context, id uuid.UUID, status enums.ChargePointRegistrationStatus) error {
+	_, err := r.db.NewUpdate().
+		Model((*models.ChargePoint)(nil)).
+		Set("registration_status = ?, updated_at = ?", status, time.Now()).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		r.log.Error("failed to update charge point registration status: ", err)
+		return err
+	}
+	return r.invalidateCache(ctx, id.String())
+}
+
+// GetPasswordHash returns the hashed OCPP Basic Auth password of a charge point. The
+// hash is never cached, so it is read from the database rather than the model.
+func (r *ChargePointRepository) GetPasswordHash(ctx context.Context, id uuid.UUID) (string, error) {
+	var hash sql.NullString
+	err := r.db.NewSelect().
+		Model((*models.ChargePoint)(nil)).
+		Column("password_hash").
+		Where("id = ?", id).
+		Scan(ctx, &hash)
+	if err != nil {
+		r.log.Error("failed to get charge point password hash: ", err)
+		return "", err
+	}
+	return hash.String, nil
+}
+
+// UpdateSecurity sets the security profile of a charge point, and its password hash when one is given
+func (r *ChargePointRepository) UpdateSecurity(ctx context.Context, id uuid.UUID, profile int, passwordHash string) error {
+	query := r.db.NewUpdate().
+		Model((*models.ChargePoint)(nil)).
+		Set("security_profile = ?, updated_at = ?", profile, time.Now()).
+		Where("id = ?", id)
+	if passwordHash != "" {
+		query = query.Set("password_hash = ?", passwordHash)
+	}
+	if _, err := query.Exec(ctx); err != nil {
+		r.log.Error("failed to update charge point security: ", err)
+		return err
+	}
+	return r.invalidateCache(ctx, id.String())
+}
+
+// ListByVendor returns the charge points of a vendor, narrowed down to a model when one is given
+func (r *ChargePointRepository) ListByVendor(ctx context.Context, vendor, model string) ([]*models.ChargePoint, error) {
+	var cps []*models.ChargePoint
+	query := r.db.NewSelect().
+		Model(&cps).
+		Where("vendor = ?", vendor)
+	if model != "" {
+		query = query.Where("model = ?", model)
+	}
+	if err := query.Scan(ctx); err != nil {
+		r.log.Error("failed to list charge points by vendor: ", err)
+		return nil, err
+	}
+	return cps, nil
+}
+
+// ListByIDs returns the charge points with the given IDs
+func (r *ChargePointRepository) ListByIDs(ctx context.Context, ids []uuid.UUID) ([]*models.ChargePoint, error) {
+	var cps []*models.ChargePoint
+	err := r.db.NewSelect().
+		Model(&cps).
+		Where("id IN (?)", bun.In(ids)).
+		Scan(ctx)
+	if err != nil {
+		r.log.Error("failed to list charge points by id: ", err)
+		return nil, err
+	}
+	return cps, nil
+}
+
+// ListByChargeStations returns the charge points of the given charge stations
+func (r *ChargePointRepository) ListByChargeStations(ctx context.Context, stationIDs []uuid.UUID) ([]*models.ChargePoint, error) {
+	var cps []*models.ChargePoint
+	err := r.db.NewSelect().
+		Model(&cps).
+		Where("charge_station_id IN (?)", bun.In(stationIDs)).
+		Scan(ctx)
+	if err != nil {
+		r.log.Error("failed to list charge points by charge station: ", err)
+		return nil, err
+	}
+	return cps, nil
+}
+
 func (r *ChargePointRepository) UpdateStatus(ctx context.Context, id uuid.UUID, status string) error {
 	_, err := r.db.NewUpdate().
 		Model((*models.ChargePoint)(nil)).
@@ -66,6 +195,132 @@ func (r *ChargePointRepository) UpdateStatus(ctx context.Context, id uuid.UUID,
 	return r.invalidateCache(ctx, id.String())
 }
 
+// UpdateConnected records whether the charge point holds a connection; a new
+// connection counts as a heartbeat
+func (r *ChargePointRepository) UpdateConnected(ctx context.Context, id uuid.UUID, connected bool) error {
+	now := time.Now()
+	query := r.db.NewUpdate().
+		Model((*models.ChargePoint)(nil)).
+		Set("connected = ?, updated_at = ?", connected, now).
+		Where("id = ?", id)
+	if connected {
+		query = query.Set("last_heartbeat = ?", now)
+	}
+	if _, err := query.Exec(ctx); err != nil {
+		r.log.Error("failed to update charge point connection state: ", err)
+		return err
+	}
+	return r.invalidateCache(ctx, id.String())
+}
+
+// UpdateHeartbeat records a heartbeat. A charge point that was marked offline
+// is back with an unknown status until it reports its status again.
+func (r *ChargePointRepository) UpdateHeartbeat(ctx context.Context, id uuid.UUID) error {
+	now := time.Now()
+	_, err := r.db.NewUpdate().
+		Model((*models.ChargePoint)(nil)).
+		Set("last_heartbeat = ?, connected = TRUE, updated_at = ?", now, now).
+		Set("status = CASE WHEN status = ? THEN ? ELSE status END", enums.ChargePointStatusOffline, enums.ChargePointStatusUnknown).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		r.log.Error("failed to update charge point heartbeat: ", err)
+		return err
+	}
+	return r.invalidateCache(ctx, id.String())
+}
+
+// UpdateHeartbeats records a heartbeat for each of the charge points with the
+// given identities, like UpdateHeartbeat
+func (r *ChargePointRepository) UpdateHeartbeats(ctx context.Context, codes []string) error {
+	now := time.Now()
+	var ids []uuid.UUID
+	_, err := r.db.NewUpdate().
+		Model((*models.ChargePoint)(nil)).
+		Set("last_heartbeat = ?, connected = TRUE, updated_at = ?", now, now).
+		Set("status = CASE WHEN status = ? THEN ? ELSE status END", enums.ChargePointStatusOffline, enums.ChargePointStatusUnknown).
+		Where("code IN (?)", bun.In(codes)).
+		Returning("id").
+		Exec(ctx, &ids)
+	if err != nil {
+		r.log.Error("failed to update charge point heartbeats: ", err)
+		return err
+	}
+	for _, id := range ids {
+		if err := r.invalidateCache(ctx, id.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// MarkOffline marks the charge points whose last heartbeat is before the given
+// time as offline and disconnected, and returns their ids
+func (r *ChargePointRepository) MarkOffline(ctx context.Context, before time.Time) ([]uuid.UUID, error) {
+	var ids []uuid.UUID
+	_, err := r.db.NewUpdate().
+		Model((*models.ChargePoint)(nil)).
+		Set("status = ?, connected = FALSE, updated_at = ?", enums.ChargePointStatusOffline, time.Now()).
+		Where("last_heartbeat < ?", before).
+		Where("status <> ? OR connected", enums.ChargePointStatusOffline).
+		Returning("id").
+		Exec(ctx, &ids)
+	if err != nil {
+		r.log.Error("failed to mark charge points offline: ", err)
+		return nil, err
+	}
+	for _, id := range ids {
+		if err := r.invalidateCache(ctx, id.String()); err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// UpdateAvailability stores the availability a charge point accepted for
+// itself as a whole. A change that takes effect straight away shows in the
+// status until the charge point reports its new status.
+func (r *ChargePointRepository) UpdateAvailability(ctx context.Context, id uuid.UUID, availability enums.Availability, scheduled bool) error {
+	query := r.db.NewUpdate().
+		Model((*models.ChargePoint)(nil)).
+		Set("availability = ?, availability_scheduled = ?, updated_at = ?", availability, scheduled, time.Now()).
+		Where("id = ?", id)
+	if !scheduled {
+		query = query.Set("status = CASE WHEN ? = ? THEN ? WHEN status = ? THEN ? ELSE status END",
+			availability, enums.AvailabilityInoperative, enums.ChargePointStatusUnavailable,
+			enums.ChargePointStatusUnavailable, enums.ChargePointStatusAvailable)
+	}
+	if _, err := query.Exec(ctx); err != nil {
+		r.log.Error("failed to update charge point availability: ", err)
+		return err
+	}
+	return r.invalidateCache(ctx, id.String())
+}
+
+// SettleAvailability completes the scheduled availability change of a charge
+// point once all of its connectors report a status that agrees with it
+func (r *ChargePointRepository) SettleAvailability(ctx context.Context, id uuid.UUID) error {
+	res, err := r.db.NewUpdate().
+		Model((*models.ChargePoint)(nil)).
+		Set("availability_scheduled = FALSE, updated_at = ?", time.Now()).
+		Set("status = CASE WHEN cp.availability = ? THEN ? WHEN cp.status = ? THEN ? ELSE cp.status END",
+			enums.AvailabilityInoperative, enums.ChargePointStatusUnavailable,
+			enums.ChargePointStatusUnavailable, enums.ChargePointStatusAvailable).
+		Where("cp.id = ?", id).
+		Where("cp.availability_scheduled").
+		Where("NOT EXISTS (SELECT 1 FROM connectors AS c WHERE c.charge_point_id = cp.id AND c.connector_id <> '0' AND (c.status = ?) <> (cp.availability = ?))",
+			enums.ChargePointStatusUnavailable, enums.AvailabilityInoperative).
+		Exec(ctx)
+	if err != nil {
+		r.log.Error("failed to settle charge point availability: ", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	return r.invalidateCache(ctx, id.String())
+}
+
 func (r *ChargePointRepository) cacheChargePoint(ctx context.Context, cp *models.ChargePoint) error {
 	data, err := json.Marshal(cp)
 	if err != nil {
@@ -89,3 +344,16 @@ func (r *ChargePointRepository) getFromCache(ctx context.Context, id string) (*m
 func (r *ChargePointRepository) invalidateCache(ctx context.Context, id string) error {
 	return r.redis.Del(ctx, "chargepoint:"+id).Err()
 }
+
+func (r *ChargePointRepository) UpdateOcppVersion(ctx context.Context, id uuid.UUID, version string) error {
+	_, err := r.db.NewUpdate().
+		Model((*models.ChargePoint)(nil)).
+		Set("ocpp_version = ?, updated_at = ?", version, time.Now()).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		r.log.Error("failed to update charge point ocpp version: ", err)
+		return err
+	}
+	return r.invalidateCache(ctx, id.String())
+}
@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+type ChargePointConfigurationRepository struct {
+	db  *bun.DB
+	log *logrus.Logger
+}
+
+func NewChargePointConfigurationRepository(db *bun.DB, log *logrus.Logger) *ChargePointConfigurationRepository {
+	return &ChargePointConfigurationRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// UpsertReported stores the key values reported by a charge point, keeping the desired state
+func (r *ChargePointConfigurationRepository) UpsertReported(ctx context.Context, items []*models.ChargePointConfiguration) error {
+	if len(items) == 0 {
+		return nil
+	}
+	now := time.Now()
+	for _, item := range items {
+		item.RefreshedAt = now
+		item.CreatedAt = now
+		item.UpdatedAt = now
+	}
+	_, err := r.db.NewInsert().
+		Model(&items).
+		On("CONFLICT (charge_point_id, key) DO UPDATE").
+		Set("value = EXCLUDED.value").
+		Set("readonly = EXCLUDED.readonly").
+		Set("refreshed_at = EXCLUDED.refreshed_at").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to store charge point configuration")
+		return err
+	}
+	return nil
+}
+
+// RecordChange stores the outcome of a ChangeConfiguration; the value itself is only
+// updated when the charge point accepted it (possibly pending a reboot)
+func (r *ChargePointConfigurationRepository) RecordChange(ctx context.Context, chargePointID uuid.UUID, key, value, status string) error {
+	now := time.Now()
+	item := &models.ChargePointConfiguration{
+		ChargePointID: chargePointID,
+		Key:           key,
+		DesiredValue:  value,
+		ChangeStatus:  status,
+		ChangedAt:     now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	query := r.db.NewInsert().
+		Model(item).
+		On("CONFLICT (charge_point_id, key) DO UPDATE").
+		Set("desired_value = EXCLUDED.desired_value").
+		Set("change_status = EXCLUDED.change_status").
+		Set("changed_at = EXCLUDED.changed_at").
+		Set("updated_at = EXCLUDED.updated_at")
+	if status == "Accepted" || status == "RebootRequired" {
+		item.Value = value
+		query = query.Set("value = EXCLUDED.value")
+	}
+	if _, err := query.Exec(ctx); err != nil {
+		r.log.WithError(err).Error("Failed to record configuration change")
+		return err
+	}
+	return nil
+}
+
+// ListByChargePoint returns the stored configuration of a charge point ordered by key
+func (r *ChargePointConfigurationRepository) ListByChargePoint(ctx context.Context, chargePointID uuid.UUID) ([]*models.ChargePointConfiguration, error) {
+	var items []*models.ChargePointConfiguration
+	err := r.db.NewSelect().
+		Model(&items).
+		Where("charge_point_id = ?", chargePointID).
+		Order("key ASC").
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to list charge point configuration")
+		return nil, err
+	}
+	return items, nil
+}
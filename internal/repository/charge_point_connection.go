@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+type ChargePointConnectionRepository struct {
+	db  *bun.DB
+	log *logrus.Logger
+}
+
+func NewChargePointConnectionRepository(db *bun.DB, log *logrus.Logger) *ChargePointConnectionRepository {
+	return &ChargePointConnectionRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *ChargePointConnectionRepository) Create(ctx context.Context, conn *models.ChargePointConnection) error {
+	_, err := r.db.NewInsert().
+		Model(conn).
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to create charge point connection")
+		return err
+	}
+	return nil
+}
+
+// Close records the end of a connection; one that is already closed is kept as it is
+func (r *ChargePointConnectionRepository) Close(ctx context.Context, conn *models.ChargePointConnection) error {
+	_, err := r.db.NewUpdate().
+		Model(conn).
+		Column("disconnected_at", "close_code", "close_reason").
+		WherePK().
+		Where("disconnected_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to close charge point connection")
+		return err
+	}
+	return nil
+}
+
+// CloseOpen closes the connections of the charge points that are still open,
+// for sessions that ended without the CSMS noticing
+func (r *ChargePointConnectionRepository) CloseOpen(ctx context.Context, chargePointIDs []uuid.UUID, at time.Time, closeCode int) error {
+	if len(chargePointIDs) == 0 {
+		return nil
+	}
+	_, err := r.db.NewUpdate().
+		Model((*models.ChargePointConnection)(nil)).
+		Set("disconnected_at = ?, close_code = ?", at, closeCode).
+		Where("charge_point_id IN (?)", bun.In(chargePointIDs)).
+		Where("disconnected_at IS NULL").
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to close open charge point connections")
+		return err
+	}
+	return nil
+}
+
+// List returns the most recent connections of a charge point
+func (r *ChargePointConnectionRepository) List(ctx context.Context, chargePointID uuid.UUID, limit int) ([]*models.ChargePointConnection, error) {
+	var conns []*models.ChargePointConnection
+	err := r.db.NewSelect().
+		Model(&conns).
+		Where("charge_point_id = ?", chargePointID).
+		Order("connected_at DESC").
+		Limit(limit).
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to list charge point connections")
+		return nil, err
+	}
+	return conns, nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+type ConfigurationDriftRepository struct {
+	db  *bun.DB
+	log *logrus.Logger
+}
+
+func NewConfigurationDriftRepository(db *bun.DB, log *logrus.Logger) *ConfigurationDriftRepository {
+	return &ConfigurationDriftRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Replace replaces the stored drift of a charge point with the given drift
+func (r *ConfigurationDriftRepository) Replace(ctx context.Context, chargePointID uuid.UUID, drifts []*models.ConfigurationDrift) error {
+	err := r.db.RunInTx(ctx, &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
+		_, err := tx.NewDelete().
+			Model((*models.ConfigurationDrift)(nil)).
+			Where("charge_point_id = ?", chargePointID).
+			Exec(ctx)
+		if err != nil || len(drifts) == 0 {
+			return err
+		}
+		_, err = tx.NewInsert().Model(&drifts).Exec(ctx)
+		return err
+	})
+	if err != nil {
+		r.log.WithError(err).Error("Failed to store configuration drift")
+		return err
+	}
+	return nil
+}
+
+// List returns the stored drift of all charge points
+func (r *ConfigurationDriftRepository) List(ctx context.Context) ([]*models.ConfigurationDrift, error) {
+	drifts := []*models.ConfigurationDrift{}
+	err := r.db.NewSelect().
+		Model(&drifts).
+		Order("charge_point_id ASC", "key ASC").
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to list configuration drift")
+		return nil, err
+	}
+	return drifts, nil
+}
+
+// DeleteByTemplate deletes the stored drift from a template
+func (r *ConfigurationDriftRepository) DeleteByTemplate(ctx context.Context, templateID uuid.UUID) error {
+	_, err := r.db.NewDelete().
+		Model((*models.ConfigurationDrift)(nil)).
+		Where("template_id = ?", templateID).
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to delete configuration drift")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+type ConfigurationTemplateRepository struct {
+	db  *bun.DB
+	log *logrus.Logger
+}
+
+func NewConfigurationTemplateRepository(db *bun.DB, log *logrus.Logger) *ConfigurationTemplateRepository {
+	return &ConfigurationTemplateRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Create creates a new configuration template
+func (r *ConfigurationTemplateRepository) Create(ctx context.Context, tpl *models.ConfigurationTemplate) error {
+	err := r.db.NewInsert().
+		Model(tpl).
+		Returning("*").
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to create configuration template")
+		return err
+	}
+	return nil
+}
+
+// GetByID retrieves a configuration template by its ID
+func (r *ConfigurationTemplateRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.ConfigurationTemplate, error) {
+	tpl := &models.ConfigurationTemplate{}
+	err := r.db.NewSelect().
+		Model(tpl).
+		Where("id = ?", id).
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to get configuration template by ID")
+		return nil, err
+	}
+	return tpl, nil
+}
+
+// FindFor returns the template for a vendor and model, preferring a model-specific
+// template over the vendor-wide one. It returns nil when no template applies.
+func (r *ConfigurationTemplateRepository) FindFor(ctx context.Context, vendor, model string) (*models.ConfigurationTemplate, error) {
+	tpl := &models.ConfigurationTemplate{}
+	err := r.db.NewSelect().
+		Model(tpl).
+		Where("vendor = ?", vendor).
+		Where("model = ? OR model = ''", model).
+		OrderExpr("model = '' ASC").
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.log.WithError(err).Error("Failed to find configuration template")
+		return nil, err
+	}
+	return tpl, nil
+}
+
+// List returns all configuration templates
+func (r *ConfigurationTemplateRepository) List(ctx context.Context) ([]*models.ConfigurationTemplate, error) {
+	var tpls []*models.ConfigurationTemplate
+	err := r.db.NewSelect().
+		Model(&tpls).
+		Order("vendor ASC", "model ASC").
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to list configuration templates")
+		return nil, err
+	}
+	return tpls, nil
+}
+
+// Update updates a configuration template
+func (r *ConfigurationTemplateRepository) Update(ctx context.Context, tpl *models.ConfigurationTemplate) error {
+	tpl.UpdatedAt = time.Now()
+	_, err := r.db.NewUpdate().
+		Model(tpl).
+		Column("name", "vendor", "model", "keys", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to update configuration template")
+		return err
+	}
+	return nil
+}
+
+// Delete deletes a configuration template by its ID
+func (r *ConfigurationTemplateRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.NewDelete().
+		Model((*models.ConfigurationTemplate)(nil)).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to delete configuration template")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+// ConnectorStatusFilter narrows down a connector status history query; zero values are ignored
+type ConnectorStatusFilter struct {
+	ChargePointID uuid.UUID
+	ConnectorID   *int
+	From          time.Time
+	To            time.Time
+	Limit         int
+}
+
+type ConnectorRepository struct {
+	db  *bun.DB
+	log *logrus.Logger
+}
+
+func NewConnectorRepository(db *bun.DB, log *logrus.Logger) *ConnectorRepository {
+	return &ConnectorRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// UpdateStatus stores a status reported for a connector in its history and on
+// the connector, creating the connector when it is reported for the first time.
+// A scheduled availability change of the connector is complete once the
+// reported status agrees with it.
+func (r *ConnectorRepository) UpdateStatus(ctx context.Context, report *models.ConnectorStatusHistory) error {
+	now := time.Now()
+	report.CreatedAt = now
+	connector := &models.Connector{
+		ID:              uuid.New(),
+		ChargePointID:   report.ChargePointID,
+		ConnectorID:     strconv.Itoa(report.ConnectorID),
+		Status:          report.Status,
+		ErrorCode:       report.ErrorCode,
+		Info:            report.Info,
+		VendorID:        report.VendorID,
+		VendorErrorCode: report.VendorErrorCode,
+		StatusUpdatedAt: report.Timestamp,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+
+	err := r.db.RunInTx(ctx, &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(report).Exec(ctx); err != nil {
+			return err
+		}
+		_, err := tx.NewInsert().
+			Model(connector).
+			Column("id", "charge_point_id", "connector_id", "status", "error_code", "info", "vendor_id",
+				"vendor_error_code", "status_updated_at", "created_at", "updated_at").
+			On("CONFLICT (charge_point_id, connector_id) DO UPDATE").
+			Set("status = EXCLUDED.status").
+			Set("error_code = EXCLUDED.error_code").
+			Set("info = EXCLUDED.info").
+			Set("vendor_id = EXCLUDED.vendor_id").
+			Set("vendor_error_code = EXCLUDED.vendor_error_code").
+			Set("status_updated_at = EXCLUDED.status_updated_at").
+			Set("availability_scheduled = c.availability_scheduled AND (c.availability = ?) <> (EXCLUDED.status = ?)",
+				enums.AvailabilityInoperative, enums.ChargePointStatusUnavailable).
+			Set("updated_at = EXCLUDED.updated_at").
+			Exec(ctx)
+		return err
+	})
+	if err != nil {
+		r.log.WithError(err).Error("Failed to update connector status")
+		return err
+	}
+	return nil
+}
+
+// UpdateAvailability stores the availability a charge point accepted for one
+// of its connectors. A change that takes effect straight away shows in the
+// status until the charge point reports the new status of the connector.
+func (r *ConnectorRepository) UpdateAvailability(ctx context.Context, chargePointID uuid.UUID, connectorID int, availability enums.Availability, scheduled bool) error {
+	query := r.db.NewUpdate().
+		Model((*models.Connector)(nil)).
+		Set("availability = ?, availability_scheduled = ?, updated_at = ?", availability, scheduled, time.Now()).
+		Where("charge_point_id = ?", chargePointID).
+		Where("connector_id = ?", strconv.Itoa(connectorID))
+	if !scheduled {
+		query = query.Set("status = CASE WHEN ? = ? THEN ? WHEN status = ? THEN ? ELSE status END",
+			availability, enums.AvailabilityInoperative, enums.ChargePointStatusUnavailable,
+			enums.ChargePointStatusUnavailable, enums.ChargePointStatusAvailable)
+	}
+	if _, err := query.Exec(ctx); err != nil {
+		r.log.WithError(err).Error("Failed to update connector availability")
+		return err
+	}
+	return nil
+}
+
+// ListByChargePoint returns the connectors of a charge point ordered by connector id
+func (r *ConnectorRepository) ListByChargePoint(ctx context.Context, chargePointID uuid.UUID) ([]*models.Connector, error) {
+	var connectors []*models.Connector
+	err := r.db.NewSelect().
+		Model(&connectors).
+		Where("charge_point_id = ?", chargePointID).
+		OrderExpr("length(connector_id), connector_id").
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to list connectors")
+		return nil, err
+	}
+	return connectors, nil
+}
+
+// ListStatusHistory returns the reported connector statuses matching the filter, most recent first
+func (r *ConnectorRepository) ListStatusHistory(ctx context.Context, filter ConnectorStatusFilter) ([]*models.ConnectorStatusHistory, error) {
+	var history []*models.ConnectorStatusHistory
+	query := r.db.NewSelect().
+		Model(&history).
+		Where("charge_point_id = ?", filter.ChargePointID)
+
+	if filter.ConnectorID != nil {
+		query = query.Where("connector_id = ?", *filter.ConnectorID)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("timestamp >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("timestamp < ?", filter.To)
+	}
+
+	err := query.
+		Order("timestamp DESC").
+		Limit(filter.Limit).
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to list connector status history")
+		return nil, err
+	}
+	return history, nil
+}
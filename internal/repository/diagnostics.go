@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+// activeDiagnosticsStatuses are the statuses of uploads the charge point is still working on
+var activeDiagnosticsStatuses = []enums.DiagnosticsStatus{
+	enums.DiagnosticsStatusRequested,
+	enums.DiagnosticsStatusUploading,
+}
+
+type DiagnosticsRepository struct {
+	db  *bun.DB
+	log *logrus.Logger
+}
+
+func NewDiagnosticsRepository(db *bun.DB, log *logrus.Logger) *DiagnosticsRepository {
+	return &DiagnosticsRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Create creates a new diagnostics upload
+func (r *DiagnosticsRepository) Create(ctx context.Context, diagnostics *models.Diagnostics) error {
+	err := r.db.NewInsert().
+		Model(diagnostics).
+		Returning("*").
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to create diagnostics")
+		return err
+	}
+	return nil
+}
+
+// GetByID retrieves a diagnostics upload by its ID
+func (r *DiagnosticsRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Diagnostics, error) {
+	diagnostics := &models.Diagnostics{}
+	err := r.db.NewSelect().
+		Model(diagnostics).
+		Where("id = ?", id).
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to get diagnostics by ID")
+		return nil, err
+	}
+	return diagnostics, nil
+}
+
+// List returns the diagnostics uploads of a charge point with the given status,
+// newest first; a nil charge point ID or an empty status matches all
+func (r *DiagnosticsRepository) List(ctx context.Context, chargePointID uuid.UUID, status enums.DiagnosticsStatus) ([]*models.Diagnostics, error) {
+	var diagnostics []*models.Diagnostics
+	query := r.db.NewSelect().Model(&diagnostics)
+	if chargePointID != uuid.Nil {
+		query = query.Where("charge_point_id = ?", chargePointID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.
+		Order("created_at DESC").
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to list diagnostics")
+		return nil, err
+	}
+	return diagnostics, nil
+}
+
+// FindActive returns the latest upload a charge point is working on, the one
+// with the given request id when it is known. It returns nil when there is none.
+func (r *DiagnosticsRepository) FindActive(ctx context.Context, chargePointID uuid.UUID, requestID *int) (*models.Diagnostics, error) {
+	diagnostics := &models.Diagnostics{}
+	query := r.db.NewSelect().
+		Model(diagnostics).
+		Where("charge_point_id = ?", chargePointID).
+		Where("status IN (?)", bun.In(activeDiagnosticsStatuses))
+	if requestID != nil {
+		query = query.Where("request_id = ?", *requestID)
+	}
+	err := query.
+		Order("created_at DESC").
+		Limit(1).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		r.log.WithError(err).Error("Failed to find active diagnostics")
+		return nil, err
+	}
+	return diagnostics, nil
+}
+
+// Update stores the progress of a diagnostics upload
+func (r *DiagnosticsRepository) Update(ctx context.Context, diagnostics *models.Diagnostics) error {
+	diagnostics.UpdatedAt = time.Now()
+	_, err := r.db.NewUpdate().
+		Model(diagnostics).
+		Column("status", "upload_status", "file_name", "size", "error", "uploaded_at", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to update diagnostics")
+		return err
+	}
+	return nil
+}
+
+// FailRequestedBefore fails the uploads requested before the given time that
+// have not ended, and returns how many were failed
+func (r *DiagnosticsRepository) FailRequestedBefore(ctx context.Context, before time.Time, reason string) (int64, error) {
+	res, err := r.db.NewUpdate().
+		Model((*models.Diagnostics)(nil)).
+		Set("status = ?", enums.DiagnosticsStatusFailed).
+		Set("error = ?", reason).
+		Set("updated_at = ?", time.Now()).
+		Where("status IN (?)", bun.In(activeDiagnosticsStatuses)).
+		Where("created_at < ?", before).
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to fail timed out diagnostics")
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// ListBefore returns the diagnostics uploads requested before the given time
+func (r *DiagnosticsRepository) ListBefore(ctx context.Context, before time.Time) ([]*models.Diagnostics, error) {
+	var diagnostics []*models.Diagnostics
+	err := r.db.NewSelect().
+		Model(&diagnostics).
+		Where("created_at < ?", before).
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to list expired diagnostics")
+		return nil, err
+	}
+	return diagnostics, nil
+}
+
+// Delete deletes diagnostics uploads by their IDs
+func (r *DiagnosticsRepository) Delete(ctx context.Context, ids ...uuid.UUID) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	_, err := r.db.NewDelete().
+		Model((*models.Diagnostics)(nil)).
+		Where("id IN (?)", bun.In(ids)).
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to delete diagnostics")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+type FirmwareArtifactRepository struct {
+	db  *bun.DB
+	log *logrus.Logger
+}
+
+func NewFirmwareArtifactRepository(db *bun.DB, log *logrus.Logger) *FirmwareArtifactRepository {
+	return &FirmwareArtifactRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Create creates a new firmware artifact
+func (r *FirmwareArtifactRepository) Create(ctx context.Context, artifact *models.FirmwareArtifact) error {
+	err := r.db.NewInsert().
+		Model(artifact).
+		Returning("*").
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to create firmware artifact")
+		return err
+	}
+	return nil
+}
+
+// GetByID retrieves a firmware artifact by its ID
+func (r *FirmwareArtifactRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.FirmwareArtifact, error) {
+	artifact := &models.FirmwareArtifact{}
+	err := r.db.NewSelect().
+		Model(artifact).
+		Where("id = ?", id).
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to get firmware artifact by ID")
+		return nil, err
+	}
+	return artifact, nil
+}
+
+// List returns the firmware artifacts of a vendor, of all vendors when vendor is empty
+func (r *FirmwareArtifactRepository) List(ctx context.Context, vendor string) ([]*models.FirmwareArtifact, error) {
+	var artifacts []*models.FirmwareArtifact
+	query := r.db.NewSelect().Model(&artifacts)
+	if vendor != "" {
+		query = query.Where("vendor = ?", vendor)
+	}
+	err := query.
+		Order("vendor ASC", "created_at DESC").
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to list firmware artifacts")
+		return nil, err
+	}
+	return artifacts, nil
+}
+
+// Delete deletes a firmware artifact by its ID
+func (r *FirmwareArtifactRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	_, err := r.db.NewDelete().
+		Model((*models.FirmwareArtifact)(nil)).
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to delete firmware artifact")
+		return err
+	}
+	return nil
+}
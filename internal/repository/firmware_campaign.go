@@ -0,0 +1,227 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+// activeFirmwareUpdateStatuses are the statuses of an update the charge point is working on
+var activeFirmwareUpdateStatuses = []enums.FirmwareUpdateStatus{
+	enums.FirmwareUpdateStatusSent,
+	enums.FirmwareUpdateStatusDownloading,
+	enums.FirmwareUpdateStatusDownloaded,
+	enums.FirmwareUpdateStatusInstalling,
+}
+
+// FirmwareTargetFilter narrows down a campaign target query; zero values are ignored
+type FirmwareTargetFilter struct {
+	Wave   int
+	Status enums.FirmwareUpdateStatus
+}
+
+type FirmwareCampaignRepository struct {
+	db  *bun.DB
+	log *logrus.Logger
+}
+
+func NewFirmwareCampaignRepository(db *bun.DB, log *logrus.Logger) *FirmwareCampaignRepository {
+	return &FirmwareCampaignRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Create creates a campaign together with its targets
+func (r *FirmwareCampaignRepository) Create(ctx context.Context, campaign *models.FirmwareCampaign, targets []*models.FirmwareCampaignTarget) error {
+	err := r.db.RunInTx(ctx, &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(campaign).Exec(ctx); err != nil {
+			return err
+		}
+		for _, target := range targets {
+			target.CampaignID = campaign.ID
+		}
+		_, err := tx.NewInsert().Model(&targets).Exec(ctx)
+		return err
+	})
+	if err != nil {
+		r.log.WithError(err).Error("Failed to create firmware campaign")
+		return err
+	}
+	return nil
+}
+
+// GetByID retrieves a campaign with its artifact by its ID
+func (r *FirmwareCampaignRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.FirmwareCampaign, error) {
+	campaign := &models.FirmwareCampaign{}
+	err := r.db.NewSelect().
+		Model(campaign).
+		Relation("Artifact").
+		Where("fc.id = ?", id).
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to get firmware campaign by ID")
+		return nil, err
+	}
+	return campaign, nil
+}
+
+// List returns the campaigns with the given status, all campaigns when status is empty, most recent first
+func (r *FirmwareCampaignRepository) List(ctx context.Context, status enums.FirmwareCampaignStatus) ([]*models.FirmwareCampaign, error) {
+	var campaigns []*models.FirmwareCampaign
+	query := r.db.NewSelect().
+		Model(&campaigns).
+		Relation("Artifact")
+	if status != "" {
+		query = query.Where("fc.status = ?", status)
+	}
+	err := query.
+		Order("fc.created_at DESC").
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to list firmware campaigns")
+		return nil, err
+	}
+	return campaigns, nil
+}
+
+// CountByArtifact returns the number of campaigns rolling out an artifact
+func (r *FirmwareCampaignRepository) CountByArtifact(ctx context.Context, artifactID uuid.UUID) (int, error) {
+	count, err := r.db.NewSelect().
+		Model((*models.FirmwareCampaign)(nil)).
+		Where("artifact_id = ?", artifactID).
+		Count(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to count firmware campaigns")
+		return 0, err
+	}
+	return count, nil
+}
+
+// UpdateState stores the status and wave of a campaign
+func (r *FirmwareCampaignRepository) UpdateState(ctx context.Context, campaign *models.FirmwareCampaign) error {
+	campaign.UpdatedAt = time.Now()
+	_, err := r.db.NewUpdate().
+		Model(campaign).
+		Column("status", "current_wave", "status_reason", "wave_started_at", "completed_at", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to update firmware campaign")
+		return err
+	}
+	return nil
+}
+
+// ListTargets returns the targets of a campaign matching the filter, by wave
+func (r *FirmwareCampaignRepository) ListTargets(ctx context.Context, campaignID uuid.UUID, filter FirmwareTargetFilter) ([]*models.FirmwareCampaignTarget, error) {
+	var targets []*models.FirmwareCampaignTarget
+	query := r.db.NewSelect().
+		Model(&targets).
+		Relation("ChargePoint").
+		Where("fct.campaign_id = ?", campaignID)
+	if filter.Wave > 0 {
+		query = query.Where("fct.wave = ?", filter.Wave)
+	}
+	if filter.Status != "" {
+		query = query.Where("fct.status = ?", filter.Status)
+	}
+	err := query.
+		Order("fct.wave ASC", "charge_point.code ASC").
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to list firmware campaign targets")
+		return nil, err
+	}
+	return targets, nil
+}
+
+// CountTargets returns the number of targets of a campaign by status
+func (r *FirmwareCampaignRepository) CountTargets(ctx context.Context, campaignID uuid.UUID) (map[enums.FirmwareUpdateStatus]int, error) {
+	var rows []struct {
+		Status enums.FirmwareUpdateStatus `bun:"status"`
+		Count  int                        `bun:"count"`
+	}
+	err := r.db.NewSelect().
+		Model((*models.FirmwareCampaignTarget)(nil)).
+		Column("status").
+		ColumnExpr("count(*) AS count").
+		Where("campaign_id = ?", campaignID).
+		Group("status").
+		Scan(ctx, &rows)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to count firmware campaign targets")
+		return nil, err
+	}
+	counts := make(map[enums.FirmwareUpdateStatus]int, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// FindActiveTarget returns the update a charge point is working on, the one
+// with the given request id when it is known. It returns nil when there is none.
+func (r *FirmwareCampaignRepository) FindActiveTarget(ctx context.Context, chargePointID uuid.UUID, requestID *int) (*models.FirmwareCampaignTarget, error) {
+	target := &models.FirmwareCampaignTarget{}
+	query := r.db.NewSelect().
+		Model(target).
+		Where("charge_point_id = ?", chargePointID).
+		Where("status IN (?)", bun.In(activeFirmwareUpdateStatuses))
+	if requestID != nil {
+		query = query.Where("request_id = ?", *requestID)
+	}
+	err := query.
+		Order("sent_at DESC").
+		Limit(1).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		r.log.WithError(err).Error("Failed to find active firmware update")
+		return nil, err
+	}
+	return target, nil
+}
+
+// UpdateTarget stores the progress of a target
+func (r *FirmwareCampaignRepository) UpdateTarget(ctx context.Context, target *models.FirmwareCampaignTarget) error {
+	target.UpdatedAt = time.Now()
+	_, err := r.db.NewUpdate().
+		Model(target).
+		Column("status", "firmware_status", "request_id", "error", "sent_at", "completed_at", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to update firmware campaign target")
+		return err
+	}
+	return nil
+}
+
+// CancelTargets cancels the targets of a campaign that have not been sent yet
+func (r *FirmwareCampaignRepository) CancelTargets(ctx context.Context, campaignID uuid.UUID) error {
+	now := time.Now()
+	_, err := r.db.NewUpdate().
+		Model((*models.FirmwareCampaignTarget)(nil)).
+		Set("status = ?", enums.FirmwareUpdateStatusCancelled).
+		Set("completed_at = ?", now).
+		Set("updated_at = ?", now).
+		Where("campaign_id = ?", campaignID).
+		Where("status = ?", enums.FirmwareUpdateStatusPending).
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to cancel firmware campaign targets")
+		return err
+	}
+	return nil
+}
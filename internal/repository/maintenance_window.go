@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+// openMaintenanceWindowStatuses are the statuses of a window that still acts on its charge points
+var openMaintenanceWindowStatuses = []enums.MaintenanceWindowStatus{
+	enums.MaintenanceWindowStatusScheduled,
+	enums.MaintenanceWindowStatusActive,
+	enums.MaintenanceWindowStatusRestoring,
+}
+
+type MaintenanceWindowRepository struct {
+	db  *bun.DB
+	log *logrus.Logger
+}
+
+func NewMaintenanceWindowRepository(db *bun.DB, log *logrus.Logger) *MaintenanceWindowRepository {
+	return &MaintenanceWindowRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Create creates a maintenance window together with its targets
+func (r *MaintenanceWindowRepository) Create(ctx context.Context, window *models.MaintenanceWindow, targets []*models.MaintenanceWindowTarget) error {
+	err := r.db.RunInTx(ctx, &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(window).Exec(ctx); err != nil {
+			return err
+		}
+		for _, target := range targets {
+			target.WindowID = window.ID
+		}
+		_, err := tx.NewInsert().Model(&targets).Exec(ctx)
+		return err
+	})
+	if err != nil {
+		r.log.WithError(err).Error("Failed to create maintenance window")
+		return err
+	}
+	return nil
+}
+
+// GetByID retrieves a maintenance window by its ID
+func (r *MaintenanceWindowRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.MaintenanceWindow, error) {
+	window := &models.MaintenanceWindow{}
+	err := r.db.NewSelect().
+		Model(window).
+		Where("id = ?", id).
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to get maintenance window by ID")
+		return nil, err
+	}
+	return window, nil
+}
+
+// List returns the windows with the given status, all windows when status is empty, latest start first
+func (r *MaintenanceWindowRepository) List(ctx context.Context, status enums.MaintenanceWindowStatus) ([]*models.MaintenanceWindow, error) {
+	var windows []*models.MaintenanceWindow
+	query := r.db.NewSelect().Model(&windows)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.
+		Order("starts_at DESC").
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to list maintenance windows")
+		return nil, err
+	}
+	return windows, nil
+}
+
+// ListOpen returns the windows that are scheduled, active or restoring, by start
+func (r *MaintenanceWindowRepository) ListOpen(ctx context.Context) ([]*models.MaintenanceWindow, error) {
+	var windows []*models.MaintenanceWindow
+	err := r.db.NewSelect().
+		Model(&windows).
+		Where("status IN (?)", bun.In(openMaintenanceWindowStatuses)).
+		Order("starts_at ASC").
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to list open maintenance windows")
+		return nil, err
+	}
+	return windows, nil
+}
+
+// CountOverlapping returns the number of charge points among the given ones
+// that are already part of an open window overlapping the period, or of a
+// window still restoring its charge points
+func (r *MaintenanceWindowRepository) CountOverlapping(ctx context.Context, chargePointIDs []uuid.UUID, startsAt, endsAt time.Time) (int, error) {
+	count, err := r.db.NewSelect().
+		Model((*models.MaintenanceWindowTarget)(nil)).
+		Join("JOIN maintenance_windows AS mw ON mw.id = mwt.window_id").
+		Where("mwt.charge_point_id IN (?)", bun.In(chargePointIDs)).
+		Where("mw.status IN (?)", bun.In(openMaintenanceWindowStatuses)).
+		WhereGroup(" AND ", func(q *bun.SelectQuery) *bun.SelectQuery {
+			return q.
+				Where("mw.status = ?", enums.MaintenanceWindowStatusRestoring).
+				WhereOr("mw.starts_at < ? AND mw.ends_at > ?", endsAt, startsAt)
+		}).
+		Count(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to count overlapping maintenance windows")
+		return 0, err
+	}
+	return count, nil
+}
+
+// UpdateState stores the status of a window
+func (r *MaintenanceWindowRepository) UpdateState(ctx context.Context, window *models.MaintenanceWindow) error {
+	window.UpdatedAt = time.Now()
+	_, err := r.db.NewUpdate().
+		Model(window).
+		Column("status", "completed_at", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to update maintenance window")
+		return err
+	}
+	return nil
+}
+
+// UpdateEnd stores the end of a window
+func (r *MaintenanceWindowRepository) UpdateEnd(ctx context.Context, window *models.MaintenanceWindow) error {
+	window.UpdatedAt = time.Now()
+	_, err := r.db.NewUpdate().
+		Model(window).
+		Column("ends_at", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to update maintenance window end")
+		return err
+	}
+	return nil
+}
+
+// ListTargets returns the targets of a window with the given status, all of
+// them when status is empty, by charge point code
+func (r *MaintenanceWindowRepository) ListTargets(ctx context.Context, windowID uuid.UUID, status enums.MaintenanceTargetStatus) ([]*models.MaintenanceWindowTarget, error) {
+	var targets []*models.MaintenanceWindowTarget
+	query := r.db.NewSelect().
+		Model(&targets).
+		Relation("ChargePoint").
+		Where("mwt.window_id = ?", windowID)
+	if status != "" {
+		query = query.Where("mwt.status = ?", status)
+	}
+	err := query.
+		Order("charge_point.code ASC").
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to list maintenance window targets")
+		return nil, err
+	}
+	return targets, nil
+}
+
+// CountTargets returns the number of targets of a window by status
+func (r *MaintenanceWindowRepository) CountTargets(ctx context.Context, windowID uuid.UUID) (map[enums.MaintenanceTargetStatus]int, error) {
+	var rows []struct {
+		Status enums.MaintenanceTargetStatus `bun:"status"`
+		Count  int                           `bun:"count"`
+	}
+	err := r.db.NewSelect().
+		Model((*models.MaintenanceWindowTarget)(nil)).
+		Column("status").
+		ColumnExpr("count(*) AS count").
+		Where("window_id = ?", windowID).
+		Group("status").
+		Scan(ctx, &rows)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to count maintenance window targets")
+		return nil, err
+	}
+	counts := make(map[enums.MaintenanceTargetStatus]int, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// UpdateTarget stores the availability of a target
+func (r *MaintenanceWindowRepository) UpdateTarget(ctx context.Context, target *models.MaintenanceWindowTarget) error {
+	target.UpdatedAt = time.Now()
+	_, err := r.db.NewUpdate().
+		Model(target).
+		Column("status", "error", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to update maintenance window target")
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+// MeterValueFilter narrows down a meter value series query; zero values are ignored
+type MeterValueFilter struct {
+	ChargePointID uuid.UUID
+	ConnectorID   *int
+	TransactionID int
+	Measurand     string
+	From          time.Time
+	To            time.Time
+	Limit         int
+}
+
+type MeterValueRepository struct {
+	db  *bun.DB
+	log *logrus.Logger
+}
+
+func NewMeterValueRepository(db *bun.DB, log *logrus.Logger) *MeterValueRepository {
+	return &MeterValueRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// BulkCreate inserts a batch of meter values in a single statement
+func (r *MeterValueRepository) BulkCreate(ctx context.Context, values []*models.MeterValue) error {
+	if len(values) == 0 {
+		return nil
+	}
+	_, err := r.db.NewInsert().
+		Model(&values).
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Errorf("Failed to insert %d meter values", len(values))
+		return err
+	}
+	return nil
+}
+
+// List returns the meter values matching the filter, ordered by sample time
+func (r *MeterValueRepository) List(ctx context.Context, filter MeterValueFilter) ([]*models.MeterValue, error) {
+	var values []*models.MeterValue
+	query := r.db.NewSelect().
+		Model(&values).
+		Where("charge_point_id = ?", filter.ChargePointID)
+
+	if filter.ConnectorID != nil {
+		query = query.Where("connector_id = ?", *filter.ConnectorID)
+	}
+	if filter.TransactionID != 0 {
+		query = query.Where("transaction_id = ?", filter.TransactionID)
+	}
+	if filter.Measurand != "" {
+		query = query.Where("measurand = ?", filter.Measurand)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("timestamp >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("timestamp < ?", filter.To)
+	}
+
+	err := query.
+		Order("timestamp ASC").
+		Limit(filter.Limit).
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to list meter values")
+		return nil, err
+	}
+	return values, nil
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+// OCPPMessageFilter narrows down a message journal query; zero values are ignored
+type OCPPMessageFilter struct {
+	Identity    string
+	Direction   enums.MessageDirection
+	MessageType int
+	Action      string
+	UniqueID    string
+	ErrorsOnly  bool
+	From        time.Time
+	To          time.Time
+	Limit       int
+	Ascending   bool // oldest first, as needed to replay a session
+}
+
+// OCPPMessageRepository stores the message journal. Entries are only ever
+// inserted and removed once they are past the retention period.
+type OCPPMessageRepository struct {
+	db  *bun.DB
+	log *logrus.Logger
+}
+
+func NewOCPPMessageRepository(db *bun.DB, log *logrus.Logger) *OCPPMessageRepository {
+	return &OCPPMessageRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// BulkCreate inserts a batch of messages in a single statement
+func (r *OCPPMessageRepository) BulkCreate(ctx context.Context, messages []*models.OCPPMessage) error {
+	if len(messages) == 0 {
+		return nil
+	}
+	_, err := r.db.NewInsert().
+		Model(&messages).
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Errorf("Failed to insert %d OCPP messages", len(messages))
+		return err
+	}
+	return nil
+}
+
+// List returns the messages matching the filter, most recent first unless Ascending is set
+func (r *OCPPMessageRepository) List(ctx context.Context, filter OCPPMessageFilter) ([]*models.OCPPMessage, error) {
+	var messages []*models.OCPPMessage
+	query := r.db.NewSelect().Model(&messages)
+
+	if filter.Identity != "" {
+		query = query.Where("identity = ?", filter.Identity)
+	}
+	if filter.Direction != "" {
+		query = query.Where("direction = ?", filter.Direction)
+	}
+	if filter.MessageType != 0 {
+		query = query.Where("message_type = ?", filter.MessageType)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.UniqueID != "" {
+		query = query.Where("unique_id = ?", filter.UniqueID)
+	}
+	if filter.ErrorsOnly {
+		query = query.Where("error IS NOT NULL")
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("timestamp >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("timestamp < ?", filter.To)
+	}
+
+	order := "timestamp DESC"
+	if filter.Ascending {
+		order = "timestamp ASC"
+	}
+	err := query.
+		Order(order).
+		Limit(filter.Limit).
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to list OCPP messages")
+		return nil, err
+	}
+	return messages, nil
+}
+
+// DeleteBefore removes the messages recorded before the given time and returns how many were removed
+func (r *OCPPMessageRepository) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	res, err := r.db.NewDelete().
+		Model((*models.OCPPMessage)(nil)).
+		Where("timestamp < ?", before).
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to delete expired OCPP messages")
+		return 0, err
+	}
+	return res.RowsAffected()
+}
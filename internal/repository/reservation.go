@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+// openReservationStatuses are the statuses of reservations that still hold a connector
+var openReservationStatuses = []enums.ReservationStatus{
+	enums.ReservationStatusRequested,
+	enums.ReservationStatusActive,
+}
+
+// ErrConnectorReserved is returned by Create when another open reservation
+// holds the connector. A reservation of connector 0 holds the whole charge
+// point, so it conflicts with the open reservations of any of its connectors.
+var ErrConnectorReserved = errors.New("connector is held by another reservation")
+
+type ReservationRepository struct {
+	db  *bun.DB
+	log *logrus.Logger
+}
+
+func NewReservationRepository(db *bun.DB, log *logrus.Logger) *ReservationRepository {
+	return &ReservationRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Create creates a new reservation, which gets its OCPP reservation id from the
+// database. The reservations of a charge point are created one at a time, so
+// the check for conflicting reservations holds until the new one is stored.
+func (r *ReservationRepository) Create(ctx context.Context, reservation *models.Reservation) error {
+	err := r.db.RunInTx(ctx, &sql.TxOptions{}, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext(?))", "reservations:"+reservation.ChargePointID.String()); err != nil {
+			return err
+		}
+		query := tx.NewSelect().
+			Model((*models.Reservation)(nil)).
+			Where("charge_point_id = ?", reservation.ChargePointID).
+			Where("status IN (?)", bun.In(openReservationStatuses))
+		if reservation.ConnectorID > 0 {
+			query = query.Where("connector_id IN (0, ?)", reservation.ConnectorID)
+		}
+		reserved, err := query.Exists(ctx)
+		if err != nil {
+			return err
+		}
+		if reserved {
+			return ErrConnectorReserved
+		}
+		return tx.NewInsert().
+			Model(reservation).
+			Returning("*").
+			Scan(ctx)
+	})
+	if errors.Is(err, ErrConnectorReserved) {
+		return err
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23505" && pgErr.ConstraintName == "idx_reservations_connector" {
+		return ErrConnectorReserved
+	}
+	if err != nil {
+		r.log.WithError(err).Error("Failed to create reservation")
+		return err
+	}
+	return nil
+}
+
+// GetByID retrieves a reservation by its ID
+func (r *ReservationRepository) GetByID(ctx context.Context, id uuid.UUID) (*models.Reservation, error) {
+	reservation := &models.Reservation{}
+	err := r.db.NewSelect().
+		Model(reservation).
+		Where("id = ?", id).
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to get reservation by ID")
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// GetByReservationID retrieves a reservation of a charge point by its OCPP
+// reservation id. It returns nil when there is none.
+func (r *ReservationRepository) GetByReservationID(ctx context.Context, chargePointID uuid.UUID, reservationID int) (*models.Reservation, error) {
+	reservation := &models.Reservation{}
+	err := r.db.NewSelect().
+		Model(reservation).
+		Where("charge_point_id = ?", chargePointID).
+		Where("reservation_id = ?", reservationID).
+		Scan(ctx)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		r.log.WithError(err).Error("Failed to get reservation by reservation id")
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// List returns the reservations of a charge point with the given status,
+// newest first; a nil charge point ID or an empty status matches all
+func (r *ReservationRepository) List(ctx context.Context, chargePointID uuid.UUID, status enums.ReservationStatus) ([]*models.Reservation, error) {
+	var reservations []*models.Reservation
+	query := r.db.NewSelect().Model(&reservations)
+	if chargePointID != uuid.Nil {
+		query = query.Where("charge_point_id = ?", chargePointID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.
+		Order("created_at DESC").
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to list reservations")
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// ListExpired returns the reservations still holding a connector that expired before the given time
+func (r *ReservationRepository) ListExpired(ctx context.Context, before time.Time) ([]*models.Reservation, error) {
+	var reservations []*models.Reservation
+	err := r.db.NewSelect().
+		Model(&reservations).
+		Where("status IN (?)", bun.In(openReservationStatuses)).
+		Where("expires_at <= ?", before).
+		Order("expires_at ASC").
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to list expired reservations")
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// Update stores the state of a reservation
+func (r *ReservationRepository) Update(ctx context.Context, reservation *models.Reservation) error {
+	reservation.UpdatedAt = time.Now()
+	_, err := r.db.NewUpdate().
+		Model(reservation).
+		Column("status", "transaction_id", "error", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to update reservation")
+		return err
+	}
+	return nil
+}
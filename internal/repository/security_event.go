@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+// SecurityEventFilter narrows down a security event query; zero values are ignored
+type SecurityEventFilter struct {
+	ChargePointID uuid.UUID
+	Type          string
+	Severities    []enums.SecurityEventSeverity
+	Alert         *bool
+	From          time.Time
+	To            time.Time
+	Limit         int
+}
+
+type SecurityEventRepository struct {
+	db  *bun.DB
+	log *logrus.Logger
+}
+
+func NewSecurityEventRepository(db *bun.DB, log *logrus.Logger) *SecurityEventRepository {
+	return &SecurityEventRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+func (r *SecurityEventRepository) Create(ctx context.Context, event *models.SecurityEvent) error {
+	event.CreatedAt = time.Now()
+	_, err := r.db.NewInsert().
+		Model(event).
+		Returning("*").
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to create security event")
+		return err
+	}
+	return nil
+}
+
+// List returns the security events matching the filter, most recent first
+func (r *SecurityEventRepository) List(ctx context.Context, filter SecurityEventFilter) ([]*models.SecurityEvent, error) {
+	var events []*models.SecurityEvent
+	query := r.db.NewSelect().Model(&events)
+
+	if filter.ChargePointID != uuid.Nil {
+		query = query.Where("charge_point_id = ?", filter.ChargePointID)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+	if len(filter.Severities) > 0 {
+		query = query.Where("severity IN (?)", bun.In(filter.Severities))
+	}
+	if filter.Alert != nil {
+		query = query.Where("alert = ?", *filter.Alert)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("timestamp >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("timestamp < ?", filter.To)
+	}
+
+	err := query.
+		Order("timestamp DESC").
+		Limit(filter.Limit).
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to list security events")
+		return nil, err
+	}
+	return events, nil
+}
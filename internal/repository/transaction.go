@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"github.com/uptrace/bun"
+
+	"github.com/mutoulbj/gocsms/internal/models"
+)
+
+type TransactionRepository struct {
+	db  *bun.DB
+	log *logrus.Logger
+}
+
+func NewTransactionRepository(db *bun.DB, log *logrus.Logger) *TransactionRepository {
+	return &TransactionRepository{
+		db:  db,
+		log: log,
+	}
+}
+
+// Create inserts a new transaction; the OCPP transaction id is assigned by the database
+func (r *TransactionRepository) Create(ctx context.Context, tx *models.Transaction) error {
+	err := r.db.NewInsert().
+		Model(tx).
+		Returning("*").
+		Scan(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to create transaction")
+		return err
+	}
+	return nil
+}
+
+// GetByTransactionID retrieves a transaction by its OCPP transaction id
+func (r *TransactionRepository) GetByTransactionID(ctx context.Context, transactionID int) (*models.Transaction, error) {
+	tx := &models.Transaction{}
+	err := r.db.NewSelect().
+		Model(tx).
+		Where("transaction_id = ?", transactionID).
+		Scan(ctx)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.log.WithError(err).Error("Failed to get transaction by transaction ID")
+		return nil, err
+	}
+	return tx, nil
+}
+
+// GetByChargePointTransactionID retrieves a transaction of a charge point by its OCPP transaction id
+func (r *TransactionRepository) GetByChargePointTransactionID(ctx context.Context, chargePointID uuid.UUID, transactionID int) (*models.Transaction, error) {
+	tx := &models.Transaction{}
+	err := r.db.NewSelect().
+		Model(tx).
+		Where("charge_point_id = ?", chargePointID).
+		Where("transaction_id = ?", transactionID).
+		Scan(ctx)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.log.WithError(err).Error("Failed to get transaction by charge point and transaction ID")
+		return nil, err
+	}
+	return tx, nil
+}
+
+// GetByChargerTxID retrieves a transaction by the id an OCPP 2.0.1 charging station assigned to it
+func (r *TransactionRepository) GetByChargerTxID(ctx context.Context, chargePointID uuid.UUID, chargerTxID string) (*models.Transaction, error) {
+	tx := &models.Transaction{}
+	err := r.db.NewSelect().
+		Model(tx).
+		Where("charge_point_id = ?", chargePointID).
+		Where("charger_transaction_id = ?", chargerTxID).
+		Scan(ctx)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.log.WithError(err).Error("Failed to get transaction by charger transaction ID")
+		return nil, err
+	}
+	return tx, nil
+}
+
+// GetActiveByIdTag retrieves the ongoing transaction started with the given id tag, if any
+func (r *TransactionRepository) GetActiveByIdTag(ctx context.Context, idTag string) (*models.Transaction, error) {
+	tx := &models.Transaction{}
+	err := r.db.NewSelect().
+		Model(tx).
+		Where("id_tag = ?", idTag).
+		Where("stop_time IS NULL").
+		Order("start_time DESC").
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		r.log.WithError(err).Error("Failed to get active transaction by id tag")
+		return nil, err
+	}
+	return tx, nil
+}
+
+// Stop persists the stop fields of a transaction
+func (r *TransactionRepository) Stop(ctx context.Context, tx *models.Transaction) error {
+	_, err := r.db.NewUpdate().
+		Model(tx).
+		Column("stop_time", "meter_stop", "total_energy_kwh", "stop_reason", "updated_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		r.log.WithError(err).Error("Failed to stop transaction")
+		return err
+	}
+	return nil
+}
@@ -1,28 +1,147 @@
 package services
 
 import (
+	"cmp"
 	"context"
+	"errors"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
 
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/enums"
 	"github.com/mutoulbj/gocsms/internal/models"
 	"github.com/mutoulbj/gocsms/internal/repository"
 )
 
+var ErrPasswordRequired = errors.New("security profiles 1 and 2 require a password")
+
 type ChargePointService struct {
-	repo *repository.ChargePointRepository
-	log  *logrus.Logger
+	repo          *repository.ChargePointRepository
+	connRepo      *repository.ChargePointConnectionRepository
+	connectorRepo *repository.ConnectorRepository
+	log           *logrus.Logger
 }
 
-func NewChargePointService(repo *repository.ChargePointRepository, log *logrus.Logger) *ChargePointService {
-	return &ChargePointService{repo: repo, log: log}
+func NewChargePointService(
+	repo *repository.ChargePointRepository,
+	connRepo *repository.ChargePointConnectionRepository,
+	connectorRepo *repository.ConnectorRepository,
+	log *logrus.Logger,
+) *ChargePointService {
+	return &ChargePointService{repo: repo, connRepo: connRepo, connectorRepo: connectorRepo, log: log}
 }
 
 func (s *ChargePointService) Register(ctx context.Context, cp *models.ChargePoint) error {
 	return s.repo.Create(ctx, cp)
 }
 
+func (s *ChargePointService) GetByCode(ctx context.Context, code string) (*models.ChargePoint, error) {
+	return s.repo.GetByCode(ctx, code)
+}
+
+// Boot applies a BootNotification from the charge point with the given identity
+// and returns it with its registration status. Provisioned charge points are
+// accepted unless an operator rejected them or has yet to approve them. Unknown
+// ones are only registered when the policy is to accept them; otherwise they are
+// answered Pending or Rejected without being stored, until an operator
+// provisions them.
+func (s *ChargePointService) Boot(ctx context.Context, code string, boot *models.ChargePoint, policy string) (*models.ChargePoint, error) {
+	now := time.Now()
+	cp, err := s.repo.GetByCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	if cp == nil {
+		switch policy {
+		case config.RegistrationPolicyAccept:
+		case config.RegistrationPolicyReject:
+			s.log.Warnf("Rejected unknown charge point %s", code)
+			return &models.ChargePoint{Code: code, RegistrationStatus: enums.ChargePointRegistrationStatusRejected}, nil
+		default:
+			s.log.Warnf("Unknown charge point %s is pending until it is provisioned", code)
+			return &models.ChargePoint{Code: code, RegistrationStatus: enums.ChargePointRegistrationStatusPending}, nil
+		}
+		cp = &models.ChargePoint{
+			ID:                 uuid.New(),
+			Name:               code,
+			Code:               code,
+			SerialNumber:       boot.SerialNumber,
+			Vendor:             boot.Vendor,
+			Model:              boot.Model,
+			FirmwareVersion:    boot.FirmwareVersion,
+			OcppVersion:        boot.OcppVersion,
+			Status:             enums.ChargePointStatusUnknown,
+			RegistrationStatus: enums.ChargePointRegistrationStatusAccepted,
+			LastHeartbeat:      now,
+			Connected:          true,
+			RegisteredAt:       now,
+			CreatedAt:          now,
+			UpdatedAt:          now,
+		}
+		s.log.Infof("Registered unknown charge point %s", code)
+		return cp, s.repo.Create(ctx, cp)
+	}
+
+	switch cp.RegistrationStatus {
+	case enums.ChargePointRegistrationStatusPending, enums.ChargePointRegistrationStatusRejected:
+	default:
+		// provisioned and approved charge points are accepted
+		cp.RegistrationStatus = enums.ChargePointRegistrationStatusAccepted
+		if cp.RegisteredAt.IsZero() {
+			cp.RegisteredAt = now
+		}
+	}
+	cp.SerialNumber = cmp.Or(boot.SerialNumber, cp.SerialNumber)
+	cp.Vendor = boot.Vendor
+	cp.Model = boot.Model
+	cp.FirmwareVersion = boot.FirmwareVersion
+	cp.OcppVersion = boot.OcppVersion
+	cp.LastHeartbeat = now
+	return cp, s.repo.UpdateBoot(ctx, cp)
+}
+
+// UpdateRegistrationStatus lets an operator accept or reject a charge point; it
+// takes effect on the next BootNotification of the charge point
+func (s *ChargePointService) UpdateRegistrationStatus(ctx context.Context, id uuid.UUID, status enums.ChargePointRegistrationStatus) error {
+	return s.repo.UpdateRegistrationStatus(ctx, id, status)
+}
+
+// SetSecurityProfile configures the OCPP security profile of a charge point.
+// Profiles 1 and 2 authenticate with a Basic Auth password, which is kept
+// unchanged when none is given.
+func (s *ChargePointService) SetSecurityProfile(ctx context.Context, id uuid.UUID, profile int, password string) error {
+	var hash string
+	if password != "" {
+		hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		hash = string(hashed)
+	} else if profile == 1 || profile == 2 {
+		current, err := s.repo.GetPasswordHash(ctx, id)
+		if err != nil {
+			return err
+		}
+		if current == "" {
+			return ErrPasswordRequired
+		}
+	}
+	return s.repo.UpdateSecurity(ctx, id, profile, hash)
+}
+
+// VerifyPassword checks the Basic Auth password a charge point connects with
+func (s *ChargePointService) VerifyPassword(ctx context.Context, id uuid.UUID, password string) bool {
+	hash, err := s.repo.GetPasswordHash(ctx, id)
+	if err != nil || hash == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
+}
+
 func (s *ChargePointService) GetByID(ctx context.Context, id string) (*models.ChargePoint, error) {
 	return s.repo.GetByID(ctx, id)
 }
@@ -30,3 +149,113 @@ func (s *ChargePointService) GetByID(ctx context.Context, id string) (*models.Ch
 func (s *ChargePointService) UpdateStatus(ctx context.Context, id uuid.UUID, status string) error {
 	return s.repo.UpdateStatus(ctx, id, status)
 }
+
+// UpdateConnectorStatus records a StatusNotification. The status of connector 0
+// is the status of the charge point as a whole.
+func (s *ChargePointService) UpdateConnectorStatus(ctx context.Context, report *models.ConnectorStatusHistory) error {
+	if err := s.connectorRepo.UpdateStatus(ctx, report); err != nil {
+		return err
+	}
+	if report.ConnectorID == 0 {
+		if err := s.repo.UpdateStatus(ctx, report.ChargePointID, string(report.Status)); err != nil {
+			return err
+		}
+	}
+	return s.repo.SettleAvailability(ctx, report.ChargePointID)
+}
+
+// RecordAvailability stores the availability a charge point accepted with
+// ChangeAvailability, for itself as a whole when connectorID is 0. A scheduled
+// change takes effect once the running transactions have ended.
+func (s *ChargePointService) RecordAvailability(ctx context.Context, id uuid.UUID, connectorID int, availability enums.Availability, scheduled bool) error {
+	if connectorID == 0 {
+		return s.repo.UpdateAvailability(ctx, id, availability, scheduled)
+	}
+	return s.connectorRepo.UpdateAvailability(ctx, id, connectorID, availability, scheduled)
+}
+
+// ListConnectors returns the connectors of a charge point with their last reported status
+func (s *ChargePointService) ListConnectors(ctx context.Context, id uuid.UUID) ([]*models.Connector, error) {
+	return s.connectorRepo.ListByChargePoint(ctx, id)
+}
+
+// ListConnectorStatusHistory returns the statuses reported for the connectors of a charge point
+func (s *ChargePointService) ListConnectorStatusHistory(ctx context.Context, filter repository.ConnectorStatusFilter) ([]*models.ConnectorStatusHistory, error) {
+	return s.connectorRepo.ListStatusHistory(ctx, filter)
+}
+
+// Heartbeat records a heartbeat of the charge point
+func (s *ChargePointService) Heartbeat(ctx context.Context, id uuid.UUID) error {
+	return s.repo.UpdateHeartbeat(ctx, id)
+}
+
+// RecordActivity counts any traffic from the charge points with the given
+// identities as a heartbeat, so busy charge points are not taken for offline
+func (s *ChargePointService) RecordActivity(ctx context.Context, codes []string) error {
+	if len(codes) == 0 {
+		return nil
+	}
+	return s.repo.UpdateHeartbeats(ctx, codes)
+}
+
+// Connect marks the charge point connected and opens an entry in its connection history
+func (s *ChargePointService) Connect(ctx context.Context, id uuid.UUID, remoteAddress string) (*models.ChargePointConnection, error) {
+	if err := s.repo.UpdateConnected(ctx, id, true); err != nil {
+		return nil, err
+	}
+	conn := &models.ChargePointConnection{
+		ChargePointID: id,
+		ConnectedAt:   time.Now(),
+		RemoteAddress: remoteAddress,
+	}
+	return conn, s.connRepo.Create(ctx, conn)
+}
+
+// Disconnect closes the connection history entry, unless the offline watchdog
+// closed it already. The charge point is only marked disconnected when it has
+// not opened a newer connection meanwhile.
+func (s *ChargePointService) Disconnect(ctx context.Context, conn *models.ChargePointConnection, closeCode int, closeReason string, reconnected bool) error {
+	conn.DisconnectedAt = time.Now()
+	conn.CloseCode = closeCode
+	conn.CloseReason = closeReason
+	if err := s.connRepo.Close(ctx, conn); err != nil {
+		return err
+	}
+	if reconnected {
+		return nil
+	}
+	return s.repo.UpdateConnected(ctx, conn.ChargePointID, false)
+}
+
+// MarkOffline marks the charge points that have not sent a heartbeat since the
+// given time as offline and closes their connections, and returns the ones it marked
+func (s *ChargePointService) MarkOffline(ctx context.Context, before time.Time, closeCode int) ([]uuid.UUID, error) {
+	ids, err := s.repo.MarkOffline(ctx, before)
+	if err != nil {
+		return nil, err
+	}
+	return ids, s.connRepo.CloseOpen(ctx, ids, time.Now(), closeCode)
+}
+
+// ListConnections returns the most recent connections of a charge point
+func (s *ChargePointService) ListConnections(ctx context.Context, id uuid.UUID, limit int) ([]*models.ChargePointConnection, error) {
+	return s.connRepo.List(ctx, id, limit)
+}
+
+func (s *ChargePointService) ListByVendor(ctx context.Context, vendor, model string) ([]*models.ChargePoint, error) {
+	return s.repo.ListByVendor(ctx, vendor, model)
+}
+
+// ListByIDs returns the charge points with the given IDs
+func (s *ChargePointService) ListByIDs(ctx context.Context, ids []uuid.UUID) ([]*models.ChargePoint, error) {
+	return s.repo.ListByIDs(ctx, ids)
+}
+
+// ListByChargeStations returns the charge points of the given charge stations
+func (s *ChargePointService) ListByChargeStations(ctx context.Context, stationIDs []uuid.UUID) ([]*models.ChargePoint, error) {
+	return s.repo.ListByChargeStations(ctx, stationIDs)
+}
+
+func (s *ChargePointService) UpdateOcppVersion(ctx context.Context, id uuid.UUID, version string) error {
+	return s.repo.UpdateOcppVersion(ctx, id, version)
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/repository"
+)
+
+type ChargePointConfigurationService struct {
+	repo *repository.ChargePointConfigurationRepository
+	log  *logrus.Logger
+}
+
+func NewChargePointConfigurationService(repo *repository.ChargePointConfigurationRepository, log *logrus.Logger) *ChargePointConfigurationService {
+	return &ChargePointConfigurationService{repo: repo, log: log}
+}
+
+// SaveReported stores the configuration keys reported by a charge point
+func (s *ChargePointConfigurationService) SaveReported(ctx context.Context, chargePointID uuid.UUID, items []*models.ChargePointConfiguration) error {
+	for _, item := range items {
+		item.ChargePointID = chargePointID
+	}
+	return s.repo.UpsertReported(ctx, items)
+}
+
+// RecordChange stores the result of a ChangeConfiguration sent to a charge point
+func (s *ChargePointConfigurationService) RecordChange(ctx context.Context, chargePointID uuid.UUID, key, value, status string) error {
+	s.log.Infof("Configuration %s=%s on charge point %s: %s", key, value, chargePointID, status)
+	return s.repo.RecordChange(ctx, chargePointID, key, value, status)
+}
+
+// List returns the last-known configuration of a charge point
+func (s *ChargePointConfigurationService) List(ctx context.Context, chargePointID uuid.UUID) ([]*models.ChargePointConfiguration, error) {
+	return s.repo.ListByChargePoint(ctx, chargePointID)
+}
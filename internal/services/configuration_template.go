@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/dto"
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/repository"
+)
+
+type ConfigurationTemplateService struct {
+	repo      *repository.ConfigurationTemplateRepository
+	driftRepo *repository.ConfigurationDriftRepository
+	cfgSvc    *ChargePointConfigurationService
+	log       *logrus.Logger
+}
+
+func NewConfigurationTemplateService(
+	repo *repository.ConfigurationTemplateRepository,
+	driftRepo *repository.ConfigurationDriftRepository,
+	cfgSvc *ChargePointConfigurationService,
+	log *logrus.Logger,
+) *ConfigurationTemplateService {
+	return &ConfigurationTemplateService{repo: repo, driftRepo: driftRepo, cfgSvc: cfgSvc, log: log}
+}
+
+// Create creates a configuration template from request
+func (s *ConfigurationTemplateService) Create(ctx context.Context, req *dto.ConfigurationTemplateRequest) (*models.ConfigurationTemplate, error) {
+	s.log.Infof("Creating configuration template %s for %s/%s", req.Name, req.Vendor, req.Model)
+	tpl := &models.ConfigurationTemplate{
+		Name:   req.Name,
+		Vendor: req.Vendor,
+		Model:  req.Model,
+		Keys:   req.Keys,
+	}
+	if err := s.repo.Create(ctx, tpl); err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
+
+// Update replaces a configuration template. The drift from it is dropped until
+// the charge points are checked against the new keys.
+func (s *ConfigurationTemplateService) Update(ctx context.Context, id uuid.UUID, req *dto.ConfigurationTemplateRequest) (*models.ConfigurationTemplate, error) {
+	s.log.Infof("Updating configuration template with ID: %s", id)
+	tpl, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	tpl.Name = req.Name
+	tpl.Vendor = req.Vendor
+	tpl.Model = req.Model
+	tpl.Keys = req.Keys
+	if err := s.repo.Update(ctx, tpl); err != nil {
+		return nil, err
+	}
+	if err := s.driftRepo.DeleteByTemplate(ctx, id); err != nil {
+		return nil, err
+	}
+	return tpl, nil
+}
+
+// Delete deletes a configuration template together with the drift from it
+func (s *ConfigurationTemplateService) Delete(ctx context.Context, id uuid.UUID) error {
+	s.log.Infof("Deleting configuration template with ID: %s", id)
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	return s.driftRepo.DeleteByTemplate(ctx, id)
+}
+
+// GetByID retrieves a configuration template by its ID
+func (s *ConfigurationTemplateService) GetByID(ctx context.Context, id uuid.UUID) (*models.ConfigurationTemplate, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// List returns all configuration templates
+func (s *ConfigurationTemplateService) List(ctx context.Context) ([]*models.ConfigurationTemplate, error) {
+	return s.repo.List(ctx)
+}
+
+// FindFor returns the template that applies to a charge point, or nil if none does
+func (s *ConfigurationTemplateService) FindFor(ctx context.Context, cp *models.ChargePoint) (*models.ConfigurationTemplate, error) {
+	if cp.Vendor == "" {
+		return nil, nil
+	}
+	return s.repo.FindFor(ctx, cp.Vendor, cp.Model)
+}
+
+// Drift compares the stored configuration of a charge point with a template
+func (s *ConfigurationTemplateService) Drift(ctx context.Context, chargePointID uuid.UUID, tpl *models.ConfigurationTemplate) ([]*models.ConfigurationDrift, error) {
+	items, err := s.cfgSvc.List(ctx, chargePointID)
+	if err != nil {
+		return nil, err
+	}
+	actual := make(map[string]string, len(items))
+	for _, item := range items {
+		if !item.RefreshedAt.IsZero() {
+			actual[item.Key] = item.Value
+		}
+	}
+
+	now := time.Now()
+	var drifts []*models.ConfigurationDrift
+	for key, expected := range tpl.Keys {
+		value, ok := actual[key]
+		if ok && value == expected {
+			continue
+		}
+		drifts = append(drifts, &models.ConfigurationDrift{
+			ChargePointID: chargePointID,
+			TemplateID:    tpl.ID,
+			Key:           key,
+			Expected:      expected,
+			Actual:        value,
+			Missing:       !ok,
+			DetectedAt:    now,
+		})
+	}
+	return drifts, nil
+}
+
+// RecordDrift stores the drift detected on a charge point in place of the one
+// detected before; an empty drift clears it
+func (s *ConfigurationTemplateService) RecordDrift(ctx context.Context, chargePointID uuid.UUID, drifts []*models.ConfigurationDrift) error {
+	return s.driftRepo.Replace(ctx, chargePointID, drifts)
+}
+
+// DriftReport returns the drift of every charge point from its template as
+// last detected by the periodic drift detection
+func (s *ConfigurationTemplateService) DriftReport(ctx context.Context) ([]*models.ConfigurationDrift, error) {
+	return s.driftRepo.List(ctx)
+}
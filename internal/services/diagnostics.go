@@ -0,0 +1,201 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/repository"
+)
+
+var (
+	ErrDiagnosticsLogType     = errors.New("security logs can only be requested from OCPP 2.0.1 charge points")
+	ErrDiagnosticsUploaded    = errors.New("diagnostics have already been uploaded")
+	ErrDiagnosticsNotUploaded = errors.New("diagnostics have not been uploaded")
+)
+
+// DiagnosticsService keeps the diagnostics and logs uploaded by charge points.
+// The uploads are requested by the OCPP diagnostics manager.
+type DiagnosticsService struct {
+	repo *repository.DiagnosticsRepository
+	cfg  *config.OCPPConfig
+	log  *logrus.Logger
+}
+
+func NewDiagnosticsService(repo *repository.DiagnosticsRepository, cfg *config.OCPPConfig, log *logrus.Logger) *DiagnosticsService {
+	return &DiagnosticsService{
+		repo: repo,
+		cfg:  cfg,
+		log:  log,
+	}
+}
+
+// Create records a diagnostics upload requested from a charge point
+func (s *DiagnosticsService) Create(ctx context.Context, diagnostics *models.Diagnostics) error {
+	return s.repo.Create(ctx, diagnostics)
+}
+
+// Get retrieves a diagnostics upload by its ID
+func (s *DiagnosticsService) Get(ctx context.Context, id uuid.UUID) (*models.Diagnostics, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// List returns the diagnostics uploads of a charge point with the given status,
+// newest first; a nil charge point ID or an empty status matches all
+func (s *DiagnosticsService) List(ctx context.Context, chargePointID uuid.UUID, status enums.DiagnosticsStatus) ([]*models.Diagnostics, error) {
+	return s.repo.List(ctx, chargePointID, status)
+}
+
+// Update stores the progress of a diagnostics upload
+func (s *DiagnosticsService) Update(ctx context.Context, diagnostics *models.Diagnostics) error {
+	return s.repo.Update(ctx, diagnostics)
+}
+
+// Delete deletes a diagnostics upload and its file
+func (s *DiagnosticsService) Delete(ctx context.Context, id uuid.UUID) error {
+	diagnostics, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return err
+	}
+	if err := os.Remove(s.FilePath(diagnostics)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		s.log.WithError(err).Warnf("Failed to remove diagnostics file of %s", id)
+	}
+	return nil
+}
+
+// FilePath returns the path of the file of a diagnostics upload, stored in a
+// directory per charge point
+func (s *DiagnosticsService) FilePath(diagnostics *models.Diagnostics) string {
+	return filepath.Join(s.cfg.DiagnosticsDir, diagnostics.ChargePointID.String(), diagnostics.ID.String())
+}
+
+// File returns a diagnostics upload whose file has been received
+func (s *DiagnosticsService) File(ctx context.Context, id uuid.UUID) (*models.Diagnostics, error) {
+	diagnostics, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if diagnostics.Status != enums.DiagnosticsStatusUploaded {
+		return nil, ErrDiagnosticsNotUploaded
+	}
+	return diagnostics, nil
+}
+
+// StoreUpload stores the file a charge point uploaded for a diagnostics
+// request. Uploads arriving after the request failed or timed out are still
+// kept, but a stored file is never replaced.
+func (s *DiagnosticsService) StoreUpload(ctx context.Context, id uuid.UUID, fileName string, file io.Reader) (*models.Diagnostics, error) {
+	diagnostics, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if diagnostics.Status == enums.DiagnosticsStatusUploaded {
+		return nil, ErrDiagnosticsUploaded
+	}
+
+	dir := filepath.Dir(s.FilePath(diagnostics))
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	size, err := io.Copy(tmp, file)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), s.FilePath(diagnostics)); err != nil {
+		return nil, err
+	}
+
+	if fileName = filepath.Base(fileName); fileName != "." && fileName != string(filepath.Separator) {
+		diagnostics.FileName = fileName
+	}
+	if diagnostics.FileName == "" {
+		diagnostics.FileName = diagnostics.ID.String()
+	}
+	diagnostics.Size = size
+	diagnostics.Status = enums.DiagnosticsStatusUploaded
+	diagnostics.Error = ""
+	diagnostics.UploadedAt = time.Now()
+	if err := s.repo.Update(ctx, diagnostics); err != nil {
+		return nil, err
+	}
+	s.log.Infof("Stored %s of charge point %s (%d bytes)", diagnostics.LogType, diagnostics.ChargePointID, size)
+	return diagnostics, nil
+}
+
+// RecordStatus tracks a diagnostics or log upload status reported by a charge
+// point on the upload it is working on
+func (s *DiagnosticsService) RecordStatus(ctx context.Context, chargePointID uuid.UUID, status string, requestID *int) error {
+	diagnostics, err := s.repo.FindActive(ctx, chargePointID, requestID)
+	if err != nil || diagnostics == nil {
+		return err
+	}
+	diagnostics.UploadStatus = status
+	switch status {
+	case "Uploading", "Uploaded":
+		// the upload may still be in flight when the status arrives; StoreUpload
+		// completes it, or TimeOut fails it when the file never arrives
+		diagnostics.Status = enums.DiagnosticsStatusUploading
+	case "UploadFailed", "UploadFailure", "BadMessage", "NotSupportedOperation", "PermissionDenied":
+		diagnostics.Status = enums.DiagnosticsStatusFailed
+		diagnostics.Error = status
+	}
+	return s.repo.Update(ctx, diagnostics)
+}
+
+// TimeOut fails the uploads that have not arrived within the upload timeout
+func (s *DiagnosticsService) TimeOut(ctx context.Context) error {
+	failed, err := s.repo.FailRequestedBefore(ctx, time.Now().Add(-s.cfg.DiagnosticsUploadTimeout), "Timed out waiting for the upload")
+	if err != nil {
+		return err
+	}
+	if failed > 0 {
+		s.log.Infof("%d diagnostics uploads timed out", failed)
+	}
+	return nil
+}
+
+// Expire removes the uploads past the retention period with their files; a
+// zero retention keeps them forever
+func (s *DiagnosticsService) Expire(ctx context.Context) error {
+	if s.cfg.DiagnosticsRetention <= 0 {
+		return nil
+	}
+	expired, err := s.repo.ListBefore(ctx, time.Now().Add(-s.cfg.DiagnosticsRetention))
+	if err != nil || len(expired) == 0 {
+		return err
+	}
+	ids := make([]uuid.UUID, 0, len(expired))
+	for _, diagnostics := range expired {
+		if err := os.Remove(s.FilePath(diagnostics)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.log.WithError(err).Warnf("Failed to remove diagnostics file of %s", diagnostics.ID)
+			continue
+		}
+		ids = append(ids, diagnostics.ID)
+	}
+	if err := s.repo.Delete(ctx, ids...); err != nil {
+		return err
+	}
+	s.log.Infof("Removed %d diagnostics uploads past the retention period", len(ids))
+	return nil
+}
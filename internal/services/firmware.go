@@ -0,0 +1,444 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/dto"
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/repository"
+)
+
+var (
+	ErrFirmwareChecksumMismatch = errors.New("firmware file does not match the checksum")
+	ErrFirmwareArtifactInUse    = errors.New("firmware artifact is used by a campaign")
+	ErrFirmwareIncompatible     = errors.New("firmware artifact is not compatible with the charge point")
+	ErrFirmwareNoTargets        = errors.New("no charge point to update")
+	ErrFirmwareCampaignState    = errors.New("firmware campaign cannot change to this state")
+)
+
+// defaultSuccessThreshold is the share of a wave that must install the firmware
+// for a campaign to go on to the next wave, unless the campaign sets its own
+const defaultSuccessThreshold = 0.9
+
+// FirmwareService manages firmware artifacts and the campaigns rolling them out.
+// The updates themselves are sent by the OCPP firmware manager.
+type FirmwareService struct {
+	artifactRepo *repository.FirmwareArtifactRepository
+	campaignRepo *repository.FirmwareCampaignRepository
+	cpSvc        *ChargePointService
+	cfg          *config.OCPPConfig
+	log          *logrus.Logger
+}
+
+func NewFirmwareService(
+	artifactRepo *repository.FirmwareArtifactRepository,
+	campaignRepo *repository.FirmwareCampaignRepository,
+	cpSvc *ChargePointService,
+	cfg *config.OCPPConfig,
+	log *logrus.Logger,
+) *FirmwareService {
+	return &FirmwareService{
+		artifactRepo: artifactRepo,
+		campaignRepo: campaignRepo,
+		cpSvc:        cpSvc,
+		cfg:          cfg,
+		log:          log,
+	}
+}
+
+// CreateArtifact stores an uploaded firmware file and its details. The file is
+// checked against the checksum of the request when one is given.
+func (s *FirmwareService) CreateArtifact(ctx context.Context, req *dto.FirmwareArtifactRequest, fileName string, file io.Reader) (*models.FirmwareArtifact, error) {
+	s.log.Infof("Uploading firmware %s %s (%s)", req.Vendor, req.Version, fileName)
+	if err := os.MkdirAll(s.cfg.FirmwareDir, 0o755); err != nil {
+		return nil, err
+	}
+	tmp, err := os.CreateTemp(s.cfg.FirmwareDir, ".upload-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), file)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+	if req.Checksum != "" && !strings.EqualFold(req.Checksum, checksum) {
+		return nil, ErrFirmwareChecksumMismatch
+	}
+
+	artifact := &models.FirmwareArtifact{
+		ID:                 uuid.New(),
+		Version:            req.Version,
+		Vendor:             req.Vendor,
+		Models:             splitModels(req.Models),
+		FileName:           filepath.Base(fileName),
+		Size:               size,
+		Checksum:           checksum,
+		SigningCertificate: req.SigningCertificate,
+		Signature:          req.Signature,
+	}
+	if err := os.Rename(tmp.Name(), s.ArtifactPath(artifact)); err != nil {
+		return nil, err
+	}
+	if err := s.artifactRepo.Create(ctx, artifact); err != nil {
+		os.Remove(s.ArtifactPath(artifact))
+		return nil, err
+	}
+	return artifact, nil
+}
+
+// ArtifactPath returns the path of the file of a firmware artifact
+func (s *FirmwareService) ArtifactPath(artifact *models.FirmwareArtifact) string {
+	return filepath.Join(s.cfg.FirmwareDir, artifact.ID.String())
+}
+
+// GetArtifact retrieves a firmware artifact by its ID
+func (s *FirmwareService) GetArtifact(ctx context.Context, id uuid.UUID) (*models.FirmwareArtifact, error) {
+	return s.artifactRepo.GetByID(ctx, id)
+}
+
+// ListArtifacts returns the firmware artifacts of a vendor, of all vendors when vendor is empty
+func (s *FirmwareService) ListArtifacts(ctx context.Context, vendor string) ([]*models.FirmwareArtifact, error) {
+	return s.artifactRepo.List(ctx, vendor)
+}
+
+// DeleteArtifact deletes a firmware artifact and its file unless a campaign uses it
+func (s *FirmwareService) DeleteArtifact(ctx context.Context, id uuid.UUID) error {
+	s.log.Infof("Deleting firmware artifact with ID: %s", id)
+	artifact, err := s.artifactRepo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	n, err := s.campaignRepo.CountByArtifact(ctx, id)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrFirmwareArtifactInUse
+	}
+	if err := s.artifactRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+	if err := os.Remove(s.ArtifactPath(artifact)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		s.log.WithError(err).Warnf("Failed to remove the file of firmware artifact %s", id)
+	}
+	return nil
+}
+
+// compatible tells whether a firmware artifact can be installed on a charge point
+func compatible(artifact *models.FirmwareArtifact, cp *models.ChargePoint) bool {
+	if !strings.EqualFold(artifact.Vendor, cp.Vendor) {
+		return false
+	}
+	return len(artifact.Models) == 0 || slices.ContainsFunc(artifact.Models, func(model string) bool {
+		return strings.EqualFold(model, cp.Model)
+	})
+}
+
+// CreateCampaign creates a running campaign for the charge points of the
+// request, split into waves in the order of their codes
+func (s *FirmwareService) CreateCampaign(ctx context.Context, req *dto.FirmwareCampaignRequest) (*models.FirmwareCampaign, error) {
+	artifact, err := s.artifactRepo.GetByID(ctx, uuid.MustParse(req.ArtifactID))
+	if err != nil {
+		return nil, err
+	}
+	cps, err := s.campaignChargePoints(ctx, artifact, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(cps) == 0 {
+		return nil, ErrFirmwareNoTargets
+	}
+	slices.SortFunc(cps, func(a, b *models.ChargePoint) int { return strings.Compare(a.Code, b.Code) })
+
+	now := time.Now()
+	campaign := &models.FirmwareCampaign{
+		Name:             req.Name,
+		ArtifactID:       artifact.ID,
+		Status:           enums.FirmwareCampaignStatusRunning,
+		WaveSize:         req.WaveSize,
+		SuccessThreshold: defaultSuccessThreshold,
+		CurrentWave:      1,
+		Waves:            (len(cps) + req.WaveSize - 1) / req.WaveSize,
+		Retries:          req.Retries,
+		RetryInterval:    req.RetryInterval,
+		WaveStartedAt:    now,
+	}
+	if req.SuccessThreshold != nil {
+		campaign.SuccessThreshold = *req.SuccessThreshold
+	}
+	targets := make([]*models.FirmwareCampaignTarget, 0, len(cps))
+	for i, cp := range cps {
+		targets = append(targets, &models.FirmwareCampaignTarget{
+			ChargePointID: cp.ID,
+			Wave:          i/req.WaveSize + 1,
+			Status:        enums.FirmwareUpdateStatusPending,
+		})
+	}
+
+	s.log.Infof("Creating firmware campaign %s: %s %s on %d charge points in %d waves",
+		req.Name, artifact.Vendor, artifact.Version, len(cps), campaign.Waves)
+	if err := s.campaignRepo.Create(ctx, campaign, targets); err != nil {
+		return nil, err
+	}
+	campaign.Artifact = artifact
+	return campaign, nil
+}
+
+// campaignChargePoints resolves the charge points a campaign request targets.
+// Explicitly listed charge points must be compatible with the artifact; those
+// of charge stations or of the whole fleet are filtered.
+func (s *FirmwareService) campaignChargePoints(ctx context.Context, artifact *models.FirmwareArtifact, req *dto.FirmwareCampaignRequest) ([]*models.ChargePoint, error) {
+	if len(req.ChargePointIDs) > 0 {
+		ids := parseUUIDs(req.ChargePointIDs)
+		cps, err := s.cpSvc.ListByIDs(ctx, ids)
+		if err != nil {
+			return nil, err
+		}
+		if len(cps) < len(ids) {
+			return nil, fmt.Errorf("%w: %d of the charge points do not exist", ErrFirmwareNoTargets, len(ids)-len(cps))
+		}
+		var incompatible []string
+		for _, cp := range cps {
+			if !compatible(artifact, cp) {
+				incompatible = append(incompatible, cp.Code)
+			}
+		}
+		if len(incompatible) > 0 {
+			return nil, fmt.Errorf("%w: %s", ErrFirmwareIncompatible, strings.Join(incompatible, ", "))
+		}
+		return cps, nil
+	}
+
+	var cps []*models.ChargePoint
+	var err error
+	if len(req.ChargeStationIDs) > 0 {
+		cps, err = s.cpSvc.ListByChargeStations(ctx, parseUUIDs(req.ChargeStationIDs))
+	} else {
+		cps, err = s.cpSvc.ListByVendor(ctx, artifact.Vendor, "")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(cps, func(cp *models.ChargePoint) bool { return !compatible(artifact, cp) }), nil
+}
+
+// GetCampaign retrieves a campaign with the number of its targets by status
+func (s *FirmwareService) GetCampaign(ctx context.Context, id uuid.UUID) (*dto.FirmwareCampaignDetails, error) {
+	campaign, err := s.campaignRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.campaignRepo.CountTargets(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.FirmwareCampaignDetails{FirmwareCampaign: campaign, Targets: counts}, nil
+}
+
+// ListCampaigns returns the campaigns with the given status, all campaigns when status is empty
+func (s *FirmwareService) ListCampaigns(ctx context.Context, status enums.FirmwareCampaignStatus) ([]*models.FirmwareCampaign, error) {
+	return s.campaignRepo.List(ctx, status)
+}
+
+// ListTargets returns the charge points of a campaign with the progress of their update
+func (s *FirmwareService) ListTargets(ctx context.Context, campaignID uuid.UUID, filter repository.FirmwareTargetFilter) ([]*models.FirmwareCampaignTarget, error) {
+	return s.campaignRepo.ListTargets(ctx, campaignID, filter)
+}
+
+// PauseCampaign stops a running campaign from sending further updates; updates
+// already sent go on and are still tracked
+func (s *FirmwareService) PauseCampaign(ctx context.Context, id uuid.UUID, reason string) (*models.FirmwareCampaign, error) {
+	campaign, err := s.campaignRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if campaign.Status != enums.FirmwareCampaignStatusRunning {
+		return nil, fmt.Errorf("%w: campaign is %s", ErrFirmwareCampaignState, campaign.Status)
+	}
+	s.log.Warnf("Pausing firmware campaign %s: %s", campaign.Name, reason)
+	campaign.Status = enums.FirmwareCampaignStatusPaused
+	campaign.StatusReason = reason
+	return campaign, s.campaignRepo.UpdateState(ctx, campaign)
+}
+
+// ResumeCampaign resumes a paused campaign. A campaign paused because a wave
+// missed the success threshold goes on with the next wave.
+func (s *FirmwareService) ResumeCampaign(ctx context.Context, id uuid.UUID) (*models.FirmwareCampaign, error) {
+	campaign, err := s.campaignRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if campaign.Status != enums.FirmwareCampaignStatusPaused {
+		return nil, fmt.Errorf("%w: campaign is %s", ErrFirmwareCampaignState, campaign.Status)
+	}
+	targets, err := s.campaignRepo.ListTargets(ctx, id, repository.FirmwareTargetFilter{Wave: campaign.CurrentWave})
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Infof("Resuming firmware campaign %s", campaign.Name)
+	campaign.Status = enums.FirmwareCampaignStatusRunning
+	campaign.StatusReason = ""
+	if !slices.ContainsFunc(targets, func(t *models.FirmwareCampaignTarget) bool { return !t.Status.IsFinal() }) {
+		return campaign, s.NextWave(ctx, campaign)
+	}
+	// the charge points not reached yet get a full timeout again
+	campaign.WaveStartedAt = time.Now()
+	return campaign, s.campaignRepo.UpdateState(ctx, campaign)
+}
+
+// CancelCampaign ends a campaign; the updates that were not sent yet are cancelled
+func (s *FirmwareService) CancelCampaign(ctx context.Context, id uuid.UUID) (*models.FirmwareCampaign, error) {
+	campaign, err := s.campaignRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch campaign.Status {
+	case enums.FirmwareCampaignStatusRunning, enums.FirmwareCampaignStatusPaused:
+	default:
+		return nil, fmt.Errorf("%w: campaign is %s", ErrFirmwareCampaignState, campaign.Status)
+	}
+
+	s.log.Infof("Cancelling firmware campaign %s", campaign.Name)
+	if err := s.campaignRepo.CancelTargets(ctx, id); err != nil {
+		return nil, err
+	}
+	campaign.Status = enums.FirmwareCampaignStatusCancelled
+	campaign.CompletedAt = time.Now()
+	return campaign, s.campaignRepo.UpdateState(ctx, campaign)
+}
+
+// NextWave moves a campaign on to its next wave, or completes it after the last one
+func (s *FirmwareService) NextWave(ctx context.Context, campaign *models.FirmwareCampaign) error {
+	now := time.Now()
+	if campaign.CurrentWave >= campaign.Waves {
+		s.log.Infof("Firmware campaign %s completed", campaign.Name)
+		campaign.Status = enums.FirmwareCampaignStatusCompleted
+		campaign.CompletedAt = now
+	} else {
+		campaign.CurrentWave++
+		campaign.WaveStartedAt = now
+		s.log.Infof("Firmware campaign %s starts wave %d of %d", campaign.Name, campaign.CurrentWave, campaign.Waves)
+	}
+	return s.campaignRepo.UpdateState(ctx, campaign)
+}
+
+// CompleteWave applies the success gate to a wave whose updates have all
+// ended: the campaign goes on with the next wave or is paused
+func (s *FirmwareService) CompleteWave(ctx context.Context, campaign *models.FirmwareCampaign, targets []*models.FirmwareCampaignTarget) error {
+	rate := successRate(targets)
+	if rate >= campaign.SuccessThreshold {
+		return s.NextWave(ctx, campaign)
+	}
+	campaign.Status = enums.FirmwareCampaignStatusPaused
+	campaign.StatusReason = fmt.Sprintf("Wave %d installed on %.0f%% of its charge points, below the %.0f%% threshold",
+		campaign.CurrentWave, 100*rate, 100*campaign.SuccessThreshold)
+	s.log.Warnf("Pausing firmware campaign %s: %s", campaign.Name, campaign.StatusReason)
+	return s.campaignRepo.UpdateState(ctx, campaign)
+}
+
+// UpdateTarget stores the progress of a campaign target
+func (s *FirmwareService) UpdateTarget(ctx context.Context, target *models.FirmwareCampaignTarget) error {
+	if target.Status.IsFinal() && target.CompletedAt.IsZero() {
+		target.CompletedAt = time.Now()
+	}
+	return s.campaignRepo.UpdateTarget(ctx, target)
+}
+
+// RecordStatus tracks a firmware status reported by a charge point on the
+// update it is working on. Statuses outside of a campaign are ignored.
+func (s *FirmwareService) RecordStatus(ctx context.Context, chargePointID uuid.UUID, status string, requestID *int) error {
+	target, err := s.campaignRepo.FindActiveTarget(ctx, chargePointID, requestID)
+	if err != nil || target == nil {
+		return err
+	}
+	target.FirmwareStatus = status
+	if updateStatus := firmwareUpdateStatus(status); updateStatus != "" {
+		target.Status = updateStatus
+	}
+	if target.Status == enums.FirmwareUpdateStatusFailed {
+		target.Error = status
+	}
+	return s.UpdateTarget(ctx, target)
+}
+
+// firmwareUpdateStatus maps an OCPP 1.6 or 2.0.1 firmware status to the
+// progress of an update, empty for statuses that tell nothing about it
+func firmwareUpdateStatus(status string) enums.FirmwareUpdateStatus {
+	switch status {
+	case "Downloading", "DownloadScheduled", "DownloadPaused":
+		return enums.FirmwareUpdateStatusDownloading
+	case "Downloaded", "SignatureVerified":
+		return enums.FirmwareUpdateStatusDownloaded
+	case "Installing", "InstallRebooting", "InstallScheduled":
+		return enums.FirmwareUpdateStatusInstalling
+	case "Installed":
+		return enums.FirmwareUpdateStatusInstalled
+	case "DownloadFailed", "InstallationFailed", "InstallVerificationFailed", "InvalidSignature":
+		return enums.FirmwareUpdateStatusFailed
+	default:
+		return ""
+	}
+}
+
+// splitModels splits a comma separated list of models
+func splitModels(list string) []string {
+	models := []string{}
+	for _, model := range strings.Split(list, ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			models = append(models, model)
+		}
+	}
+	return models
+}
+
+func parseUUIDs(ids []string) []uuid.UUID {
+	parsed := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if u, err := uuid.Parse(id); err == nil && !slices.Contains(parsed, u) {
+			parsed = append(parsed, u)
+		}
+	}
+	return parsed
+}
+
+// successRate returns the share of the targets of a wave that installed the
+// firmware, cancelled targets not counting
+func successRate(targets []*models.FirmwareCampaignTarget) float64 {
+	var installed, total int
+	for _, t := range targets {
+		switch t.Status {
+		case enums.FirmwareUpdateStatusCancelled:
+			continue
+		case enums.FirmwareUpdateStatusInstalled:
+			installed++
+		}
+		total++
+	}
+	if total == 0 {
+		return 1
+	}
+	return float64(installed) / float64(total)
+}
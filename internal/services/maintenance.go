@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/dto"
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/repository"
+)
+
+var (
+	ErrMaintenanceNoTargets   = errors.New("no charge point to take out of service")
+	ErrMaintenancePeriod      = errors.New("maintenance window has already ended")
+	ErrMaintenanceOverlap     = errors.New("charge points are already part of an overlapping maintenance window")
+	ErrMaintenanceWindowState = errors.New("maintenance window cannot change to this state")
+)
+
+// MaintenanceService manages the maintenance windows taking charge points out
+// of service. The availability changes are sent by the OCPP maintenance scheduler.
+type MaintenanceService struct {
+	repo  *repository.MaintenanceWindowRepository
+	cpSvc *ChargePointService
+	log   *logrus.Logger
+}
+
+func NewMaintenanceService(repo *repository.MaintenanceWindowRepository, cpSvc *ChargePointService, log *logrus.Logger) *MaintenanceService {
+	return &MaintenanceService{
+		repo:  repo,
+		cpSvc: cpSvc,
+		log:   log,
+	}
+}
+
+// Create schedules a maintenance window for the charge points of the request.
+// A charge point can only be part of one window at a time.
+func (s *MaintenanceService) Create(ctx context.Context, req *dto.MaintenanceWindowRequest) (*models.MaintenanceWindow, error) {
+	if !req.EndsAt.After(time.Now()) {
+		return nil, ErrMaintenancePeriod
+	}
+	cps, err := s.windowChargePoints(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(cps) == 0 {
+		return nil, ErrMaintenanceNoTargets
+	}
+	slices.SortFunc(cps, func(a, b *models.ChargePoint) int { return strings.Compare(a.Code, b.Code) })
+
+	ids := make([]uuid.UUID, 0, len(cps))
+	targets := make([]*models.MaintenanceWindowTarget, 0, len(cps))
+	for _, cp := range cps {
+		ids = append(ids, cp.ID)
+		targets = append(targets, &models.MaintenanceWindowTarget{
+			ChargePointID: cp.ID,
+			Status:        enums.MaintenanceTargetStatusPending,
+		})
+	}
+	overlapping, err := s.repo.CountOverlapping(ctx, ids, req.StartsAt, req.EndsAt)
+	if err != nil {
+		return nil, err
+	}
+	if overlapping > 0 {
+		return nil, fmt.Errorf("%w: %d of the charge points", ErrMaintenanceOverlap, overlapping)
+	}
+
+	window := &models.MaintenanceWindow{
+		Name:     req.Name,
+		Reason:   req.Reason,
+		Status:   enums.MaintenanceWindowStatusScheduled,
+		StartsAt: req.StartsAt,
+		EndsAt:   req.EndsAt,
+	}
+	s.log.Infof("Scheduling maintenance window %s from %s to %s on %d charge points",
+		req.Name, req.StartsAt.Format(time.RFC3339), req.EndsAt.Format(time.RFC3339), len(cps))
+	if err := s.repo.Create(ctx, window, targets); err != nil {
+		return nil, err
+	}
+	return window, nil
+}
+
+// windowChargePoints resolves the charge points a maintenance window request targets
+func (s *MaintenanceService) windowChargePoints(ctx context.Context, req *dto.MaintenanceWindowRequest) ([]*models.ChargePoint, error) {
+	if len(req.ChargePointIDs) == 0 {
+		return s.cpSvc.ListByChargeStations(ctx, parseUUIDs(req.ChargeStationIDs))
+	}
+	ids := parseUUIDs(req.ChargePointIDs)
+	cps, err := s.cpSvc.ListByIDs(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	if len(cps) < len(ids) {
+		return nil, fmt.Errorf("%w: %d of the charge points do not exist", ErrMaintenanceNoTargets, len(ids)-len(cps))
+	}
+	return cps, nil
+}
+
+// Get retrieves a maintenance window with the number of its targets by status
+func (s *MaintenanceService) Get(ctx context.Context, id uuid.UUID) (*dto.MaintenanceWindowDetails, error) {
+	window, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	counts, err := s.repo.CountTargets(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return &dto.MaintenanceWindowDetails{MaintenanceWindow: window, Targets: counts}, nil
+}
+
+// List returns the maintenance windows with the given status, all windows when status is empty
+func (s *MaintenanceService) List(ctx context.Context, status enums.MaintenanceWindowStatus) ([]*models.MaintenanceWindow, error) {
+	return s.repo.List(ctx, status)
+}
+
+// ListOpen returns the maintenance windows that still act on their charge points
+func (s *MaintenanceService) ListOpen(ctx context.Context) ([]*models.MaintenanceWindow, error) {
+	return s.repo.ListOpen(ctx)
+}
+
+// ListTargets returns the charge points of a maintenance window with their
+// availability, those with the given status when status is not empty
+func (s *MaintenanceService) ListTargets(ctx context.Context, windowID uuid.UUID, status enums.MaintenanceTargetStatus) ([]*models.MaintenanceWindowTarget, error) {
+	return s.repo.ListTargets(ctx, windowID, status)
+}
+
+// UpdateState stores the status of a maintenance window
+func (s *MaintenanceService) UpdateState(ctx context.Context, window *models.MaintenanceWindow) error {
+	return s.repo.UpdateState(ctx, window)
+}
+
+// UpdateTarget stores the availability of a charge point of a maintenance window
+func (s *MaintenanceService) UpdateTarget(ctx context.Context, target *models.MaintenanceWindowTarget) error {
+	return s.repo.UpdateTarget(ctx, target)
+}
+
+// Cancel cancels a maintenance window that has not started, and ends an active
+// one now so that its charge points are put back in service
+func (s *MaintenanceService) Cancel(ctx context.Context, id uuid.UUID) (*models.MaintenanceWindow, error) {
+	window, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	switch window.Status {
+	case enums.MaintenanceWindowStatusScheduled:
+		s.log.Infof("Cancelling maintenance window %s", window.Name)
+		window.Status = enums.MaintenanceWindowStatusCancelled
+		window.CompletedAt = time.Now()
+		return window, s.repo.UpdateState(ctx, window)
+	case enums.MaintenanceWindowStatusActive:
+		// the scheduler restores the charge points on its next check
+		s.log.Infof("Ending maintenance window %s early", window.Name)
+		window.EndsAt = time.Now()
+		return window, s.repo.UpdateEnd(ctx, window)
+	default:
+		return nil, fmt.Errorf("%w: window is %s", ErrMaintenanceWindowState, window.Status)
+	}
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/repository"
+)
+
+// messageRetentionInterval is how often messages past the retention period are removed
+const messageRetentionInterval = time.Hour
+
+// MessageJournalService records the OCPP frames exchanged with charge points.
+// Frames go through a bounded queue drained by a single batch writer, like
+// meter values, but are dropped rather than blocking when the queue is full so
+// the journal never slows down the charge point connections.
+type MessageJournalService struct {
+	repo  *repository.OCPPMessageRepository
+	cfg   *config.OCPPConfig
+	log   *logrus.Logger
+	queue chan *models.OCPPMessage
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func NewMessageJournalService(repo *repository.OCPPMessageRepository, cfg *config.OCPPConfig, log *logrus.Logger) *MessageJournalService {
+	return &MessageJournalService{
+		repo:  repo,
+		cfg:   cfg,
+		log:   log,
+		queue: make(chan *models.OCPPMessage, cfg.MessageQueueSize),
+		done:  make(chan struct{}),
+	}
+}
+
+// Start launches the batch writer and the retention cleanup
+func (s *MessageJournalService) Start() {
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop flushes the queued messages and stops the batch writer
+func (s *MessageJournalService) Stop() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// Record hands a message to the batch writer, dropping it when the queue is full
+func (s *MessageJournalService) Record(msg *models.OCPPMessage) {
+	select {
+	case s.queue <- msg:
+	default:
+		s.log.Warnf("Message journal queue is full, dropping %s message of %s", msg.Direction, msg.Identity)
+	}
+}
+
+// List returns the recorded messages matching the filter
+func (s *MessageJournalService) List(ctx context.Context, filter repository.OCPPMessageFilter) ([]*models.OCPPMessage, error) {
+	return s.repo.List(ctx, filter)
+}
+
+func (s *MessageJournalService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.MessageFlushInterval)
+	defer ticker.Stop()
+	retention := time.NewTicker(messageRetentionInterval)
+	defer retention.Stop()
+
+	batch := make([]*models.OCPPMessage, 0, s.cfg.MessageBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.repo.BulkCreate(ctx, batch); err != nil {
+			s.log.WithError(err).Errorf("Dropping %d OCPP messages", len(batch))
+		}
+		batch = make([]*models.OCPPMessage, 0, s.cfg.MessageBatchSize)
+	}
+
+	s.expire()
+	for {
+		select {
+		case msg := <-s.queue:
+			batch = append(batch, msg)
+			if len(batch) >= s.cfg.MessageBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-retention.C:
+			s.expire()
+		case <-s.done:
+			for {
+				select {
+				case msg := <-s.queue:
+					batch = append(batch, msg)
+					if len(batch) >= s.cfg.MessageBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// expire removes the messages past the retention period; a zero retention keeps them forever
+func (s *MessageJournalService) expire() {
+	if s.cfg.MessageRetention <= 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	removed, err := s.repo.DeleteBefore(ctx, time.Now().Add(-s.cfg.MessageRetention))
+	if err != nil {
+		return
+	}
+	if removed > 0 {
+		s.log.Infof("Removed %d OCPP messages past the retention period", removed)
+	}
+}
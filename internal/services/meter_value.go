@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/repository"
+)
+
+// MeterValueService ingests meter values through a bounded queue that is
+// drained by a single worker writing batched inserts, so bursts of samples
+// from a large fleet turn into a steady stream of multi-row statements.
+type MeterValueService struct {
+	repo  *repository.MeterValueRepository
+	cfg   *config.OCPPConfig
+	log   *logrus.Logger
+	queue chan *models.MeterValue
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+func NewMeterValueService(repo *repository.MeterValueRepository, cfg *config.OCPPConfig, log *logrus.Logger) *MeterValueService {
+	return &MeterValueService{
+		repo:  repo,
+		cfg:   cfg,
+		log:   log,
+		queue: make(chan *models.MeterValue, cfg.MeterValueQueueSize),
+		done:  make(chan struct{}),
+	}
+}
+
+// Start launches the batch writer
+func (s *MeterValueService) Start() {
+	s.wg.Add(1)
+	go s.run()
+}
+
+// Stop flushes the queued meter values and stops the batch writer
+func (s *MeterValueService) Stop() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+// Enqueue hands meter values to the batch writer. It blocks while the queue is
+// full, which pushes back on the charge point connection producing the samples.
+func (s *MeterValueService) Enqueue(ctx context.Context, values []*models.MeterValue) error {
+	for _, v := range values {
+		select {
+		case s.queue <- v:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// List returns a meter value series
+func (s *MeterValueService) List(ctx context.Context, filter repository.MeterValueFilter) ([]*models.MeterValue, error) {
+	return s.repo.List(ctx, filter)
+}
+
+func (s *MeterValueService) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.cfg.MeterValueFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*models.MeterValue, 0, s.cfg.MeterValueBatchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := s.repo.BulkCreate(ctx, batch); err != nil {
+			// a single bad row fails the whole statement, and meter values are
+			// billing data, so the batch is retried row by row to keep the rest
+			for _, v := range batch {
+				if err := s.repo.BulkCreate(ctx, []*models.MeterValue{v}); err != nil {
+					s.log.WithError(err).Errorf("Dropping %s meter value of charge point %s connector %d sampled at %s",
+						v.Measurand, v.ChargePointID, v.ConnectorID, v.Timestamp.Format(time.RFC3339))
+				}
+			}
+		}
+		batch = make([]*models.MeterValue, 0, s.cfg.MeterValueBatchSize)
+	}
+
+	for {
+		select {
+		case v := <-s.queue:
+			batch = append(batch, v)
+			if len(batch) >= s.cfg.MeterValueBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-s.done:
+			for {
+				select {
+				case v := <-s.queue:
+					batch = append(batch, v)
+					if len(batch) >= s.cfg.MeterValueBatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
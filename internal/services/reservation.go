@@ -0,0 +1,117 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/repository"
+)
+
+var (
+	ErrReservationExpiry   = errors.New("reservation expiry date has already passed")
+	ErrReservationConflict = errors.New("connector is already reserved")
+	ErrReservationState    = errors.New("reservation no longer holds a connector")
+)
+
+// ReservationService keeps the connector reservations of charge points. The
+// reservations are sent to and cancelled on the charge points by the OCPP
+// reservation manager.
+type ReservationService struct {
+	repo *repository.ReservationRepository
+	log  *logrus.Logger
+}
+
+func NewReservationService(repo *repository.ReservationRepository, log *logrus.Logger) *ReservationService {
+	return &ReservationService{
+		repo: repo,
+		log:  log,
+	}
+}
+
+// Create records a reservation about to be sent to its charge point. A
+// connector can only be held by one reservation at a time, including one
+// that expired but has not been released by the reservation manager yet, and
+// a reservation of connector 0 holds all connectors of the charge point.
+func (s *ReservationService) Create(ctx context.Context, reservation *models.Reservation) error {
+	if !reservation.ExpiresAt.After(time.Now()) {
+		return ErrReservationExpiry
+	}
+	reservation.Status = enums.ReservationStatusRequested
+	err := s.repo.Create(ctx, reservation)
+	if errors.Is(err, repository.ErrConnectorReserved) {
+		return fmt.Errorf("%w: connector %d", ErrReservationConflict, reservation.ConnectorID)
+	}
+	return err
+}
+
+// Get retrieves a reservation by its ID
+func (s *ReservationService) Get(ctx context.Context, id uuid.UUID) (*models.Reservation, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+// List returns the reservations of a charge point with the given status,
+// newest first; a nil charge point ID or an empty status matches all
+func (s *ReservationService) List(ctx context.Context, chargePointID uuid.UUID, status enums.ReservationStatus) ([]*models.Reservation, error) {
+	return s.repo.List(ctx, chargePointID, status)
+}
+
+// ListExpired returns the reservations still holding a connector past their expiry
+func (s *ReservationService) ListExpired(ctx context.Context) ([]*models.Reservation, error) {
+	return s.repo.ListExpired(ctx, time.Now())
+}
+
+// Update stores the state of a reservation
+func (s *ReservationService) Update(ctx context.Context, reservation *models.Reservation) error {
+	return s.repo.Update(ctx, reservation)
+}
+
+// Use links the transaction a charge point started on one of its reservations
+// to the reservation. The charge point decides whether the reservation still
+// held, so it is used up even when it expired here in the meantime.
+func (s *ReservationService) Use(ctx context.Context, chargePointID uuid.UUID, reservationID, transactionID int) error {
+	reservation, err := s.repo.GetByReservationID(ctx, chargePointID, reservationID)
+	if err != nil {
+		return err
+	}
+	if reservation == nil {
+		s.log.Warnf("Transaction %d started on unknown reservation %d of charge point %s", transactionID, reservationID, chargePointID)
+		return nil
+	}
+	if reservation.Status == enums.ReservationStatusUsed {
+		return nil
+	}
+	reservation.Status = enums.ReservationStatusUsed
+	reservation.TransactionID = transactionID
+	reservation.Error = ""
+	if err := s.repo.Update(ctx, reservation); err != nil {
+		return err
+	}
+	s.log.Infof("Reservation %d of charge point %s used by transaction %d", reservationID, chargePointID, transactionID)
+	return nil
+}
+
+// RecordStatusUpdate tracks the end of a reservation reported by an OCPP 2.0.1
+// charging station, Expired or Removed
+func (s *ReservationService) RecordStatusUpdate(ctx context.Context, chargePointID uuid.UUID, reservationID int, status string) error {
+	reservation, err := s.repo.GetByReservationID(ctx, chargePointID, reservationID)
+	if err != nil || reservation == nil || reservation.Status.IsFinal() {
+		return err
+	}
+	switch status {
+	case "Expired":
+		reservation.Status = enums.ReservationStatusExpired
+	case "Removed":
+		reservation.Status = enums.ReservationStatusCancelled
+		reservation.Error = "Removed by the charging station"
+	default:
+		return nil
+	}
+	return s.repo.Update(ctx, reservation)
+}
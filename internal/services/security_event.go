@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"maps"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/config"
+	"github.com/mutoulbj/gocsms/internal/enums"
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/repository"
+)
+
+// securityEventSeverities are the default severities of the security events
+// defined by OCPP 2.0.1 and, with their Central System names, by the OCPP 1.6
+// security extension. Vendor specific event types default to MEDIUM.
+var securityEventSeverities = map[string]enums.SecurityEventSeverity{
+	"TamperDetectionActivated":            enums.SecurityEventSeverityCritical,
+	"InvalidFirmwareSignature":            enums.SecurityEventSeverityCritical,
+	"InvalidFirmwareSigningCertificate":   enums.SecurityEventSeverityCritical,
+	"AttemptedReplayAttacks":              enums.SecurityEventSeverityCritical,
+	"SecurityLogWasCleared":               enums.SecurityEventSeverityCritical,
+	"FailedToAuthenticateAtCsms":          enums.SecurityEventSeverityHigh,
+	"FailedToAuthenticateAtCentralSystem": enums.SecurityEventSeverityHigh,
+	"CsmsFailedToAuthenticate":            enums.SecurityEventSeverityHigh,
+	"CentralSystemFailedToAuthenticate":   enums.SecurityEventSeverityHigh,
+	"InvalidCsmsCertificate":              enums.SecurityEventSeverityHigh,
+	"InvalidCentralSystemCertificate":     enums.SecurityEventSeverityHigh,
+	"InvalidChargingStationCertificate":   enums.SecurityEventSeverityHigh,
+	"InvalidChargePointCertificate":       enums.SecurityEventSeverityHigh,
+	"InvalidTLSVersion":                   enums.SecurityEventSeverityHigh,
+	"InvalidTLSCipherSuite":               enums.SecurityEventSeverityHigh,
+	"MaintenanceLoginFailed":              enums.SecurityEventSeverityHigh,
+	"MemoryExhaustion":                    enums.SecurityEventSeverityHigh,
+	"ReconfigurationOfSecurityParameters": enums.SecurityEventSeverityHigh,
+	"FirmwareUpdated":                     enums.SecurityEventSeverityMedium,
+	"SettingSystemTime":                   enums.SecurityEventSeverityMedium,
+	"ResetOrReboot":                       enums.SecurityEventSeverityMedium,
+	"MaintenanceLoginAccepted":            enums.SecurityEventSeverityMedium,
+	"InvalidMessages":                     enums.SecurityEventSeverityMedium,
+	"StartupOfTheDevice":                  enums.SecurityEventSeverityLow,
+}
+
+// SecurityEventService stores the security events reported by charge points
+// and raises an alert for those at or above the configured severity
+type SecurityEventService struct {
+	repo       *repository.SecurityEventRepository
+	severities map[string]enums.SecurityEventSeverity
+	threshold  enums.SecurityEventSeverity
+	log        *logrus.Logger
+}
+
+func NewSecurityEventService(repo *repository.SecurityEventRepository, cfg *config.OCPPConfig, log *logrus.Logger) *SecurityEventService {
+	severities := maps.Clone(securityEventSeverities)
+	for eventType, value := range cfg.SecurityEventSeverities {
+		severity := enums.SecurityEventSeverity(strings.ToUpper(value))
+		if !severity.IsValid() {
+			log.Warnf("Ignoring invalid severity %q of security event %s", value, eventType)
+			continue
+		}
+		severities[eventType] = severity
+	}
+
+	threshold := enums.SecurityEventSeverity(strings.ToUpper(cfg.SecurityAlertSeverity))
+	if !threshold.IsValid() {
+		log.Warnf("Invalid security alert severity %q, using HIGH", cfg.SecurityAlertSeverity)
+		threshold = enums.SecurityEventSeverityHigh
+	}
+
+	return &SecurityEventService{
+		repo:       repo,
+		severities: severities,
+		threshold:  threshold,
+		log:        log,
+	}
+}
+
+// Record assigns the severity of the event type, stores the event and raises
+// an alert when the severity reaches the threshold
+func (s *SecurityEventService) Record(ctx context.Context, event *models.SecurityEvent) error {
+	event.Severity = s.severity(event.Type)
+	event.Alert = event.Severity.AtLeast(s.threshold)
+	if err := s.repo.Create(ctx, event); err != nil {
+		return err
+	}
+
+	if event.Alert {
+		s.log.WithFields(logrus.Fields{
+			"charge_point_id": event.ChargePointID,
+			"type":            event.Type,
+			"severity":        event.Severity,
+			"timestamp":       event.Timestamp,
+			"tech_info":       event.TechInfo,
+		}).Error("Security alert")
+	}
+	return nil
+}
+
+func (s *SecurityEventService) List(ctx context.Context, filter repository.SecurityEventFilter) ([]*models.SecurityEvent, error) {
+	return s.repo.List(ctx, filter)
+}
+
+func (s *SecurityEventService) severity(eventType string) enums.SecurityEventSeverity {
+	if severity, ok := s.severities[eventType]; ok {
+		return severity
+	}
+	return enums.SecurityEventSeverityMedium
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/models"
+	"github.com/mutoulbj/gocsms/internal/repository"
+)
+
+var (
+	ErrIdTagInvalid        = errors.New("id tag is invalid")
+	ErrIdTagConcurrentTx   = errors.New("id tag is already used in another transaction")
+	ErrTransactionNotFound = errors.New("transaction not found")
+)
+
+type TransactionService struct {
+	repo *repository.TransactionRepository
+	log  *logrus.Logger
+}
+
+func NewTransactionService(repo *repository.TransactionRepository, log *logrus.Logger) *TransactionService {
+	return &TransactionService{repo: repo, log: log}
+}
+
+// Authorize checks whether an id tag may start a charging session. Id tags
+// longer than maxLength, which depends on the OCPP version, are invalid.
+// It returns ErrIdTagInvalid or ErrIdTagConcurrentTx when it may not.
+func (s *TransactionService) Authorize(ctx context.Context, idTag string, maxLength int) error {
+	if idTag == "" || len(idTag) > maxLength {
+		return ErrIdTagInvalid
+	}
+	active, err := s.repo.GetActiveByIdTag(ctx, idTag)
+	if err != nil {
+		return err
+	}
+	if active != nil {
+		return ErrIdTagConcurrentTx
+	}
+	return nil
+}
+
+// Start records a new transaction. The transaction is always recorded because the
+// charge point has already started it; the returned error reports the authorization
+// result of the id tag (ErrIdTagInvalid, ErrIdTagConcurrentTx) when it is not accepted.
+func (s *TransactionService) Start(ctx context.Context, tx *models.Transaction, maxIdTagLength int) (*models.Transaction, error) {
+	authErr := s.Authorize(ctx, tx.IdTag, maxIdTagLength)
+	if authErr != nil && !errors.Is(authErr, ErrIdTagInvalid) && !errors.Is(authErr, ErrIdTagConcurrentTx) {
+		return nil, authErr
+	}
+
+	if err := s.repo.Create(ctx, tx); err != nil {
+		return nil, err
+	}
+	s.log.Infof("Started transaction %d on charge point %s connector %d", tx.TransactionID, tx.ChargePointID, tx.ConnectorID)
+	return tx, authErr
+}
+
+// Stop closes a transaction of a charge point and computes the delivered energy
+// from the meter readings (Wh). Transactions of other charge points are not found.
+func (s *TransactionService) Stop(
+	ctx context.Context,
+	chargePointID uuid.UUID,
+	transactionID int,
+	meterStop float64,
+	timestamp time.Time,
+	reason string,
+) (*models.Transaction, error) {
+	tx, err := s.repo.GetByChargePointTransactionID(ctx, chargePointID, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, ErrTransactionNotFound
+	}
+	if !tx.IsActive() {
+		s.log.Warnf("Transaction %d is already stopped", transactionID)
+		return tx, nil
+	}
+
+	tx.StopTime = timestamp
+	tx.MeterStop = meterStop
+	tx.TotalEnergyKwh = max(meterStop-tx.MeterStart, 0) / 1000
+	tx.StopReason = reason
+	tx.UpdatedAt = time.Now()
+	if err := s.repo.Stop(ctx, tx); err != nil {
+		return nil, err
+	}
+	s.log.Infof("Stopped transaction %d, delivered %.3f kWh", transactionID, tx.TotalEnergyKwh)
+	return tx, nil
+}
+
+// GetByChargerTxID retrieves a transaction by the id an OCPP 2.0.1 charging station assigned to it
+func (s *TransactionService) GetByChargerTxID(ctx context.Context, chargePointID uuid.UUID, chargerTxID string) (*models.Transaction, error) {
+	tx, err := s.repo.GetByChargerTxID(ctx, chargePointID, chargerTxID)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, ErrTransactionNotFound
+	}
+	return tx, nil
+}
+
+// GetByTransactionID retrieves a transaction of a charge point by its OCPP transaction id
+func (s *TransactionService) GetByTransactionID(ctx context.Context, chargePointID uuid.UUID, transactionID int) (*models.Transaction, error) {
+	tx, err := s.repo.GetByChargePointTransactionID(ctx, chargePointID, transactionID)
+	if err != nil {
+		return nil, err
+	}
+	if tx == nil {
+		return nil, ErrTransactionNotFound
+	}
+	return tx, nil
+}
@@ -0,0 +1,485 @@
+// Package simulator provides virtual OCPP 1.6 charge points that connect to
+// the CSMS over OCPP-J, to exercise ocpp.Server without real hardware.
+package simulator
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/sirupsen/logrus"
+
+	"github.com/mutoulbj/gocsms/internal/ocpp"
+)
+
+var (
+	ErrNotConnected   = errors.New("charger is not connected")
+	ErrConnectorBusy  = errors.New("connector is busy")
+	ErrNotAuthorized  = errors.New("id tag was not accepted")
+	ErrBootNotAllowed = errors.New("boot notification was rejected")
+)
+
+// Config describes a virtual charge point
+type Config struct {
+	// URL of the OCPP endpoint, the identity is appended, e.g. ws://localhost:9000/ocpp
+	URL             string
+	Identity        string
+	Password        string // HTTP basic auth password (security profile 1), none when empty
+	Vendor          string
+	Model           string
+	SerialNumber    string
+	FirmwareVersion string
+	Connectors      int
+	// HeartbeatInterval overrides the interval of the BootNotification response when set
+	HeartbeatInterval time.Duration
+	// RemoteSession is the charging session started by RemoteStartTransaction
+	RemoteSession Session
+	CallTimeout   time.Duration
+	// Observer is told about the connections and CALLs of the charger, none when nil
+	Observer Observer
+}
+
+// Observer is notified of the WebSocket handshakes and CALLs of chargers,
+// e.g. to measure how fast the CSMS serves them. It is called concurrently
+// by the chargers sharing it.
+type Observer interface {
+	// Connected reports a handshake and how long it took, err is set when it failed
+	Connected(identity string, handshake time.Duration, err error)
+	// Disconnected reports that a connection opened by Connect has closed, err
+	// is set when it was lost rather than closed by the charger
+	Disconnected(identity string, err error)
+	// CallCompleted reports a CALL of the charger and the time until its
+	// reply; err is set for a CALLERROR, a timeout or a lost connection
+	CallCompleted(identity, action string, latency time.Duration, err error)
+}
+
+type nopObserver struct{}
+
+func (nopObserver) Connected(string, time.Duration, error)             {}
+func (nopObserver) Disconnected(string, error)                         {}
+func (nopObserver) CallCompleted(string, string, time.Duration, error) {}
+
+// HandlerFunc answers a CALL from the CSMS with a response payload, or with
+// an error that becomes a CALLERROR; *ocpp.CallErrorResponse sets its code
+type HandlerFunc func(ctx context.Context, payload json.RawMessage) (any, error)
+
+// Charger is a virtual OCPP 1.6 charge point. It keeps one WebSocket to the
+// CSMS, sends its CALLs one at a time as required by OCPP-J and answers the
+// CALLs of the CSMS with the registered handlers.
+type Charger struct {
+	cfg Config
+	log *logrus.Entry
+
+	connMu sync.Mutex
+	ws     *websocket.Conn
+	closed chan struct{}
+
+	writeMu  sync.Mutex
+	callSlot chan struct{}
+
+	pendingMu sync.Mutex
+	pending   map[string]chan *ocpp.OCPPMessage
+
+	handlersMu sync.RWMutex
+	handlers   map[string]HandlerFunc
+
+	mu            sync.Mutex
+	sessions      map[int]*session    // running charging sessions by connector
+	meters        map[int]int         // energy register of every connector, Wh
+	inoperative   map[int]bool        // connectors made inoperative by ChangeAvailability, 0 for the charger
+	reservations  map[int]reservation // reservations made by ReserveNow by connector
+	configuration map[string]string
+	heartbeatStop chan struct{}
+	sessionsWG    sync.WaitGroup
+}
+
+func NewCharger(cfg Config, log *logrus.Logger) *Charger {
+	if cfg.Connectors <= 0 {
+		cfg.Connectors = 1
+	}
+	if cfg.CallTimeout <= 0 {
+		cfg.CallTimeout = 30 * time.Second
+	}
+	if cfg.Observer == nil {
+		cfg.Observer = nopObserver{}
+	}
+	c := &Charger{
+		cfg:          cfg,
+		log:          log.WithField("charger", cfg.Identity),
+		callSlot:     make(chan struct{}, 1),
+		pending:      make(map[string]chan *ocpp.OCPPMessage),
+		handlers:     make(map[string]HandlerFunc),
+		sessions:     make(map[int]*session),
+		meters:       make(map[int]int),
+		inoperative:  make(map[int]bool),
+		reservations: make(map[int]reservation),
+		configuration: map[string]string{
+			"HeartbeatInterval":        "0",
+			"MeterValueSampleInterval": "60",
+			"NumberOfConnectors":       fmt.Sprint(cfg.Connectors),
+		},
+	}
+	c.registerDefaultHandlers()
+	return c
+}
+
+// Identity returns the OCPP identity of the charger
+func (c *Charger) Identity() string {
+	return c.cfg.Identity
+}
+
+// Connectors returns the number of connectors of the charger
+func (c *Charger) Connectors() int {
+	return c.cfg.Connectors
+}
+
+// Handle registers the handler answering CALLs of the CSMS for action,
+// replacing the default one
+func (c *Charger) Handle(action string, fn HandlerFunc) {
+	c.handlersMu.Lock()
+	c.handlers[action] = fn
+	c.handlersMu.Unlock()
+}
+
+// Connect opens the WebSocket to the CSMS using OCPP 1.6
+func (c *Charger) Connect(ctx context.Context) error {
+	dialer := websocket.Dialer{
+		Subprotocols:     []string{string(ocpp.OCPP16)},
+		HandshakeTimeout: c.cfg.CallTimeout,
+	}
+	header := http.Header{}
+	if c.cfg.Password != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(c.cfg.Identity + ":" + c.cfg.Password))
+		header.Set("Authorization", "Basic "+credentials)
+	}
+	start := time.Now()
+	ws, resp, err := dialer.DialContext(ctx, strings.TrimSuffix(c.cfg.URL, "/")+"/"+c.cfg.Identity, header)
+	if err != nil {
+		if resp != nil {
+			err = fmt.Errorf("failed to connect: %w (HTTP %d)", err, resp.StatusCode)
+		} else {
+			err = fmt.Errorf("failed to connect: %w", err)
+		}
+		c.cfg.Observer.Connected(c.cfg.Identity, time.Since(start), err)
+		return err
+	}
+	c.cfg.Observer.Connected(c.cfg.Identity, time.Since(start), nil)
+
+	closed := make(chan struct{})
+	c.connMu.Lock()
+	c.ws, c.closed = ws, closed
+	c.connMu.Unlock()
+	go c.read(ws, closed)
+	c.log.Info("Connected")
+	return nil
+}
+
+// Close closes the WebSocket; running sessions fail on their next call
+func (c *Charger) Close() error {
+	c.stopHeartbeat()
+	c.connMu.Lock()
+	ws := c.ws
+	c.ws = nil
+	c.connMu.Unlock()
+	if ws == nil {
+		return nil
+	}
+	c.writeMu.Lock()
+	_ = ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+	c.writeMu.Unlock()
+	return ws.Close()
+}
+
+// Call sends a CALL to the CSMS and decodes its CALLRESULT into resp; a
+// CALLERROR is returned as *ocpp.CallErrorResponse
+func (c *Charger) Call(ctx context.Context, action string, req, resp any) error {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.CallTimeout)
+	defer cancel()
+
+	select {
+	case c.callSlot <- struct{}{}:
+		defer func() { <-c.callSlot }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	sent := time.Now()
+	err := c.call(ctx, action, req, resp)
+	c.cfg.Observer.CallCompleted(c.cfg.Identity, action, time.Since(sent), err)
+	return err
+}
+
+// call sends a CALL once the charger holds the call slot and waits for its reply
+func (c *Charger) call(ctx context.Context, action string, req, resp any) error {
+	c.connMu.Lock()
+	ws, closed := c.ws, c.closed
+	c.connMu.Unlock()
+	if ws == nil {
+		return ErrNotConnected
+	}
+
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	msg := ocpp.OCPPMessage{
+		MessageTypeID: ocpp.Call,
+		UniqueID:      uuid.NewString(),
+		Action:        action,
+		Payload:       payload,
+	}
+	replyCh := make(chan *ocpp.OCPPMessage, 1)
+	c.pendingMu.Lock()
+	c.pending[msg.UniqueID] = replyCh
+	c.pendingMu.Unlock()
+	defer func() {
+		c.pendingMu.Lock()
+		delete(c.pending, msg.UniqueID)
+		c.pendingMu.Unlock()
+	}()
+
+	if err := c.write(ws, msg); err != nil {
+		return fmt.Errorf("failed to send %s: %w", action, err)
+	}
+
+	select {
+	case reply := <-replyCh:
+		if reply.MessageTypeID == ocpp.CallError {
+			return &ocpp.CallErrorResponse{Code: reply.ErrorCode, Description: reply.ErrorMessage, Details: reply.ErrorDetails}
+		}
+		if resp == nil {
+			return nil
+		}
+		if err := json.Unmarshal(reply.Payload, resp); err != nil {
+			return fmt.Errorf("invalid %s response: %w", action, err)
+		}
+		return nil
+	case <-closed:
+		return ErrNotConnected
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Boot sends BootNotification until the CSMS accepts the charger, waiting the
+// interval it asks for in between, then starts sending heartbeats
+func (c *Charger) Boot(ctx context.Context) error {
+	for {
+		var resp ocpp.BootNotificationResponse
+		err := c.Call(ctx, "BootNotification", ocpp.BootNotificationRequest{
+			ChargePointVendor:       c.cfg.Vendor,
+			ChargePointModel:        c.cfg.Model,
+			ChargePointSerialNumber: c.cfg.SerialNumber,
+			FirmwareVersion:         c.cfg.FirmwareVersion,
+		}, &resp)
+		if err != nil {
+			return err
+		}
+
+		interval := time.Duration(resp.Interval) * time.Second
+		switch resp.Status {
+		case "Accepted":
+			c.log.Infof("Boot accepted, heartbeat interval %s", interval)
+			c.startHeartbeat(interval)
+			return nil
+		case "Rejected":
+			if interval <= 0 {
+				return ErrBootNotAllowed
+			}
+		}
+		if interval <= 0 {
+			interval = 10 * time.Second
+		}
+		c.log.Infof("Boot %s, retrying in %s", strings.ToLower(resp.Status), interval)
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Heartbeat sends a Heartbeat
+func (c *Charger) Heartbeat(ctx context.Context) error {
+	return c.Call(ctx, "Heartbeat", ocpp.HeartbeatRequest{}, &ocpp.HeartbeatResponse{})
+}
+
+// StatusNotification reports the status of a connector, 0 being the charger itself
+func (c *Charger) StatusNotification(ctx context.Context, connector int, status, errorCode string) error {
+	if errorCode == "" {
+		errorCode = "NoError"
+	}
+	now := time.Now().UTC()
+	return c.Call(ctx, "StatusNotification", ocpp.StatusNotificationRequest{
+		ConnectorID: connector,
+		Status:      status,
+		ErrorCode:   errorCode,
+		Timestamp:   &now,
+	}, &ocpp.StatusNotificationResponse{})
+}
+
+// Authorize asks the CSMS whether an id tag may charge and returns the IdTagInfo status
+func (c *Charger) Authorize(ctx context.Context, idTag string) (string, error) {
+	var resp ocpp.AuthorizeResponse
+	if err := c.Call(ctx, "Authorize", ocpp.AuthorizeRequest{IdTag: idTag}, &resp); err != nil {
+		return "", err
+	}
+	return resp.IdTagInfo.Status, nil
+}
+
+// read receives the frames of the CSMS until the WebSocket closes
+func (c *Charger) read(ws *websocket.Conn, closed chan struct{}) {
+	defer close(closed)
+	for {
+		_, data, err := ws.ReadMessage()
+		if err != nil {
+			c.connMu.Lock()
+			current := c.ws == ws
+			if current {
+				c.ws = nil
+			}
+			c.connMu.Unlock()
+			if current {
+				c.stopHeartbeat()
+				c.log.WithError(err).Warn("Disconnected")
+				c.cfg.Observer.Disconnected(c.cfg.Identity, err)
+			} else {
+				c.cfg.Observer.Disconnected(c.cfg.Identity, nil)
+			}
+			return
+		}
+
+		var msg ocpp.OCPPMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			c.log.WithError(err).Warn("Dropping invalid frame")
+			continue
+		}
+		if msg.MessageTypeID == ocpp.Call {
+			go c.answer(ws, &msg)
+			continue
+		}
+		c.pendingMu.Lock()
+		ch, ok := c.pending[msg.UniqueID]
+		c.pendingMu.Unlock()
+		if ok {
+			ch <- &msg
+		}
+	}
+}
+
+// answer handles a CALL of the CSMS and writes the reply
+func (c *Charger) answer(ws *websocket.Conn, msg *ocpp.OCPPMessage) {
+	c.handlersMu.RLock()
+	handler, ok := c.handlers[msg.Action]
+	c.handlersMu.RUnlock()
+
+	reply := ocpp.OCPPMessage{MessageTypeID: ocpp.CallResult, UniqueID: msg.UniqueID}
+	ctx, deferred := withAfterReply(context.Background())
+	if !ok {
+		reply = callError(msg.UniqueID, ocpp.ErrorCodeNotImplemented, fmt.Sprintf("Action %s is not implemented by the simulator", msg.Action))
+	} else if resp, err := handler(ctx, msg.Payload); err != nil {
+		var callErr *ocpp.CallErrorResponse
+		if errors.As(err, &callErr) {
+			reply = callError(msg.UniqueID, callErr.Code, callErr.Description)
+		} else {
+			reply = callError(msg.UniqueID, ocpp.ErrorCodeInternalError, err.Error())
+		}
+	} else if reply.Payload, err = json.Marshal(resp); err != nil {
+		reply = callError(msg.UniqueID, ocpp.ErrorCodeInternalError, err.Error())
+	}
+
+	c.log.Infof("Answering %s", msg.Action)
+	if err := c.write(ws, reply); err != nil {
+		c.log.WithError(err).Warnf("Failed to answer %s", msg.Action)
+		return
+	}
+	for _, fn := range *deferred {
+		go fn()
+	}
+}
+
+func (c *Charger) write(ws *websocket.Conn, msg ocpp.OCPPMessage) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return ws.WriteMessage(websocket.TextMessage, data)
+}
+
+// startHeartbeat sends heartbeats at the interval, unless overridden by the configuration
+func (c *Charger) startHeartbeat(interval time.Duration) {
+	if c.cfg.HeartbeatInterval > 0 {
+		interval = c.cfg.HeartbeatInterval
+	}
+	c.stopHeartbeat()
+	if interval <= 0 {
+		return
+	}
+	stop := make(chan struct{})
+	c.mu.Lock()
+	c.heartbeatStop = stop
+	c.configuration["HeartbeatInterval"] = fmt.Sprint(int(interval.Seconds()))
+	c.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := c.Heartbeat(context.Background()); err != nil {
+					c.log.WithError(err).Warn("Heartbeat failed")
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func (c *Charger) stopHeartbeat() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.heartbeatStop != nil {
+		close(c.heartbeatStop)
+		c.heartbeatStop = nil
+	}
+}
+
+func callError(uniqueID, code, description string) ocpp.OCPPMessage {
+	return ocpp.OCPPMessage{
+		MessageTypeID: ocpp.CallError,
+		UniqueID:      uniqueID,
+		ErrorCode:     code,
+		ErrorMessage:  description,
+	}
+}
+
+type afterReplyKey struct{}
+
+// withAfterReply returns a context that collects work to run once the reply
+// to the CALL being answered has been written
+func withAfterReply(ctx context.Context) (context.Context, *[]func()) {
+	fns := &[]func(){}
+	return context.WithValue(ctx, afterReplyKey{}, fns), fns
+}
+
+// afterReply schedules fn to run in its own goroutine after the reply is written,
+// e.g. the charging session a RemoteStartTransaction asks for
+func afterReply(ctx context.Context, fn func()) {
+	if fns, ok := ctx.Value(afterReplyKey{}).(*[]func()); ok {
+		*fns = append(*fns, fn)
+		return
+	}
+	go fn()
+}
@@ -0,0 +1,335 @@
+package simulator
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/mutoulbj/gocsms/internal/ocpp"
+)
+
+// registerDefaultHandlers answers the CSMS-initiated calls of OCPP 1.6 Core
+// the way a well-behaved charge point does
+func (c *Charger) registerDefaultHandlers() {
+	c.Handle("RemoteStartTransaction", c.handleRemoteStartTransaction)
+	c.Handle("RemoteStopTransaction", c.handleRemoteStopTransaction)
+	c.Handle("Reset", c.handleReset)
+	c.Handle("UnlockConnector", c.handleUnlockConnector)
+	c.Handle("GetConfiguration", c.handleGetConfiguration)
+	c.Handle("ChangeConfiguration", c.handleChangeConfiguration)
+	c.Handle("TriggerMessage", c.handleTriggerMessage)
+	c.Handle("ChangeAvailability", c.handleChangeAvailability)
+	c.Handle("ReserveNow", c.handleReserveNow)
+	c.Handle("CancelReservation", c.handleCancelReservation)
+}
+
+func (c *Charger) handleRemoteStartTransaction(ctx context.Context, payload json.RawMessage) (any, error) {
+	var req ocpp.RemoteStartTransactionRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, err
+	}
+
+	connector := c.freeConnector()
+	if req.ConnectorID != nil {
+		connector = *req.ConnectorID
+		c.mu.Lock()
+		_, busy := c.sessions[connector]
+		c.mu.Unlock()
+		if busy || connector < 1 || connector > c.cfg.Connectors {
+			connector = 0
+		}
+	}
+	if connector == 0 {
+		return ocpp.RemoteStartTransactionResponse{Status: "Rejected"}, nil
+	}
+
+	s := c.cfg.RemoteSession
+	s.Connector, s.IdTag = connector, req.IdTag
+	afterReply(ctx, func() {
+		if err := c.Charge(context.Background(), s); err != nil {
+			c.log.WithError(err).Warnf("Remote started session on connector %d failed", connector)
+		}
+	})
+	return ocpp.RemoteStartTransactionResponse{Status: "Accepted"}, nil
+}
+
+func (c *Charger) handleRemoteStopTransaction(ctx context.Context, payload json.RawMessage) (any, error) {
+	var req ocpp.RemoteStopTransactionRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, err
+	}
+	if !c.stopSession(req.TransactionID, "Remote") {
+		return ocpp.RemoteStopTransactionResponse{Status: "Rejected"}, nil
+	}
+	return ocpp.RemoteStopTransactionResponse{Status: "Accepted"}, nil
+}
+
+// handleReset stops the running sessions, then reconnects and boots again
+func (c *Charger) handleReset(ctx context.Context, payload json.RawMessage) (any, error) {
+	var req ocpp.ResetRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, err
+	}
+	afterReply(ctx, func() {
+		c.stopSessions(req.Type + "Reset")
+		c.Close()
+		time.Sleep(time.Second)
+
+		ctx, cancel := context.WithTimeout(context.Background(), c.cfg.CallTimeout)
+		defer cancel()
+		if err := c.Connect(ctx); err != nil {
+			c.log.WithError(err).Error("Failed to reconnect after reset")
+			return
+		}
+		if err := c.Boot(ctx); err != nil {
+			c.log.WithError(err).Error("Failed to boot after reset")
+		}
+	})
+	return ocpp.ResetResponse{Status: "Accepted"}, nil
+}
+
+func (c *Charger) handleUnlockConnector(ctx context.Context, payload json.RawMessage) (any, error) {
+	var req ocpp.UnlockConnectorRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, err
+	}
+	if req.ConnectorID < 1 || req.ConnectorID > c.cfg.Connectors {
+		return ocpp.UnlockConnectorResponse{Status: "NotSupported"}, nil
+	}
+	c.mu.Lock()
+	if s, ok := c.sessions[req.ConnectorID]; ok {
+		s.signal("UnlockCommand")
+	}
+	c.mu.Unlock()
+	return ocpp.UnlockConnectorResponse{Status: "Unlocked"}, nil
+}
+
+func (c *Charger) handleGetConfiguration(ctx context.Context, payload json.RawMessage) (any, error) {
+	var req ocpp.GetConfigurationRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var resp ocpp.GetConfigurationResponse
+	keys := req.Key
+	if len(keys) == 0 {
+		for key := range c.configuration {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+	}
+	for _, key := range keys {
+		value, ok := c.configuration[key]
+		if !ok {
+			resp.UnknownKey = append(resp.UnknownKey, key)
+			continue
+		}
+		resp.ConfigurationKey = append(resp.ConfigurationKey, ocpp.KeyValue{
+			Key:      key,
+			Readonly: key == "NumberOfConnectors",
+			Value:    &value,
+		})
+	}
+	return resp, nil
+}
+
+func (c *Charger) handleChangeConfiguration(ctx context.Context, payload json.RawMessage) (any, error) {
+	var req ocpp.ChangeConfigurationRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	_, known := c.configuration[req.Key]
+	c.mu.Unlock()
+	switch {
+	case !known:
+		return ocpp.ChangeConfigurationResponse{Status: "NotSupported"}, nil
+	case req.Key == "NumberOfConnectors":
+		return ocpp.ChangeConfigurationResponse{Status: "Rejected"}, nil
+	case req.Key == "HeartbeatInterval":
+		seconds, err := strconv.Atoi(req.Value)
+		if err != nil || seconds < 0 {
+			return ocpp.ChangeConfigurationResponse{Status: "Rejected"}, nil
+		}
+		c.startHeartbeat(time.Duration(seconds) * time.Second)
+	}
+
+	c.mu.Lock()
+	c.configuration[req.Key] = req.Value
+	c.mu.Unlock()
+	return ocpp.ChangeConfigurationResponse{Status: "Accepted"}, nil
+}
+
+// handleTriggerMessage sends the requested message after the reply; connector
+// statuses are derived from the running sessions
+func (c *Charger) handleTriggerMessage(ctx context.Context, payload json.RawMessage) (any, error) {
+	var req ocpp.TriggerMessageRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, err
+	}
+	if req.ConnectorID != nil && (*req.ConnectorID < 1 || *req.ConnectorID > c.cfg.Connectors) {
+		return ocpp.TriggerMessageResponse{Status: "Rejected"}, nil
+	}
+
+	var send func(ctx context.Context) error
+	switch req.RequestedMessage {
+	case "BootNotification":
+		send = c.Boot
+	case "Heartbeat":
+		send = c.Heartbeat
+	case "StatusNotification":
+		send = func(ctx context.Context) error {
+			connectors := []int{0}
+			if req.ConnectorID != nil {
+				connectors = []int{*req.ConnectorID}
+			} else {
+				for connector := 1; connector <= c.cfg.Connectors; connector++ {
+					connectors = append(connectors, connector)
+				}
+			}
+			for _, connector := range connectors {
+				if err := c.StatusNotification(ctx, connector, c.connectorStatus(connector), ""); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	default:
+		return ocpp.TriggerMessageResponse{Status: "NotImplemented"}, nil
+	}
+
+	afterReply(ctx, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), c.cfg.CallTimeout)
+		defer cancel()
+		if err := send(ctx); err != nil {
+			c.log.WithError(err).Warnf("Failed to send triggered %s", req.RequestedMessage)
+		}
+	})
+	return ocpp.TriggerMessageResponse{Status: "Accepted"}, nil
+}
+
+// handleChangeAvailability reports the new status of the connectors; changes
+// affecting a running session are rejected as the simulator does not schedule them
+func (c *Charger) handleChangeAvailability(ctx context.Context, payload json.RawMessage) (any, error) {
+	var req ocpp.ChangeAvailabilityRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, err
+	}
+	if req.ConnectorID < 0 || req.ConnectorID > c.cfg.Connectors {
+		return ocpp.ChangeAvailabilityResponse{Status: "Rejected"}, nil
+	}
+
+	connectors := []int{req.ConnectorID}
+	if req.ConnectorID == 0 {
+		for connector := 1; connector <= c.cfg.Connectors; connector++ {
+			connectors = append(connectors, connector)
+		}
+	}
+	for _, connector := range connectors {
+		if c.connectorStatus(connector) == "Charging" {
+			return ocpp.ChangeAvailabilityResponse{Status: "Rejected"}, nil
+		}
+	}
+
+	c.mu.Lock()
+	c.inoperative[req.ConnectorID] = req.Type == "Inoperative"
+	c.mu.Unlock()
+	afterReply(ctx, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), c.cfg.CallTimeout)
+		defer cancel()
+		for _, connector := range connectors {
+			status := c.connectorStatus(connector)
+			if err := c.StatusNotification(ctx, connector, status, ""); err != nil {
+				c.log.WithError(err).Warnf("Failed to report connector %d %s", connector, status)
+				return
+			}
+		}
+	})
+	return ocpp.ChangeAvailabilityResponse{Status: "Accepted"}, nil
+}
+
+// handleReserveNow reserves a free connector and reports it Reserved; the
+// simulator does not support reserving connector 0
+func (c *Charger) handleReserveNow(ctx context.Context, payload json.RawMessage) (any, error) {
+	var req ocpp.ReserveNowRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, err
+	}
+	if req.ConnectorID < 1 || req.ConnectorID > c.cfg.Connectors {
+		return ocpp.ReserveNowResponse{Status: "Rejected"}, nil
+	}
+	switch c.connectorStatus(req.ConnectorID) {
+	case "Charging":
+		return ocpp.ReserveNowResponse{Status: "Occupied"}, nil
+	case "Unavailable":
+		return ocpp.ReserveNowResponse{Status: "Unavailable"}, nil
+	}
+
+	c.mu.Lock()
+	// a reservation with the same id is replaced
+	if r, ok := c.reservations[req.ConnectorID]; ok && r.id != req.ReservationID && time.Now().Before(r.expiry) {
+		c.mu.Unlock()
+		return ocpp.ReserveNowResponse{Status: "Occupied"}, nil
+	}
+	c.reservations[req.ConnectorID] = reservation{id: req.ReservationID, idTag: req.IdTag, expiry: req.ExpiryDate}
+	c.mu.Unlock()
+	c.reportStatus(ctx, req.ConnectorID)
+	return ocpp.ReserveNowResponse{Status: "Accepted"}, nil
+}
+
+// handleCancelReservation removes a reservation and reports its connector Available again
+func (c *Charger) handleCancelReservation(ctx context.Context, payload json.RawMessage) (any, error) {
+	var req ocpp.CancelReservationRequest
+	if err := json.Unmarshal(payload, &req); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	connector := 0
+	for id, r := range c.reservations {
+		if r.id == req.ReservationID {
+			connector = id
+			delete(c.reservations, id)
+		}
+	}
+	c.mu.Unlock()
+	if connector == 0 {
+		return ocpp.CancelReservationResponse{Status: "Rejected"}, nil
+	}
+	c.reportStatus(ctx, connector)
+	return ocpp.CancelReservationResponse{Status: "Accepted"}, nil
+}
+
+// reportStatus sends the status of a connector once the reply has been sent
+func (c *Charger) reportStatus(ctx context.Context, connector int) {
+	afterReply(ctx, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), c.cfg.CallTimeout)
+		defer cancel()
+		status := c.connectorStatus(connector)
+		if err := c.StatusNotification(ctx, connector, status, ""); err != nil {
+			c.log.WithError(err).Warnf("Failed to report connector %d %s", connector, status)
+		}
+	})
+}
+
+// connectorStatus returns Charging for the connectors with a running session,
+// Unavailable for those made inoperative, Reserved for those with an unexpired
+// reservation and Available for the others
+func (c *Charger) connectorStatus(connector int) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.sessions[connector]; ok && connector > 0 {
+		return "Charging"
+	}
+	if c.inoperative[connector] || c.inoperative[0] {
+		return "Unavailable"
+	}
+	if r, ok := c.reservations[connector]; ok && time.Now().Before(r.expiry) {
+		return "Reserved"
+	}
+	return "Available"
+}
@@ -0,0 +1,271 @@
+package simulator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is what a fleet of virtual chargers does, read from a YAML file:
+//
+//	url: ws://localhost:9000/ocpp
+//	chargers:
+//	  count: 10
+//	  prefix: SIM-
+//	  connectors: 2
+//	  rampUp: 10s
+//	remoteSession:
+//	  duration: 5m
+//	  meterInterval: 30s
+//	  power: 11000
+//	steps:
+//	  - action: boot
+//	  - action: status
+//	    status: Available
+//	  - action: session
+//	    connector: 1
+//	    idTag: TAG-001
+//	    duration: 2m
+//	    meterInterval: 15s
+//	    power: 7400
+//	  - action: wait
+//	    duration: 10m
+//
+// Every charger connects and runs the steps in order, Repeat times, while
+// answering the calls of the CSMS.
+type Scenario struct {
+	URL               string        `yaml:"url"`
+	Chargers          ChargerSpec   `yaml:"chargers"`
+	HeartbeatInterval time.Duration `yaml:"heartbeatInterval"` // overrides the interval of the CSMS
+	CallTimeout       time.Duration `yaml:"callTimeout"`
+	RemoteSession     SessionSpec   `yaml:"remoteSession"` // sessions started by RemoteStartTransaction
+	Repeat            int           `yaml:"repeat"`
+	Steps             []Step        `yaml:"steps"`
+	// Observer is given to the chargers of the scenario, none when nil
+	Observer Observer `yaml:"-"`
+}
+
+// ChargerSpec describes the virtual chargers of a scenario. Their identities
+// are the prefix followed by a three digit number counting from First.
+type ChargerSpec struct {
+	Count           int           `yaml:"count"`
+	Prefix          string        `yaml:"prefix"`
+	First           int           `yaml:"first"`
+	Connectors      int           `yaml:"connectors"`
+	Vendor          string        `yaml:"vendor"`
+	Model           string        `yaml:"model"`
+	FirmwareVersion string        `yaml:"firmwareVersion"`
+	Password        string        `yaml:"password"`
+	RampUp          time.Duration `yaml:"rampUp"` // connections are spread evenly over this period
+}
+
+// SessionSpec describes a charging session
+type SessionSpec struct {
+	Duration      time.Duration `yaml:"duration"`
+	MeterInterval time.Duration `yaml:"meterInterval"`
+	Power         int           `yaml:"power"` // W
+}
+
+// Step is one action of a scenario:
+//
+//	boot        BootNotification until accepted, then heartbeats
+//	heartbeat   a single Heartbeat
+//	status      StatusNotification of connector, or of the charger and all its connectors when omitted
+//	authorize   Authorize idTag
+//	session     a full charging session on connector with idTag
+//	wait        keep the connection open for duration, answering the CSMS
+//	disconnect  close the connection
+//	connect     open the connection again
+type Step struct {
+	Action    string `yaml:"action"`
+	Connector *int   `yaml:"connector"`
+	Status    string `yaml:"status"`
+	ErrorCode string `yaml:"errorCode"`
+	IdTag     string `yaml:"idTag"`
+	// Duration, MeterInterval and Power of a session; Duration also of a wait
+	SessionSpec `yaml:",inline"`
+}
+
+// LoadScenario reads a scenario from a YAML file and applies the defaults
+func LoadScenario(name string) (*Scenario, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	sc := &Scenario{}
+	if err := yaml.Unmarshal(data, sc); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", name, err)
+	}
+	if sc.URL == "" {
+		sc.URL = "ws://localhost:9000/ocpp"
+	}
+	if sc.Chargers.Count <= 0 {
+		sc.Chargers.Count = 1
+	}
+	if sc.Chargers.Prefix == "" {
+		sc.Chargers.Prefix = "SIM-"
+	}
+	if sc.Chargers.First <= 0 {
+		sc.Chargers.First = 1
+	}
+	if sc.Chargers.Vendor == "" {
+		sc.Chargers.Vendor = "gocsms"
+	}
+	if sc.Chargers.Model == "" {
+		sc.Chargers.Model = "Simulator"
+	}
+	if sc.RemoteSession.Duration <= 0 {
+		sc.RemoteSession.Duration = 5 * time.Minute
+	}
+	if sc.Repeat <= 0 {
+		sc.Repeat = 1
+	}
+	for i, step := range sc.Steps {
+		if !validActions[step.Action] {
+			return nil, fmt.Errorf("invalid scenario %s: unknown action %q in step %d", name, step.Action, i+1)
+		}
+	}
+	return sc, nil
+}
+
+var validActions = map[string]bool{
+	"boot": true, "heartbeat": true, "status": true, "authorize": true,
+	"session": true, "wait": true, "disconnect": true, "connect": true,
+}
+
+// NewChargers creates the virtual chargers of the scenario
+func (sc *Scenario) NewChargers(log *logrus.Logger) []*Charger {
+	chargers := make([]*Charger, 0, sc.Chargers.Count)
+	for i := range sc.Chargers.Count {
+		identity := fmt.Sprintf("%s%03d", sc.Chargers.Prefix, sc.Chargers.First+i)
+		chargers = append(chargers, NewCharger(Config{
+			URL:               sc.URL,
+			Identity:          identity,
+			Password:          sc.Chargers.Password,
+			Vendor:            sc.Chargers.Vendor,
+			Model:             sc.Chargers.Model,
+			SerialNumber:      identity,
+			FirmwareVersion:   sc.Chargers.FirmwareVersion,
+			Connectors:        sc.Chargers.Connectors,
+			HeartbeatInterval: sc.HeartbeatInterval,
+			RemoteSession: Session{
+				Duration:      sc.RemoteSession.Duration,
+				MeterInterval: sc.RemoteSession.MeterInterval,
+				Power:         sc.RemoteSession.Power,
+			},
+			CallTimeout: sc.CallTimeout,
+			Observer:    sc.Observer,
+		}, log))
+	}
+	return chargers
+}
+
+// Run connects the chargers, spread over the ramp-up period, and runs the
+// steps on every charger. It returns once all chargers are done, with the
+// errors of the chargers that failed.
+func (sc *Scenario) Run(ctx context.Context, chargers []*Charger) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	var delay time.Duration
+	if len(chargers) > 1 {
+		delay = sc.Chargers.RampUp / time.Duration(len(chargers)-1)
+	}
+	for i, charger := range chargers {
+		if i > 0 && delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer charger.Close()
+			if err := sc.RunCharger(ctx, charger); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", charger.Identity(), err))
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// RunCharger connects a charger and runs the steps on it
+func (sc *Scenario) RunCharger(ctx context.Context, charger *Charger) error {
+	if err := charger.Connect(ctx); err != nil {
+		return err
+	}
+	for range sc.Repeat {
+		for i, step := range sc.Steps {
+			if err := step.run(ctx, charger); err != nil {
+				return fmt.Errorf("step %d (%s): %w", i+1, step.Action, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Step) run(ctx context.Context, c *Charger) error {
+	switch s.Action {
+	case "boot":
+		return c.Boot(ctx)
+	case "heartbeat":
+		return c.Heartbeat(ctx)
+	case "status":
+		if s.Connector != nil {
+			return c.StatusNotification(ctx, *s.Connector, s.Status, s.ErrorCode)
+		}
+		for connector := 0; connector <= c.Connectors(); connector++ {
+			if err := c.StatusNotification(ctx, connector, s.Status, s.ErrorCode); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "authorize":
+		status, err := c.Authorize(ctx, s.IdTag)
+		if err != nil {
+			return err
+		}
+		c.log.Infof("Id tag %s is %s", s.IdTag, status)
+		return nil
+	case "session":
+		connector := 1
+		if s.Connector != nil {
+			connector = *s.Connector
+		}
+		return c.Charge(ctx, Session{
+			Connector:     connector,
+			IdTag:         s.IdTag,
+			Duration:      s.Duration,
+			MeterInterval: s.MeterInterval,
+			Power:         s.Power,
+		})
+	case "wait":
+		select {
+		case <-time.After(s.Duration):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	case "disconnect":
+		return c.Close()
+	case "connect":
+		return c.Connect(ctx)
+	default:
+		return fmt.Errorf("unknown action %q", s.Action)
+	}
+}
@@ -0,0 +1,225 @@
+package simulator
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mutoulbj/gocsms/internal/ocpp"
+)
+
+// Session describes a charging session
+type Session struct {
+	Connector     int
+	IdTag         string
+	Duration      time.Duration // how long the EV charges before the session stops locally
+	MeterInterval time.Duration // interval of the MeterValues sent while charging, none when zero
+	Power         int           // charging power in W
+}
+
+// session is a running charging session
+type session struct {
+	transactionID int
+	stop          chan string // receives the StopTransaction reason to end the session early
+}
+
+// reservation holds a connector for an id tag until it expires
+type reservation struct {
+	id     int
+	idTag  string
+	expiry time.Time
+}
+
+// Charge runs a full charging session on a connector: Preparing, StartTransaction,
+// Charging with periodic MeterValues, StopTransaction, Finishing and back to
+// Available. It blocks until the session ends, after Duration or earlier when
+// the CSMS stops it.
+func (c *Charger) Charge(ctx context.Context, s Session) error {
+	running := &session{stop: make(chan string, 1)}
+	c.mu.Lock()
+	if _, busy := c.sessions[s.Connector]; busy {
+		c.mu.Unlock()
+		return ErrConnectorBusy
+	}
+	// a session of another id tag leaves the reservation of the connector alone
+	var reservationID *int
+	if r, ok := c.reservations[s.Connector]; ok && r.idTag == s.IdTag && time.Now().Before(r.expiry) {
+		reservationID = &r.id
+		delete(c.reservations, s.Connector)
+	}
+	c.sessions[s.Connector] = running
+	c.sessionsWG.Add(1)
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.sessions, s.Connector)
+		c.mu.Unlock()
+		c.sessionsWG.Done()
+	}()
+
+	if err := c.StatusNotification(ctx, s.Connector, "Preparing", ""); err != nil {
+		return err
+	}
+
+	var start ocpp.StartTransactionResponse
+	err := c.Call(ctx, "StartTransaction", ocpp.StartTransactionRequest{
+		ConnectorID:   s.Connector,
+		IdTag:         s.IdTag,
+		MeterStart:    c.meter(s.Connector, 0),
+		ReservationID: reservationID,
+		Timestamp:     time.Now().UTC(),
+	}, &start)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	running.transactionID = start.TransactionID
+	c.mu.Unlock()
+	if start.IdTagInfo.Status != "Accepted" {
+		c.log.Warnf("Id tag %s was %s on connector %d", s.IdTag, start.IdTagInfo.Status, s.Connector)
+		if err := c.StatusNotification(ctx, s.Connector, "Finishing", ""); err != nil {
+			return err
+		}
+		if err := c.StatusNotification(ctx, s.Connector, "Available", ""); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %s", ErrNotAuthorized, start.IdTagInfo.Status)
+	}
+	c.log.Infof("Started transaction %d on connector %d", start.TransactionID, s.Connector)
+
+	if err := c.StatusNotification(ctx, s.Connector, "Charging", ""); err != nil {
+		return err
+	}
+
+	reason := c.deliver(ctx, s, running)
+
+	err = c.Call(ctx, "StopTransaction", ocpp.StopTransactionRequest{
+		IdTag:         s.IdTag,
+		MeterStop:     c.meter(s.Connector, 0),
+		Timestamp:     time.Now().UTC(),
+		TransactionID: start.TransactionID,
+		Reason:        reason,
+	}, &ocpp.StopTransactionResponse{})
+	if err != nil {
+		return err
+	}
+	c.log.Infof("Stopped transaction %d on connector %d: %s", start.TransactionID, s.Connector, reason)
+
+	if err := c.StatusNotification(ctx, s.Connector, "Finishing", ""); err != nil {
+		return err
+	}
+	return c.StatusNotification(ctx, s.Connector, "Available", "")
+}
+
+// deliver charges until the session duration has passed or the session is
+// stopped, sending MeterValues on the way, and returns the stop reason
+func (c *Charger) deliver(ctx context.Context, s Session, running *session) string {
+	done := time.NewTimer(s.Duration)
+	defer done.Stop()
+	var tick <-chan time.Time
+	if s.MeterInterval > 0 {
+		ticker := time.NewTicker(s.MeterInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	last := time.Now()
+	sample := func() {
+		now := time.Now()
+		c.meter(s.Connector, int(float64(s.Power)*now.Sub(last).Hours()))
+		last = now
+	}
+	for {
+		select {
+		case <-tick:
+			sample()
+			if err := c.meterValues(ctx, s, running.transactionID); err != nil {
+				c.log.WithError(err).Warn("MeterValues failed")
+			}
+		case <-done.C:
+			sample()
+			return "Local"
+		case reason := <-running.stop:
+			sample()
+			return reason
+		case <-ctx.Done():
+			sample()
+			return "Other"
+		}
+	}
+}
+
+func (c *Charger) meterValues(ctx context.Context, s Session, transactionID int) error {
+	return c.Call(ctx, "MeterValues", ocpp.MeterValuesRequest{
+		ConnectorID:   s.Connector,
+		TransactionID: &transactionID,
+		MeterValue: []ocpp.MeterValue{{
+			Timestamp: time.Now().UTC(),
+			SampledValue: []ocpp.SampledValue{
+				{
+					Value:     strconv.Itoa(c.meter(s.Connector, 0)),
+					Context:   "Sample.Periodic",
+					Measurand: "Energy.Active.Import.Register",
+					Unit:      "Wh",
+				},
+				{
+					Value:     strconv.Itoa(s.Power),
+					Context:   "Sample.Periodic",
+					Measurand: "Power.Active.Import",
+					Unit:      "W",
+				},
+			},
+		}},
+	}, &ocpp.MeterValuesResponse{})
+}
+
+// meter adds energy to the register of a connector and returns its reading in Wh
+func (c *Charger) meter(connector, wh int) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.meters[connector] += wh
+	return c.meters[connector]
+}
+
+// stopSession ends the session with the transaction id, reporting whether one was running
+func (c *Charger) stopSession(transactionID int, reason string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, s := range c.sessions {
+		if s.transactionID == transactionID {
+			s.signal(reason)
+			return true
+		}
+	}
+	return false
+}
+
+// stopSessions ends all sessions and waits for them to finish
+func (c *Charger) stopSessions(reason string) {
+	c.mu.Lock()
+	for _, s := range c.sessions {
+		s.signal(reason)
+	}
+	c.mu.Unlock()
+	c.sessionsWG.Wait()
+}
+
+// freeConnector returns the first connector without a session, 0 when all are busy
+func (c *Charger) freeConnector() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for connector := 1; connector <= c.cfg.Connectors; connector++ {
+		if _, busy := c.sessions[connector]; !busy {
+			return connector
+		}
+	}
+	return 0
+}
+
+func (s *session) signal(reason string) {
+	select {
+	case s.stop <- reason:
+	default:
+	}
+}
@@ -0,0 +1,22 @@
+-- SQL migration
+CREATE TABLE transactions (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    transaction_id SERIAL NOT NULL UNIQUE,
+    charge_point_id UUID NOT NULL,
+    connector_id INTEGER NOT NULL,
+    id_tag VARCHAR(20) NOT NULL,
+    user_id UUID,
+    start_time TIMESTAMPTZ NOT NULL,
+    stop_time TIMESTAMPTZ,
+    meter_start DOUBLE PRECISION NOT NULL,
+    meter_stop DOUBLE PRECISION,
+    total_energy_kwh DOUBLE PRECISION NOT NULL DEFAULT 0,
+    stop_reason VARCHAR(30),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Add indexes for performance
+CREATE INDEX idx_transactions_charge_point_id ON transactions(charge_point_id);
+CREATE INDEX idx_transactions_id_tag ON transactions(id_tag);
+CREATE INDEX idx_transactions_active ON transactions(charge_point_id, connector_id) WHERE stop_time IS NULL;
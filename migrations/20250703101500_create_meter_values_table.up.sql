@@ -0,0 +1,21 @@
+-- SQL migration
+CREATE TABLE meter_values (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    charge_point_id UUID NOT NULL,
+    connector_id INTEGER NOT NULL,
+    transaction_id INTEGER,
+    timestamp TIMESTAMPTZ NOT NULL,
+    measurand VARCHAR(50) NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    raw_value TEXT,
+    unit VARCHAR(20) NOT NULL DEFAULT 'Wh',
+    context VARCHAR(30),
+    format VARCHAR(20),
+    phase VARCHAR(10),
+    location VARCHAR(10),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Add indexes for performance
+CREATE INDEX idx_meter_values_connector_series ON meter_values(charge_point_id, connector_id, timestamp);
+CREATE INDEX idx_meter_values_transaction_series ON meter_values(transaction_id, timestamp) WHERE transaction_id IS NOT NULL;
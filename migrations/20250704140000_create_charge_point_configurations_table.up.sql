@@ -0,0 +1,15 @@
+-- SQL migration
+CREATE TABLE charge_point_configurations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    charge_point_id UUID NOT NULL,
+    key VARCHAR(50) NOT NULL,
+    value VARCHAR(500),
+    readonly BOOLEAN NOT NULL DEFAULT FALSE,
+    desired_value VARCHAR(500),
+    change_status VARCHAR(20),
+    changed_at TIMESTAMPTZ,
+    refreshed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (charge_point_id, key)
+);
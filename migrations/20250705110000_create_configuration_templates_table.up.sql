@@ -0,0 +1,11 @@
+-- SQL migration
+CREATE TABLE configuration_templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    vendor VARCHAR(20) NOT NULL,
+    model VARCHAR(20) NOT NULL DEFAULT '',
+    keys JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (vendor, model)
+);
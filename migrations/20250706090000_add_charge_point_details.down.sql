@@ -0,0 +1,8 @@
+-- SQL migration
+DROP INDEX IF EXISTS idx_charge_points_vendor_model;
+ALTER TABLE charge_points
+    DROP COLUMN IF EXISTS model,
+    DROP COLUMN IF EXISTS vendor,
+    DROP COLUMN IF EXISTS connected,
+    DROP COLUMN IF EXISTS registered_at;
+ALTER TABLE charge_points RENAME COLUMN ocpp_version TO ocpp_protocol;
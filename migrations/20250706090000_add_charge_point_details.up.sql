@@ -0,0 +1,9 @@
+-- SQL migration
+ALTER TABLE charge_points RENAME COLUMN ocpp_protocol TO ocpp_version;
+ALTER TABLE charge_points
+    ADD COLUMN model VARCHAR(20),
+    ADD COLUMN vendor VARCHAR(20),
+    ADD COLUMN connected BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN registered_at TIMESTAMPTZ;
+
+CREATE INDEX idx_charge_points_vendor_model ON charge_points(vendor, model);
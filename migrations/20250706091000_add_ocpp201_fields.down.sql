@@ -0,0 +1,6 @@
+-- SQL migration
+ALTER TABLE charge_points
+    ALTER COLUMN vendor TYPE VARCHAR(20),
+    ALTER COLUMN model TYPE VARCHAR(20);
+DROP INDEX IF EXISTS idx_transactions_charger_transaction_id;
+ALTER TABLE transactions DROP COLUMN IF EXISTS charger_transaction_id;
@@ -0,0 +1,13 @@
+-- SQL migration
+-- OCPP 2.0.1 charging stations assign their own transaction ids
+ALTER TABLE transactions ADD COLUMN charger_transaction_id VARCHAR(36);
+CREATE UNIQUE INDEX idx_transactions_charger_transaction_id ON transactions(charge_point_id, charger_transaction_id);
+
+-- OCPP 2.0.1 device model variables are stored as <component>.<variable> keys
+ALTER TABLE charge_point_configurations ALTER COLUMN key TYPE VARCHAR(200);
+ALTER TABLE charge_point_configurations ALTER COLUMN value TYPE VARCHAR(2500);
+
+-- OCPP 2.0.1 vendor names are up to 50 characters
+ALTER TABLE charge_points
+    ALTER COLUMN vendor TYPE VARCHAR(50),
+    ALTER COLUMN model TYPE VARCHAR(50);
@@ -0,0 +1,3 @@
+-- SQL migration
+DROP INDEX IF EXISTS idx_charge_points_registration_status;
+ALTER TABLE charge_points ALTER COLUMN charge_station_id SET NOT NULL;
@@ -0,0 +1,4 @@
+-- SQL migration
+-- charge points registered by their first BootNotification are not assigned to a station yet
+ALTER TABLE charge_points ALTER COLUMN charge_station_id DROP NOT NULL;
+CREATE INDEX idx_charge_points_registration_status ON charge_points(registration_status);
@@ -0,0 +1,4 @@
+-- SQL migration
+ALTER TABLE charge_points
+    DROP COLUMN IF EXISTS security_profile,
+    DROP COLUMN IF EXISTS password_hash;
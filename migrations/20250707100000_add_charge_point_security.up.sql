@@ -0,0 +1,4 @@
+-- SQL migration
+ALTER TABLE charge_points
+    ADD COLUMN security_profile SMALLINT NOT NULL DEFAULT 0,
+    ADD COLUMN password_hash VARCHAR(255);
@@ -0,0 +1,16 @@
+-- SQL migration
+CREATE TABLE security_events (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    charge_point_id UUID NOT NULL,
+    type VARCHAR(50) NOT NULL,
+    severity VARCHAR(10) NOT NULL,
+    timestamp TIMESTAMPTZ NOT NULL,
+    tech_info VARCHAR(255),
+    alert BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Add indexes for performance
+CREATE INDEX idx_security_events_charge_point ON security_events(charge_point_id, timestamp);
+CREATE INDEX idx_security_events_timestamp ON security_events(timestamp);
+CREATE INDEX idx_security_events_alert ON security_events(timestamp) WHERE alert;
@@ -0,0 +1,3 @@
+-- SQL migration
+DROP INDEX IF EXISTS idx_charge_points_last_heartbeat;
+DROP TABLE IF EXISTS charge_point_connections CASCADE;
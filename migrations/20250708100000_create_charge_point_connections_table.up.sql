@@ -0,0 +1,16 @@
+-- SQL migration
+CREATE TABLE charge_point_connections (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    charge_point_id UUID NOT NULL,
+    connected_at TIMESTAMPTZ NOT NULL,
+    disconnected_at TIMESTAMPTZ,
+    remote_address VARCHAR(255) NOT NULL,
+    close_code INTEGER,
+    close_reason VARCHAR(255)
+);
+
+-- Add indexes for performance
+CREATE INDEX idx_charge_point_connections_charge_point ON charge_point_connections(charge_point_id, connected_at);
+CREATE INDEX idx_charge_point_connections_open ON charge_point_connections(charge_point_id) WHERE disconnected_at IS NULL;
+-- the offline watchdog looks for charge points whose heartbeat lapsed
+CREATE INDEX idx_charge_points_last_heartbeat ON charge_points(last_heartbeat);
@@ -0,0 +1,19 @@
+-- SQL migration
+DROP TABLE IF EXISTS connector_status_history CASCADE;
+
+ALTER TABLE connectors
+    DROP COLUMN status,
+    DROP COLUMN error_code,
+    DROP COLUMN info,
+    DROP COLUMN vendor_id,
+    DROP COLUMN vendor_error_code,
+    DROP COLUMN status_updated_at;
+DELETE FROM connectors WHERE standard IS NULL OR format IS NULL OR power_type IS NULL
+    OR max_voltage IS NULL OR max_amperage IS NULL OR max_power IS NULL;
+ALTER TABLE connectors
+    ALTER COLUMN standard SET NOT NULL,
+    ALTER COLUMN format SET NOT NULL,
+    ALTER COLUMN power_type SET NOT NULL,
+    ALTER COLUMN max_voltage SET NOT NULL,
+    ALTER COLUMN max_amperage SET NOT NULL,
+    ALTER COLUMN max_power SET NOT NULL;
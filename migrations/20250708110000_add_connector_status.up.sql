@@ -0,0 +1,32 @@
+-- SQL migration
+-- connectors are created by their first StatusNotification, before their specs are known
+ALTER TABLE connectors
+    ALTER COLUMN standard DROP NOT NULL,
+    ALTER COLUMN format DROP NOT NULL,
+    ALTER COLUMN power_type DROP NOT NULL,
+    ALTER COLUMN max_voltage DROP NOT NULL,
+    ALTER COLUMN max_amperage DROP NOT NULL,
+    ALTER COLUMN max_power DROP NOT NULL;
+ALTER TABLE connectors
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'UNKNOWN',
+    ADD COLUMN error_code VARCHAR(50),
+    ADD COLUMN info VARCHAR(50),
+    ADD COLUMN vendor_id VARCHAR(255),
+    ADD COLUMN vendor_error_code VARCHAR(50),
+    ADD COLUMN status_updated_at TIMESTAMPTZ;
+
+CREATE TABLE connector_status_history (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    charge_point_id UUID NOT NULL,
+    connector_id INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    error_code VARCHAR(50),
+    info VARCHAR(50),
+    vendor_id VARCHAR(255),
+    vendor_error_code VARCHAR(50),
+    timestamp TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Add indexes for performance
+CREATE INDEX idx_connector_status_history_series ON connector_status_history(charge_point_id, connector_id, timestamp);
@@ -0,0 +1,2 @@
+-- SQL migration
+DROP TABLE IF EXISTS ocpp_messages CASCADE;
@@ -0,0 +1,18 @@
+-- SQL migration
+CREATE TABLE ocpp_messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    identity VARCHAR(255) NOT NULL,
+    direction VARCHAR(10) NOT NULL,
+    message_type SMALLINT NOT NULL,
+    unique_id VARCHAR(64),
+    action VARCHAR(50),
+    payload TEXT NOT NULL,
+    latency_ms BIGINT,
+    error VARCHAR(512),
+    timestamp TIMESTAMPTZ NOT NULL
+);
+
+-- Add indexes for performance
+CREATE INDEX idx_ocpp_messages_identity ON ocpp_messages(identity, timestamp);
+CREATE INDEX idx_ocpp_messages_unique_id ON ocpp_messages(identity, unique_id);
+CREATE INDEX idx_ocpp_messages_timestamp ON ocpp_messages(timestamp);
@@ -0,0 +1,5 @@
+-- SQL migration
+DROP TABLE IF EXISTS firmware_campaign_targets CASCADE;
+DROP TABLE IF EXISTS firmware_campaigns CASCADE;
+DROP TABLE IF EXISTS firmware_artifacts CASCADE;
+ALTER TABLE charge_points DROP COLUMN IF EXISTS firmware_version;
@@ -0,0 +1,54 @@
+-- SQL migration
+ALTER TABLE charge_points ADD COLUMN firmware_version VARCHAR(50);
+
+CREATE TABLE firmware_artifacts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    version VARCHAR(50) NOT NULL,
+    vendor VARCHAR(20) NOT NULL,
+    models JSONB NOT NULL DEFAULT '[]',
+    file_name VARCHAR(255) NOT NULL,
+    size BIGINT NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    signing_certificate TEXT,
+    signature TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (vendor, version)
+);
+
+CREATE TABLE firmware_campaigns (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    artifact_id UUID NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    wave_size INTEGER NOT NULL,
+    success_threshold DOUBLE PRECISION NOT NULL,
+    current_wave INTEGER NOT NULL DEFAULT 0,
+    waves INTEGER NOT NULL,
+    retries INTEGER,
+    retry_interval INTEGER,
+    status_reason VARCHAR(255),
+    wave_started_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE firmware_campaign_targets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    campaign_id UUID NOT NULL,
+    charge_point_id UUID NOT NULL,
+    wave INTEGER NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    firmware_status VARCHAR(30),
+    request_id INTEGER,
+    error VARCHAR(512),
+    sent_at TIMESTAMPTZ,
+    completed_at TIMESTAMPTZ,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (campaign_id, charge_point_id)
+);
+
+-- Add indexes for performance
+CREATE INDEX idx_firmware_campaigns_status ON firmware_campaigns(status);
+CREATE INDEX idx_firmware_campaign_targets_charge_point ON firmware_campaign_targets(charge_point_id, status);
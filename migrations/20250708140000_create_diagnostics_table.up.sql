@@ -0,0 +1,21 @@
+-- SQL migration
+CREATE TABLE diagnostics (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    charge_point_id UUID NOT NULL,
+    log_type VARCHAR(20) NOT NULL,
+    request_id INTEGER,
+    status VARCHAR(20) NOT NULL,
+    upload_status VARCHAR(30),
+    start_time TIMESTAMPTZ,
+    stop_time TIMESTAMPTZ,
+    file_name VARCHAR(255),
+    size BIGINT,
+    error VARCHAR(512),
+    uploaded_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Add indexes for performance
+CREATE INDEX idx_diagnostics_charge_point ON diagnostics(charge_point_id, created_at);
+CREATE INDEX idx_diagnostics_created_at ON diagnostics(created_at);
@@ -0,0 +1,9 @@
+-- SQL migration
+DROP TABLE IF EXISTS maintenance_window_targets CASCADE;
+DROP TABLE IF EXISTS maintenance_windows CASCADE;
+ALTER TABLE connectors
+    DROP COLUMN IF EXISTS availability,
+    DROP COLUMN IF EXISTS availability_scheduled;
+ALTER TABLE charge_points
+    DROP COLUMN IF EXISTS availability,
+    DROP COLUMN IF EXISTS availability_scheduled;
@@ -0,0 +1,33 @@
+-- SQL migration
+ALTER TABLE charge_points
+    ADD COLUMN availability VARCHAR(20) NOT NULL DEFAULT 'OPERATIVE',
+    ADD COLUMN availability_scheduled BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE connectors
+    ADD COLUMN availability VARCHAR(20) NOT NULL DEFAULT 'OPERATIVE',
+    ADD COLUMN availability_scheduled BOOLEAN NOT NULL DEFAULT false;
+
+CREATE TABLE maintenance_windows (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(100) NOT NULL,
+    reason VARCHAR(255),
+    status VARCHAR(20) NOT NULL,
+    starts_at TIMESTAMPTZ NOT NULL,
+    ends_at TIMESTAMPTZ NOT NULL,
+    completed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE maintenance_window_targets (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    window_id UUID NOT NULL,
+    charge_point_id UUID NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    error VARCHAR(512),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (window_id, charge_point_id)
+);
+
+-- Add indexes for performance
+CREATE INDEX idx_maintenance_windows_status ON maintenance_windows(status, starts_at);
+CREATE INDEX idx_maintenance_window_targets_charge_point ON maintenance_window_targets(charge_point_id);
@@ -0,0 +1,21 @@
+-- SQL migration
+CREATE TABLE reservations (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    reservation_id SERIAL NOT NULL UNIQUE,
+    charge_point_id UUID NOT NULL,
+    connector_id INTEGER NOT NULL,
+    id_tag VARCHAR(36) NOT NULL,
+    parent_id_tag VARCHAR(36),
+    status VARCHAR(20) NOT NULL,
+    expires_at TIMESTAMPTZ NOT NULL,
+    transaction_id INTEGER,
+    error VARCHAR(512),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Add indexes for performance
+CREATE INDEX idx_reservations_charge_point ON reservations(charge_point_id, created_at);
+CREATE INDEX idx_reservations_open ON reservations(expires_at) WHERE status IN ('REQUESTED', 'ACTIVE');
+-- a connector is held by at most one open reservation
+CREATE UNIQUE INDEX idx_reservations_connector ON reservations(charge_point_id, connector_id) WHERE status IN ('REQUESTED', 'ACTIVE');
@@ -0,0 +1,2 @@
+-- SQL migration
+ALTER TABLE transactions ALTER COLUMN id_tag TYPE VARCHAR(20);
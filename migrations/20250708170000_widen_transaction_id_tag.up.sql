@@ -0,0 +1,3 @@
+-- SQL migration
+-- OCPP 2.0.1 id tokens are up to 36 characters
+ALTER TABLE transactions ALTER COLUMN id_tag TYPE VARCHAR(36);
@@ -0,0 +1,15 @@
+-- SQL migration
+-- the drift of each charge point from its template as last detected
+CREATE TABLE configuration_drifts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    charge_point_id UUID NOT NULL,
+    template_id UUID NOT NULL,
+    key VARCHAR(200) NOT NULL,
+    expected VARCHAR(2500) NOT NULL,
+    actual VARCHAR(2500),
+    missing BOOLEAN NOT NULL DEFAULT FALSE,
+    detected_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (charge_point_id, key)
+);
+
+CREATE INDEX idx_configuration_drifts_template ON configuration_drifts(template_id);